@@ -4,6 +4,7 @@ import (
 	"context"
 	"enuma-elish/config"
 	"enuma-elish/infra"
+	"enuma-elish/internal/admin"
 	"enuma-elish/internal/auth"
 	"enuma-elish/internal/class"
 	"enuma-elish/internal/exam"
@@ -63,6 +64,7 @@ func New(c *config.Config, infra *infra.Infra) *API {
 	question.New(api.config, api.infra, api.Engine, validate).Init()
 	ppdb.New(api.config, api.infra, api.Engine, validate).Init()
 	storage.New(api.config, api.infra, api.Engine, validate).Init()
+	admin.New(api.config, api.infra, api.Engine, validate).Init()
 
 	return api
 }
@@ -80,6 +82,12 @@ func (api *API) Run() {
 		}()
 	}
 
+	if api.config.JWT.RotationInterval > 0 {
+		go api.rotateKeys()
+	}
+
+	go api.infra.Scheduler.Run(context.Background())
+
 	s := &http.Server{
 		Addr:         fmt.Sprintf("%s:%s", api.config.Http.Host, api.config.Http.Port),
 		Handler:      api,
@@ -94,6 +102,24 @@ func (api *API) Run() {
 	}
 }
 
+// rotateKeys checks the signing key every hour and replaces it once it is
+// older than jwt.rotation_interval.
+func (api *API) rotateKeys() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		key, err := api.infra.Keys.RotateIfDue(context.Background())
+		if err != nil {
+			log.Error().Err(err).Msg("failed to rotate jwt signing key")
+		} else if key != nil {
+			log.Info().Str("kid", key.ID).Msg("jwt signing key rotated")
+		}
+
+		<-ticker.C
+	}
+}
+
 func (api *API) Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "api",
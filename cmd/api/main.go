@@ -4,6 +4,7 @@ import (
 	"enuma-elish/api"
 	"enuma-elish/config"
 	"enuma-elish/infra"
+	"enuma-elish/worker"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -26,6 +27,8 @@ func main() {
 
 	a := api.New(c, i)
 	cmd.AddCommand(a.Command())
+	cmd.AddCommand(worker.New(c, i).Command())
+	cmd.AddCommand(i.Keys.Command())
 
 	if err := cmd.Execute(); err != nil {
 		log.Err(err).Msg("command execution failed")
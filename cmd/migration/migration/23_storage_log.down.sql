@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_storage_log_school_id;
+
+ALTER TABLE storage_log
+DROP COLUMN IF EXISTS school_id;
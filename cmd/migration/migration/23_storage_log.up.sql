@@ -0,0 +1,32 @@
+-- The storage service has always written to storage_log; create it for
+-- databases that only ran 22_storage and scope uploads to a school.
+CREATE TABLE IF NOT EXISTS storage_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    public_id VARCHAR(255) NOT NULL,
+    original_filename VARCHAR(255) NOT NULL,
+    file_type VARCHAR(50) NOT NULL,
+    file_size BIGINT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    url TEXT NOT NULL,
+    secure_url TEXT NOT NULL,
+    folder VARCHAR(255),
+    width INTEGER,
+    height INTEGER,
+    format VARCHAR(50),
+    created_at BIGINT NOT NULL DEFAULT (
+        EXTRACT(
+            EPOCH
+            FROM
+                now()
+        ) * 1000
+    ) :: BIGINT,
+    updated_at BIGINT NOT NULL DEFAULT 0
+);
+
+ALTER TABLE storage_log
+ADD COLUMN IF NOT EXISTS school_id UUID REFERENCES school(id);
+
+CREATE INDEX IF NOT EXISTS idx_storage_log_public_id ON storage_log(public_id);
+CREATE INDEX IF NOT EXISTS idx_storage_log_user_id ON storage_log(user_id);
+CREATE INDEX IF NOT EXISTS idx_storage_log_school_id ON storage_log(school_id);
@@ -0,0 +1,23 @@
+DROP POLICY IF EXISTS tenant_isolation ON storage_log;
+ALTER TABLE storage_log NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE storage_log DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON ppdb;
+ALTER TABLE ppdb NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE ppdb DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON subject;
+ALTER TABLE subject NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE subject DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON class;
+ALTER TABLE class NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE class DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON question;
+ALTER TABLE question NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE question DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON exam;
+ALTER TABLE exam NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE exam DISABLE ROW LEVEL SECURITY;
@@ -0,0 +1,32 @@
+-- Row level security backing the tenant scoping done in the repositories.
+-- Transactions that call tenant.Apply set app.school_id; sessions that do
+-- not set it (migrations, seeders, platform admins) are left unrestricted.
+ALTER TABLE exam ENABLE ROW LEVEL SECURITY;
+ALTER TABLE exam FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON exam
+    USING (COALESCE(current_setting('app.school_id', true), '') = '' OR school_id = current_setting('app.school_id', true)::uuid);
+
+ALTER TABLE question ENABLE ROW LEVEL SECURITY;
+ALTER TABLE question FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON question
+    USING (COALESCE(current_setting('app.school_id', true), '') = '' OR school_id = current_setting('app.school_id', true)::uuid);
+
+ALTER TABLE class ENABLE ROW LEVEL SECURITY;
+ALTER TABLE class FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON class
+    USING (COALESCE(current_setting('app.school_id', true), '') = '' OR school_id = current_setting('app.school_id', true)::uuid);
+
+ALTER TABLE subject ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subject FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON subject
+    USING (COALESCE(current_setting('app.school_id', true), '') = '' OR school_id = current_setting('app.school_id', true)::uuid);
+
+ALTER TABLE ppdb ENABLE ROW LEVEL SECURITY;
+ALTER TABLE ppdb FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON ppdb
+    USING (COALESCE(current_setting('app.school_id', true), '') = '' OR school_id = current_setting('app.school_id', true)::uuid);
+
+ALTER TABLE storage_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE storage_log FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON storage_log
+    USING (COALESCE(current_setting('app.school_id', true), '') = '' OR school_id IS NULL OR school_id = current_setting('app.school_id', true)::uuid);
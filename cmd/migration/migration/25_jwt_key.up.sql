@@ -0,0 +1,13 @@
+-- Signing keys for access and refresh tokens. The private key is encrypted
+-- with the jwt.secret from config, see pkg/jwt/keyset.go.
+CREATE TABLE IF NOT EXISTS jwt_key (
+    id VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    retired_at BIGINT,
+    expires_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_jwt_key_created_at ON jwt_key(created_at);
@@ -0,0 +1,4 @@
+ALTER TABLE school DROP COLUMN IF EXISTS mfa_required;
+
+DROP TABLE IF EXISTS user_mfa_recovery_code;
+DROP TABLE IF EXISTS user_mfa;
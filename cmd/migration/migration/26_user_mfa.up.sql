@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    enabled_at BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL DEFAULT (
+        EXTRACT(
+            EPOCH
+            FROM
+                now()
+        ) * 1000
+    ) :: BIGINT,
+    updated_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS user_mfa_recovery_code (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(255) NOT NULL,
+    used_at BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL DEFAULT (
+        EXTRACT(
+            EPOCH
+            FROM
+                now()
+        ) * 1000
+    ) :: BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_mfa_recovery_code_user_id ON user_mfa_recovery_code(user_id);
+
+ALTER TABLE school
+ADD COLUMN IF NOT EXISTS mfa_required BOOLEAN NOT NULL DEFAULT false;
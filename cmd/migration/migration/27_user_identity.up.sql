@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_identity (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR NOT NULL DEFAULT '',
+    last_login_at BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL DEFAULT (
+        EXTRACT(
+            EPOCH
+            FROM
+                now()
+        ) * 1000
+    ) :: BIGINT,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identity_user_id ON user_identity(user_id);
@@ -0,0 +1,25 @@
+-- API keys for integrations. user_id is the service account the key acts as;
+-- only the SHA-256 of the key is stored, prefix identifies it for lookups.
+CREATE TABLE IF NOT EXISTS api_key (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    school_id UUID NOT NULL REFERENCES school(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at BIGINT NOT NULL DEFAULT 0,
+    last_used_at BIGINT NOT NULL DEFAULT 0,
+    last_used_ip VARCHAR(64) NOT NULL DEFAULT '',
+    revoked_at BIGINT NOT NULL DEFAULT 0,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at BIGINT NOT NULL DEFAULT (
+        EXTRACT(
+            EPOCH
+            FROM
+                now()
+        ) * 1000
+    ) :: BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_key_school_id ON api_key(school_id);
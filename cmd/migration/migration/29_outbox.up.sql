@@ -0,0 +1,22 @@
+-- Domain events written in the same transaction as the change they describe
+-- and published to the event bus by the relay in the worker.
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    school_id UUID REFERENCES school(id) ON DELETE CASCADE,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    available_at BIGINT NOT NULL DEFAULT 0,
+    published_at BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL DEFAULT (
+        EXTRACT(
+            EPOCH
+            FROM
+                now()
+        ) * 1000
+    ) :: BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(available_at, created_at) WHERE published_at = 0;
+CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox(published_at) WHERE published_at > 0;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ppdb_open_end_at;
+
+ALTER TABLE ppdb DROP COLUMN IF EXISTS closed_at;
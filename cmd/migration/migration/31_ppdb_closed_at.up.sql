@@ -0,0 +1,5 @@
+-- Stamped by the ppdb.close scheduled job once a period has ended.
+ALTER TABLE ppdb
+ADD COLUMN IF NOT EXISTS closed_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_ppdb_open_end_at ON ppdb(end_at) WHERE closed_at = 0;
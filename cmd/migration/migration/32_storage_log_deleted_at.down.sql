@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_storage_log_deleted_at;
+
+ALTER TABLE storage_log DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+-- Deleted files stay on Cloudinary until the storage.purge scheduled job
+-- removes them, so an accidental delete can still be recovered.
+ALTER TABLE storage_log
+ADD COLUMN IF NOT EXISTS deleted_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_storage_log_deleted_at ON storage_log(deleted_at) WHERE deleted_at > 0;
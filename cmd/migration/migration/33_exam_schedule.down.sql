@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_exam_grade_open_deadline;
+
+ALTER TABLE exam_grade
+DROP COLUMN IF EXISTS submitted_at,
+DROP COLUMN IF EXISTS deadline_at,
+DROP COLUMN IF EXISTS started_at;
+
+ALTER TABLE exam
+DROP COLUMN IF EXISTS duration_minutes,
+DROP COLUMN IF EXISTS end_at,
+DROP COLUMN IF EXISTS start_at;
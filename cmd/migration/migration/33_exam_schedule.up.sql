@@ -0,0 +1,16 @@
+-- Availability window and time limit of an exam, 0 leaves it unrestricted.
+ALTER TABLE exam
+ADD COLUMN IF NOT EXISTS start_at BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS end_at BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS duration_minutes INTEGER NOT NULL DEFAULT 0;
+
+-- A student's attempt. deadline_at is fixed when the attempt starts, from
+-- the time limit and the end of the window, so later edits of the exam do
+-- not move it.
+ALTER TABLE exam_grade
+ADD COLUMN IF NOT EXISTS started_at BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS deadline_at BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS submitted_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_exam_grade_open_deadline ON exam_grade(deadline_at)
+WHERE submitted_at = 0 AND deadline_at > 0;
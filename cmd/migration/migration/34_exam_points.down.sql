@@ -0,0 +1,7 @@
+ALTER TABLE exam_grade
+DROP COLUMN IF EXISTS result,
+DROP COLUMN IF EXISTS max_score,
+DROP COLUMN IF EXISTS score;
+
+ALTER TABLE exam_question
+DROP COLUMN IF EXISTS points;
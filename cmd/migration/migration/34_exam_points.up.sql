@@ -0,0 +1,10 @@
+-- Points of a question in this exam, NULL falls back to question.points.
+ALTER TABLE exam_question
+ADD COLUMN IF NOT EXISTS points INTEGER NULL CHECK (points IS NULL OR points > 0);
+
+-- Points scored out of max_score, and the per question breakdown. The
+-- breakdown of a submission with unscored essays is stored without a grade.
+ALTER TABLE exam_grade
+ADD COLUMN IF NOT EXISTS score DECIMAL(10, 2) NULL,
+ADD COLUMN IF NOT EXISTS max_score DECIMAL(10, 2) NULL,
+ADD COLUMN IF NOT EXISTS result JSONB NULL;
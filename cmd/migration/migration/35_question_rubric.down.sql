@@ -0,0 +1,2 @@
+ALTER TABLE question
+DROP COLUMN IF EXISTS rubric;
@@ -0,0 +1,3 @@
+-- Rubric of an essay question: criteria, each with levels worth some points.
+ALTER TABLE question
+ADD COLUMN IF NOT EXISTS rubric JSONB NULL;
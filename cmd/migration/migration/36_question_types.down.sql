@@ -0,0 +1,9 @@
+ALTER TABLE question
+DROP COLUMN IF EXISTS answer_key;
+
+-- Fails while questions of the newer types exist
+ALTER TABLE question
+DROP CONSTRAINT IF EXISTS question_question_type_check;
+
+ALTER TABLE question
+ADD CONSTRAINT question_question_type_check CHECK (question_type IN ('multiple_choice', 'essay'));
@@ -0,0 +1,12 @@
+ALTER TABLE question
+DROP CONSTRAINT IF EXISTS question_question_type_check;
+
+ALTER TABLE question
+ADD CONSTRAINT question_question_type_check CHECK (question_type IN (
+    'multiple_choice', 'essay', 'true_false', 'multi_select', 'short_answer', 'numeric', 'matching', 'ordering'
+));
+
+-- Answer key of the auto-graded types other than multiple_choice, which
+-- keeps correct_answer.
+ALTER TABLE question
+ADD COLUMN IF NOT EXISTS answer_key JSONB NULL;
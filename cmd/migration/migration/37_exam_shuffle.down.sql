@@ -0,0 +1,6 @@
+ALTER TABLE exam_grade
+DROP COLUMN IF EXISTS seed;
+
+ALTER TABLE exam
+DROP COLUMN IF EXISTS shuffle_options,
+DROP COLUMN IF EXISTS shuffle_questions;
@@ -0,0 +1,7 @@
+ALTER TABLE exam
+ADD COLUMN IF NOT EXISTS shuffle_questions BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS shuffle_options BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Seed of the order a student sees the exam in, 0 until it is first needed.
+ALTER TABLE exam_grade
+ADD COLUMN IF NOT EXISTS seed BIGINT NOT NULL DEFAULT 0;
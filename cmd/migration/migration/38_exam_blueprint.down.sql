@@ -0,0 +1,7 @@
+ALTER TABLE exam_grade
+DROP COLUMN IF EXISTS question_ids;
+
+ALTER TABLE exam
+DROP COLUMN IF EXISTS draw_per_student;
+
+DROP TABLE IF EXISTS exam_blueprint;
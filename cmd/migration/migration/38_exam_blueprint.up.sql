@@ -0,0 +1,19 @@
+-- Rules drawing questions from the question bank into an exam.
+CREATE TABLE IF NOT EXISTS exam_blueprint (
+    id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    exam_id UUID NOT NULL REFERENCES exam (id),
+    subject_id UUID NOT NULL REFERENCES subject (id),
+    question_type VARCHAR(20) NOT NULL,
+    difficulty_level VARCHAR(20) NOT NULL CHECK (difficulty_level IN ('easy', 'medium', 'hard')),
+    count INTEGER NOT NULL CHECK (count > 0),
+    created_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_exam_blueprint_exam ON exam_blueprint(exam_id);
+
+ALTER TABLE exam
+ADD COLUMN IF NOT EXISTS draw_per_student BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Questions drawn for the attempt of exams drawn per student, NULL until drawn.
+ALTER TABLE exam_grade
+ADD COLUMN IF NOT EXISTS question_ids UUID[];
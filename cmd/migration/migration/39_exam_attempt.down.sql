@@ -0,0 +1,28 @@
+ALTER TABLE exam_grade
+ADD COLUMN IF NOT EXISTS answers TEXT,
+ADD COLUMN IF NOT EXISTS result JSONB NULL,
+ADD COLUMN IF NOT EXISTS started_at BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS deadline_at BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS submitted_at BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS seed BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS question_ids UUID[];
+
+CREATE INDEX IF NOT EXISTS idx_exam_grade_open_deadline ON exam_grade(deadline_at)
+WHERE submitted_at = 0 AND deadline_at > 0;
+
+-- Only the latest attempt of every student fits back into exam_grade.
+UPDATE exam_grade eg
+SET answers = ea.answers, result = ea.result, started_at = ea.started_at, deadline_at = ea.deadline_at,
+    submitted_at = ea.submitted_at, seed = ea.seed, question_ids = ea.question_ids
+FROM (
+    SELECT DISTINCT ON (exam_id, student_id) *
+    FROM exam_attempt
+    ORDER BY exam_id, student_id, number DESC
+) ea
+WHERE eg.exam_id = ea.exam_id AND eg.student_id = ea.student_id AND eg.is_deleted = false;
+
+DROP TABLE IF EXISTS exam_attempt;
+
+ALTER TABLE exam
+DROP COLUMN IF EXISTS scoring_policy,
+DROP COLUMN IF EXISTS max_attempts;
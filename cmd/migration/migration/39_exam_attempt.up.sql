@@ -0,0 +1,50 @@
+-- How many attempts a student gets, and which of them the grade of the exam
+-- is derived from.
+ALTER TABLE exam
+ADD COLUMN IF NOT EXISTS max_attempts INTEGER NOT NULL DEFAULT 1 CHECK (max_attempts > 0),
+ADD COLUMN IF NOT EXISTS scoring_policy VARCHAR(10) NOT NULL DEFAULT 'highest' CHECK (scoring_policy IN ('highest', 'latest', 'average'));
+
+-- A student's attempt at an exam, numbered from 1. The columns moved here
+-- from exam_grade, which keeps the grade of the exam.
+CREATE TABLE IF NOT EXISTS exam_attempt (
+    id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    exam_id UUID NOT NULL REFERENCES exam (id),
+    student_id UUID NOT NULL REFERENCES users (id),
+    number INTEGER NOT NULL CHECK (number > 0),
+    answers TEXT,
+    result JSONB,
+    score DECIMAL(10, 2),
+    max_score DECIMAL(10, 2),
+    grade DECIMAL(5, 2),
+    started_at BIGINT NOT NULL DEFAULT 0,
+    deadline_at BIGINT NOT NULL DEFAULT 0,
+    submitted_at BIGINT NOT NULL DEFAULT 0,
+    seed BIGINT NOT NULL DEFAULT 0,
+    question_ids UUID[],
+    created_at BIGINT NOT NULL DEFAULT 0,
+    updated_at BIGINT NOT NULL DEFAULT 0,
+    UNIQUE (exam_id, student_id, number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_exam_attempt_open_deadline ON exam_attempt(deadline_at)
+WHERE submitted_at = 0 AND deadline_at > 0;
+
+-- Every existing submission, started attempt or opened shuffled or drawn
+-- exam becomes attempt 1.
+INSERT INTO exam_attempt (exam_id, student_id, number, answers, result, score, max_score, grade,
+                          started_at, deadline_at, submitted_at, seed, question_ids, created_at, updated_at)
+SELECT exam_id, student_id, 1, answers, result, score, max_score,
+       CASE WHEN answers IS NOT NULL THEN grade END,
+       started_at, deadline_at, submitted_at, seed, question_ids, created_at, updated_at
+FROM exam_grade
+WHERE is_deleted = false
+AND (answers IS NOT NULL OR started_at > 0 OR seed <> 0 OR question_ids IS NOT NULL);
+
+ALTER TABLE exam_grade
+DROP COLUMN IF EXISTS answers,
+DROP COLUMN IF EXISTS result,
+DROP COLUMN IF EXISTS started_at,
+DROP COLUMN IF EXISTS deadline_at,
+DROP COLUMN IF EXISTS submitted_at,
+DROP COLUMN IF EXISTS seed,
+DROP COLUMN IF EXISTS question_ids;
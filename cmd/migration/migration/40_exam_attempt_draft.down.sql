@@ -0,0 +1,4 @@
+ALTER TABLE exam_attempt
+DROP COLUMN IF EXISTS draft,
+DROP COLUMN IF EXISTS draft_version,
+DROP COLUMN IF EXISTS draft_saved_at;
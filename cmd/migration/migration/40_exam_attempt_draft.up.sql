@@ -0,0 +1,7 @@
+-- The answers saved so far of an open attempt. Autosaves land in Redis and
+-- are flushed here, so a draft survives Redis losing it; draft_version is
+-- the version of the draft that was flushed last.
+ALTER TABLE exam_attempt
+ADD COLUMN IF NOT EXISTS draft TEXT,
+ADD COLUMN IF NOT EXISTS draft_version BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS draft_saved_at BIGINT NOT NULL DEFAULT 0;
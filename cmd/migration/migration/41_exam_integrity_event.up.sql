@@ -0,0 +1,18 @@
+-- Integrity signals of an attempt: reported by the client (focus lost, tab
+-- hidden, copy, paste, fullscreen exit) or detected by the server (IP change,
+-- another session on the same attempt).
+CREATE TABLE IF NOT EXISTS exam_integrity_event (
+    id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    attempt_id UUID NOT NULL REFERENCES exam_attempt (id),
+    exam_id UUID NOT NULL REFERENCES exam (id),
+    student_id UUID NOT NULL REFERENCES users (id),
+    type VARCHAR(30) NOT NULL CHECK (type IN ('focus_lost', 'tab_hidden', 'copy', 'paste', 'fullscreen_exit', 'ip_change', 'concurrent_session')),
+    source VARCHAR(10) NOT NULL CHECK (source IN ('client', 'server')),
+    detail VARCHAR(500) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    occurred_at BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_exam_integrity_event_student ON exam_integrity_event(exam_id, student_id, occurred_at);
@@ -0,0 +1,11 @@
+ALTER TABLE exam_grade
+DROP COLUMN IF EXISTS feedback;
+
+ALTER TABLE question
+DROP COLUMN IF EXISTS explanation;
+
+ALTER TABLE exam
+DROP COLUMN IF EXISTS release_mode,
+DROP COLUMN IF EXISTS released_at,
+DROP COLUMN IF EXISTS show_correct_answers,
+DROP COLUMN IF EXISTS show_explanations;
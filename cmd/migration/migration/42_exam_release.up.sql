@@ -0,0 +1,16 @@
+-- When students see their grades: right away, once the exam window closes,
+-- or when a teacher releases them (released_at, which also releases early).
+-- Once released, exams may show the correct answers and the explanations of
+-- their questions.
+ALTER TABLE exam
+ADD COLUMN IF NOT EXISTS release_mode VARCHAR(20) NOT NULL DEFAULT 'immediate' CHECK (release_mode IN ('immediate', 'after_close', 'manual')),
+ADD COLUMN IF NOT EXISTS released_at BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS show_correct_answers BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS show_explanations BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE question
+ADD COLUMN IF NOT EXISTS explanation TEXT NOT NULL DEFAULT '';
+
+-- The teacher's feedback to the student on the exam
+ALTER TABLE exam_grade
+ADD COLUMN IF NOT EXISTS feedback TEXT NOT NULL DEFAULT '';
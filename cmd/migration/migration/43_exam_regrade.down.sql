@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS exam_grade_change;
+DROP TABLE IF EXISTS exam_regrade_request;
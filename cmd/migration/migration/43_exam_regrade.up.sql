@@ -0,0 +1,44 @@
+-- A student's dispute of the score of one question of a graded attempt,
+-- resolved by a teacher. A question has at most one pending request.
+CREATE TABLE IF NOT EXISTS exam_regrade_request (
+    id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    exam_id UUID NOT NULL REFERENCES exam (id),
+    attempt_id UUID NOT NULL REFERENCES exam_attempt (id),
+    student_id UUID NOT NULL REFERENCES users (id),
+    question_id UUID NOT NULL REFERENCES question (id),
+    reason TEXT NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+    comment TEXT NOT NULL DEFAULT '',
+    previous_score DECIMAL(10, 2),
+    adjusted_score DECIMAL(10, 2),
+    resolved_by UUID REFERENCES users (id),
+    resolved_at BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL DEFAULT 0,
+    updated_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exam_regrade_request_pending ON exam_regrade_request(attempt_id, question_id)
+WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_exam_regrade_request_exam ON exam_regrade_request(exam_id, status, created_at);
+
+-- Every change of the score or grade of an attempt, and of grades of the exam
+-- set directly or derived again by a new scoring policy, which have no
+-- attempt.
+CREATE TABLE IF NOT EXISTS exam_grade_change (
+    id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    exam_id UUID NOT NULL REFERENCES exam (id),
+    student_id UUID NOT NULL REFERENCES users (id),
+    attempt_id UUID REFERENCES exam_attempt (id),
+    source VARCHAR(10) NOT NULL CHECK (source IN ('auto', 'essay', 'regrade', 'direct', 'policy')),
+    question_id UUID REFERENCES question (id),
+    regrade_id UUID REFERENCES exam_regrade_request (id),
+    previous_score DECIMAL(10, 2),
+    score DECIMAL(10, 2),
+    previous_grade DECIMAL(5, 2),
+    grade DECIMAL(5, 2),
+    changed_by UUID REFERENCES users (id),
+    note TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_exam_grade_change_student ON exam_grade_change(exam_id, student_id, created_at);
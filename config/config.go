@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"time"
 )
 
 type App struct {
@@ -34,8 +35,34 @@ type Http struct {
 }
 
 type JWT struct {
-	Secret   string `json:"secret"`
-	Duration int    `json:"duration"`
+	Secret          string `json:"secret"`
+	Duration        int    `json:"duration"`         // access token, minute
+	RefreshDuration int    `json:"refresh_duration"` // refresh token and session, minute
+	// Algorithm of generated signing keys, RS256 or EdDSA. Tokens are signed
+	// with HS256 and Secret until the first key is generated.
+	Algorithm        string `json:"algorithm"`
+	RotationInterval int    `json:"rotation_interval"` // signing key, hour; 0 disables scheduled rotation
+}
+
+// AccessTTL is the lifetime of access tokens, 2 hours when not configured.
+func (j JWT) AccessTTL() time.Duration {
+	if j.Duration <= 0 {
+		return time.Hour * 2
+	}
+	return time.Duration(j.Duration) * time.Minute
+}
+
+// RotationTTL is the age at which the signing key is rotated.
+func (j JWT) RotationTTL() time.Duration {
+	return time.Duration(j.RotationInterval) * time.Hour
+}
+
+// RefreshTTL is the lifetime of refresh tokens, 10 days when not configured.
+func (j JWT) RefreshTTL() time.Duration {
+	if j.RefreshDuration <= 0 {
+		return time.Hour * 240
+	}
+	return time.Duration(j.RefreshDuration) * time.Minute
 }
 
 type Redis struct {
@@ -53,6 +80,16 @@ type SMTP struct {
 	Password string `json:"password"`
 }
 
+type Mail struct {
+	// Driver is smtp, log or memory. When empty smtp is used if smtp.host is
+	// set, otherwise log.
+	Driver   string `json:"driver"`
+	From     string `json:"from"` // defaults to smtp.username
+	FromName string `json:"from_name"`
+	Dir      string `json:"dir"`      // log driver also writes .eml files here
+	LogoURL  string `json:"logo_url"` // default logo, a school's own logo wins
+}
+
 type Telemetry struct {
 	Enable           bool   `json:"enable"`
 	ServiceName      string `json:"service_name"`
@@ -67,15 +104,69 @@ type Cloudinary struct {
 	Folder    string `json:"folder"`
 }
 
+type RateLimit struct {
+	Window          int `json:"window"`           // minute
+	IPLimit         int `json:"ip_limit"`         // requests per IP and window on each auth endpoint
+	DelayAfter      int `json:"delay_after"`      // failures before attempts are delayed
+	DelayBase       int `json:"delay_base"`       // second, doubled for every further failure
+	DelayMax        int `json:"delay_max"`        // second
+	LockoutAfter    int `json:"lockout_after"`    // failures within the window before the account is locked
+	LockoutDuration int `json:"lockout_duration"` // minute
+}
+
+type Queue struct {
+	Concurrency       int `json:"concurrency"`        // jobs the worker runs at once
+	MaxAttempts       int `json:"max_attempts"`       // before a job is moved to the dead letter list
+	BackoffBase       int `json:"backoff_base"`       // second, doubled for every further attempt
+	BackoffMax        int `json:"backoff_max"`        // second
+	VisibilityTimeout int `json:"visibility_timeout"` // second, a running job is handed out again after this
+}
+
+type Exam struct {
+	SubmitGrace int `json:"submit_grace"` // second, submissions this late after the deadline are still accepted
+}
+
+type Scheduler struct {
+	Timeout  int    `json:"timeout"`  // second, a run is canceled and its lock released after this
+	Timezone string `json:"timezone"` // IANA name the cron expressions are evaluated in, UTC when empty
+}
+
+type Webhook struct {
+	Timeout      int `json:"timeout"`       // second
+	DisableAfter int `json:"disable_after"` // consecutive failed deliveries before a webhook is disabled
+	// AllowPrivateNetworks lets webhooks call loopback and private
+	// addresses; never enable it in production.
+	AllowPrivateNetworks bool `json:"allow_private_networks"`
+}
+
+// OIDCProvider is an OpenID Connect provider users can sign in with, keyed
+// by its name in Config.OIDC, e.g. "google".
+type OIDCProvider struct {
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+	// AllowedDomains restricts sign in to these email domains; empty allows any.
+	AllowedDomains []string `json:"allowed_domains"`
+}
+
 type Config struct {
-	App        App        `json:"app"`
-	Http       Http       `json:"http"`
-	Postgres   Postgres   `json:"postgres"`
-	JWT        JWT        `json:"jwt"`
-	Redis      Redis      `json:"redis"`
-	SMTP       SMTP       `json:"smtp"`
-	Cloudinary Cloudinary `json:"cloudinary"`
-	Telemetry  Telemetry  `json:"telemetry"`
+	App        App                     `json:"app"`
+	Http       Http                    `json:"http"`
+	Postgres   Postgres                `json:"postgres"`
+	JWT        JWT                     `json:"jwt"`
+	RateLimit  RateLimit               `json:"rate_limit"`
+	OIDC       map[string]OIDCProvider `json:"oidc"`
+	Redis      Redis                   `json:"redis"`
+	Queue      Queue                   `json:"queue"`
+	Webhook    Webhook                 `json:"webhook"`
+	Scheduler  Scheduler               `json:"scheduler"`
+	Exam       Exam                    `json:"exam"`
+	SMTP       SMTP                    `json:"smtp"`
+	Mail       Mail                    `json:"mail"`
+	Cloudinary Cloudinary              `json:"cloudinary"`
+	Telemetry  Telemetry               `json:"telemetry"`
 }
 
 func New(path string) (*Config, error) {
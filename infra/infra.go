@@ -2,8 +2,20 @@ package infra
 
 import (
 	"enuma-elish/config"
+	"enuma-elish/pkg/apikey"
 	"enuma-elish/pkg/cloudinary"
+	"enuma-elish/pkg/event"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/mailer"
+	"enuma-elish/pkg/oidc"
+	"enuma-elish/pkg/queue"
+	"enuma-elish/pkg/ratelimit"
+	"enuma-elish/pkg/scheduler"
+	"enuma-elish/pkg/session"
+	"enuma-elish/pkg/webhook"
 	"fmt"
+	"net/mail"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
@@ -14,6 +26,16 @@ type Infra struct {
 	Postgres   *sqlx.DB
 	Redis      *redis.Client
 	Cloudinary *cloudinary.Service
+	Session    *session.Store
+	Keys       *jwt.KeySet
+	RateLimit  *ratelimit.Limiter
+	OIDC       map[string]*oidc.Provider
+	APIKeys    *apikey.Store
+	Mail       *mailer.Sender
+	Queue      *queue.Queue
+	Events     *event.Bus
+	Webhooks   *webhook.Store
+	Scheduler  *scheduler.Scheduler
 }
 
 func New(c *config.Config) (*Infra, error) {
@@ -40,9 +62,100 @@ func New(c *config.Config) (*Infra, error) {
 		return nil, fmt.Errorf("failed to initialize cloudinary: %w", err)
 	}
 
+	q := queue.New(rdb, queue.Config{
+		Concurrency:       c.Queue.Concurrency,
+		MaxAttempts:       c.Queue.MaxAttempts,
+		BackoffBase:       time.Duration(c.Queue.BackoffBase) * time.Second,
+		BackoffMax:        time.Duration(c.Queue.BackoffMax) * time.Second,
+		VisibilityTimeout: time.Duration(c.Queue.VisibilityTimeout) * time.Second,
+	})
+
+	mail, err := newMail(c, postgres, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize mailer: %w", err)
+	}
+
+	location := time.UTC
+	if c.Scheduler.Timezone != "" {
+		location, err = time.LoadLocation(c.Scheduler.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scheduler timezone: %w", err)
+		}
+	}
+
+	providers := make(map[string]*oidc.Provider, len(c.OIDC))
+	for name, p := range c.OIDC {
+		providers[name] = oidc.New(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}, nil)
+	}
+
 	return &Infra{
 		Postgres:   postgres,
 		Redis:      rdb,
 		Cloudinary: cloudinaryService,
+		Session:    session.New(rdb),
+		Keys:       jwt.NewKeySet(postgres, c.JWT.Secret, c.JWT.Algorithm, c.JWT.RotationTTL(), c.JWT.RefreshTTL()),
+		RateLimit: ratelimit.New(rdb, ratelimit.Config{
+			Window:          time.Duration(c.RateLimit.Window) * time.Minute,
+			IPLimit:         c.RateLimit.IPLimit,
+			DelayAfter:      c.RateLimit.DelayAfter,
+			DelayBase:       time.Duration(c.RateLimit.DelayBase) * time.Second,
+			DelayMax:        time.Duration(c.RateLimit.DelayMax) * time.Second,
+			LockoutAfter:    c.RateLimit.LockoutAfter,
+			LockoutDuration: time.Duration(c.RateLimit.LockoutDuration) * time.Minute,
+		}),
+		OIDC:    providers,
+		APIKeys: apikey.New(postgres),
+		Mail:    mail,
+		Queue:   q,
+		Events:  event.NewBus(),
+		Webhooks: webhook.New(postgres, q, webhook.Config{
+			Timeout:              time.Duration(c.Webhook.Timeout) * time.Second,
+			DisableAfter:         c.Webhook.DisableAfter,
+			AllowPrivateNetworks: c.Webhook.AllowPrivateNetworks,
+		}),
+		Scheduler: scheduler.New(rdb, scheduler.Config{
+			Timeout:  time.Duration(c.Scheduler.Timeout) * time.Second,
+			Location: location,
+		}),
 	}, nil
 }
+
+func newMail(c *config.Config, db *sqlx.DB, q *queue.Queue) (*mailer.Sender, error) {
+	from := mail.Address{Name: c.Mail.FromName, Address: c.Mail.From}
+	if from.Address == "" {
+		from.Address = c.SMTP.Username
+	}
+
+	driver := c.Mail.Driver
+	if driver == "" {
+		driver = "log"
+		if c.SMTP.Host != "" {
+			driver = "smtp"
+		}
+	}
+
+	var m mailer.Mailer
+	switch driver {
+	case "smtp":
+		m = mailer.NewSMTP(c.SMTP.Host, c.SMTP.Port, c.SMTP.Username, c.SMTP.Password, from)
+	case "log":
+		m = mailer.NewLog(c.Mail.Dir, from)
+	case "memory":
+		m = mailer.NewMemory()
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", driver)
+	}
+
+	brand := mailer.Branding{Name: c.Mail.FromName, Logo: c.Mail.LogoURL}
+	if brand.Name == "" {
+		brand.Name = c.App.Name
+	}
+
+	return mailer.NewSender(m, db, q, brand)
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"enuma-elish/config"
+	"enuma-elish/infra"
+	"enuma-elish/internal/admin/handler"
+	"enuma-elish/internal/admin/service"
+	"enuma-elish/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Admin holds the platform operations endpoints, they are only open to
+// users with the admin users.role.
+type Admin struct {
+	*gin.Engine
+	c *config.Config
+	i *infra.Infra
+	v *validator.Validate
+}
+
+func New(c *config.Config, i *infra.Infra, r *gin.Engine, v *validator.Validate) *Admin {
+	return &Admin{
+		c:      c,
+		i:      i,
+		Engine: r,
+		v:      v,
+	}
+}
+
+func (a *Admin) Init() {
+	svc := service.New(a.i.Scheduler)
+	h := handler.New(svc, a.v)
+
+	authMiddleware := middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys)
+
+	// RequireRole without school roles lets platform admins through only
+	v1 := a.Group("/api/v1/admin").Use(authMiddleware, middleware.RequireRole())
+	v1.GET("/scheduler/jobs", h.ListScheduledJobs)
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"enuma-elish/internal/admin/service"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type Handler struct {
+	service   service.Service
+	validator *validator.Validate
+}
+
+func New(service service.Service, validator *validator.Validate) *Handler {
+	return &Handler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+func (h *Handler) ListScheduledJobs(c *gin.Context) {
+	jobs, err := h.service.ListScheduledJobs(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("list scheduled jobs success").
+		SetData(jobs)
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,15 @@
+package response
+
+// ScheduledJob is a scheduled job with the outcome of its last run. Failures
+// counts the failed runs since the last successful one.
+type ScheduledJob struct {
+	Name           string `json:"name"`
+	Schedule       string `json:"schedule"`
+	Running        bool   `json:"running"`
+	LastRunAt      int64  `json:"last_run_at"`
+	LastSuccessAt  int64  `json:"last_success_at"`
+	LastDurationMs int64  `json:"last_duration_ms"`
+	LastError      string `json:"last_error"`
+	Failures       int64  `json:"failures"`
+	NextRunAt      int64  `json:"next_run_at"`
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"enuma-elish/internal/admin/service/data/response"
+	"enuma-elish/pkg/scheduler"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Service interface {
+	ListScheduledJobs(ctx context.Context) ([]response.ScheduledJob, error)
+}
+
+type service struct {
+	scheduler *scheduler.Scheduler
+}
+
+func New(scheduler *scheduler.Scheduler) Service {
+	return &service{scheduler: scheduler}
+}
+
+func (s *service) ListScheduledJobs(ctx context.Context) ([]response.ScheduledJob, error) {
+	jobs, err := s.scheduler.Jobs(ctx)
+	if err != nil {
+		log.Err(err).Msg("error listing scheduled jobs")
+		return nil, err
+	}
+
+	res := make([]response.ScheduledJob, 0, len(jobs))
+	for _, j := range jobs {
+		res = append(res, response.ScheduledJob{
+			Name:           j.Name,
+			Schedule:       j.Spec,
+			Running:        j.Running,
+			LastRunAt:      j.LastRunAt,
+			LastSuccessAt:  j.LastSuccessAt,
+			LastDurationMs: j.LastDurationMs,
+			LastError:      j.LastError,
+			Failures:       j.Failures,
+			NextRunAt:      j.NextRunAt,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"enuma-elish/internal/admin/service/data/response"
+	authRequest "enuma-elish/internal/auth/service/data/request"
+	authResponse "enuma-elish/internal/auth/service/data/response"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListScheduledJobs(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	jobsUrl := server.URL + "/api/v1/admin/scheduler/jobs"
+
+	httpClient := commonHttp.NewHttpClient().
+		SetUrl(jobsUrl).
+		SetMethod(http.MethodGet).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without a token, got %d", httpClient.Status())
+	}
+
+	token := &authResponse.LoginResponse{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/login").
+		SetMethod(http.MethodPost).
+		SetJsonHeader().
+		SetRequestBody(&authRequest.LoginRequest{Email: "admin@gmail.com", Password: "12345678"}).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(token))
+
+	if httpClient.Error() != nil {
+		t.Fatalf("http response error: %v", httpClient.Error())
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	jobs := []response.ScheduledJob{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(jobsUrl).
+		SetMethod(http.MethodGet).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(&jobs))
+
+	if httpClient.Status() != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", httpClient.Status())
+	}
+
+	names := map[string]response.ScheduledJob{}
+	for _, j := range jobs {
+		names[j.Name] = j
+	}
+	for _, name := range []string{"ppdb.close", "teacher.expire_invites", "student.expire_invites", "storage.purge"} {
+		j, ok := names[name]
+		if !ok {
+			t.Fatalf("expected the %s job to be listed", name)
+		}
+		if j.NextRunAt == 0 {
+			t.Errorf("expected a next run for %s", name)
+		}
+	}
+}
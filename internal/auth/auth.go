@@ -30,17 +30,35 @@ func New(c *config.Config, i *infra.Infra, r *gin.Engine, v *validator.Validate)
 
 func (a *Auth) Init() {
 	r := repository.New(a.i.Postgres, a.i.Redis)
-	s := service.New(r, a.c)
+	s := service.New(r, a.c, a.i.Session, a.i.Keys, a.i.RateLimit, a.i.OIDC, a.i.Mail)
 	h := handler.New(s, a.v)
 
+	a.GET("/.well-known/jwks.json", h.JWKS)
+
 	v1 := a.Group("/api/v1/auth")
 	v1.POST("/register", h.Register)
-	v1.POST("/login", h.Login)
-	v1.POST("/register/verify-email", h.VerifyEmail)
-	v1.POST("/forgot-password", h.ForgotPassword)
-	v1.POST("/forgot-password/verify", h.ForgotPasswordVerify)
-	v1.POST("/refresh-token", h.RefreshToken)
-
-	v1.GET("/me", middleware.Auth(a.c.JWT.Secret), h.Me)
-	v1.PUT("/me", middleware.Auth(a.c.JWT.Secret), h.UpdateUser)
+	v1.POST("/login", middleware.RateLimit(a.i.RateLimit, "login"), h.Login)
+	v1.POST("/login/mfa", middleware.RateLimit(a.i.RateLimit, "login_mfa"), h.LoginMFA)
+	v1.POST("/login/mfa/enroll", middleware.RateLimit(a.i.RateLimit, "login_mfa"), h.EnrollMFAWithChallenge)
+	v1.POST("/register/verify-email", middleware.RateLimit(a.i.RateLimit, "verify_email"), h.VerifyEmail)
+	v1.POST("/forgot-password", middleware.RateLimit(a.i.RateLimit, "forgot_password"), h.ForgotPassword)
+	v1.POST("/forgot-password/verify", middleware.RateLimit(a.i.RateLimit, "forgot_password_verify"), h.ForgotPasswordVerify)
+	v1.POST("/refresh-token", middleware.RateLimit(a.i.RateLimit, "refresh_token"), h.RefreshToken)
+	v1.POST("/unlock", middleware.RateLimit(a.i.RateLimit, "unlock"), h.UnlockAccount)
+	v1.GET("/oidc/:provider/login", middleware.RateLimit(a.i.RateLimit, "oidc"), h.OIDCLogin)
+	v1.POST("/oidc/:provider/callback", middleware.RateLimit(a.i.RateLimit, "oidc"), h.OIDCCallback)
+
+	v1.GET("/me", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.Me)
+	v1.PUT("/me", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.UpdateUser)
+	v1.POST("/logout", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.Logout)
+	v1.GET("/sessions", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.ListSessions)
+	v1.DELETE("/sessions", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.RevokeAllSessions)
+	v1.DELETE("/sessions/:session_id", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.RevokeSession)
+	v1.POST("/mfa/enroll", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.EnrollMFA)
+	v1.POST("/mfa/enroll/verify", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.VerifyMFAEnrollment)
+	v1.POST("/mfa/verify", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.VerifyMFA)
+	v1.POST("/mfa/recovery-codes", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.RegenerateRecoveryCodes)
+	v1.POST("/mfa/disable", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.DisableMFA)
+	v1.GET("/identities", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.ListIdentities)
+	v1.DELETE("/identities/:identity_id", middleware.Auth(a.i.Keys, a.i.Session, a.i.APIKeys), h.UnlinkIdentity)
 }
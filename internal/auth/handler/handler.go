@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
@@ -63,6 +64,9 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
+	req.UserAgent = c.Request.UserAgent()
+	req.IP = c.ClientIP()
+
 	data, err := h.service.Login(c.Request.Context(), req)
 	if err != nil {
 		c.Error(err)
@@ -153,6 +157,8 @@ func (h *Handler) ForgotPasswordVerify(c *gin.Context) {
 		return
 	}
 
+	req.IP = c.ClientIP()
+
 	err = h.service.ForgotPasswordVerify(c.Request.Context(), req)
 	if err != nil {
 		c.Error(err)
@@ -220,3 +226,357 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+func (h *Handler) Logout(c *gin.Context) {
+	err := h.service.Logout(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("logout success")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ListSessions(c *gin.Context) {
+	data, err := h.service.ListSessions(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("get sessions success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) RevokeSession(c *gin.Context) {
+	err := h.service.RevokeSession(c.Request.Context(), c.Param("session_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("revoke session success")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) RevokeAllSessions(c *gin.Context) {
+	err := h.service.RevokeAllSessions(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("revoke all sessions success")
+
+	c.JSON(http.StatusOK, response)
+}
+
+// JWKS serves the public signing keys as a bare RFC 7517 document so other
+// services can verify our tokens without the shared secret.
+func (h *Handler) JWKS(c *gin.Context) {
+	keys, err := h.service.JWKS(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *Handler) LoginMFA(c *gin.Context) {
+	req := request.LoginMFARequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	req.UserAgent = c.Request.UserAgent()
+	req.IP = c.ClientIP()
+
+	data, err := h.service.LoginMFA(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("login success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) EnrollMFAWithChallenge(c *gin.Context) {
+	req := request.MFATokenRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	data, err := h.service.EnrollMFAWithChallenge(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("mfa enroll success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) EnrollMFA(c *gin.Context) {
+	data, err := h.service.EnrollMFA(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("mfa enroll success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) VerifyMFAEnrollment(c *gin.Context) {
+	req := request.MFACodeRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	data, err := h.service.VerifyMFAEnrollment(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("mfa enabled").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	req := request.MFACodeRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	data, err := h.service.RegenerateRecoveryCodes(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("regenerate recovery codes success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) VerifyMFA(c *gin.Context) {
+	req := request.MFAVerifyRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = h.service.VerifyMFA(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("mfa verified")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) DisableMFA(c *gin.Context) {
+	req := request.MFAVerifyRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = h.service.DisableMFA(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("mfa disabled")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) UnlockAccount(c *gin.Context) {
+	req := request.UnlockAccountRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = h.service.UnlockAccount(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("unlock account success")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	data, err := h.service.OIDCLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("oidc login success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	req := request.OIDCCallbackRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	req.UserAgent = c.Request.UserAgent()
+	req.IP = c.ClientIP()
+
+	data, err := h.service.OIDCCallback(c.Request.Context(), c.Param("provider"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("login success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ListIdentities(c *gin.Context) {
+	data, err := h.service.ListIdentities(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("list identities success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) UnlinkIdentity(c *gin.Context) {
+	identityID, err := uuid.Parse(c.Param("identity_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	err = h.service.UnlinkIdentity(c.Request.Context(), identityID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("unlink identity success")
+
+	c.JSON(http.StatusOK, response)
+}
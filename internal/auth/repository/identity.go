@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const OIDCStateKey = "oidc_state"
+
+// OIDCStateTTL is how long the user may take to sign in with the provider.
+const OIDCStateTTL = time.Minute * 10
+
+// UserIdentity links a user to the subject of an external OIDC provider.
+type UserIdentity struct {
+	ID          uuid.UUID `db:"id"`
+	UserID      uuid.UUID `db:"user_id"`
+	Provider    string    `db:"provider"`
+	Subject     string    `db:"subject"`
+	Email       string    `db:"email"`
+	LastLoginAt int64     `db:"last_login_at"`
+	CreatedAt   int64     `db:"created_at"`
+}
+
+// OIDCState is kept between redirecting to the provider and its callback.
+type OIDCState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func (r *repository) GetUserIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	identity := &UserIdentity{}
+	err := r.db.GetContext(ctx, identity, "SELECT id, user_id, provider, subject, email, last_login_at, created_at FROM user_identity WHERE provider = $1 AND subject = $2", provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (r *repository) ListUserIdentities(ctx context.Context, userID uuid.UUID) ([]UserIdentity, error) {
+	identities := []UserIdentity{}
+	err := r.db.SelectContext(ctx, &identities, "SELECT id, user_id, provider, subject, email, last_login_at, created_at FROM user_identity WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *repository) CreateUserIdentity(ctx context.Context, identity *UserIdentity) error {
+	err := r.db.GetContext(ctx, identity, `INSERT INTO user_identity (user_id, provider, subject, email, last_login_at) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, user_id, provider, subject, email, last_login_at, created_at`,
+		identity.UserID, identity.Provider, identity.Subject, identity.Email, time.Now().UnixMilli())
+	if err != nil {
+		log.Err(err).Str("user", identity.UserID.String()).Str("provider", identity.Provider).Msg("failed to create user identity")
+		return err
+	}
+	return nil
+}
+
+func (r *repository) TouchUserIdentity(ctx context.Context, id uuid.UUID, email string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE user_identity SET email = $2, last_login_at = $3 WHERE id = $1", id, email, time.Now().UnixMilli())
+	return err
+}
+
+// DeleteUserIdentity reports false when the identity does not belong to the user.
+func (r *repository) DeleteUserIdentity(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM user_identity WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *repository) CreateOIDCState(ctx context.Context, state string, s *OIDCState) error {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	err = r.rdb.Set(ctx, OIDCStateKey+":"+state, value, OIDCStateTTL).Err()
+	if err != nil {
+		log.Err(err).Str("provider", s.Provider).Msg("failed to set oidc state")
+		return err
+	}
+	return nil
+}
+
+// ConsumeOIDCState returns the state and deletes it, so a callback can only
+// be completed once.
+func (r *repository) ConsumeOIDCState(ctx context.Context, state string) (*OIDCState, error) {
+	res, err := r.rdb.GetDel(ctx, OIDCStateKey+":"+state).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &OIDCState{}
+	err = json.Unmarshal([]byte(res), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
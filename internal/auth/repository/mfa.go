@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+const MFAChallengeKey = "mfa_challenge"
+
+// MFAChallengeTTL is how long the second login step may take.
+const MFAChallengeTTL = time.Minute * 5
+
+type UserMFA struct {
+	UserID       uuid.UUID `db:"user_id"`
+	Secret       string    `db:"secret"`
+	Enabled      bool      `db:"enabled"`
+	LastUsedStep int64     `db:"last_used_step"`
+	EnabledAt    int64     `db:"enabled_at"`
+	CreatedAt    int64     `db:"created_at"`
+	UpdatedAt    int64     `db:"updated_at"`
+}
+
+type UserMFARecoveryCode struct {
+	ID        uuid.UUID `db:"id"`
+	UserID    uuid.UUID `db:"user_id"`
+	CodeHash  string    `db:"code_hash"`
+	UsedAt    int64     `db:"used_at"`
+	CreatedAt int64     `db:"created_at"`
+}
+
+// MFAChallenge is what a password login leaves behind in Redis until the
+// second factor is provided. Enroll is set when the school requires MFA but
+// the user has not enrolled yet.
+type MFAChallenge struct {
+	Token      string    `json:"token"`
+	UserID     uuid.UUID `json:"user_id"`
+	Email      string    `json:"email"`
+	UserRole   string    `json:"user_role"`
+	SchoolID   uuid.UUID `json:"school_id"`
+	SchoolRole string    `json:"school_role"`
+	Enroll     bool      `json:"enroll"`
+	Attempts   int       `json:"attempts"`
+}
+
+func (r *repository) GetUserMFA(ctx context.Context, userID uuid.UUID) (*UserMFA, error) {
+	m := &UserMFA{}
+	err := r.db.GetContext(ctx, m, "SELECT user_id, secret, enabled, last_used_step, enabled_at, created_at, updated_at FROM user_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SaveUserMFASecret stores a pending secret. It never overwrites a secret
+// that is already enabled.
+func (r *repository) SaveUserMFASecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO user_mfa (user_id, secret, enabled, updated_at) VALUES ($1, $2, false, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, updated_at = EXCLUDED.updated_at
+		WHERE user_mfa.enabled = false`, userID, secret, time.Now().UnixMilli())
+	if err != nil {
+		log.Err(err).Str("user", userID.String()).Msg("failed to save mfa secret")
+		return err
+	}
+	return nil
+}
+
+// EnableUserMFA turns on MFA and replaces the recovery codes in one go.
+func (r *repository) EnableUserMFA(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UnixMilli()
+	_, err = tx.ExecContext(ctx, "UPDATE user_mfa SET enabled = true, enabled_at = $2, last_used_step = $3, updated_at = $2 WHERE user_id = $1", userID, now, step)
+	if err != nil {
+		return err
+	}
+
+	err = replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	err = replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, codeHashes []string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM user_mfa_recovery_code WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		_, err = tx.ExecContext(ctx, "INSERT INTO user_mfa_recovery_code (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) GetUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]UserMFARecoveryCode, error) {
+	codes := []UserMFARecoveryCode{}
+	err := r.db.SelectContext(ctx, &codes, "SELECT id, user_id, code_hash, used_at, created_at FROM user_mfa_recovery_code WHERE user_id = $1 AND used_at = 0", userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode marks the code as used and reports false when another
+// request used it first.
+func (r *repository) UseRecoveryCode(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE user_mfa_recovery_code SET used_at = $2 WHERE id = $1 AND used_at = 0", id, time.Now().UnixMilli())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// UseMFAStep records the TOTP step that was just accepted and reports false
+// when that step (or a later one) was already used, i.e. the code is replayed.
+func (r *repository) UseMFAStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE user_mfa SET last_used_step = $2, updated_at = $3 WHERE user_id = $1 AND last_used_step < $2", userID, step, time.Now().UnixMilli())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *repository) DeleteUserMFA(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_mfa_recovery_code WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
+// GetUserMFARequired reports whether any school of the user requires MFA
+// for one of the given roles the user holds there.
+func (r *repository) GetUserMFARequired(ctx context.Context, userID uuid.UUID, roles []string) (bool, error) {
+	var required bool
+	err := r.db.GetContext(ctx, &required, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_school_role usr
+			JOIN school s ON s.id = usr.school_id
+			WHERE usr.user_id = $1 AND usr.is_deleted = false AND usr.role_id::text = ANY($2) AND s.mfa_required
+		)`, userID, pq.Array(roles))
+	if err != nil {
+		return false, err
+	}
+	return required, nil
+}
+
+func (r *repository) CreateMFAChallenge(ctx context.Context, c *MFAChallenge) error {
+	value, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	err = r.rdb.Set(ctx, MFAChallengeKey+":"+c.Token, value, MFAChallengeTTL).Err()
+	if err != nil {
+		log.Err(err).Str("user", c.Email).Msg("failed to set mfa challenge")
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetMFAChallenge(ctx context.Context, token string) (*MFAChallenge, error) {
+	res, err := r.rdb.Get(ctx, MFAChallengeKey+":"+token).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &MFAChallenge{}
+	err = json.Unmarshal([]byte(res), c)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// UpdateMFAChallenge writes the challenge back without extending its TTL.
+func (r *repository) UpdateMFAChallenge(ctx context.Context, c *MFAChallenge) error {
+	value, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return r.rdb.Set(ctx, MFAChallengeKey+":"+c.Token, value, redis.KeepTTL).Err()
+}
+
+func (r *repository) DeleteMFAChallenge(ctx context.Context, token string) error {
+	return r.rdb.Del(ctx, MFAChallengeKey+":"+token).Err()
+}
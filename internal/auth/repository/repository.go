@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
@@ -44,6 +45,28 @@ type Repository interface {
 	VerifyForgotPasswordToken(ctx context.Context, email string) (*UserForgotPasswordToken, error)
 	UpdatePassword(ctx context.Context, email, password string) error
 	UpdateUser(ctx context.Context, profile *User) (*User, error)
+	GetUserMFA(ctx context.Context, userID uuid.UUID) (*UserMFA, error)
+	SaveUserMFASecret(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableUserMFA(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	GetUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]UserMFARecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, id uuid.UUID) (bool, error)
+	UseMFAStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	DeleteUserMFA(ctx context.Context, userID uuid.UUID) error
+	GetUserMFARequired(ctx context.Context, userID uuid.UUID, roles []string) (bool, error)
+	CreateMFAChallenge(ctx context.Context, c *MFAChallenge) error
+	GetMFAChallenge(ctx context.Context, token string) (*MFAChallenge, error)
+	UpdateMFAChallenge(ctx context.Context, c *MFAChallenge) error
+	DeleteMFAChallenge(ctx context.Context, token string) error
+	CreateUnlockToken(ctx context.Context, token, email string, ttl time.Duration) error
+	ConsumeUnlockToken(ctx context.Context, token string) (string, error)
+	GetUserIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	ListUserIdentities(ctx context.Context, userID uuid.UUID) ([]UserIdentity, error)
+	CreateUserIdentity(ctx context.Context, identity *UserIdentity) error
+	TouchUserIdentity(ctx context.Context, id uuid.UUID, email string) error
+	DeleteUserIdentity(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	CreateOIDCState(ctx context.Context, state string, s *OIDCState) error
+	ConsumeOIDCState(ctx context.Context, state string) (*OIDCState, error)
 }
 
 type repository struct {
@@ -55,6 +55,7 @@ type UserForgotPasswordToken struct {
 const (
 	VerifyEmailTokenKey    = "verify_email_token"
 	ForgotPasswordTokenKey = "forgot_password"
+	UnlockTokenKey         = "unlock_account"
 )
 
 func (r *repository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
@@ -165,6 +166,24 @@ func (r *repository) UpdatePassword(ctx context.Context, email, password string)
 	return nil
 }
 
+func (r *repository) CreateUnlockToken(ctx context.Context, token, email string, ttl time.Duration) error {
+	err := r.rdb.Set(ctx, UnlockTokenKey+":"+token, email, ttl).Err()
+	if err != nil {
+		log.Err(err).Str("user", email).Msg("failed to set unlock token")
+		return err
+	}
+	return nil
+}
+
+// ConsumeUnlockToken returns the email of the token and deletes it.
+func (r *repository) ConsumeUnlockToken(ctx context.Context, token string) (string, error) {
+	email, err := r.rdb.GetDel(ctx, UnlockTokenKey+":"+token).Result()
+	if err != nil {
+		return "", err
+	}
+	return email, nil
+}
+
 func (r *repository) Redis() *redis.Client {
 	return r.rdb
 }
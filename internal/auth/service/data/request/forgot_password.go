@@ -8,4 +8,5 @@ type ForgotPasswordVerifyRequest struct {
 	Email       string `json:"email" validate:"required,email"`
 	Token       string `json:"token" validate:"required"`
 	NewPassword string `json:"new_password" validate:"required,min=8"`
+	IP          string `json:"-"`
 }
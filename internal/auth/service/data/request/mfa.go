@@ -0,0 +1,22 @@
+package request
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+// MFAVerifyRequest takes either a TOTP code or one of the recovery codes.
+type MFAVerifyRequest struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+type LoginMFARequest struct {
+	MFAVerifyRequest
+	MFAToken  string `json:"mfa_token" validate:"required"`
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
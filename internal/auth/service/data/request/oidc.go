@@ -0,0 +1,8 @@
+package request
+
+type OIDCCallbackRequest struct {
+	Code      string `json:"code" validate:"required"`
+	State     string `json:"state" validate:"required"`
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
@@ -0,0 +1,15 @@
+package response
+
+import "github.com/google/uuid"
+
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type IdentityResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Provider    string    `json:"provider"`
+	Email       string    `json:"email"`
+	LastLoginAt int64     `json:"last_login_at"`
+	CreatedAt   int64     `json:"created_at"`
+}
@@ -5,7 +5,11 @@ import (
 	"enuma-elish/internal/auth/repository"
 	"enuma-elish/internal/auth/service/data/request"
 	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/mailer"
 	"fmt"
+	"net/url"
+	"strings"
+
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
@@ -28,19 +32,31 @@ func (s *service) ForgotPassword(ctx context.Context, data request.ForgotPasswor
 		return err
 	}
 
-	go func() {
-		verifyUrl := fmt.Sprintf("%s/forgot-password?token=%s&email=%s", s.config.Http.FrontendHost, token.Token, data.Email)
-		err = s.sendEmail(u.Email, verifyUrl, "verify email")
-		if err != nil {
-			log.Err(err).Msg("Failed to send email")
-		}
-	}()
+	err = s.mail.Enqueue(ctx, mailer.Email{
+		To:       u.Email,
+		Template: mailer.TemplateForgotPassword,
+		Data: mailer.Data{
+			URL: fmt.Sprintf("%s/forgot-password?token=%s&email=%s", s.config.Http.FrontendHost, token.Token, url.QueryEscape(data.Email)),
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Str("email", data.Email).Msg("failed to enqueue forgot password email")
+		return err
+	}
 
 	return nil
 }
 
+// ForgotPasswordVerify counts wrong tokens per email and IP, so guessing
+// from one address cannot lock the user out of resetting from another. The
+// token outlives a lockout; being random, it cannot be guessed anyway.
 func (s *service) ForgotPasswordVerify(ctx context.Context, data request.ForgotPasswordVerifyRequest) error {
-	_, err := s.repository.GetUserByEmail(ctx, data.Email)
+	guardID := strings.ToLower(data.Email) + ":" + data.IP
+	if err := s.forgot.Check(ctx, guardID); err != nil {
+		return err
+	}
+
+	user, err := s.repository.GetUserByEmail(ctx, data.Email)
 	if err != nil {
 		log.Error().Err(err).Str("email", data.Email).Msg("user not found")
 		return err
@@ -54,6 +70,9 @@ func (s *service) ForgotPasswordVerify(ctx context.Context, data request.ForgotP
 
 	if token.Token != data.Token {
 		log.Error().Str("token", token.Token).Str("email", data.Email).Msg("invalid token")
+		if _, err := s.forgot.Fail(ctx, guardID); err != nil {
+			log.Error().Err(err).Str("email", data.Email).Msg("failed to record token attempt")
+		}
 		return commonError.ErrInvalidToken
 	}
 
@@ -70,6 +89,14 @@ func (s *service) ForgotPasswordVerify(ctx context.Context, data request.ForgotP
 	}
 
 	s.repository.Redis().Del(ctx, repository.ForgotPasswordTokenKey+":"+data.Email)
+	s.forgot.Reset(ctx, guardID)
+
+	// A password reset signs the user out everywhere.
+	err = s.sessions.RevokeAll(ctx, user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("email", data.Email).Msg("failed to revoke sessions")
+		return err
+	}
 
 	return nil
 }
@@ -8,18 +8,29 @@ import (
 	"enuma-elish/internal/auth/service/data/response"
 	commonError "enuma-elish/pkg/error"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/mailer"
+	"enuma-elish/pkg/session"
 	"errors"
-	"time"
+	"fmt"
+	"strings"
 
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func (s *service) Login(ctx context.Context, data request.LoginRequest) (*response.LoginResponse, error) {
+	email := strings.ToLower(data.Email)
+	if err := s.login.Check(ctx, email); err != nil {
+		return nil, err
+	}
+
 	user, err := s.repository.GetUserByEmail(ctx, data.Email)
 	if err != nil {
 		log.Err(err).Str("email", data.Email).Msg("User not found")
 		if errors.Is(err, sql.ErrNoRows) {
+			s.loginFailed(ctx, email, nil)
 			return nil, commonError.ErrUserNotFound
 		}
 		return nil, err
@@ -27,13 +38,24 @@ func (s *service) Login(ctx context.Context, data request.LoginRequest) (*respon
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(data.Password))
 	if err != nil {
 		log.Err(err).Str("email", data.Email).Msg("password incorrect")
+		s.loginFailed(ctx, email, user)
 		return nil, commonError.ErrInvalidPassword
 	}
 
+	if err := s.login.Reset(ctx, email); err != nil {
+		log.Err(err).Str("email", data.Email).Msg("failed to reset login attempts")
+	}
+
+	return s.loginUser(ctx, user, data.UserAgent, data.IP)
+}
+
+// loginUser picks the school the tokens are scoped to and, unless a second
+// factor is still needed, starts the session of an authenticated user.
+func (s *service) loginUser(ctx context.Context, user *repository.User, userAgent, ip string) (*response.LoginResponse, error) {
 	userSchoolRole, err := s.repository.GetFirstUserSchoolRoleByUserID(ctx, user.ID)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
-			log.Err(err).Str("email", data.Email).Msg("failed to get user first user")
+			log.Err(err).Str("email", user.Email).Msg("failed to get user first user")
 			return nil, err
 		}
 
@@ -44,50 +66,105 @@ func (s *service) Login(ctx context.Context, data request.LoginRequest) (*respon
 		userSchoolRole = &repository.UserSchoolRole{}
 	}
 
-	now := time.Now()
-	exp := now.Add(time.Hour * 2).Unix()
-	nbf := now.Unix()
-	iat := now.Unix()
-	payload := jwt.Payload{
-		Exp: exp,
-		Iat: iat,
-		Nbf: nbf,
-		Iss: "genesis",
-		Sub: user.ID.String(),
-		Aud: "genesis",
-		User: jwt.User{
-			ID:         user.ID,
-			Email:      user.Email,
-			SchoolID:   userSchoolRole.SchoolID,
-			SchoolRole: userSchoolRole.RoleID,
-			UserRole:   user.Role,
+	challenge, err := s.mfaChallenge(ctx, user, userSchoolRole)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	return s.startSession(ctx, jwt.User{
+		ID:         user.ID,
+		Email:      user.Email,
+		SchoolID:   userSchoolRole.SchoolID,
+		SchoolRole: userSchoolRole.RoleID,
+		UserRole:   user.Role,
+	}, userAgent, ip, false)
+}
+
+// loginFailed counts a failed password for email. When that locks the
+// account, an existing user gets an email with a link to unlock it early.
+func (s *service) loginFailed(ctx context.Context, email string, user *repository.User) {
+	locked, err := s.login.Fail(ctx, email)
+	if err != nil {
+		log.Err(err).Str("email", email).Msg("failed to record login attempt")
+		return
+	}
+	if !locked {
+		return
+	}
+
+	log.Warn().Str("email", email).Msg("account locked after failed logins")
+	if user == nil {
+		return
+	}
+
+	token := uuid.New().String()
+	err = s.repository.CreateUnlockToken(ctx, token, email, s.login.LockoutDuration())
+	if err != nil {
+		return
+	}
+
+	err = s.mail.Enqueue(ctx, mailer.Email{
+		To:       user.Email,
+		Template: mailer.TemplateUnlockAccount,
+		Data: mailer.Data{
+			URL:     fmt.Sprintf("%s/unlock-account?token=%s", s.config.Http.FrontendHost, token),
+			Minutes: int(s.login.LockoutDuration().Minutes()),
 		},
+	})
+	if err != nil {
+		log.Err(err).Str("email", email).Msg("failed to enqueue unlock email")
 	}
+}
 
-	accessToken, err := jwt.GenerateToken(payload, s.config.JWT.Secret)
+func (s *service) UnlockAccount(ctx context.Context, data request.UnlockAccountRequest) error {
+	email, err := s.repository.ConsumeUnlockToken(ctx, data.Token)
 	if err != nil {
-		log.Err(err).Str("email", data.Email).Msg("Error generating token")
+		if errors.Is(err, redis.Nil) {
+			return commonError.ErrInvalidToken
+		}
+		log.Err(err).Msg("failed to get unlock token")
+		return err
+	}
+
+	return s.login.Unlock(ctx, email)
+}
+
+// startSession creates the session and the first token pair once the user
+// has passed every login step. mfa records that one of them was a second
+// factor.
+func (s *service) startSession(ctx context.Context, user jwt.User, userAgent, ip string, mfa bool) (*response.LoginResponse, error) {
+	sess, err := s.sessions.Create(ctx, user.ID, userAgent, ip, s.config.JWT.RefreshTTL())
+	if err != nil {
+		log.Err(err).Str("email", user.Email).Msg("Error creating session")
 		return nil, err
 	}
 
-	refreshPayload := payload
-	refreshPayload.Exp = now.Add(time.Hour * 240).Unix()
-	refreshToken, err := jwt.GenerateToken(refreshPayload, s.config.JWT.Secret)
+	if mfa {
+		if err := s.sessions.VerifyMFA(ctx, sess); err != nil {
+			log.Err(err).Str("email", user.Email).Msg("Error verifying session mfa")
+			return nil, err
+		}
+	}
+
+	tokens, err := s.keys.GenerateTokenPair(ctx, user, sess.ID, sess.RefreshID, s.config.JWT.AccessTTL(), s.config.JWT.RefreshTTL())
 	if err != nil {
-		log.Err(err).Str("email", data.Email).Msg("Error generating refresh token")
+		log.Err(err).Str("email", user.Email).Msg("Error generating token")
 		return nil, err
 	}
 
 	res := &response.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}
 
 	return res, nil
 }
 
 func (s *service) RefreshToken(ctx context.Context, data request.RefreshTokenRequest) (*response.LoginResponse, error) {
-	token, err := jwt.Verify(data.RefreshToken, s.config.JWT.Secret)
+	token, err := s.keys.Verify(ctx, data.RefreshToken)
 	if err != nil {
 		log.Err(err).Str("refresh_token", data.RefreshToken).Msg("Refresh token invalid")
 		return nil, err
@@ -99,32 +176,36 @@ func (s *service) RefreshToken(ctx context.Context, data request.RefreshTokenReq
 		return nil, err
 	}
 
-	now := time.Now()
-	exp := now.Add(time.Hour * 24).Unix()
-	nbf := now.Unix()
-	iat := now.Unix()
+	if payload.Typ != jwt.TypeRefresh {
+		return nil, jwt.ErrInvalidTokenType
+	}
 
-	payload.Exp = exp
-	payload.Iat = iat
-	payload.Nbf = nbf
+	sess, err := s.sessions.Get(ctx, payload.Sid)
+	if err != nil {
+		log.Err(err).Str("session_id", payload.Sid).Msg("Refresh token session revoked")
+		return nil, err
+	}
+	if sess.UserID != payload.User.ID {
+		return nil, session.ErrSessionRevoked
+	}
 
-	accessToken, err := jwt.GenerateToken(*payload, s.config.JWT.Secret)
+	refreshID, err := s.sessions.Rotate(ctx, sess, payload.Jti, s.config.JWT.RefreshTTL())
 	if err != nil {
-		log.Err(err).Msg("Error generating token")
+		if errors.Is(err, session.ErrRefreshTokenReused) {
+			log.Warn().Str("session_id", sess.ID).Str("user_id", sess.UserID.String()).Msg("Refresh token reuse detected, session revoked")
+		}
 		return nil, err
 	}
 
-	refreshPayload := payload
-	refreshPayload.Exp = now.Add(time.Hour * 240).Unix()
-	refreshToken, err := jwt.GenerateToken(*refreshPayload, s.config.JWT.Secret)
+	tokens, err := s.keys.GenerateTokenPair(ctx, payload.User, sess.ID, refreshID, s.config.JWT.AccessTTL(), s.config.JWT.RefreshTTL())
 	if err != nil {
-		log.Err(err).Msg("Error generating refresh token")
+		log.Err(err).Msg("Error generating token")
 		return nil, err
 	}
 
 	res := &response.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}
 
 	return res, nil
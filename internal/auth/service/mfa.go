@@ -0,0 +1,476 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"enuma-elish/internal/auth/repository"
+	"enuma-elish/internal/auth/service/data/request"
+	"enuma-elish/internal/auth/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/totp"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	// maxMFAAttempts wrong codes invalidate the challenge and force a new
+	// password login. Wrong codes are also counted per user by the mfa
+	// guard, which locks the second factor.
+	maxMFAAttempts = 5
+)
+
+// mfaRequiredRoles are the school roles a school can force to use MFA.
+var mfaRequiredRoles = []string{repository.UserSchoolRoleAdmin, repository.UserSchoolRoleHeadTeacher}
+
+// mfaChallenge returns the response of the first login step when a second
+// factor is needed, or nil when tokens can be issued right away.
+func (s *service) mfaChallenge(ctx context.Context, user *repository.User, role *repository.UserSchoolRole) (*response.LoginResponse, error) {
+	mfa, err := s.repository.GetUserMFA(ctx, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Err(err).Str("email", user.Email).Msg("failed to get user mfa")
+		return nil, err
+	}
+
+	enroll := false
+	if mfa != nil && mfa.Enabled {
+		// A locked second factor gets no challenge, as it would with a
+		// locked password
+		if err := s.mfa.Check(ctx, user.ID.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		required, err := s.mfaRequired(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		enroll = true
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.CreateMFAChallenge(ctx, &repository.MFAChallenge{
+		Token:      token,
+		UserID:     user.ID,
+		Email:      user.Email,
+		UserRole:   user.Role,
+		SchoolID:   role.SchoolID,
+		SchoolRole: role.RoleID,
+		Enroll:     enroll,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.LoginResponse{
+		MFARequired:           !enroll,
+		MFAEnrollmentRequired: enroll,
+		MFAToken:              token,
+	}, nil
+}
+
+// mfaRequired reports whether any school the user is an admin or head
+// teacher of requires MFA, not only the school the user logs in to, since
+// the session can be switched to the others.
+func (s *service) mfaRequired(ctx context.Context, userID uuid.UUID) (bool, error) {
+	required, err := s.repository.GetUserMFARequired(ctx, userID, mfaRequiredRoles)
+	if err != nil {
+		log.Err(err).Str("user_id", userID.String()).Msg("failed to get school mfa setting")
+		return false, err
+	}
+
+	return required, nil
+}
+
+func (s *service) LoginMFA(ctx context.Context, data request.LoginMFARequest) (*response.LoginResponse, error) {
+	challenge, err := s.repository.GetMFAChallenge(ctx, data.MFAToken)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, commonError.ErrMFAChallengeExpired
+		}
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if challenge.Enroll {
+		recoveryCodes, err = s.enableMFA(ctx, challenge.UserID, data.Code)
+	} else {
+		err = s.verifySecondFactor(ctx, challenge.UserID, data.MFAVerifyRequest)
+	}
+	if err != nil {
+		if errors.Is(err, commonError.ErrInvalidMFACode) {
+			s.failMFAChallenge(ctx, challenge)
+		}
+		return nil, err
+	}
+
+	err = s.repository.DeleteMFAChallenge(ctx, challenge.Token)
+	if err != nil {
+		log.Err(err).Str("email", challenge.Email).Msg("failed to delete mfa challenge")
+	}
+
+	res, err := s.startSession(ctx, jwt.User{
+		ID:         challenge.UserID,
+		Email:      challenge.Email,
+		SchoolID:   challenge.SchoolID,
+		SchoolRole: challenge.SchoolRole,
+		UserRole:   challenge.UserRole,
+	}, data.UserAgent, data.IP, true)
+	if err != nil {
+		return nil, err
+	}
+
+	res.RecoveryCodes = recoveryCodes
+	return res, nil
+}
+
+func (s *service) failMFAChallenge(ctx context.Context, challenge *repository.MFAChallenge) {
+	challenge.Attempts++
+
+	var err error
+	if challenge.Attempts >= maxMFAAttempts {
+		err = s.repository.DeleteMFAChallenge(ctx, challenge.Token)
+	} else {
+		err = s.repository.UpdateMFAChallenge(ctx, challenge)
+	}
+	if err != nil {
+		log.Err(err).Str("email", challenge.Email).Msg("failed to update mfa challenge")
+	}
+}
+
+// EnrollMFA starts enrollment for the authenticated user.
+func (s *service) EnrollMFA(ctx context.Context) (*response.MFAEnrollResponse, error) {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.enroll(ctx, claim.User.ID, claim.User.Email)
+}
+
+// EnrollMFAWithChallenge starts enrollment for a user whose school requires
+// MFA, before they hold any token.
+func (s *service) EnrollMFAWithChallenge(ctx context.Context, data request.MFATokenRequest) (*response.MFAEnrollResponse, error) {
+	challenge, err := s.repository.GetMFAChallenge(ctx, data.MFAToken)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, commonError.ErrMFAChallengeExpired
+		}
+		return nil, err
+	}
+
+	if !challenge.Enroll {
+		return nil, commonError.ErrMFAAlreadyEnabled
+	}
+
+	return s.enroll(ctx, challenge.UserID, challenge.Email)
+}
+
+func (s *service) enroll(ctx context.Context, userID uuid.UUID, email string) (*response.MFAEnrollResponse, error) {
+	mfa, err := s.repository.GetUserMFA(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, commonError.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.SaveUserMFASecret(ctx, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := s.config.App.Name
+	if issuer == "" {
+		issuer = "Genesis"
+	}
+
+	return &response.MFAEnrollResponse{
+		Secret: secret,
+		URI:    totp.URI(issuer, email, secret),
+	}, nil
+}
+
+// VerifyMFAEnrollment confirms the pending secret with a first code and
+// returns the recovery codes, which are only ever shown here.
+func (s *service) VerifyMFAEnrollment(ctx context.Context, data request.MFACodeRequest) (*response.RecoveryCodesResponse, error) {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.enableMFA(ctx, claim.User.ID, data.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verifySessionMFA(ctx, claim.Sid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// VerifyMFA steps up the current session with a second factor, which
+// switching into a school that requires MFA needs.
+func (s *service) VerifyMFA(ctx context.Context, data request.MFAVerifyRequest) error {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.verifySecondFactor(ctx, claim.User.ID, data)
+	if err != nil {
+		return err
+	}
+
+	return s.verifySessionMFA(ctx, claim.Sid)
+}
+
+func (s *service) verifySessionMFA(ctx context.Context, sid string) error {
+	sess, err := s.sessions.Get(ctx, sid)
+	if err != nil {
+		return err
+	}
+
+	err = s.sessions.VerifyMFA(ctx, sess)
+	if err != nil {
+		log.Err(err).Str("session_id", sid).Msg("failed to verify session mfa")
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) enableMFA(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := s.repository.GetUserMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, commonError.ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, commonError.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, commonError.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.EnableUserMFA(ctx, userID, step, hashes)
+	if err != nil {
+		log.Err(err).Str("user_id", userID.String()).Msg("failed to enable mfa")
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, data request.MFACodeRequest) (*response.RecoveryCodesResponse, error) {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verifySecondFactor(ctx, claim.User.ID, request.MFAVerifyRequest{Code: data.Code})
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.ReplaceRecoveryCodes(ctx, claim.User.ID, hashes)
+	if err != nil {
+		log.Err(err).Str("user_id", claim.User.ID.String()).Msg("failed to replace recovery codes")
+		return nil, err
+	}
+
+	return &response.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *service) DisableMFA(ctx context.Context, data request.MFAVerifyRequest) error {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	required, err := s.mfaRequired(ctx, claim.User.ID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return commonError.ErrMFARequired
+	}
+
+	err = s.verifySecondFactor(ctx, claim.User.ID, data)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.DeleteUserMFA(ctx, claim.User.ID)
+	if err != nil {
+		log.Err(err).Str("user_id", claim.User.ID.String()).Msg("failed to disable mfa")
+		return err
+	}
+
+	return nil
+}
+
+// verifySecondFactor accepts a TOTP code that was not used before or an
+// unused recovery code, which is consumed. Wrong codes count per user
+// across challenges and sessions, so new password logins do not reset the
+// guesses, and lock the second factor like wrong passwords lock the login.
+func (s *service) verifySecondFactor(ctx context.Context, userID uuid.UUID, data request.MFAVerifyRequest) error {
+	id := userID.String()
+	if err := s.mfa.Check(ctx, id); err != nil {
+		return err
+	}
+
+	err := s.checkSecondFactor(ctx, userID, data)
+	if err == nil {
+		if err := s.mfa.Reset(ctx, id); err != nil {
+			log.Err(err).Str("user_id", id).Msg("failed to reset mfa attempts")
+		}
+		return nil
+	}
+
+	if errors.Is(err, commonError.ErrInvalidMFACode) {
+		locked, failErr := s.mfa.Fail(ctx, id)
+		if failErr != nil {
+			log.Err(failErr).Str("user_id", id).Msg("failed to record mfa attempt")
+		}
+		if locked {
+			log.Warn().Str("user_id", id).Msg("second factor locked after failed codes")
+		}
+	}
+	return err
+}
+
+func (s *service) checkSecondFactor(ctx context.Context, userID uuid.UUID, data request.MFAVerifyRequest) error {
+	mfa, err := s.repository.GetUserMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return commonError.ErrMFANotEnabled
+		}
+		return err
+	}
+	if !mfa.Enabled {
+		return commonError.ErrMFANotEnabled
+	}
+
+	if data.Code != "" {
+		step, ok := totp.Validate(mfa.Secret, data.Code, time.Now())
+		if !ok {
+			return commonError.ErrInvalidMFACode
+		}
+
+		fresh, err := s.repository.UseMFAStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return commonError.ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	codes, err := s.repository.GetUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	recoveryCode := normalizeRecoveryCode(data.RecoveryCode)
+	for _, code := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(code.CodeHash), []byte(recoveryCode)) != nil {
+			continue
+		}
+
+		used, err := s.repository.UseRecoveryCode(ctx, code.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return commonError.ErrInvalidMFACode
+		}
+
+		log.Info().Str("user_id", userID.String()).Msg("recovery code used")
+		return nil
+	}
+
+	return commonError.ErrInvalidMFACode
+}
+
+// newRecoveryCodes returns the codes shown to the user and their bcrypt
+// hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+
+	for range recoveryCodeCount {
+		b := make([]byte, 10)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, nil, err
+			}
+			b[i] = recoveryCodeAlphabet[n.Int64()]
+		}
+
+		hash, err := bcrypt.GenerateFromPassword(b, bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes = append(codes, string(b[:5])+"-"+string(b[5:]))
+		hashes = append(hashes, string(hash))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"enuma-elish/internal/auth/repository"
+	"enuma-elish/internal/auth/service/data/request"
+	"enuma-elish/internal/auth/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/oidc"
+	"errors"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// OIDCLogin starts an authorization code flow with PKCE and returns the
+// provider URL the frontend redirects the user to.
+func (s *service) OIDCLogin(ctx context.Context, provider string) (*response.OIDCLoginResponse, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, commonError.ErrUnknownOIDCProvider
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Err(err).Str("provider", provider).Msg("failed to build authorization url")
+		return nil, err
+	}
+
+	err = s.repository.CreateOIDCState(ctx, state, &repository.OIDCState{
+		Provider: provider,
+		Nonce:    nonce,
+		Verifier: verifier,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.OIDCLoginResponse{AuthorizationURL: authURL}, nil
+}
+
+// OIDCCallback finishes the flow. A known identity logs its user in; an
+// unknown one is linked to the user with the same, provider verified, email.
+// Users are never created here, they still come from registration or invites.
+func (s *service) OIDCCallback(ctx context.Context, provider string, data request.OIDCCallbackRequest) (*response.LoginResponse, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, commonError.ErrUnknownOIDCProvider
+	}
+
+	state, err := s.repository.ConsumeOIDCState(ctx, data.State)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, commonError.ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	if state.Provider != provider {
+		return nil, commonError.ErrInvalidOIDCState
+	}
+
+	token, err := p.Exchange(ctx, data.Code, state.Verifier)
+	if err != nil {
+		log.Err(err).Str("provider", provider).Msg("failed to exchange authorization code")
+		return nil, err
+	}
+
+	claims, err := p.Verify(ctx, token.IDToken, state.Nonce)
+	if err != nil {
+		log.Err(err).Str("provider", provider).Msg("invalid id token")
+		return nil, err
+	}
+
+	if !s.oidcEmailAllowed(provider, claims) {
+		log.Warn().Str("provider", provider).Str("email", claims.Email).Msg("oidc email not allowed")
+		return nil, commonError.ErrOIDCEmailNotAllowed
+	}
+
+	user, err := s.identityUser(ctx, provider, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loginUser(ctx, user, data.UserAgent, data.IP)
+}
+
+func (s *service) oidcEmailAllowed(provider string, claims *oidc.Claims) bool {
+	if claims.Email == "" || !claims.EmailVerified {
+		return false
+	}
+
+	domains := s.config.OIDC[provider].AllowedDomains
+	if len(domains) == 0 {
+		return true
+	}
+
+	domain := strings.ToLower(claims.Email[strings.LastIndex(claims.Email, "@")+1:])
+	for _, d := range domains {
+		if strings.ToLower(d) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *service) identityUser(ctx context.Context, provider string, claims *oidc.Claims) (*repository.User, error) {
+	identity, err := s.repository.GetUserIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		if err := s.repository.TouchUserIdentity(ctx, identity.ID, claims.Email); err != nil {
+			log.Err(err).Str("identity_id", identity.ID.String()).Msg("failed to update identity")
+		}
+		return s.repository.GetUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Err(err).Str("provider", provider).Msg("failed to get user identity")
+		return nil, err
+	}
+
+	user, err := s.repository.GetUserByEmail(ctx, claims.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, commonError.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	err = s.repository.CreateUserIdentity(ctx, &repository.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Str("provider", provider).Msg("linked oidc identity")
+	return user, nil
+}
+
+func (s *service) ListIdentities(ctx context.Context) ([]response.IdentityResponse, error) {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := s.repository.ListUserIdentities(ctx, claim.User.ID)
+	if err != nil {
+		log.Err(err).Str("user_id", claim.User.ID.String()).Msg("failed to list identities")
+		return nil, err
+	}
+
+	res := make([]response.IdentityResponse, 0, len(identities))
+	for _, identity := range identities {
+		res = append(res, response.IdentityResponse{
+			ID:          identity.ID,
+			Provider:    identity.Provider,
+			Email:       identity.Email,
+			LastLoginAt: identity.LastLoginAt,
+			CreatedAt:   identity.CreatedAt,
+		})
+	}
+
+	return res, nil
+}
+
+func (s *service) UnlinkIdentity(ctx context.Context, id uuid.UUID) error {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.repository.DeleteUserIdentity(ctx, claim.User.ID, id)
+	if err != nil {
+		log.Err(err).Str("identity_id", id.String()).Msg("failed to unlink identity")
+		return err
+	}
+	if !deleted {
+		return commonError.ErrIdentityNotFound
+	}
+
+	return nil
+}
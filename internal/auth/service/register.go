@@ -6,9 +6,10 @@ import (
 	"enuma-elish/internal/auth/repository"
 	"enuma-elish/internal/auth/service/data/request"
 	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/mailer"
 	"errors"
 	"fmt"
-	"net/smtp"
+	"net/url"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
@@ -47,13 +48,18 @@ func (s *service) Register(ctx context.Context, data request.Register) error {
 		return err
 	}
 
-	go func() {
-		verifyUrl := fmt.Sprintf("%s/email-verification?token=%s&email=%s&type=registration", s.config.Http.FrontendHost, user.Token, data.Email)
-		err = s.sendEmail(user.Email, verifyUrl, "verify email")
-		if err != nil {
-			log.Err(err).Msg("Failed to send email")
-		}
-	}()
+	err = s.mail.Enqueue(ctx, mailer.Email{
+		To:       user.Email,
+		Template: mailer.TemplateVerifyEmail,
+		Data: mailer.Data{
+			Name: data.Name,
+			URL:  fmt.Sprintf("%s/email-verification?token=%s&email=%s&type=registration", s.config.Http.FrontendHost, user.Token, url.QueryEscape(data.Email)),
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Str("email", data.Email).Msg("failed to enqueue verification email")
+		return err
+	}
 
 	return nil
 }
@@ -63,20 +69,6 @@ func hashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
-func (s *service) sendEmail(to string, msg, subject string) error {
-	auth := smtp.PlainAuth("", s.config.SMTP.Username, s.config.SMTP.Password, s.config.SMTP.Host)
-	message := []byte(fmt.Sprintf("Subject: %s\r\n\r\n%s", subject, msg))
-
-	addr := fmt.Sprintf("%s:%d", s.config.SMTP.Host, s.config.SMTP.Port)
-	err := smtp.SendMail(addr, auth, s.config.SMTP.Username, []string{to}, message)
-	if err != nil {
-		log.Err(err).Msg("Failed to send email")
-		return err
-	}
-
-	return nil
-}
-
 func (s *service) VerifyEmail(ctx context.Context, data request.VerifyEmailRequest) error {
 	u, err := s.repository.VerifyEmailToken(ctx, data.Email)
 	if err != nil {
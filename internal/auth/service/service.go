@@ -8,6 +8,11 @@ import (
 	"enuma-elish/internal/auth/service/data/request"
 	"enuma-elish/internal/auth/service/data/response"
 	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/mailer"
+	"enuma-elish/pkg/oidc"
+	"enuma-elish/pkg/ratelimit"
+	"enuma-elish/pkg/session"
 	"errors"
 	"fmt"
 	"time"
@@ -24,17 +29,48 @@ type Service interface {
 	ForgotPasswordVerify(ctx context.Context, data request.ForgotPasswordVerifyRequest) error
 	RefreshToken(ctx context.Context, data request.RefreshTokenRequest) (*response.LoginResponse, error)
 	UpdateUser(ctx context.Context, data request.UpdateUserRequest) (*response.UserResponse, error)
+	Logout(ctx context.Context) error
+	ListSessions(ctx context.Context) ([]response.SessionResponse, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeAllSessions(ctx context.Context) error
+	JWKS(ctx context.Context) (*jwt.JWKS, error)
+	LoginMFA(ctx context.Context, data request.LoginMFARequest) (*response.LoginResponse, error)
+	EnrollMFA(ctx context.Context) (*response.MFAEnrollResponse, error)
+	EnrollMFAWithChallenge(ctx context.Context, data request.MFATokenRequest) (*response.MFAEnrollResponse, error)
+	VerifyMFAEnrollment(ctx context.Context, data request.MFACodeRequest) (*response.RecoveryCodesResponse, error)
+	VerifyMFA(ctx context.Context, data request.MFAVerifyRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, data request.MFACodeRequest) (*response.RecoveryCodesResponse, error)
+	DisableMFA(ctx context.Context, data request.MFAVerifyRequest) error
+	UnlockAccount(ctx context.Context, data request.UnlockAccountRequest) error
+	OIDCLogin(ctx context.Context, provider string) (*response.OIDCLoginResponse, error)
+	OIDCCallback(ctx context.Context, provider string, data request.OIDCCallbackRequest) (*response.LoginResponse, error)
+	ListIdentities(ctx context.Context) ([]response.IdentityResponse, error)
+	UnlinkIdentity(ctx context.Context, id uuid.UUID) error
 }
 
 type service struct {
 	repository repository.Repository
 	config     *config.Config
+	sessions   *session.Store
+	keys       *jwt.KeySet
+	login      *ratelimit.Guard
+	forgot     *ratelimit.Guard
+	mfa        *ratelimit.Guard
+	providers  map[string]*oidc.Provider
+	mail       *mailer.Sender
 }
 
-func New(r repository.Repository, c *config.Config) Service {
+func New(r repository.Repository, c *config.Config, sessions *session.Store, keys *jwt.KeySet, limiter *ratelimit.Limiter, providers map[string]*oidc.Provider, mail *mailer.Sender) Service {
 	return &service{
 		repository: r,
 		config:     c,
+		sessions:   sessions,
+		keys:       keys,
+		login:      limiter.Guard("login"),
+		forgot:     limiter.Guard("forgot_password"),
+		mfa:        limiter.Guard("mfa"),
+		providers:  providers,
+		mail:       mail,
 	}
 }
 
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"enuma-elish/internal/auth/service/data/response"
+	"enuma-elish/pkg/jwt"
+
+	"github.com/rs/zerolog/log"
+)
+
+func (s *service) Logout(ctx context.Context) error {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.sessions.Revoke(ctx, claim.User.ID, claim.Sid)
+	if err != nil {
+		log.Err(err).Str("session_id", claim.Sid).Msg("failed to revoke session")
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) ListSessions(ctx context.Context) ([]response.SessionResponse, error) {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessions.List(ctx, claim.User.ID)
+	if err != nil {
+		log.Err(err).Str("user_id", claim.User.ID.String()).Msg("failed to list sessions")
+		return nil, err
+	}
+
+	res := make([]response.SessionResponse, 0, len(sessions))
+	for _, sess := range sessions {
+		res = append(res, response.SessionResponse{
+			ID:         sess.ID,
+			UserAgent:  sess.UserAgent,
+			IP:         sess.IP,
+			CreatedAt:  sess.CreatedAt,
+			LastSeenAt: sess.LastSeenAt,
+			ExpiresAt:  sess.ExpiresAt,
+			Current:    sess.ID == claim.Sid,
+		})
+	}
+
+	return res, nil
+}
+
+func (s *service) RevokeSession(ctx context.Context, sessionID string) error {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.sessions.Revoke(ctx, claim.User.ID, sessionID)
+	if err != nil {
+		log.Err(err).Str("session_id", sessionID).Msg("failed to revoke session")
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) RevokeAllSessions(ctx context.Context) error {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.sessions.RevokeAll(ctx, claim.User.ID)
+	if err != nil {
+		log.Err(err).Str("user_id", claim.User.ID.String()).Msg("failed to revoke sessions")
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) JWKS(ctx context.Context) (*jwt.JWKS, error) {
+	keys, err := s.keys.JWKS(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to load jwks")
+		return nil, err
+	}
+
+	return keys, nil
+}
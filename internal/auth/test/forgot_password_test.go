@@ -0,0 +1,75 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"enuma-elish/internal/auth/repository"
+	"enuma-elish/internal/auth/service/data/request"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestForgotPasswordGuessesDoNotLockOutUser(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	ctx := context.Background()
+	userID := uuid.New()
+	email := "forgot-" + userID.String() + "@gmail.com"
+
+	db := testInfra.Postgres
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM users WHERE id = $1", userID)
+		testInfra.Redis.Del(ctx, repository.ForgotPasswordTokenKey+":"+email)
+	})
+
+	_, err := db.Exec("INSERT INTO users (id, email, name, password, is_verified, created_by) VALUES ($1, $2, 'Forgot', '', true, $1)", userID, email)
+	if err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	token := uuid.New().String()
+	value, err := json.Marshal(repository.UserForgotPasswordToken{Email: email, Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testInfra.Redis.Set(ctx, repository.ForgotPasswordTokenKey+":"+email, value, time.Minute).Err(); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+
+	verify := func(ip, token string) int {
+		header := make(http.Header)
+		header.Set("X-Forwarded-For", ip)
+		return commonHttp.NewHttpClient().
+			SetUrl(server.URL + "/api/v1/auth/forgot-password/verify").
+			SetMethod(http.MethodPost).
+			SetHeader(header).
+			SetJsonHeader().
+			SetRequestBody(&request.ForgotPasswordVerifyRequest{Email: email, Token: token, NewPassword: "new-password"}).
+			Do().
+			UnmarshalResponse(commonHttp.NewResponse()).
+			Status()
+	}
+
+	// Someone who knows the email guesses until they are throttled
+	attacker := "203.0.113.7"
+	throttled := false
+	for i := 0; i <= testInfra.RateLimit.Config().LockoutAfter; i++ {
+		if verify(attacker, uuid.New().String()) == http.StatusTooManyRequests {
+			throttled = true
+			break
+		}
+	}
+	if !throttled {
+		t.Fatalf("expected the guesses to be throttled")
+	}
+
+	if status := verify("198.51.100.9", token); status != http.StatusOK {
+		t.Fatalf("expected status 200 OK resetting from another address, got %d", status)
+	}
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"enuma-elish/internal/auth/service/data/request"
+	"enuma-elish/internal/auth/service/data/response"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogout(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	reqBody := request.LoginRequest{
+		Email:    "admin@gmail.com",
+		Password: "12345678",
+	}
+
+	token := &response.LoginResponse{}
+	loginResponse := commonHttp.NewResponse().SetData(token)
+	httpClient := commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/login").
+		SetMethod(http.MethodPost).
+		SetJsonHeader().
+		SetRequestBody(&reqBody).
+		Do().
+		UnmarshalResponse(loginResponse)
+
+	if httpClient.Error() != nil {
+		t.Fatalf("http response error: %v", httpClient.Error())
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/logout").
+		SetMethod(http.MethodPost).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", httpClient.Status())
+	}
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/me").
+		SetMethod(http.MethodGet).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 Unauthorized after logout, got %d", httpClient.Status())
+	}
+
+	refreshBody := request.RefreshTokenRequest{RefreshToken: token.RefreshToken}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/refresh-token").
+		SetMethod(http.MethodPost).
+		SetJsonHeader().
+		SetRequestBody(&refreshBody).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 Unauthorized for revoked refresh token, got %d", httpClient.Status())
+	}
+}
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"enuma-elish/internal/auth/service/data/request"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestLoginThrottled(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	reqBody := request.LoginRequest{
+		Email:    uuid.New().String() + "@gmail.com",
+		Password: "wrong-password",
+	}
+
+	login := func() int {
+		httpClient := commonHttp.NewHttpClient().
+			SetUrl(server.URL + "/api/v1/auth/login").
+			SetMethod(http.MethodPost).
+			SetJsonHeader().
+			SetRequestBody(&reqBody).
+			Do().
+			UnmarshalResponse(commonHttp.NewResponse())
+		return httpClient.Status()
+	}
+
+	for i := 0; i < testInfra.RateLimit.Config().DelayAfter; i++ {
+		if status := login(); status == http.StatusTooManyRequests {
+			t.Fatalf("attempt %d throttled too early", i+1)
+		}
+	}
+
+	if status := login(); status != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 Too Many Requests, got %d", status)
+	}
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"enuma-elish/internal/auth/service/data/request"
+	"enuma-elish/internal/auth/service/data/response"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func refreshToken(t *testing.T, url, token string) (*response.LoginResponse, int) {
+	t.Helper()
+
+	reqBody := request.RefreshTokenRequest{RefreshToken: token}
+	tokens := &response.LoginResponse{}
+	httpClient := commonHttp.NewHttpClient().
+		SetUrl(url + "/api/v1/auth/refresh-token").
+		SetMethod(http.MethodPost).
+		SetJsonHeader().
+		SetRequestBody(&reqBody).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(tokens))
+
+	return tokens, httpClient.Status()
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	reqBody := request.LoginRequest{
+		Email:    "admin@gmail.com",
+		Password: "12345678",
+	}
+
+	login := &response.LoginResponse{}
+	httpClient := commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/login").
+		SetMethod(http.MethodPost).
+		SetJsonHeader().
+		SetRequestBody(&reqBody).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(login))
+
+	if httpClient.Error() != nil {
+		t.Fatalf("http response error: %v", httpClient.Error())
+	}
+
+	if _, status := refreshToken(t, server.URL, login.AccessToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 Unauthorized for access token, got %d", status)
+	}
+
+	rotated, status := refreshToken(t, server.URL, login.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", status)
+	}
+
+	if _, status := refreshToken(t, server.URL, login.RefreshToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 Unauthorized for replayed refresh token, got %d", status)
+	}
+
+	if _, status := refreshToken(t, server.URL, rotated.RefreshToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 Unauthorized after reuse revoked the session, got %d", status)
+	}
+}
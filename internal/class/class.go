@@ -33,21 +33,21 @@ func (cl *Class) Init() {
 	s := service.New(r, cl.c)
 	h := handler.New(s, cl.v)
 
-	authMiddleware := middleware.Auth(cl.c.JWT.Secret)
+	authMiddleware := middleware.Auth(cl.i.Keys, cl.i.Session, cl.i.APIKeys)
 
-	v1 := cl.Group("/api/v1/class").Use(authMiddleware)
-	v1.POST("", h.CreateClass)
-	v1.GET("", h.ListClass)
-	v1.GET("/:class_id", h.GetDetailClass)
-	v1.PUT("/:class_id", h.UpdateClass)
-	v1.DELETE("/:class_id", h.DeleteClass)
-	v1.POST("/add-students", h.AddStudentsToClass)
-	v1.POST("/assign-teachers", h.AddTeachersToClass)
-	v1.POST("/add-subjects", h.AddSubjectsToClass)
-	v1.GET("/:class_id/students", h.GetStudentsByClass)
-	v1.GET("/:class_id/teachers", h.GetTeachersByClass)
-	v1.GET("/:class_id/subjects", h.GetSubjectsByClass)
-	v1.DELETE("/teacher", h.RemoveTeachersFromClass)
-	v1.DELETE("/student", h.RemoveStudentsFromClass)
-	v1.DELETE("/subject", h.RemoveSubjectsFromClass)
+	v1 := cl.Group("/api/v1/class").Use(middleware.AllowAPIKey(), authMiddleware)
+	v1.POST("", middleware.RequirePermission(middleware.PermClassManage), h.CreateClass)
+	v1.GET("", middleware.RequirePermission(middleware.PermClassRead), h.ListClass)
+	v1.GET("/:class_id", middleware.RequirePermission(middleware.PermClassRead), h.GetDetailClass)
+	v1.PUT("/:class_id", middleware.RequirePermission(middleware.PermClassManage), h.UpdateClass)
+	v1.DELETE("/:class_id", middleware.RequirePermission(middleware.PermClassManage), h.DeleteClass)
+	v1.POST("/add-students", middleware.RequirePermission(middleware.PermClassManage), h.AddStudentsToClass)
+	v1.POST("/assign-teachers", middleware.RequirePermission(middleware.PermClassManage), h.AddTeachersToClass)
+	v1.POST("/add-subjects", middleware.RequirePermission(middleware.PermClassManage), h.AddSubjectsToClass)
+	v1.GET("/:class_id/students", middleware.RequirePermission(middleware.PermClassRead), h.GetStudentsByClass)
+	v1.GET("/:class_id/teachers", middleware.RequirePermission(middleware.PermClassRead), h.GetTeachersByClass)
+	v1.GET("/:class_id/subjects", middleware.RequirePermission(middleware.PermClassRead), h.GetSubjectsByClass)
+	v1.DELETE("/teacher", middleware.RequirePermission(middleware.PermClassManage), h.RemoveTeachersFromClass)
+	v1.DELETE("/student", middleware.RequirePermission(middleware.PermClassManage), h.RemoveStudentsFromClass)
+	v1.DELETE("/subject", middleware.RequirePermission(middleware.PermClassManage), h.RemoveSubjectsFromClass)
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"enuma-elish/internal/class/service/data/request"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/event"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/tenant"
 	"fmt"
 	"time"
 
@@ -130,15 +133,22 @@ func New(db *sqlx.DB) Repository {
 }
 
 func (r *repository) CreateClass(ctx context.Context, class Class) error {
+	schoolID, err := tenant.Scope(ctx, class.SchoolID)
+	if err != nil {
+		return err
+	}
+	class.SchoolID = schoolID
+
 	query := `INSERT INTO class (id, school_id, name, created_at, created_by, updated_at) 
 			  VALUES (:id, :school_id, :name, :created_at, :created_by, :updated_at)`
-	_, err := r.db.NamedExecContext(ctx, query, class)
+	_, err = r.db.NamedExecContext(ctx, query, class)
 	return err
 }
 
 func (r *repository) GetClassByID(ctx context.Context, classID uuid.UUID) (*Class, error) {
 	class := &Class{}
-	err := r.db.GetContext(ctx, class, "SELECT * FROM class WHERE id = $1", classID)
+	query := "SELECT * FROM class WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2)"
+	err := r.db.GetContext(ctx, class, query, classID, tenant.Filter(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -146,11 +156,16 @@ func (r *repository) GetClassByID(ctx context.Context, classID uuid.UUID) (*Clas
 }
 
 func (r *repository) GetListClasses(ctx context.Context, httpQuery request.GetListClassQuery) ([]Class, int, error) {
+	schoolID, err := tenant.ScopeString(ctx, httpQuery.SchoolID)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var classes []Class
 	selectQuery := "SELECT * FROM class WHERE school_id = $1"
 	countQuery := "SELECT COUNT(*) FROM class WHERE school_id = $1"
 
-	filterParams := []interface{}{httpQuery.SchoolID}
+	filterParams := []interface{}{schoolID}
 	filterQuery := ""
 
 	if httpQuery.Search != "" {
@@ -175,7 +190,7 @@ func (r *repository) GetListClasses(ctx context.Context, httpQuery request.GetLi
 	limitParams := []interface{}{httpQuery.PageSize, httpQuery.GetOffset()}
 	selectParams := append(filterParams, limitParams...)
 
-	err := r.db.SelectContext(ctx, &classes, selectQuery+filterQuery+orderQuery, selectParams...)
+	err = r.db.SelectContext(ctx, &classes, selectQuery+filterQuery+orderQuery, selectParams...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -190,17 +205,29 @@ func (r *repository) GetListClasses(ctx context.Context, httpQuery request.GetLi
 }
 
 func (r *repository) UpdateClass(ctx context.Context, class Class) error {
+	if err := r.checkClassTenant(ctx, class.ID); err != nil {
+		return err
+	}
+
 	query := `UPDATE class SET name = :name, updated_at = :updated_at, updated_by = :updated_by WHERE id = :id`
 	_, err := r.db.NamedExecContext(ctx, query, class)
 	return err
 }
 
 func (r *repository) DeleteClass(ctx context.Context, classID uuid.UUID) error {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return err
+	}
+
 	_, err := r.db.ExecContext(ctx, "DELETE FROM class WHERE id = $1", classID)
 	return err
 }
 
 func (r *repository) AddStudentsToClass(ctx context.Context, classID uuid.UUID, studentIDs []uuid.UUID) error {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -215,6 +242,11 @@ func (r *repository) AddStudentsToClass(ctx context.Context, classID uuid.UUID,
 		}
 	}()
 
+	err = checkClassMembers(ctx, tx, classID, studentIDs)
+	if err != nil {
+		return err
+	}
+
 	now := time.Now().UnixMilli()
 	var classStudents []ClassStudent
 
@@ -237,6 +269,13 @@ func (r *repository) AddStudentsToClass(ctx context.Context, classID uuid.UUID,
 		return err
 	}
 
+	err = r.writeMembersEvent(ctx, tx, classID, func(schoolID uuid.UUID) event.Event {
+		return event.ClassMembersAdded{ClassID: classID, SchoolID: schoolID, Role: event.RoleStudent, UserIDs: studentIDs}
+	})
+	if err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return err
@@ -247,6 +286,9 @@ func (r *repository) AddStudentsToClass(ctx context.Context, classID uuid.UUID,
 }
 
 func (r *repository) AddTeachersToClass(ctx context.Context, classID uuid.UUID, teacherIDs []uuid.UUID) error {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return err
+	}
 
 	jwtClaims, err := jwt.ExtractContext(ctx)
 	if err != nil {
@@ -268,6 +310,11 @@ func (r *repository) AddTeachersToClass(ctx context.Context, classID uuid.UUID,
 		}
 	}()
 
+	err = checkClassMembers(ctx, tx, classID, teacherIDs)
+	if err != nil {
+		return err
+	}
+
 	now := time.Now().UnixMilli()
 	var classTeachers []ClassTeacher
 
@@ -291,6 +338,13 @@ func (r *repository) AddTeachersToClass(ctx context.Context, classID uuid.UUID,
 		return err
 	}
 
+	err = r.writeMembersEvent(ctx, tx, classID, func(schoolID uuid.UUID) event.Event {
+		return event.ClassMembersAdded{ClassID: classID, SchoolID: schoolID, Role: event.RoleTeacher, UserIDs: teacherIDs}
+	})
+	if err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return err
@@ -301,6 +355,10 @@ func (r *repository) AddTeachersToClass(ctx context.Context, classID uuid.UUID,
 }
 
 func (r *repository) AddSubjectsToClass(ctx context.Context, classID uuid.UUID, subjectIDs []uuid.UUID) error {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -347,6 +405,10 @@ func (r *repository) AddSubjectsToClass(ctx context.Context, classID uuid.UUID,
 }
 
 func (r *repository) GetStudentsByClassID(ctx context.Context, classID uuid.UUID, query request.GetStudentsByClassQuery) ([]Student, int, error) {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return nil, 0, err
+	}
+
 	baseQuery := `
 		SELECT u.id, u.name, u.email, u.is_verified, u.created_at, u.updated_at
 		FROM users u
@@ -379,6 +441,10 @@ func (r *repository) GetStudentsByClassID(ctx context.Context, classID uuid.UUID
 }
 
 func (r *repository) GetTeachersByClassID(ctx context.Context, classID uuid.UUID, query request.GetTeachersByClassQuery) ([]Teacher, int, error) {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return nil, 0, err
+	}
+
 	baseQuery := `
 		SELECT u.id, u.name, u.email, u.is_verified, u.created_at, u.updated_at
 		FROM users u
@@ -411,6 +477,10 @@ func (r *repository) GetTeachersByClassID(ctx context.Context, classID uuid.UUID
 }
 
 func (r *repository) GetSubjectsByClassID(ctx context.Context, classID uuid.UUID, query request.GetSubjectsByClassQuery) ([]Subject, int, error) {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return nil, 0, err
+	}
+
 	baseQuery := `
 		SELECT s.id, s.name, s.school_id, s.created_at, s.updated_at
 		FROM subject s
@@ -443,24 +513,92 @@ func (r *repository) GetSubjectsByClassID(ctx context.Context, classID uuid.UUID
 }
 
 func (r *repository) RemoveTeachersFromClass(ctx context.Context, classID uuid.UUID, teacherIDs []uuid.UUID) error {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
 	query := `DELETE FROM class_teacher WHERE class_id = $1 AND teacher_id = ANY($2)`
-	_, err := r.db.ExecContext(ctx, query, classID, pq.Array(teacherIDs))
+	_, err = tx.ExecContext(ctx, query, classID, pq.Array(teacherIDs))
+	if err != nil {
+		return err
+	}
+
+	err = r.writeMembersEvent(ctx, tx, classID, func(schoolID uuid.UUID) event.Event {
+		return event.ClassMembersRemoved{ClassID: classID, SchoolID: schoolID, Role: event.RoleTeacher, UserIDs: teacherIDs}
+	})
 	if err != nil {
 		return err
 	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	committed = true
+
 	return nil
 }
 
 func (r *repository) RemoveStudentsFromClass(ctx context.Context, classID uuid.UUID, studentIDs []uuid.UUID) error {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
 	query := `DELETE FROM class_student WHERE class_id = $1 AND student_id = ANY($2)`
-	_, err := r.db.ExecContext(ctx, query, classID, pq.Array(studentIDs))
+	_, err = tx.ExecContext(ctx, query, classID, pq.Array(studentIDs))
+	if err != nil {
+		return err
+	}
+
+	err = r.writeMembersEvent(ctx, tx, classID, func(schoolID uuid.UUID) event.Event {
+		return event.ClassMembersRemoved{ClassID: classID, SchoolID: schoolID, Role: event.RoleStudent, UserIDs: studentIDs}
+	})
 	if err != nil {
 		return err
 	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	committed = true
+
 	return nil
 }
 
 func (r *repository) RemoveSubjectsFromClass(ctx context.Context, classID uuid.UUID, subjectIDs []uuid.UUID) error {
+	if err := r.checkClassTenant(ctx, classID); err != nil {
+		return err
+	}
+
 	query := `DELETE FROM class_subject WHERE class_id = $1 AND subject_id = ANY($2)`
 	_, err := r.db.ExecContext(ctx, query, classID, pq.Array(subjectIDs))
 	if err != nil {
@@ -468,3 +606,42 @@ func (r *repository) RemoveSubjectsFromClass(ctx context.Context, classID uuid.U
 	}
 	return nil
 }
+
+// writeMembersEvent adds the membership event built for the class's school
+// to the outbox of tx.
+func (r *repository) writeMembersEvent(ctx context.Context, tx *sqlx.Tx, classID uuid.UUID, build func(schoolID uuid.UUID) event.Event) error {
+	var schoolID uuid.UUID
+	err := tx.GetContext(ctx, &schoolID, "SELECT school_id FROM class WHERE id = $1", classID)
+	if err != nil {
+		return err
+	}
+	return event.Write(ctx, tx, schoolID, build(schoolID))
+}
+
+// checkClassMembers returns ErrForbidden when one of the users holds no role
+// in the school of the class, so users of other schools cannot be added to it.
+func checkClassMembers(ctx context.Context, tx *sqlx.Tx, classID uuid.UUID, userIDs []uuid.UUID) error {
+	var outsiders int
+	query := `SELECT COUNT(*) FROM unnest($2::uuid[]) AS member(user_id)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM user_school_role usr
+			JOIN class c ON c.school_id = usr.school_id
+			WHERE c.id = $1 AND usr.user_id = member.user_id AND usr.is_deleted = false
+		)`
+	err := tx.GetContext(ctx, &outsiders, query, classID, pq.Array(userIDs))
+	if err != nil {
+		return err
+	}
+	if outsiders > 0 {
+		return commonError.ErrForbidden
+	}
+	return nil
+}
+
+// checkClassTenant returns sql.ErrNoRows when the class does not exist within
+// the caller's school.
+func (r *repository) checkClassTenant(ctx context.Context, classID uuid.UUID) error {
+	var id uuid.UUID
+	query := `SELECT id FROM class WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2)`
+	return r.db.GetContext(ctx, &id, query, classID, tenant.Filter(ctx))
+}
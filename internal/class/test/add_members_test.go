@@ -0,0 +1,113 @@
+package test
+
+import (
+	authRequest "enuma-elish/internal/auth/service/data/request"
+	authResponse "enuma-elish/internal/auth/service/data/response"
+	"enuma-elish/internal/class/service/data/request"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAddMembersFromOtherSchool(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	// A class of one school, a teacher of that school and a teacher and a
+	// student of another
+	school, otherSchool, classID := uuid.New(), uuid.New(), uuid.New()
+	teacher, otherTeacher, otherStudent := uuid.New(), uuid.New(), uuid.New()
+	users := []uuid.UUID{teacher, otherTeacher, otherStudent}
+
+	db := testInfra.Postgres
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM class_teacher WHERE class_id = $1", classID)
+		db.Exec("DELETE FROM class_student WHERE class_id = $1", classID)
+		db.Exec("DELETE FROM class WHERE id = $1", classID)
+		for _, id := range users {
+			db.Exec("DELETE FROM user_school_role WHERE user_id = $1", id)
+		}
+		db.Exec("DELETE FROM school WHERE id IN ($1, $2)", school, otherSchool)
+		for _, id := range users {
+			db.Exec("DELETE FROM users WHERE id = $1", id)
+		}
+	})
+
+	for _, id := range users {
+		_, err := db.Exec("INSERT INTO users (id, email, name, password, created_by) VALUES ($1, $2, 'Member', '', $1)", id, "member-"+id.String()+"@gmail.com")
+		if err != nil {
+			t.Fatalf("seed user: %v", err)
+		}
+	}
+	seed := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO school (id, name, level, created_by) VALUES ($1, 'Class School', 'senior', $2)", []any{school, teacher}},
+		{"INSERT INTO school (id, name, level, created_by) VALUES ($1, 'Other School', 'senior', $2)", []any{otherSchool, teacher}},
+		{"INSERT INTO class (id, school_id, name, created_by) VALUES ($1, $2, 'X-1', $3)", []any{classID, school, teacher}},
+		{"INSERT INTO user_school_role (user_id, school_id, role_id, created_by) VALUES ($1, $2, 'teacher', $1)", []any{teacher, school}},
+		{"INSERT INTO user_school_role (user_id, school_id, role_id, created_by) VALUES ($1, $2, 'teacher', $1)", []any{otherTeacher, otherSchool}},
+		{"INSERT INTO user_school_role (user_id, school_id, role_id, created_by) VALUES ($1, $2, 'student', $1)", []any{otherStudent, otherSchool}},
+	}
+	for _, s := range seed {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	token := &authResponse.LoginResponse{}
+	httpClient := commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/login").
+		SetMethod(http.MethodPost).
+		SetJsonHeader().
+		SetRequestBody(&authRequest.LoginRequest{Email: "admin@gmail.com", Password: "12345678"}).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(token))
+
+	if httpClient.Error() != nil {
+		t.Fatalf("http response error: %v", httpClient.Error())
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	post := func(path string, body any) int {
+		return commonHttp.NewHttpClient().
+			SetUrl(server.URL + "/api/v1/class" + path).
+			SetMethod(http.MethodPost).
+			SetHeader(header).
+			SetJsonHeader().
+			SetRequestBody(body).
+			Do().
+			UnmarshalResponse(commonHttp.NewResponse()).
+			Status()
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body any
+		want int
+	}{
+		{"student of another school", "/add-students", &request.AddStudentToClassRequest{ClassID: classID, StudentIDs: []uuid.UUID{otherStudent}}, http.StatusForbidden},
+		{"teacher of another school", "/assign-teachers", &request.AddTeacherToClassRequest{ClassID: classID, TeacherIDs: []uuid.UUID{otherTeacher}}, http.StatusForbidden},
+		{"one teacher of another school", "/assign-teachers", &request.AddTeacherToClassRequest{ClassID: classID, TeacherIDs: []uuid.UUID{teacher, otherTeacher}}, http.StatusForbidden},
+		{"teacher of the school", "/assign-teachers", &request.AddTeacherToClassRequest{ClassID: classID, TeacherIDs: []uuid.UUID{teacher}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		if status := post(tt.path, tt.body); status != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, status)
+		}
+	}
+
+	var members int
+	err := db.Get(&members, "SELECT COUNT(*) FROM class_teacher WHERE class_id = $1 AND teacher_id = $2", classID, otherTeacher)
+	if err != nil || members != 0 {
+		t.Fatalf("expected no teacher of another school in the class, got %d, %v", members, err)
+	}
+}
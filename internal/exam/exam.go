@@ -30,25 +30,43 @@ func New(c *config.Config, i *infra.Infra, r *gin.Engine, v *validator.Validate)
 
 func (e *Exam) Init() {
 	r := repository.New(e.i.Postgres, e.i.Redis)
-	s := service.New(e.c, r)
+	s := service.New(e.c, r, e.i.Mail)
 	h := handler.New(s, e.v)
 
-	authMiddleware := middleware.Auth(e.c.JWT.Secret)
+	e.i.Scheduler.Register("exam.close_attempts", "* * * * *", s.CloseExpiredAttempts)
+	e.i.Scheduler.Register("exam.flush_drafts", "* * * * *", s.FlushDrafts)
 
-	v1 := e.Group("/api/v1/exam").Use(authMiddleware)
+	authMiddleware := middleware.Auth(e.i.Keys, e.i.Session, e.i.APIKeys)
 
-	v1.POST("", h.CreateExam)
-	v1.GET("", h.GetListExams)
-	v1.GET("/:exam_id", h.GetDetailExam)
-	v1.PUT("/:exam_id", h.UpdateExam)
-	v1.DELETE("/:exam_id", h.DeleteExam)
+	v1 := e.Group("/api/v1/exam").Use(middleware.AllowAPIKey(), authMiddleware)
 
-	v1.POST("/assign", h.AssignExamToClass)
-	v1.POST("/grade", h.GradeExam)
-	v1.GET("/:exam_id/students", h.GetExamStudents)
+	v1.POST("", middleware.RequirePermission(middleware.PermExamManage), h.CreateExam)
+	v1.GET("", middleware.RequirePermission(middleware.PermExamRead), h.GetListExams)
+	v1.GET("/:exam_id", middleware.RequirePermission(middleware.PermExamRead), h.GetDetailExam)
+	v1.PUT("/:exam_id", middleware.RequirePermission(middleware.PermExamManage), h.UpdateExam)
+	v1.DELETE("/:exam_id", middleware.RequirePermission(middleware.PermExamManage), h.DeleteExam)
 
-	studentV1 := e.Group("/api/v1/student/exam")
+	v1.POST("/assign", middleware.RequirePermission(middleware.PermExamManage), h.AssignExamToClass)
+	v1.POST("/grade", middleware.RequirePermission(middleware.PermExamGrade), h.GradeExam)
+	v1.POST("/grade/essay", middleware.RequirePermission(middleware.PermExamGrade), h.ScoreEssays)
+	v1.POST("/grade/feedback", middleware.RequirePermission(middleware.PermExamGrade), h.SetFeedback)
+	v1.POST("/:exam_id/release", middleware.RequirePermission(middleware.PermExamGrade), h.ReleaseGrades)
+	v1.DELETE("/:exam_id/release", middleware.RequirePermission(middleware.PermExamGrade), h.WithdrawGrades)
+	v1.GET("/:exam_id/students", middleware.RequirePermission(middleware.PermExamGrade), h.GetExamStudents)
+	v1.GET("/:exam_id/students/:student_id/attempts", middleware.RequirePermission(middleware.PermExamGrade), h.GetExamStudentAttempts)
+	v1.GET("/:exam_id/students/:student_id/integrity", middleware.RequirePermission(middleware.PermExamGrade), h.GetExamStudentIntegrity)
+	v1.GET("/:exam_id/students/:student_id/grade-history", middleware.RequirePermission(middleware.PermExamGrade), h.GetExamStudentGradeHistory)
+	v1.GET("/:exam_id/regrades", middleware.RequirePermission(middleware.PermExamGrade), h.GetRegradeRequests)
+	v1.POST("/regrades/:regrade_id/resolve", middleware.RequirePermission(middleware.PermExamGrade), h.ResolveRegradeRequest)
+
+	studentV1 := e.Group("/api/v1/student/exam").Use(authMiddleware, middleware.RequirePermission(middleware.PermExamTake))
 	studentV1.GET("", h.GetStudentExams)
 	studentV1.GET("/:exam_id", h.GetStudentExamDetail)
+	studentV1.POST("/:exam_id/start", h.StartExamAttempt)
+	studentV1.PUT("/:exam_id/draft", h.SaveDraft)
+	studentV1.POST("/:exam_id/integrity", h.ReportIntegrityEvents)
+	studentV1.GET("/:exam_id/attempts", h.GetStudentAttempts)
+	studentV1.POST("/:exam_id/regrades", h.CreateRegradeRequest)
+	studentV1.GET("/:exam_id/regrades", h.GetStudentRegradeRequests)
 	studentV1.POST("/submit", h.SubmitExamAnswers)
 }
@@ -3,7 +3,10 @@ package handler
 import (
 	"enuma-elish/internal/exam/service"
 	"enuma-elish/internal/exam/service/data/request"
+	commonError "enuma-elish/pkg/error"
 	commonHttp "enuma-elish/pkg/http"
+	"enuma-elish/pkg/jwt"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -232,6 +235,100 @@ func (h *Handler) GradeExam(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+func (h *Handler) SetFeedback(c *gin.Context) {
+	data := request.FeedbackRequest{}
+	err := c.ShouldBindJSON(&data)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(data); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = h.service.SetFeedback(c.Request.Context(), data)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("feedback saved successfully").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ReleaseGrades(c *gin.Context) {
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	err = h.service.ReleaseGrades(c.Request.Context(), examID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("exam grades released successfully")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) WithdrawGrades(c *gin.Context) {
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	err = h.service.WithdrawGrades(c.Request.Context(), examID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("exam grades withdrawn successfully")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ScoreEssays(c *gin.Context) {
+	data := request.ScoreEssaysRequest{}
+	err := c.ShouldBindJSON(&data)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(data); err != nil {
+		c.Error(err)
+		return
+	}
+
+	result, err := h.service.ScoreEssays(c.Request.Context(), data)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("essays scored successfully").
+		SetData(result)
+
+	c.JSON(http.StatusOK, response)
+}
+
 func (h *Handler) GetExamStudents(c *gin.Context) {
 	examIDStr := c.Param("exam_id")
 	examID, err := uuid.Parse(examIDStr)
@@ -274,14 +371,205 @@ func (h *Handler) GetExamStudents(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) SubmitExamAnswers(c *gin.Context) {
-	studentIDStr := c.GetString("user_id") // Assuming user_id is set by auth middleware
-	studentID, err := uuid.Parse(studentIDStr)
+func (h *Handler) GetExamStudentAttempts(c *gin.Context) {
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	studentID, err := uuid.Parse(c.Param("student_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data, err := h.service.GetExamStudentAttempts(c.Request.Context(), examID, studentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("get exam student attempts success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) GetExamStudentIntegrity(c *gin.Context) {
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	studentID, err := uuid.Parse(c.Param("student_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data, err := h.service.GetExamStudentIntegrity(c.Request.Context(), examID, studentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("get exam student integrity success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) GetExamStudentGradeHistory(c *gin.Context) {
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	studentID, err := uuid.Parse(c.Param("student_id"))
 	if err != nil {
 		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
+	data, err := h.service.GetExamStudentGradeHistory(c.Request.Context(), examID, studentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("get exam student grade history success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) GetRegradeRequests(c *gin.Context) {
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	httpQuery := request.GetRegradeRequestsQuery{}
+	httpQuery.Query = commonHttp.DefaultQuery()
+
+	err = c.BindQuery(&httpQuery)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(httpQuery); err != nil {
+		c.Error(err)
+		return
+	}
+
+	data, meta, err := h.service.GetRegradeRequests(c.Request.Context(), examID, httpQuery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("get regrade requests success").
+		SetData(data).
+		SetMeta(meta)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ResolveRegradeRequest(c *gin.Context) {
+	regradeID, err := uuid.Parse(c.Param("regrade_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data := request.ResolveRegradeRequest{}
+	err = c.ShouldBindJSON(&data)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	data.RegradeID = regradeID
+
+	if err := h.validator.Struct(data); err != nil {
+		c.Error(err)
+		return
+	}
+
+	res, err := h.service.ResolveRegradeRequest(c.Request.Context(), data)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("regrade request resolved successfully").
+		SetData(res)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ReportIntegrityEvents(c *gin.Context) {
+	studentID, err := studentID(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data := request.ReportIntegrityEventsRequest{}
+	err = c.ShouldBindJSON(&data)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	data.ExamID = examID
+	data.IP = c.ClientIP()
+	data.UserAgent = c.Request.UserAgent()
+
+	if err := h.validator.Struct(data); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = h.service.ReportIntegrityEvents(c.Request.Context(), studentID, data)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("integrity events reported successfully")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) SubmitExamAnswers(c *gin.Context) {
+	studentID, err := studentID(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
 	data := request.SubmitExamAnswersRequest{}
 	err = c.ShouldBindJSON(&data)
 	if err != nil {
@@ -308,13 +596,65 @@ func (h *Handler) SubmitExamAnswers(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) GetStudentExams(c *gin.Context) {
-	studentIDStr := c.GetString("user_id") // Assuming user_id is set by auth middleware
-	studentID, err := uuid.Parse(studentIDStr)
+// SaveDraft answers a conflict with the current draft, which the client
+// merges its answers into before saving again.
+func (h *Handler) SaveDraft(c *gin.Context) {
+	studentID, err := studentID(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data := request.SaveDraftRequest{}
+	err = c.ShouldBindJSON(&data)
 	if err != nil {
 		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
+	data.ExamID = examID
+	data.IP = c.ClientIP()
+	data.UserAgent = c.Request.UserAgent()
+
+	if err := h.validator.Struct(data); err != nil {
+		c.Error(err)
+		return
+	}
+
+	draft, err := h.service.SaveDraft(c.Request.Context(), studentID, data)
+	if err != nil {
+		if errors.Is(err, commonError.ErrDraftConflict) {
+			response := commonHttp.NewResponse().
+				SetCode(commonError.ErrDraftConflict.Code).
+				SetMessage(err.Error()).
+				SetData(draft)
+
+			c.JSON(response.Code, response)
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("exam draft saved successfully").
+		SetData(draft)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) GetStudentExams(c *gin.Context) {
+	studentID, err := studentID(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	httpQuery := request.GetStudentExamsQuery{}
 	httpQuery.Query = commonHttp.DefaultQuery()
@@ -345,10 +685,9 @@ func (h *Handler) GetStudentExams(c *gin.Context) {
 }
 
 func (h *Handler) GetStudentExamDetail(c *gin.Context) {
-	studentIDStr := c.GetString("user_id") // Assuming user_id is set by auth middleware
-	studentID, err := uuid.Parse(studentIDStr)
+	studentID, err := studentID(c)
 	if err != nil {
-		c.Error(err).SetType(gin.ErrorTypeBind)
+		c.Error(err)
 		return
 	}
 
@@ -361,12 +700,7 @@ func (h *Handler) GetStudentExamDetail(c *gin.Context) {
 
 	data, err := h.service.GetStudentExamDetail(c.Request.Context(), examID, studentID)
 	if err != nil {
-		response := commonHttp.NewResponse().
-			SetCode(http.StatusInternalServerError).
-			SetMessage("get student exam detail error").
-			SetErrors([]error{err})
-
-		c.JSON(response.Code, response)
+		c.Error(err)
 		return
 	}
 
@@ -377,3 +711,133 @@ func (h *Handler) GetStudentExamDetail(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+func (h *Handler) StartExamAttempt(c *gin.Context) {
+	studentID, err := studentID(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data, err := h.service.StartExamAttempt(c.Request.Context(), examID, studentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("start exam attempt success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) GetStudentAttempts(c *gin.Context) {
+	studentID, err := studentID(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data, err := h.service.GetStudentAttempts(c.Request.Context(), examID, studentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("get exam attempts success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) CreateRegradeRequest(c *gin.Context) {
+	studentID, err := studentID(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data := request.CreateRegradeRequest{}
+	err = c.ShouldBindJSON(&data)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	data.ExamID = examID
+
+	if err := h.validator.Struct(data); err != nil {
+		c.Error(err)
+		return
+	}
+
+	res, err := h.service.CreateRegradeRequest(c.Request.Context(), studentID, data)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusCreated).
+		SetMessage("regrade request created successfully").
+		SetData(res)
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *Handler) GetStudentRegradeRequests(c *gin.Context) {
+	studentID, err := studentID(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	examID, err := uuid.Parse(c.Param("exam_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data, err := h.service.GetStudentRegradeRequests(c.Request.Context(), examID, studentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("get regrade requests success").
+		SetData(data)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// studentID is the ID of the logged in student.
+func studentID(c *gin.Context) (uuid.UUID, error) {
+	claim, err := jwt.ExtractContext(c.Request.Context())
+	if err != nil {
+		return uuid.Nil, commonError.ErrUnauthorized
+	}
+	return claim.User.ID, nil
+}
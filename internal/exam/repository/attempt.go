@@ -0,0 +1,377 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"enuma-elish/internal/exam/service/data/request"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/event"
+	"enuma-elish/pkg/tenant"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// ExamAttempt is one of a student's attempts at an exam, numbered from 1.
+// DeadlineAt is zero for attempts without a time limit or window end.
+type ExamAttempt struct {
+	ID          uuid.UUID `db:"id"`
+	ExamID      uuid.UUID `db:"exam_id"`
+	StudentID   uuid.UUID `db:"student_id"`
+	Number      int       `db:"number"`
+	Answers     *string   `db:"answers"` // JSON string of answers, nil until submitted
+	Result      *string   `db:"result"`  // JSON array of QuestionResult
+	Score       *float64  `db:"score"`
+	MaxScore    *float64  `db:"max_score"`
+	Grade       *float64  `db:"grade"`
+	StartedAt   int64     `db:"started_at"`
+	DeadlineAt  int64     `db:"deadline_at"`
+	SubmittedAt int64     `db:"submitted_at"`
+	Seed        int64     `db:"seed"`
+	CreatedAt   int64     `db:"created_at"`
+	UpdatedAt   int64     `db:"updated_at"`
+}
+
+// FinalGrade is the grade of the exam, derived from the attempts by the
+// scoring policy of the exam and stored in exam_grade.
+type FinalGrade struct {
+	Grade    *float64
+	Score    *float64
+	MaxScore *float64
+}
+
+// Scoring derives the grade of the exam from the student's attempts, ordered
+// by number, as the scoring policy of the exam does.
+type Scoring func(attempts []ExamAttempt) FinalGrade
+
+// OpenAttempt returns the student's attempt that is not submitted yet. When
+// there is none it opens the next one with the seed, or returns
+// ErrNoAttemptsLeft once the student used maxAttempts.
+func (r *repository) OpenAttempt(ctx context.Context, examID, studentID uuid.UUID, maxAttempts int, seed int64) (*ExamAttempt, error) {
+	if err := r.checkExamTenant(ctx, examID); err != nil {
+		return nil, err
+	}
+
+	latest, err := r.GetLatestAttempt(ctx, examID, studentID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if latest != nil && latest.Answers == nil {
+		return latest, nil
+	}
+
+	number := 1
+	if latest != nil {
+		number = latest.Number + 1
+	}
+	if number > maxAttempts {
+		return nil, commonError.ErrNoAttemptsLeft
+	}
+
+	// Of concurrent opens of the same attempt the first one wins, the others
+	// return it
+	now := time.Now().UnixMilli()
+	insertQuery := `INSERT INTO exam_attempt (id, exam_id, student_id, number, seed, created_at, updated_at)
+					VALUES ($1, $2, $3, $4, $5, $6, $6)
+					ON CONFLICT (exam_id, student_id, number) DO NOTHING`
+	_, err = r.db.ExecContext(ctx, insertQuery, uuid.New(), examID, studentID, number, seed, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetLatestAttempt(ctx, examID, studentID)
+}
+
+// StartAttempt starts the clock of the attempt and returns it. Starting
+// again keeps the original start and deadline, so reloading the exam does
+// not reset the clock.
+func (r *repository) StartAttempt(ctx context.Context, attemptID uuid.UUID, startedAt, deadlineAt int64) (*ExamAttempt, error) {
+	query := `UPDATE exam_attempt ea SET
+				  started_at = CASE WHEN ea.started_at = 0 THEN $2 ELSE ea.started_at END,
+				  deadline_at = CASE WHEN ea.started_at = 0 THEN $3 ELSE ea.deadline_at END,
+				  updated_at = $2
+			  FROM exam e
+			  WHERE ea.id = $1 AND e.id = ea.exam_id AND ($4::uuid IS NULL OR e.school_id = $4)
+			  RETURNING ea.id, ea.exam_id, ea.student_id, ea.number, ea.answers, ea.result, ea.score, ea.max_score, ea.grade,
+			  ea.started_at, ea.deadline_at, ea.submitted_at, ea.seed, ea.created_at, ea.updated_at`
+
+	var attempt ExamAttempt
+	err := r.db.GetContext(ctx, &attempt, query, attemptID, startedAt, deadlineAt, tenant.Filter(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// GetLatestAttempt returns sql.ErrNoRows when the student has no attempt at
+// the exam.
+func (r *repository) GetLatestAttempt(ctx context.Context, examID, studentID uuid.UUID) (*ExamAttempt, error) {
+	query := `SELECT ea.id, ea.exam_id, ea.student_id, ea.number, ea.answers, ea.result, ea.score, ea.max_score, ea.grade,
+			  ea.started_at, ea.deadline_at, ea.submitted_at, ea.seed, ea.created_at, ea.updated_at
+			  FROM exam_attempt ea
+			  JOIN exam e ON e.id = ea.exam_id
+			  WHERE ea.exam_id = $1 AND ea.student_id = $2 AND ($3::uuid IS NULL OR e.school_id = $3)
+			  ORDER BY ea.number DESC
+			  LIMIT 1`
+
+	var attempt ExamAttempt
+	err := r.db.GetContext(ctx, &attempt, query, examID, studentID, tenant.Filter(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// GetAttempts returns the student's attempts at the exam, oldest first.
+func (r *repository) GetAttempts(ctx context.Context, examID, studentID uuid.UUID) ([]ExamAttempt, error) {
+	query := `SELECT ea.id, ea.exam_id, ea.student_id, ea.number, ea.answers, ea.result, ea.score, ea.max_score, ea.grade,
+			  ea.started_at, ea.deadline_at, ea.submitted_at, ea.seed, ea.created_at, ea.updated_at
+			  FROM exam_attempt ea
+			  JOIN exam e ON e.id = ea.exam_id
+			  WHERE ea.exam_id = $1 AND ea.student_id = $2 AND ($3::uuid IS NULL OR e.school_id = $3)
+			  ORDER BY ea.number`
+
+	var attempts []ExamAttempt
+	err := r.db.SelectContext(ctx, &attempts, query, examID, studentID, tenant.Filter(ctx))
+	return attempts, err
+}
+
+// GetAttemptQuestionIDs returns the questions drawn for the attempt, nil
+// when none were drawn yet.
+func (r *repository) GetAttemptQuestionIDs(ctx context.Context, attemptID uuid.UUID) ([]uuid.UUID, error) {
+	query := `SELECT ea.question_ids
+			  FROM exam_attempt ea
+			  JOIN exam e ON e.id = ea.exam_id
+			  WHERE ea.id = $1 AND ($2::uuid IS NULL OR e.school_id = $2)`
+
+	var questionIDs []uuid.UUID
+	err := r.db.QueryRowContext(ctx, query, attemptID, tenant.Filter(ctx)).Scan(pq.Array(&questionIDs))
+	return questionIDs, err
+}
+
+// EnsureAttemptQuestions stores the questions drawn for the attempt unless
+// some are stored already, and returns the stored ones, so concurrent draws
+// agree on a single set.
+func (r *repository) EnsureAttemptQuestions(ctx context.Context, attemptID uuid.UUID, questionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	query := `UPDATE exam_attempt ea SET question_ids = COALESCE(ea.question_ids, $2), updated_at = $3
+			  FROM exam e
+			  WHERE ea.id = $1 AND e.id = ea.exam_id AND ($4::uuid IS NULL OR e.school_id = $4)
+			  RETURNING ea.question_ids`
+
+	var stored []uuid.UUID
+	err := r.db.QueryRowContext(ctx, query, attemptID, pq.Array(questionIDs), time.Now().UnixMilli(), tenant.Filter(ctx)).
+		Scan(pq.Array(&stored))
+	return stored, err
+}
+
+// SubmitAttempt stores the answers of the attempt and writes the
+// ExamSubmitted event. It returns sql.ErrNoRows when the attempt was
+// submitted already.
+func (r *repository) SubmitAttempt(ctx context.Context, attempt ExamAttempt, answers []request.ExamAnswer) error {
+	if err := r.checkExamTenant(ctx, attempt.ExamID); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+
+	// Convert answers to JSON
+	answersJSON, err := json.Marshal(answers)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	if err := tenant.Apply(ctx, tx); err != nil {
+		return err
+	}
+
+	updateQuery := `UPDATE exam_attempt SET answers = $1, draft = NULL, submitted_at = $2, updated_at = $2
+					WHERE id = $3 AND answers IS NULL`
+	res, err := tx.ExecContext(ctx, updateQuery, string(answersJSON), now, attempt.ID)
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	schoolID, err := examSchoolID(ctx, tx, attempt.ExamID)
+	if err != nil {
+		return err
+	}
+
+	err = event.Write(ctx, tx, schoolID, event.ExamSubmitted{
+		ExamID:      attempt.ExamID,
+		StudentID:   attempt.StudentID,
+		SchoolID:    schoolID,
+		Attempt:     attempt.Number,
+		SubmittedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
+// CloseExpiredAttempts submits the attempts whose deadline passed before the
+// given time without answers, with their flushed draft as the answers at the
+// deadline, and writes an ExamSubmitted event for each.
+func (r *repository) CloseExpiredAttempts(ctx context.Context, before int64) ([]ExamAttempt, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	query := `UPDATE exam_attempt SET answers = COALESCE(draft, '[]'), draft = NULL, submitted_at = deadline_at, updated_at = $2
+			  WHERE answers IS NULL AND submitted_at = 0 AND deadline_at > 0 AND deadline_at < $1
+			  RETURNING id, exam_id, student_id, number, answers, result, score, max_score, grade,
+			  started_at, deadline_at, submitted_at, seed, created_at, updated_at`
+
+	var closed []ExamAttempt
+	err = tx.SelectContext(ctx, &closed, query, before, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attempt := range closed {
+		schoolID, err := examSchoolID(ctx, tx, attempt.ExamID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = event.Write(ctx, tx, schoolID, event.ExamSubmitted{
+			ExamID:      attempt.ExamID,
+			StudentID:   attempt.StudentID,
+			SchoolID:    schoolID,
+			Attempt:     attempt.Number,
+			SubmittedAt: attempt.SubmittedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	committed = true
+
+	return closed, nil
+}
+
+// SaveAttemptResult stores the breakdown of a submitted attempt and its
+// grade, which is cleared while essays wait for a score, together with the
+// grade of the exam scoring derives from the student's attempts, and records
+// the change in the grade history. It returns sql.ErrNoRows when the attempt
+// was not submitted.
+func (r *repository) SaveAttemptResult(ctx context.Context, attempt ExamAttempt, result ExamResult, scoring Scoring, change GradeChange) error {
+	if err := r.checkExamTenant(ctx, attempt.ExamID); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	return r.saveGrade(ctx, attempt.ExamID, attempt.StudentID, attempt.Number, result.Auto, now, func(tx *sqlx.Tx) (*float64, error) {
+		return writeAttemptResult(ctx, tx, attempt, result, scoring, change, now)
+	})
+}
+
+// lockAttempts returns the student's attempts, locked until the transaction
+// ends so concurrent gradings derive the grade of the exam one after the
+// other.
+func lockAttempts(ctx context.Context, tx *sqlx.Tx, examID, studentID uuid.UUID) ([]ExamAttempt, error) {
+	query := `SELECT id, exam_id, student_id, number, answers, result, score, max_score, grade,
+			  started_at, deadline_at, submitted_at, seed, created_at, updated_at
+			  FROM exam_attempt
+			  WHERE exam_id = $1 AND student_id = $2
+			  ORDER BY number
+			  FOR UPDATE`
+
+	var attempts []ExamAttempt
+	err := tx.SelectContext(ctx, &attempts, query, examID, studentID)
+	return attempts, err
+}
+
+// writeAttemptResult stores the result of the attempt and the grade of the
+// exam scoring derives from the locked attempts, and returns that grade.
+func writeAttemptResult(ctx context.Context, tx *sqlx.Tx, attempt ExamAttempt, result ExamResult, scoring Scoring, change GradeChange, now int64) (*float64, error) {
+	questions, err := json.Marshal(result.Questions)
+	if err != nil {
+		return nil, err
+	}
+
+	updateQuery := `UPDATE exam_attempt SET score = $1, max_score = $2, result = $3, grade = $4, updated_at = $5
+					WHERE id = $6`
+	upsertQuery := `INSERT INTO exam_grade (id, exam_id, student_id, grade, score, max_score, created_at, updated_at)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+					ON CONFLICT (exam_id, student_id, is_deleted)
+					DO UPDATE SET grade = $4, score = $5, max_score = $6, updated_at = $7`
+
+	attempts, err := lockAttempts(ctx, tx, attempt.ExamID, attempt.StudentID)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(attempts, func(a ExamAttempt) bool { return a.ID == attempt.ID })
+	if i < 0 || attempts[i].Answers == nil {
+		return nil, sql.ErrNoRows
+	}
+	previous := attempts[i]
+	attempts[i].Score, attempts[i].MaxScore, attempts[i].Grade = &result.Score, &result.MaxScore, result.Grade
+	final := scoring(attempts)
+
+	_, err = tx.ExecContext(ctx, updateQuery, result.Score, result.MaxScore, string(questions), result.Grade, now, attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, upsertQuery, uuid.New(), attempt.ExamID, attempt.StudentID, final.Grade, final.Score, final.MaxScore, now)
+	if err != nil {
+		return nil, err
+	}
+
+	change.AttemptID = &attempt.ID
+	change.ExamID = attempt.ExamID
+	change.StudentID = attempt.StudentID
+	change.PreviousScore, change.Score = previous.Score, &result.Score
+	change.PreviousGrade, change.Grade = previous.Grade, result.Grade
+	return final.Grade, addGradeChange(ctx, tx, change, now)
+}
@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"enuma-elish/internal/exam/service/data/request"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/tenant"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// The draft of an open attempt is a Redis hash under DraftKey and the
+// attempt ID, with the answer and the version of every saved question next
+// to the version and save time of the whole draft. DraftDirtyKey holds the
+// attempts whose draft changed since it was last flushed to Postgres.
+const (
+	DraftKey      = "exam:draft"
+	DraftDirtyKey = "exam:draft:dirty"
+	DraftTTL      = 7 * 24 * time.Hour
+
+	draftFlushBatch = 100
+)
+
+// Draft is what a student saved of an open attempt so far. Every save bumps
+// Version, and Versions has the version each question was last saved at.
+type Draft struct {
+	Version  int64
+	SavedAt  int64
+	Answers  []request.ExamAnswer
+	Versions map[uuid.UUID]int64
+}
+
+func draftKey(attemptID uuid.UUID) string {
+	return DraftKey + ":" + attemptID.String()
+}
+
+// saveDraftScript saves answers unless one of their questions was saved
+// after the version the client based its answers on, which means another
+// tab changed it in the meantime. It returns the new version, -1 when the
+// draft is sealed, -2 on a conflict and -3 when the draft is not loaded.
+var saveDraftScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -3
+end
+if redis.call('HEXISTS', KEYS[1], 'sealed') == 1 then
+	return -1
+end
+local base = tonumber(ARGV[1])
+for i = 5, #ARGV, 2 do
+	local version = redis.call('HGET', KEYS[1], 'v:' .. ARGV[i])
+	if version and tonumber(version) > base then
+		return -2
+	end
+end
+local version = redis.call('HINCRBY', KEYS[1], 'version', 1)
+redis.call('HSET', KEYS[1], 'saved_at', ARGV[2])
+for i = 5, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], 'a:' .. ARGV[i], ARGV[i + 1], 'v:' .. ARGV[i], version)
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+redis.call('SADD', KEYS[2], ARGV[4])
+return version
+`)
+
+// loadDraftScript fills the hash from the draft flushed to Postgres, unless
+// a concurrent request loaded it first.
+var loadDraftScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', ARGV[2], 'saved_at', ARGV[3])
+for i = 4, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], 'a:' .. ARGV[i], ARGV[i + 1], 'v:' .. ARGV[i], ARGV[2])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 1
+`)
+
+// sealDraftScript marks the draft as being submitted, after which saves are
+// rejected, and returns it. It returns nil when the draft is not loaded.
+var sealDraftScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return false
+end
+redis.call('HSET', KEYS[1], 'sealed', 1)
+return redis.call('HGETALL', KEYS[1])
+`)
+
+// SaveDraft saves the answers into the draft of the attempt and returns the
+// new version. It returns ErrDraftConflict when another save changed one of
+// the questions after baseVersion, and ErrAttemptSubmitted when the attempt
+// is being submitted.
+func (r *repository) SaveDraft(ctx context.Context, attemptID uuid.UUID, baseVersion int64, answers []request.ExamAnswer) (int64, error) {
+	keys := []string{draftKey(attemptID), DraftDirtyKey}
+	args := []interface{}{baseVersion, time.Now().UnixMilli(), DraftTTL.Milliseconds(), attemptID.String()}
+	for _, answer := range answers {
+		answerJSON, err := json.Marshal(answer)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, answer.QuestionID.String(), string(answerJSON))
+	}
+
+	for loaded := false; ; loaded = true {
+		version, err := saveDraftScript.Run(ctx, r.rdb, keys, args...).Int64()
+		if err != nil {
+			return 0, err
+		}
+
+		switch version {
+		case -1:
+			return 0, commonError.ErrAttemptSubmitted
+		case -2:
+			return 0, commonError.ErrDraftConflict
+		case -3:
+			if loaded {
+				return 0, fmt.Errorf("draft of attempt %s vanished while saving", attemptID)
+			}
+			if err := r.loadDraft(ctx, attemptID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		return version, nil
+	}
+}
+
+// GetDraft returns the draft of the attempt, falling back to the one flushed
+// to Postgres when Redis does not have it.
+func (r *repository) GetDraft(ctx context.Context, attemptID uuid.UUID) (*Draft, error) {
+	values, err := r.rdb.HGetAll(ctx, draftKey(attemptID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return r.getStoredDraft(ctx, attemptID)
+	}
+	return parseDraft(values)
+}
+
+// SealDraft stops saves into the draft of the attempt while it is submitted
+// and returns the draft. UnsealDraft reopens it when the submission fails.
+func (r *repository) SealDraft(ctx context.Context, attemptID uuid.UUID) (*Draft, error) {
+	keys := []string{draftKey(attemptID)}
+	for loaded := false; ; loaded = true {
+		fields, err := sealDraftScript.Run(ctx, r.rdb, keys).StringSlice()
+		if err == nil {
+			values := make(map[string]string, len(fields)/2)
+			for i := 0; i+1 < len(fields); i += 2 {
+				values[fields[i]] = fields[i+1]
+			}
+			return parseDraft(values)
+		}
+		if !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+
+		if loaded {
+			return nil, fmt.Errorf("draft of attempt %s vanished while sealing", attemptID)
+		}
+		if err := r.loadDraft(ctx, attemptID); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *repository) UnsealDraft(ctx context.Context, attemptID uuid.UUID) error {
+	return r.rdb.HDel(ctx, draftKey(attemptID), "sealed").Err()
+}
+
+// ClearDraft drops the draft of a submitted attempt from Redis.
+func (r *repository) ClearDraft(ctx context.Context, attemptID uuid.UUID) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, draftKey(attemptID))
+		pipe.SRem(ctx, DraftDirtyKey, attemptID.String())
+		return nil
+	})
+	return err
+}
+
+// FlushDrafts writes the drafts changed since the last flush to their
+// attempts and returns how many it wrote. Drafts of submitted attempts and
+// drafts older than the flushed one are skipped.
+func (r *repository) FlushDrafts(ctx context.Context) (int, error) {
+	query := `UPDATE exam_attempt SET draft = $1, draft_version = $2, draft_saved_at = $3
+			  WHERE id = $4 AND answers IS NULL AND draft_version <= $2`
+
+	flushed := 0
+	for {
+		ids, err := r.rdb.SPopN(ctx, DraftDirtyKey, draftFlushBatch).Result()
+		if err != nil {
+			return flushed, err
+		}
+		if len(ids) == 0 {
+			return flushed, nil
+		}
+
+		for i, id := range ids {
+			err := r.flushDraft(ctx, query, id)
+			if err != nil {
+				// Leave the drafts not flushed yet for the next run
+				members := make([]interface{}, 0, len(ids)-i)
+				for _, id := range ids[i:] {
+					members = append(members, id)
+				}
+				if err := r.rdb.SAdd(ctx, DraftDirtyKey, members...).Err(); err != nil {
+					return flushed, err
+				}
+				return flushed, err
+			}
+			flushed++
+		}
+	}
+}
+
+func (r *repository) flushDraft(ctx context.Context, query, id string) error {
+	attemptID, err := uuid.Parse(id)
+	if err != nil {
+		return nil
+	}
+
+	values, err := r.rdb.HGetAll(ctx, draftKey(attemptID)).Result()
+	if err != nil || len(values) == 0 {
+		return err
+	}
+	draft, err := parseDraft(values)
+	if err != nil {
+		return err
+	}
+
+	answersJSON, err := json.Marshal(draft.Answers)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, query, string(answersJSON), draft.Version, draft.SavedAt, attemptID)
+	return err
+}
+
+// loadDraft puts the draft flushed to Postgres back into Redis. Its answers
+// all count as saved at the flushed version.
+func (r *repository) loadDraft(ctx context.Context, attemptID uuid.UUID) error {
+	draft, err := r.getStoredDraft(ctx, attemptID)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{DraftTTL.Milliseconds(), draft.Version, draft.SavedAt}
+	for _, answer := range draft.Answers {
+		answerJSON, err := json.Marshal(answer)
+		if err != nil {
+			return err
+		}
+		args = append(args, answer.QuestionID.String(), string(answerJSON))
+	}
+
+	return loadDraftScript.Run(ctx, r.rdb, []string{draftKey(attemptID)}, args...).Err()
+}
+
+func (r *repository) getStoredDraft(ctx context.Context, attemptID uuid.UUID) (*Draft, error) {
+	query := `SELECT ea.draft, ea.draft_version, ea.draft_saved_at
+			  FROM exam_attempt ea
+			  JOIN exam e ON e.id = ea.exam_id
+			  WHERE ea.id = $1 AND ($2::uuid IS NULL OR e.school_id = $2)`
+
+	var stored struct {
+		Draft   *string `db:"draft"`
+		Version int64   `db:"draft_version"`
+		SavedAt int64   `db:"draft_saved_at"`
+	}
+	err := r.db.GetContext(ctx, &stored, query, attemptID, tenant.Filter(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	draft := &Draft{Version: stored.Version, SavedAt: stored.SavedAt, Versions: make(map[uuid.UUID]int64)}
+	if stored.Draft != nil {
+		if err := json.Unmarshal([]byte(*stored.Draft), &draft.Answers); err != nil {
+			return nil, err
+		}
+	}
+	for _, answer := range draft.Answers {
+		draft.Versions[answer.QuestionID] = stored.Version
+	}
+	return draft, nil
+}
+
+// parseDraft reads the fields of the draft hash, keeping the answers in the
+// order of their questions' IDs so drafts read the same every time.
+func parseDraft(values map[string]string) (*Draft, error) {
+	draft := &Draft{Versions: make(map[uuid.UUID]int64)}
+	var err error
+	if draft.Version, err = strconv.ParseInt(values["version"], 10, 64); err != nil {
+		return nil, err
+	}
+	if draft.SavedAt, err = strconv.ParseInt(values["saved_at"], 10, 64); err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for field := range values {
+		if strings.HasPrefix(field, "a:") {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+
+	for _, field := range fields {
+		var answer request.ExamAnswer
+		if err := json.Unmarshal([]byte(values[field]), &answer); err != nil {
+			return nil, err
+		}
+		version, err := strconv.ParseInt(values["v:"+strings.TrimPrefix(field, "a:")], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		draft.Answers = append(draft.Answers, answer)
+		draft.Versions[answer.QuestionID] = version
+	}
+	return draft, nil
+}
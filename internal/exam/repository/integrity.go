@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"enuma-elish/pkg/tenant"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// AttemptSessionKey prefixes the Redis hash of the sessions seen on an
+// attempt and the IP it was last used from. A session seen within
+// AttemptSessionWindow counts as active.
+const (
+	AttemptSessionKey    = "exam:attempt:sessions"
+	AttemptSessionWindow = 2 * time.Minute
+	AttemptSessionTTL    = 7 * 24 * time.Hour
+)
+
+// IntegrityEvent is a signal of the integrity of an attempt, reported by the
+// client or detected by the server.
+type IntegrityEvent struct {
+	ID         uuid.UUID `db:"id"`
+	AttemptID  uuid.UUID `db:"attempt_id"`
+	ExamID     uuid.UUID `db:"exam_id"`
+	StudentID  uuid.UUID `db:"student_id"`
+	Attempt    int       `db:"attempt"`
+	Type       string    `db:"type"`
+	Source     string    `db:"source"`
+	Detail     string    `db:"detail"`
+	IP         string    `db:"ip"`
+	UserAgent  string    `db:"user_agent"`
+	OccurredAt int64     `db:"occurred_at"`
+	CreatedAt  int64     `db:"created_at"`
+}
+
+// AttemptSession is what a request tells about its attempt. ConcurrentWith
+// is another session active on the attempt, and PreviousIP the IP of the
+// attempt's previous request.
+type AttemptSession struct {
+	ConcurrentWith string
+	PreviousIP     string
+}
+
+// trackSessionScript records the session and IP of a request on an attempt.
+// It returns another session active within the window, on every request and
+// not only the first of a session, so devices taking turns on the attempt
+// are caught too, and the IP of the previous request.
+var trackSessionScript = redis.NewScript(`
+local concurrent = ''
+local fields = redis.call('HGETALL', KEYS[1])
+for i = 1, #fields, 2 do
+	local name = fields[i]
+	if string.sub(name, 1, 2) == 's:' and name ~= 's:' .. ARGV[1]
+		and tonumber(ARGV[2]) - tonumber(fields[i + 1]) < tonumber(ARGV[3]) then
+		concurrent = string.sub(name, 3)
+	end
+end
+local ip = redis.call('HGET', KEYS[1], 'ip') or ''
+redis.call('HSET', KEYS[1], 's:' .. ARGV[1], ARGV[2], 'ip', ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return {concurrent, ip}
+`)
+
+// TrackAttemptSession records a request of the session from the IP on the
+// attempt and reports what it reveals.
+func (r *repository) TrackAttemptSession(ctx context.Context, attemptID uuid.UUID, sessionID, ip string) (AttemptSession, error) {
+	key := AttemptSessionKey + ":" + attemptID.String()
+	now := time.Now().UnixMilli()
+
+	values, err := trackSessionScript.Run(ctx, r.rdb, []string{key},
+		sessionID, now, AttemptSessionWindow.Milliseconds(), ip, AttemptSessionTTL.Milliseconds()).StringSlice()
+	if err != nil {
+		return AttemptSession{}, err
+	}
+	return AttemptSession{ConcurrentWith: values[0], PreviousIP: values[1]}, nil
+}
+
+// AddIntegrityEvents stores the events of an attempt.
+func (r *repository) AddIntegrityEvents(ctx context.Context, events []IntegrityEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := r.checkExamTenant(ctx, events[0].ExamID); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	if err := tenant.Apply(ctx, tx); err != nil {
+		return err
+	}
+
+	insertQuery := `INSERT INTO exam_integrity_event
+					(id, attempt_id, exam_id, student_id, type, source, detail, ip, user_agent, occurred_at, created_at)
+					VALUES (:id, :attempt_id, :exam_id, :student_id, :type, :source, :detail, :ip, :user_agent, :occurred_at, :created_at)`
+	_, err = tx.NamedExecContext(ctx, insertQuery, events)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
+// GetIntegrityEvents returns the student's integrity timeline of the exam
+// over all attempts, oldest first.
+func (r *repository) GetIntegrityEvents(ctx context.Context, examID, studentID uuid.UUID) ([]IntegrityEvent, error) {
+	if err := r.checkExamTenant(ctx, examID); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ie.id, ie.attempt_id, ie.exam_id, ie.student_id, ea.number AS attempt, ie.type, ie.source,
+			  ie.detail, ie.ip, ie.user_agent, ie.occurred_at, ie.created_at
+			  FROM exam_integrity_event ie
+			  JOIN exam_attempt ea ON ea.id = ie.attempt_id
+			  WHERE ie.exam_id = $1 AND ie.student_id = $2
+			  ORDER BY ie.occurred_at, ie.created_at`
+
+	var events []IntegrityEvent
+	err := r.db.SelectContext(ctx, &events, query, examID, studentID)
+	return events, err
+}
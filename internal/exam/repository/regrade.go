@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"context"
+	"enuma-elish/internal/exam/service/data/request"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/event"
+	"enuma-elish/pkg/tenant"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// RegradeRequest is a student's dispute of the score of one question of a
+// graded attempt. PreviousScore and AdjustedScore are the scores of the
+// question before and after a teacher resolved it.
+type RegradeRequest struct {
+	ID            uuid.UUID  `db:"id"`
+	ExamID        uuid.UUID  `db:"exam_id"`
+	AttemptID     uuid.UUID  `db:"attempt_id"`
+	StudentID     uuid.UUID  `db:"student_id"`
+	QuestionID    uuid.UUID  `db:"question_id"`
+	Reason        string     `db:"reason"`
+	Status        string     `db:"status"` // pending, accepted or rejected
+	Comment       string     `db:"comment"`
+	PreviousScore *float64   `db:"previous_score"`
+	AdjustedScore *float64   `db:"adjusted_score"`
+	ResolvedBy    *uuid.UUID `db:"resolved_by"`
+	ResolvedAt    int64      `db:"resolved_at"`
+	CreatedAt     int64      `db:"created_at"`
+	UpdatedAt     int64      `db:"updated_at"`
+
+	// Read along from the attempt, the exam and the student
+	Attempt      int       `db:"attempt"`
+	ExamName     string    `db:"exam_name"`
+	SchoolID     uuid.UUID `db:"school_id"`
+	StudentName  string    `db:"student_name"`
+	StudentEmail string    `db:"student_email"`
+}
+
+// GradeChange is an entry of the grade history: how the score and grade of
+// an attempt changed, or of the exam for grades set directly or derived by a
+// new scoring policy, which have no attempt. Source is auto, essay, regrade,
+// direct or policy.
+type GradeChange struct {
+	ID            uuid.UUID  `db:"id"`
+	ExamID        uuid.UUID  `db:"exam_id"`
+	StudentID     uuid.UUID  `db:"student_id"`
+	AttemptID     *uuid.UUID `db:"attempt_id"`
+	Attempt       int        `db:"attempt"`
+	Source        string     `db:"source"`
+	QuestionID    *uuid.UUID `db:"question_id"`
+	RegradeID     *uuid.UUID `db:"regrade_id"`
+	PreviousScore *float64   `db:"previous_score"`
+	Score         *float64   `db:"score"`
+	PreviousGrade *float64   `db:"previous_grade"`
+	Grade         *float64   `db:"grade"`
+	ChangedBy     *uuid.UUID `db:"changed_by"`
+	Note          string     `db:"note"`
+	CreatedAt     int64      `db:"created_at"`
+}
+
+// CreateRegradeRequest returns ErrRegradePending when the question of the
+// attempt has a pending request already.
+func (r *repository) CreateRegradeRequest(ctx context.Context, regrade RegradeRequest) error {
+	if err := r.checkExamTenant(ctx, regrade.ExamID); err != nil {
+		return err
+	}
+
+	insertQuery := `INSERT INTO exam_regrade_request
+					(id, exam_id, attempt_id, student_id, question_id, reason, status, created_at, updated_at)
+					VALUES (:id, :exam_id, :attempt_id, :student_id, :question_id, :reason, :status, :created_at, :updated_at)`
+	_, err := r.db.NamedExecContext(ctx, insertQuery, regrade)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+			return commonError.ErrRegradePending
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetRegradeRequest(ctx context.Context, regradeID uuid.UUID) (*RegradeRequest, error) {
+	query := `SELECT rr.id, rr.exam_id, rr.attempt_id, rr.student_id, rr.question_id, rr.reason, rr.status, rr.comment,
+			  rr.previous_score, rr.adjusted_score, rr.resolved_by, rr.resolved_at, rr.created_at, rr.updated_at,
+			  ea.number AS attempt, e.name AS exam_name, e.school_id, u.name AS student_name, u.email AS student_email
+			  FROM exam_regrade_request rr
+			  JOIN exam_attempt ea ON ea.id = rr.attempt_id
+			  JOIN exam e ON e.id = rr.exam_id
+			  JOIN users u ON u.id = rr.student_id
+			  WHERE rr.id = $1 AND ($2::uuid IS NULL OR e.school_id = $2)`
+
+	var regrade RegradeRequest
+	err := r.db.GetContext(ctx, &regrade, query, regradeID, tenant.Filter(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &regrade, nil
+}
+
+// GetRegradeRequests lists the regrade requests of the exam, optionally
+// only those of a status.
+func (r *repository) GetRegradeRequests(ctx context.Context, examID uuid.UUID, query request.GetRegradeRequestsQuery) ([]RegradeRequest, int, error) {
+	if err := r.checkExamTenant(ctx, examID); err != nil {
+		return nil, 0, err
+	}
+
+	baseQuery := `SELECT rr.id, rr.exam_id, rr.attempt_id, rr.student_id, rr.question_id, rr.reason, rr.status, rr.comment,
+				  rr.previous_score, rr.adjusted_score, rr.resolved_by, rr.resolved_at, rr.created_at, rr.updated_at,
+				  ea.number AS attempt, e.name AS exam_name, e.school_id, u.name AS student_name, u.email AS student_email
+				  FROM exam_regrade_request rr
+				  JOIN exam_attempt ea ON ea.id = rr.attempt_id
+				  JOIN exam e ON e.id = rr.exam_id
+				  JOIN users u ON u.id = rr.student_id
+				  WHERE rr.exam_id = $1`
+
+	countQuery := `SELECT COUNT(*)
+				   FROM exam_regrade_request rr
+				   WHERE rr.exam_id = $1`
+
+	params := []interface{}{examID}
+	paramCount := 1
+
+	if query.Status != "" {
+		paramCount++
+		baseQuery += fmt.Sprintf(" AND rr.status = $%d", paramCount)
+		countQuery += fmt.Sprintf(" AND rr.status = $%d", paramCount)
+		params = append(params, query.Status)
+	}
+
+	var regrades []RegradeRequest
+	limitOrderQuery := fmt.Sprintf(" ORDER BY %s %s LIMIT $%d OFFSET $%d", query.OrderBy, query.Order, paramCount+1, paramCount+2)
+	params = append(params, query.PageSize, query.GetOffset())
+
+	err := r.db.SelectContext(ctx, &regrades, baseQuery+limitOrderQuery, params...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err = r.db.GetContext(ctx, &total, countQuery, params[:paramCount]...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return regrades, total, nil
+}
+
+// GetStudentRegradeRequests returns the student's regrade requests on the
+// exam, oldest first.
+func (r *repository) GetStudentRegradeRequests(ctx context.Context, examID, studentID uuid.UUID) ([]RegradeRequest, error) {
+	query := `SELECT rr.id, rr.exam_id, rr.attempt_id, rr.student_id, rr.question_id, rr.reason, rr.status, rr.comment,
+			  rr.previous_score, rr.adjusted_score, rr.resolved_by, rr.resolved_at, rr.created_at, rr.updated_at,
+			  ea.number AS attempt, e.name AS exam_name, e.school_id, u.name AS student_name, u.email AS student_email
+			  FROM exam_regrade_request rr
+			  JOIN exam_attempt ea ON ea.id = rr.attempt_id
+			  JOIN exam e ON e.id = rr.exam_id
+			  JOIN users u ON u.id = rr.student_id
+			  WHERE rr.exam_id = $1 AND rr.student_id = $2 AND ($3::uuid IS NULL OR e.school_id = $3)
+			  ORDER BY rr.created_at`
+
+	var regrades []RegradeRequest
+	err := r.db.SelectContext(ctx, &regrades, query, examID, studentID, tenant.Filter(ctx))
+	return regrades, err
+}
+
+// ResolveRegradeRequest stores the teacher's resolution of a pending
+// request and writes the ExamRegradeResolved event. Accepted requests come
+// with the regraded result of the attempt, stored like SaveAttemptResult
+// does. It returns ErrRegradeResolved when the request is not pending
+// anymore.
+func (r *repository) ResolveRegradeRequest(ctx context.Context, regrade RegradeRequest, attempt ExamAttempt, result *ExamResult, scoring Scoring) error {
+	if err := r.checkExamTenant(ctx, regrade.ExamID); err != nil {
+		return err
+	}
+
+	updateQuery := `UPDATE exam_regrade_request SET status = $1, comment = $2, previous_score = $3, adjusted_score = $4,
+					resolved_by = $5, resolved_at = $6, updated_at = $6
+					WHERE id = $7 AND status = 'pending'`
+
+	return r.saveGrade(ctx, regrade.ExamID, regrade.StudentID, attempt.Number, false, regrade.ResolvedAt, func(tx *sqlx.Tx) (*float64, error) {
+		res, err := tx.ExecContext(ctx, updateQuery, regrade.Status, regrade.Comment, regrade.PreviousScore, regrade.AdjustedScore,
+			regrade.ResolvedBy, regrade.ResolvedAt, regrade.ID)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if updated == 0 {
+			return nil, commonError.ErrRegradeResolved
+		}
+
+		// Only a regraded result changes the grade of the exam
+		var grade *float64
+		if result != nil {
+			change := GradeChange{
+				Source:     "regrade",
+				QuestionID: &regrade.QuestionID,
+				RegradeID:  &regrade.ID,
+				ChangedBy:  regrade.ResolvedBy,
+				Note:       regrade.Comment,
+			}
+			grade, err = writeAttemptResult(ctx, tx, attempt, *result, scoring, change, regrade.ResolvedAt)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		schoolID, err := examSchoolID(ctx, tx, regrade.ExamID)
+		if err != nil {
+			return nil, err
+		}
+
+		return grade, event.Write(ctx, tx, schoolID, event.ExamRegradeResolved{
+			RequestID:  regrade.ID,
+			ExamID:     regrade.ExamID,
+			StudentID:  regrade.StudentID,
+			SchoolID:   schoolID,
+			Attempt:    attempt.Number,
+			QuestionID: regrade.QuestionID,
+			Status:     regrade.Status,
+			Score:      regrade.AdjustedScore,
+			ResolvedAt: regrade.ResolvedAt,
+		})
+	})
+}
+
+// GetGradeChanges returns the student's grade history of the exam, oldest
+// first.
+func (r *repository) GetGradeChanges(ctx context.Context, examID, studentID uuid.UUID) ([]GradeChange, error) {
+	if err := r.checkExamTenant(ctx, examID); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT gc.id, gc.exam_id, gc.student_id, gc.attempt_id, COALESCE(ea.number, 0) AS attempt, gc.source,
+			  gc.question_id, gc.regrade_id, gc.previous_score, gc.score, gc.previous_grade, gc.grade,
+			  gc.changed_by, gc.note, gc.created_at
+			  FROM exam_grade_change gc
+			  LEFT JOIN exam_attempt ea ON ea.id = gc.attempt_id
+			  WHERE gc.exam_id = $1 AND gc.student_id = $2
+			  ORDER BY gc.created_at`
+
+	var changes []GradeChange
+	err := r.db.SelectContext(ctx, &changes, query, examID, studentID)
+	return changes, err
+}
+
+// addGradeChange records the change in the grade history, unless neither
+// the score nor the grade changed.
+func addGradeChange(ctx context.Context, tx *sqlx.Tx, change GradeChange, now int64) error {
+	if sameValue(change.PreviousScore, change.Score) && sameValue(change.PreviousGrade, change.Grade) {
+		return nil
+	}
+	change.ID = uuid.New()
+	change.CreatedAt = now
+
+	insertQuery := `INSERT INTO exam_grade_change
+					(id, exam_id, student_id, attempt_id, source, question_id, regrade_id,
+					previous_score, score, previous_grade, grade, changed_by, note, created_at)
+					VALUES (:id, :exam_id, :student_id, :attempt_id, :source, :question_id, :regrade_id,
+					:previous_score, :score, :previous_grade, :grade, :changed_by, :note, :created_at)`
+	_, err := tx.NamedExecContext(ctx, insertQuery, change)
+	return err
+}
+
+// sameValue compares scores and grades as stored, to two decimals.
+func sameValue(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return math.Round(*a*100) == math.Round(*b*100)
+}
@@ -3,28 +3,45 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"enuma-elish/internal/exam/service/data/request"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/event"
+	"enuma-elish/pkg/tenant"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
 type Exam struct {
-	ID        uuid.UUID      `db:"id"`
-	Name      string         `db:"name"`
-	SchoolID  uuid.UUID      `db:"school_id"`
-	SubjectID uuid.UUID      `db:"subject_id"`
-	CreatedAt int64          `db:"created_at"`
-	CreatedBy uuid.UUID      `db:"created_by"`
-	UpdatedAt int64          `db:"updated_at"`
-	UpdatedBy sql.NullString `db:"updated_by"`
-	DeletedAt int64          `db:"deleted_at"`
-	DeletedBy sql.NullString `db:"deleted_by"`
+	ID               uuid.UUID      `db:"id"`
+	Name             string         `db:"name"`
+	SchoolID         uuid.UUID      `db:"school_id"`
+	SubjectID        uuid.UUID      `db:"subject_id"`
+	StartAt          int64          `db:"start_at"`
+	EndAt            int64          `db:"end_at"`
+	DurationMinutes  int            `db:"duration_minutes"`
+	ShuffleQuestions bool           `db:"shuffle_questions"`
+	ShuffleOptions   bool           `db:"shuffle_options"`
+	DrawPerStudent   bool           `db:"draw_per_student"`
+	MaxAttempts      int            `db:"max_attempts"`
+	ScoringPolicy    string         `db:"scoring_policy"`
+	ReleaseMode      string         `db:"release_mode"`
+	ReleasedAt       int64          `db:"released_at"`
+	ShowCorrect      bool           `db:"show_correct_answers"`
+	ShowExplanations bool           `db:"show_explanations"`
+	CreatedAt        int64          `db:"created_at"`
+	CreatedBy        uuid.UUID      `db:"created_by"`
+	UpdatedAt        int64          `db:"updated_at"`
+	UpdatedBy        sql.NullString `db:"updated_by"`
+	DeletedAt        int64          `db:"deleted_at"`
+	DeletedBy        sql.NullString `db:"deleted_by"`
 }
 
 type ExamClass struct {
@@ -45,7 +62,8 @@ type ExamGrade struct {
 	ExamID    uuid.UUID      `db:"exam_id"`
 	StudentID uuid.UUID      `db:"student_id"`
 	Grade     *float64       `db:"grade"`
-	Answers   *string        `db:"answers"` // JSON string of answers
+	Score     *float64       `db:"score"`
+	MaxScore  *float64       `db:"max_score"`
 	IsDeleted bool           `db:"is_deleted"`
 	CreatedAt int64          `db:"created_at"`
 	CreatedBy uuid.UUID      `db:"created_by"`
@@ -59,6 +77,7 @@ type ExamQuestion struct {
 	ID         uuid.UUID      `db:"id"`
 	ExamID     uuid.UUID      `db:"exam_id"`
 	QuestionID uuid.UUID      `db:"question_id"`
+	Points     *int           `db:"points"` // nil uses the points of the question
 	IsDeleted  bool           `db:"is_deleted"`
 	CreatedAt  int64          `db:"created_at"`
 	CreatedBy  uuid.UUID      `db:"created_by"`
@@ -74,6 +93,10 @@ type Question struct {
 	QuestionType  string         `db:"question_type"`
 	Options       *string        `db:"options"`        // JSON string for multiple choice options
 	CorrectAnswer *string        `db:"correct_answer"` // Correct option ID for multiple choice
+	Points        int            `db:"points"`         // Points in the exam, the override of exam_question first
+	Rubric        *string        `db:"rubric"`         // JSON rubric of essays
+	AnswerKey     *string        `db:"answer_key"`     // JSON answer key of the other auto-graded types
+	Explanation   string         `db:"explanation"`
 	CreatedAt     int64          `db:"created_at"`
 	CreatedBy     uuid.UUID      `db:"created_by"`
 	UpdatedAt     int64          `db:"updated_at"`
@@ -83,68 +106,187 @@ type Question struct {
 }
 
 type ExamWithSubject struct {
-	ID          uuid.UUID      `db:"id"`
-	Name        string         `db:"name"`
-	SchoolID    uuid.UUID      `db:"school_id"`
-	SubjectID   uuid.UUID      `db:"subject_id"`
-	SubjectName string         `db:"subject_name"`
-	IsDeleted   bool           `db:"is_deleted"`
-	CreatedAt   int64          `db:"created_at"`
-	CreatedBy   uuid.UUID      `db:"created_by"`
-	UpdatedAt   int64          `db:"updated_at"`
-	UpdatedBy   sql.NullString `db:"updated_by"`
-	DeletedAt   int64          `db:"deleted_at"`
-	DeletedBy   sql.NullString `db:"deleted_by"`
+	ID               uuid.UUID      `db:"id"`
+	Name             string         `db:"name"`
+	SchoolID         uuid.UUID      `db:"school_id"`
+	SubjectID        uuid.UUID      `db:"subject_id"`
+	SubjectName      string         `db:"subject_name"`
+	StartAt          int64          `db:"start_at"`
+	EndAt            int64          `db:"end_at"`
+	DurationMinutes  int            `db:"duration_minutes"`
+	ShuffleQuestions bool           `db:"shuffle_questions"`
+	ShuffleOptions   bool           `db:"shuffle_options"`
+	DrawPerStudent   bool           `db:"draw_per_student"`
+	MaxAttempts      int            `db:"max_attempts"`
+	ScoringPolicy    string         `db:"scoring_policy"`
+	ReleaseMode      string         `db:"release_mode"`
+	ReleasedAt       int64          `db:"released_at"`
+	ShowCorrect      bool           `db:"show_correct_answers"`
+	ShowExplanations bool           `db:"show_explanations"`
+	IsDeleted        bool           `db:"is_deleted"`
+	CreatedAt        int64          `db:"created_at"`
+	CreatedBy        uuid.UUID      `db:"created_by"`
+	UpdatedAt        int64          `db:"updated_at"`
+	UpdatedBy        sql.NullString `db:"updated_by"`
+	DeletedAt        int64          `db:"deleted_at"`
+	DeletedBy        sql.NullString `db:"deleted_by"`
+}
+
+// BlueprintRule draws Count questions of a type and difficulty from the
+// question bank of a subject.
+type BlueprintRule struct {
+	ID              uuid.UUID `db:"id"`
+	ExamID          uuid.UUID `db:"exam_id"`
+	SubjectID       uuid.UUID `db:"subject_id"`
+	QuestionType    string    `db:"question_type"`
+	DifficultyLevel string    `db:"difficulty_level"`
+	Count           int       `db:"count"`
+	CreatedAt       int64     `db:"created_at"`
 }
 
 type StudentWithGrade struct {
-	ID        uuid.UUID      `db:"id"`
-	Name      string         `db:"name"`
-	Email     string         `db:"email"`
-	Grade     *float64       `db:"grade"`
-	CreatedAt int64          `db:"created_at"`
-	CreatedBy uuid.UUID      `db:"created_by"`
-	UpdatedAt int64          `db:"updated_at"`
-	UpdatedBy sql.NullString `db:"updated_by"`
-	DeletedAt int64          `db:"deleted_at"`
-	DeletedBy sql.NullString `db:"deleted_by"`
+	ID       uuid.UUID `db:"id"`
+	Name     string    `db:"name"`
+	Email    string    `db:"email"`
+	Grade    *float64  `db:"grade"`
+	Score    *float64  `db:"score"`
+	MaxScore *float64  `db:"max_score"`
+	Attempts int       `db:"attempts"`
+	Feedback string    `db:"feedback"`
+	// IntegrityEvents counts the student's integrity events of the exam and
+	// IntegrityFlags has their distinct types
+	IntegrityEvents int            `db:"integrity_events"`
+	IntegrityFlags  pq.StringArray `db:"integrity_flags"`
+	CreatedAt       int64          `db:"created_at"`
+	CreatedBy       uuid.UUID      `db:"created_by"`
+	UpdatedAt       int64          `db:"updated_at"`
+	UpdatedBy       sql.NullString `db:"updated_by"`
+	DeletedAt       int64          `db:"deleted_at"`
+	DeletedBy       sql.NullString `db:"deleted_by"`
+}
+
+// StudentExam is an exam of a student with the final grade and the state of
+// the student's latest attempt.
+type StudentExam struct {
+	ID               uuid.UUID      `db:"id"`
+	Name             string         `db:"name"`
+	SchoolID         uuid.UUID      `db:"school_id"`
+	SubjectID        uuid.UUID      `db:"subject_id"`
+	SubjectName      string         `db:"subject_name"`
+	StartAt          int64          `db:"start_at"`
+	EndAt            int64          `db:"end_at"`
+	DurationMinutes  int            `db:"duration_minutes"`
+	ShuffleQuestions bool           `db:"shuffle_questions"`
+	ShuffleOptions   bool           `db:"shuffle_options"`
+	DrawPerStudent   bool           `db:"draw_per_student"`
+	MaxAttempts      int            `db:"max_attempts"`
+	ScoringPolicy    string         `db:"scoring_policy"`
+	ReleaseMode      string         `db:"release_mode"`
+	ReleasedAt       int64          `db:"released_at"`
+	ShowCorrect      bool           `db:"show_correct_answers"`
+	ShowExplanations bool           `db:"show_explanations"`
+	Grade            *float64       `db:"grade"`
+	Score            *float64       `db:"score"`
+	MaxScore         *float64       `db:"max_score"`
+	Feedback         string         `db:"feedback"`     // The teacher's feedback to the student
+	Attempts         int            `db:"attempts"`     // Attempts opened so far
+	StartedAt        int64          `db:"started_at"`   // Of the latest attempt
+	IsSubmitted      bool           `db:"is_submitted"` // Whether the latest attempt is submitted
+	IsDeleted        bool           `db:"is_deleted"`
+	CreatedAt        int64          `db:"created_at"`
+	CreatedBy        uuid.UUID      `db:"created_by"`
+	UpdatedAt        int64          `db:"updated_at"`
+	UpdatedBy        sql.NullString `db:"updated_by"`
+	DeletedAt        int64          `db:"deleted_at"`
+	DeletedBy        sql.NullString `db:"deleted_by"`
+}
+
+// QuestionResult is the outcome of one question of a submission, stored as
+// part of exam_attempt.result.
+type QuestionResult struct {
+	QuestionID   uuid.UUID `json:"question_id"`
+	QuestionType string    `json:"question_type"`
+	Points       int       `json:"points"`
+	// Score is nil for essays that were not scored yet
+	Score   *float64 `json:"score"`
+	Correct *bool    `json:"correct,omitempty"` // auto-graded types only, false for partial credit
+	// Essays scored against a rubric keep the picked levels
+	Criteria []CriterionResult `json:"criteria,omitempty"`
+	Feedback string            `json:"feedback,omitempty"`
+	// Regraded questions keep the score a teacher gave on a regrade request
+	Regraded bool `json:"regraded,omitempty"`
+}
+
+type CriterionResult struct {
+	CriterionID string  `json:"criterion_id"`
+	LevelID     string  `json:"level_id"`
+	Points      float64 `json:"points"`
 }
 
-type StudentExamWithAnswers struct {
-	ID          uuid.UUID      `db:"id"`
-	Name        string         `db:"name"`
-	SchoolID    uuid.UUID      `db:"school_id"`
-	SubjectID   uuid.UUID      `db:"subject_id"`
-	SubjectName string         `db:"subject_name"`
-	Grade       *float64       `db:"grade"`
-	Answers     *string        `db:"answers"`
-	IsDeleted   bool           `db:"is_deleted"`
-	CreatedAt   int64          `db:"created_at"`
-	CreatedBy   uuid.UUID      `db:"created_by"`
-	UpdatedAt   int64          `db:"updated_at"`
-	UpdatedBy   sql.NullString `db:"updated_by"`
-	DeletedAt   int64          `db:"deleted_at"`
-	DeletedBy   sql.NullString `db:"deleted_by"`
+// ExamResult is the graded breakdown of a submission. Grade is the
+// percentage of MaxScore scored, nil while essays wait for a score.
+type ExamResult struct {
+	Score     float64
+	MaxScore  float64
+	Questions []QuestionResult
+	Grade     *float64
+	Auto      bool
 }
 
 type Repository interface {
-	CreateExam(ctx context.Context, exam Exam, questionIDs []uuid.UUID) error
+	CreateExam(ctx context.Context, exam Exam, questionIDs []uuid.UUID, points map[uuid.UUID]int, blueprint []BlueprintRule) error
 	GetExamByID(ctx context.Context, examID uuid.UUID) (*ExamWithSubject, error)
 	GetListExams(ctx context.Context, query request.GetListExamQuery) ([]ExamWithSubject, int, error)
-	UpdateExam(ctx context.Context, examID uuid.UUID, exam Exam) error
+	UpdateExam(ctx context.Context, examID uuid.UUID, exam Exam, scoring Scoring, change GradeChange) error
 	DeleteExam(ctx context.Context, examID uuid.UUID) error
 
 	AssignExamToClass(ctx context.Context, examID, classID uuid.UUID) error
 	GetExamQuestions(ctx context.Context, examID uuid.UUID) ([]Question, error)
+	GetExamBlueprint(ctx context.Context, examID uuid.UUID) ([]BlueprintRule, error)
+	DrawQuestions(ctx context.Context, schoolID uuid.UUID, blueprint []BlueprintRule, exclude []uuid.UUID) ([]uuid.UUID, error)
+	GetQuestionsByIDs(ctx context.Context, questionIDs []uuid.UUID) ([]Question, error)
 
-	GradeExam(ctx context.Context, examID, studentID uuid.UUID, grade float64) error
-	AutoGradeExam(ctx context.Context, examID, studentID uuid.UUID, totalScore, maxScore float64) error
+	GradeExam(ctx context.Context, examID, studentID uuid.UUID, grade float64, change GradeChange) error
+	SetFeedback(ctx context.Context, examID, studentID uuid.UUID, feedback string) error
+	ReleaseGrades(ctx context.Context, examID uuid.UUID, releasedAt int64) error
 	GetExamStudents(ctx context.Context, examID uuid.UUID, query request.GetExamStudentsQuery) ([]StudentWithGrade, int, error)
 
+	// Attempts
+	OpenAttempt(ctx context.Context, examID, studentID uuid.UUID, maxAttempts int, seed int64) (*ExamAttempt, error)
+	StartAttempt(ctx context.Context, attemptID uuid.UUID, startedAt, deadlineAt int64) (*ExamAttempt, error)
+	GetLatestAttempt(ctx context.Context, examID, studentID uuid.UUID) (*ExamAttempt, error)
+	GetAttempts(ctx context.Context, examID, studentID uuid.UUID) ([]ExamAttempt, error)
+	GetAttemptQuestionIDs(ctx context.Context, attemptID uuid.UUID) ([]uuid.UUID, error)
+	EnsureAttemptQuestions(ctx context.Context, attemptID uuid.UUID, questionIDs []uuid.UUID) ([]uuid.UUID, error)
+	SubmitAttempt(ctx context.Context, attempt ExamAttempt, answers []request.ExamAnswer) error
+	CloseExpiredAttempts(ctx context.Context, before int64) ([]ExamAttempt, error)
+	SaveAttemptResult(ctx context.Context, attempt ExamAttempt, result ExamResult, scoring Scoring, change GradeChange) error
+
+	// Regrades
+	CreateRegradeRequest(ctx context.Context, regrade RegradeRequest) error
+	GetRegradeRequest(ctx context.Context, regradeID uuid.UUID) (*RegradeRequest, error)
+	GetRegradeRequests(ctx context.Context, examID uuid.UUID, query request.GetRegradeRequestsQuery) ([]RegradeRequest, int, error)
+	GetStudentRegradeRequests(ctx context.Context, examID, studentID uuid.UUID) ([]RegradeRequest, error)
+	ResolveRegradeRequest(ctx context.Context, regrade RegradeRequest, attempt ExamAttempt, result *ExamResult, scoring Scoring) error
+	GetGradeChanges(ctx context.Context, examID, studentID uuid.UUID) ([]GradeChange, error)
+
+	// Integrity
+	TrackAttemptSession(ctx context.Context, attemptID uuid.UUID, sessionID, ip string) (AttemptSession, error)
+	AddIntegrityEvents(ctx context.Context, events []IntegrityEvent) error
+	GetIntegrityEvents(ctx context.Context, examID, studentID uuid.UUID) ([]IntegrityEvent, error)
+
+	// Drafts
+	SaveDraft(ctx context.Context, attemptID uuid.UUID, baseVersion int64, answers []request.ExamAnswer) (int64, error)
+	GetDraft(ctx context.Context, attemptID uuid.UUID) (*Draft, error)
+	SealDraft(ctx context.Context, attemptID uuid.UUID) (*Draft, error)
+	UnsealDraft(ctx context.Context, attemptID uuid.UUID) error
+	ClearDraft(ctx context.Context, attemptID uuid.UUID) error
+	FlushDrafts(ctx context.Context) (int, error)
+
 	// Student exam operations
-	SubmitExamAnswers(ctx context.Context, examID, studentID uuid.UUID, answers []request.ExamAnswer) error
-	GetStudentExams(ctx context.Context, studentID uuid.UUID, query request.GetStudentExamsQuery) ([]StudentExamWithAnswers, int, error)
-	GetStudentExamDetail(ctx context.Context, examID, studentID uuid.UUID) (*StudentExamWithAnswers, error)
+	IsExamAssigned(ctx context.Context, examID, studentID uuid.UUID) (bool, error)
+	GetStudentExams(ctx context.Context, studentID uuid.UUID, query request.GetStudentExamsQuery) ([]StudentExam, int, error)
+	GetStudentExamDetail(ctx context.Context, examID, studentID uuid.UUID) (*StudentExam, error)
 
 	Redis() *redis.Client
 	Tx(ctx context.Context, options *sql.TxOptions) (*sqlx.Tx, error)
@@ -162,7 +304,15 @@ func New(db *sqlx.DB, rdb *redis.Client) Repository {
 	}
 }
 
-func (r *repository) CreateExam(ctx context.Context, exam Exam, questionIDs []uuid.UUID) error {
+// CreateExam creates the exam with its questions and blueprint; points
+// overrides the points of the questions it contains.
+func (r *repository) CreateExam(ctx context.Context, exam Exam, questionIDs []uuid.UUID, points map[uuid.UUID]int, blueprint []BlueprintRule) error {
+	schoolID, err := tenant.Scope(ctx, exam.SchoolID)
+	if err != nil {
+		return err
+	}
+	exam.SchoolID = schoolID
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -177,9 +327,15 @@ func (r *repository) CreateExam(ctx context.Context, exam Exam, questionIDs []uu
 		}
 	}()
 
+	if err := tenant.Apply(ctx, tx); err != nil {
+		return err
+	}
+
 	// Insert exam
-	insertExamQuery := `INSERT INTO exam (id, name, school_id, subject_id, created_at, updated_at) 
-					    VALUES (:id, :name, :school_id, :subject_id, :created_at, :updated_at)`
+	insertExamQuery := `INSERT INTO exam (id, name, school_id, subject_id, start_at, end_at, duration_minutes, shuffle_questions, shuffle_options,
+					    draw_per_student, max_attempts, scoring_policy, release_mode, show_correct_answers, show_explanations, created_at, updated_at) 
+					    VALUES (:id, :name, :school_id, :subject_id, :start_at, :end_at, :duration_minutes, :shuffle_questions, :shuffle_options,
+					    :draw_per_student, :max_attempts, :scoring_policy, :release_mode, :show_correct_answers, :show_explanations, :created_at, :updated_at)`
 	_, err = tx.NamedExecContext(ctx, insertExamQuery, exam)
 	if err != nil {
 		return err
@@ -189,20 +345,41 @@ func (r *repository) CreateExam(ctx context.Context, exam Exam, questionIDs []uu
 	now := time.Now().UnixMilli()
 	var examQuestions []ExamQuestion
 	for _, questionID := range questionIDs {
-		examQuestions = append(examQuestions, ExamQuestion{
+		examQuestion := ExamQuestion{
 			ID:         uuid.New(),
 			ExamID:     exam.ID,
 			QuestionID: questionID,
 			CreatedAt:  now,
 			UpdatedAt:  0,
-		})
+		}
+		if p, ok := points[questionID]; ok {
+			examQuestion.Points = &p
+		}
+		examQuestions = append(examQuestions, examQuestion)
 	}
 
-	insertQuestionsQuery := `INSERT INTO exam_question (id, exam_id, question_id, created_at, updated_at) 
-							 VALUES (:id, :exam_id, :question_id, :created_at, :updated_at)`
-	_, err = tx.NamedExecContext(ctx, insertQuestionsQuery, examQuestions)
-	if err != nil {
-		return err
+	// Exams drawn per student may have no questions of their own
+	if len(examQuestions) > 0 {
+		insertQuestionsQuery := `INSERT INTO exam_question (id, exam_id, question_id, points, created_at, updated_at) 
+								 VALUES (:id, :exam_id, :question_id, :points, :created_at, :updated_at)`
+		_, err = tx.NamedExecContext(ctx, insertQuestionsQuery, examQuestions)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range blueprint {
+		blueprint[i].ID = uuid.New()
+		blueprint[i].ExamID = exam.ID
+		blueprint[i].CreatedAt = now
+	}
+	if len(blueprint) > 0 {
+		insertBlueprintQuery := `INSERT INTO exam_blueprint (id, exam_id, subject_id, question_type, difficulty_level, count, created_at)
+								 VALUES (:id, :exam_id, :subject_id, :question_type, :difficulty_level, :count, :created_at)`
+		_, err = tx.NamedExecContext(ctx, insertBlueprintQuery, blueprint)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = tx.Commit()
@@ -215,13 +392,16 @@ func (r *repository) CreateExam(ctx context.Context, exam Exam, questionIDs []uu
 }
 
 func (r *repository) GetExamByID(ctx context.Context, examID uuid.UUID) (*ExamWithSubject, error) {
-	query := `SELECT e.id, e.name, e.school_id, e.subject_id, s.name as subject_name, e.created_at, e.updated_at
+	query := `SELECT e.id, e.name, e.school_id, e.subject_id, s.name as subject_name,
+			  e.start_at, e.end_at, e.duration_minutes, e.shuffle_questions, e.shuffle_options, e.draw_per_student,
+			  e.max_attempts, e.scoring_policy, e.release_mode, e.released_at, e.show_correct_answers, e.show_explanations,
+			  e.created_at, e.updated_at
 			  FROM exam e
 			  JOIN subject s ON e.subject_id = s.id
-			  WHERE e.id = $1`
+			  WHERE e.id = $1 AND ($2::uuid IS NULL OR e.school_id = $2)`
 
 	var exam ExamWithSubject
-	err := r.db.GetContext(ctx, &exam, query, examID)
+	err := r.db.GetContext(ctx, &exam, query, examID, tenant.Filter(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -229,7 +409,15 @@ func (r *repository) GetExamByID(ctx context.Context, examID uuid.UUID) (*ExamWi
 }
 
 func (r *repository) GetListExams(ctx context.Context, query request.GetListExamQuery) ([]ExamWithSubject, int, error) {
-	baseQuery := `SELECT e.id, e.name, e.school_id, e.subject_id, s.name as subject_name, e.created_at, e.updated_at
+	schoolID, err := tenant.ScopeString(ctx, query.SchoolID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	baseQuery := `SELECT e.id, e.name, e.school_id, e.subject_id, s.name as subject_name,
+				  e.start_at, e.end_at, e.duration_minutes, e.shuffle_questions, e.shuffle_options, e.draw_per_student,
+				  e.max_attempts, e.scoring_policy, e.release_mode, e.released_at, e.show_correct_answers, e.show_explanations,
+				  e.created_at, e.updated_at
 				  FROM exam e
 				  JOIN subject s ON e.subject_id = s.id
 				  WHERE e.school_id = $1`
@@ -238,7 +426,7 @@ func (r *repository) GetListExams(ctx context.Context, query request.GetListExam
 				   FROM exam e
 				   WHERE e.school_id = $1`
 
-	params := []interface{}{query.SchoolID}
+	params := []interface{}{schoolID}
 	paramCount := 1
 
 	if query.SubjectID != "" {
@@ -252,7 +440,7 @@ func (r *repository) GetListExams(ctx context.Context, query request.GetListExam
 	limitOrderQuery := fmt.Sprintf(" ORDER BY %s %s LIMIT $%d OFFSET $%d", query.OrderBy, query.Order, paramCount+1, paramCount+2)
 	params = append(params, query.PageSize, query.GetOffset())
 
-	err := r.db.SelectContext(ctx, &exams, baseQuery+limitOrderQuery, params...)
+	err = r.db.SelectContext(ctx, &exams, baseQuery+limitOrderQuery, params...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -266,13 +454,148 @@ func (r *repository) GetListExams(ctx context.Context, query request.GetListExam
 	return exams, total, nil
 }
 
-func (r *repository) UpdateExam(ctx context.Context, examID uuid.UUID, exam Exam) error {
-	updateQuery := `UPDATE exam SET name = $1, subject_id = $2, updated_at = $3 WHERE id = $4`
-	_, err := r.db.ExecContext(ctx, updateQuery, exam.Name, exam.SubjectID, exam.UpdatedAt, examID)
-	return err
+// UpdateExam returns ErrMaxAttemptsUsed when max attempts would drop below
+// the attempts a student already opened. When the scoring policy changes,
+// the grade of the exam of every student who submitted an attempt is
+// derived again by scoring and the change recorded in the grade history.
+func (r *repository) UpdateExam(ctx context.Context, examID uuid.UUID, exam Exam, scoring Scoring, change GradeChange) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	if err := tenant.Apply(ctx, tx); err != nil {
+		return err
+	}
+
+	// Locking the exam makes concurrent updates see the policy the other
+	// one stored
+	var policy string
+	err = tx.GetContext(ctx, &policy, "SELECT scoring_policy FROM exam WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2) FOR UPDATE",
+		examID, tenant.Filter(ctx))
+	if err != nil {
+		return err
+	}
+
+	var used int
+	err = tx.GetContext(ctx, &used, "SELECT COALESCE(MAX(number), 0) FROM exam_attempt WHERE exam_id = $1", examID)
+	if err != nil {
+		return err
+	}
+	if exam.MaxAttempts < used {
+		return commonError.ErrMaxAttemptsUsed
+	}
+
+	updateQuery := `UPDATE exam SET name = $1, subject_id = $2, start_at = $3, end_at = $4, duration_minutes = $5,
+					shuffle_questions = $6, shuffle_options = $7, max_attempts = $8, scoring_policy = $9, release_mode = $10,
+					show_correct_answers = $11, show_explanations = $12, updated_at = $13
+					WHERE id = $14`
+	_, err = tx.ExecContext(ctx, updateQuery, exam.Name, exam.SubjectID, exam.StartAt, exam.EndAt, exam.DurationMinutes,
+		exam.ShuffleQuestions, exam.ShuffleOptions, exam.MaxAttempts, exam.ScoringPolicy, exam.ReleaseMode,
+		exam.ShowCorrect, exam.ShowExplanations, exam.UpdatedAt, examID)
+	if err != nil {
+		return err
+	}
+
+	if exam.ScoringPolicy != policy {
+		err = rescoreExam(ctx, tx, examID, scoring, change, exam.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
+// rescoreExam derives the grade of the exam of every student who submitted
+// an attempt again from their locked attempts, records the change in the
+// grade history and writes ExamGraded for the grades that changed.
+func rescoreExam(ctx context.Context, tx *sqlx.Tx, examID uuid.UUID, scoring Scoring, change GradeChange, now int64) error {
+	selectQuery := `SELECT grade, score FROM exam_grade WHERE exam_id = $1 AND student_id = $2 AND is_deleted = false FOR UPDATE`
+	upsertQuery := `INSERT INTO exam_grade (id, exam_id, student_id, grade, score, max_score, created_at, updated_at)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+					ON CONFLICT (exam_id, student_id, is_deleted)
+					DO UPDATE SET grade = $4, score = $5, max_score = $6, updated_at = $7`
+
+	var studentIDs []uuid.UUID
+	err := tx.SelectContext(ctx, &studentIDs, "SELECT DISTINCT student_id FROM exam_attempt WHERE exam_id = $1 AND answers IS NOT NULL", examID)
+	if err != nil {
+		return err
+	}
+
+	schoolID, err := examSchoolID(ctx, tx, examID)
+	if err != nil {
+		return err
+	}
+
+	for _, studentID := range studentIDs {
+		attempts, err := lockAttempts(ctx, tx, examID, studentID)
+		if err != nil {
+			return err
+		}
+		final := scoring(attempts)
+
+		var previous struct {
+			Grade *float64 `db:"grade"`
+			Score *float64 `db:"score"`
+		}
+		err = tx.GetContext(ctx, &previous, selectQuery, examID, studentID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, upsertQuery, uuid.New(), examID, studentID, final.Grade, final.Score, final.MaxScore, now)
+		if err != nil {
+			return err
+		}
+
+		change.ExamID = examID
+		change.StudentID = studentID
+		change.PreviousScore, change.Score = previous.Score, final.Score
+		change.PreviousGrade, change.Grade = previous.Grade, final.Grade
+		err = addGradeChange(ctx, tx, change, now)
+		if err != nil {
+			return err
+		}
+
+		if final.Grade == nil || sameValue(previous.Grade, final.Grade) {
+			continue
+		}
+		err = event.Write(ctx, tx, schoolID, event.ExamGraded{
+			ExamID:    examID,
+			StudentID: studentID,
+			SchoolID:  schoolID,
+			Grade:     *final.Grade,
+			GradedAt:  now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (r *repository) DeleteExam(ctx context.Context, examID uuid.UUID) error {
+	if err := r.checkExamTenant(ctx, examID); err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -287,6 +610,10 @@ func (r *repository) DeleteExam(ctx context.Context, examID uuid.UUID) error {
 		}
 	}()
 
+	if err := tenant.Apply(ctx, tx); err != nil {
+		return err
+	}
+
 	// Delete related records first
 	_, err = tx.ExecContext(ctx, "DELETE FROM exam_question WHERE exam_id = $1", examID)
 	if err != nil {
@@ -298,11 +625,37 @@ func (r *repository) DeleteExam(ctx context.Context, examID uuid.UUID) error {
 		return err
 	}
 
+	_, err = tx.ExecContext(ctx, "DELETE FROM exam_blueprint WHERE exam_id = $1", examID)
+	if err != nil {
+		return err
+	}
+
 	_, err = tx.ExecContext(ctx, "DELETE FROM exam_grade WHERE exam_id = $1", examID)
 	if err != nil {
 		return err
 	}
 
+	_, err = tx.ExecContext(ctx, "DELETE FROM exam_integrity_event WHERE exam_id = $1", examID)
+	if err != nil {
+		return err
+	}
+
+	// Grade changes reference regrade requests, both reference attempts
+	_, err = tx.ExecContext(ctx, "DELETE FROM exam_grade_change WHERE exam_id = $1", examID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM exam_regrade_request WHERE exam_id = $1", examID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM exam_attempt WHERE exam_id = $1", examID)
+	if err != nil {
+		return err
+	}
+
 	// Delete exam
 	_, err = tx.ExecContext(ctx, "DELETE FROM exam WHERE id = $1", examID)
 	if err != nil {
@@ -319,6 +672,22 @@ func (r *repository) DeleteExam(ctx context.Context, examID uuid.UUID) error {
 }
 
 func (r *repository) AssignExamToClass(ctx context.Context, examID, classID uuid.UUID) error {
+	// The class must belong to the same school as the exam, which in turn
+	// must belong to the caller's school.
+	var sameSchool bool
+	checkQuery := `SELECT EXISTS (
+					   SELECT 1 FROM exam e
+					   JOIN class c ON c.school_id = e.school_id
+					   WHERE e.id = $1 AND c.id = $2 AND ($3::uuid IS NULL OR e.school_id = $3)
+				   )`
+	err := r.db.GetContext(ctx, &sameSchool, checkQuery, examID, classID, tenant.Filter(ctx))
+	if err != nil {
+		return err
+	}
+	if !sameSchool {
+		return commonError.ErrForbidden
+	}
+
 	now := time.Now().UnixMilli()
 	examClass := ExamClass{
 		ID:        uuid.New(),
@@ -330,57 +699,231 @@ func (r *repository) AssignExamToClass(ctx context.Context, examID, classID uuid
 
 	insertQuery := `INSERT INTO exam_class (id, exam_id, class_id, created_at, updated_at) 
 					VALUES (:id, :exam_id, :class_id, :created_at, :updated_at)
-					ON CONFLICT (exam_id, class_id) DO NOTHING`
-	_, err := r.db.NamedExecContext(ctx, insertQuery, examClass)
+					ON CONFLICT (exam_id, class_id, is_deleted) DO NOTHING`
+	_, err = r.db.NamedExecContext(ctx, insertQuery, examClass)
 	return err
 }
 
 func (r *repository) GetExamQuestions(ctx context.Context, examID uuid.UUID) ([]Question, error) {
-	query := `SELECT q.id, q.question, q.question_type, q.options, q.correct_answer
+	query := `SELECT q.id, q.question, q.question_type, q.options, q.correct_answer,
+			  COALESCE(eq.points, q.points, 1) AS points, q.rubric, q.answer_key, q.explanation
 			  FROM question q
 			  JOIN exam_question eq ON q.id = eq.question_id
-			  WHERE eq.exam_id = $1`
+			  JOIN exam e ON e.id = eq.exam_id
+			  WHERE eq.exam_id = $1 AND ($2::uuid IS NULL OR e.school_id = $2)
+			  ORDER BY eq.created_at, eq.id`
 
 	var questions []Question
-	err := r.db.SelectContext(ctx, &questions, query, examID)
+	err := r.db.SelectContext(ctx, &questions, query, examID, tenant.Filter(ctx))
 	return questions, err
 }
 
-func (r *repository) GradeExam(ctx context.Context, examID, studentID uuid.UUID, grade float64) error {
-	now := time.Now().UnixMilli()
+func (r *repository) GetExamBlueprint(ctx context.Context, examID uuid.UUID) ([]BlueprintRule, error) {
+	query := `SELECT eb.id, eb.exam_id, eb.subject_id, eb.question_type, eb.difficulty_level, eb.count, eb.created_at
+			  FROM exam_blueprint eb
+			  JOIN exam e ON e.id = eb.exam_id
+			  WHERE eb.exam_id = $1 AND ($2::uuid IS NULL OR e.school_id = $2)
+			  ORDER BY eb.created_at, eb.id`
 
-	// Upsert grade
-	upsertQuery := `INSERT INTO exam_grade (id, exam_id, student_id, grade, created_at, updated_at) 
-					VALUES ($1, $2, $3, $4, $5, $6)
-					ON CONFLICT (exam_id, student_id) 
-					DO UPDATE SET grade = $4, updated_at = $6`
+	var blueprint []BlueprintRule
+	err := r.db.SelectContext(ctx, &blueprint, query, examID, tenant.Filter(ctx))
+	return blueprint, err
+}
 
-	_, err := r.db.ExecContext(ctx, upsertQuery, uuid.New(), examID, studentID, grade, now, now)
-	return err
+// DrawQuestions draws random questions of the school's question bank for
+// every rule of the blueprint, never drawing a question twice or one of
+// exclude. It returns ErrNotEnoughQuestions when the bank cannot fill a rule.
+func (r *repository) DrawQuestions(ctx context.Context, schoolID uuid.UUID, blueprint []BlueprintRule, exclude []uuid.UUID) ([]uuid.UUID, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id FROM question
+			  WHERE school_id = $1 AND subject_id = $2 AND question_type = $3 AND difficulty_level = $4
+			  AND id <> ALL($5::uuid[])
+			  ORDER BY random()
+			  LIMIT $6`
+
+	drawn := make([]uuid.UUID, 0)
+	for _, rule := range blueprint {
+		var ids []uuid.UUID
+		err := r.db.SelectContext(ctx, &ids, query, schoolID, rule.SubjectID, rule.QuestionType, rule.DifficultyLevel,
+			pq.Array(append(drawn, exclude...)), rule.Count)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) < rule.Count {
+			return nil, commonError.ErrNotEnoughQuestions
+		}
+		drawn = append(drawn, ids...)
+	}
+	return drawn, nil
 }
 
-func (r *repository) AutoGradeExam(ctx context.Context, examID, studentID uuid.UUID, totalScore, maxScore float64) error {
-	now := time.Now().UnixMilli()
+// GetQuestionsByIDs returns the questions in the order of the IDs, with the
+// points of the question bank.
+func (r *repository) GetQuestionsByIDs(ctx context.Context, questionIDs []uuid.UUID) ([]Question, error) {
+	query := `SELECT q.id, q.question, q.question_type, q.options, q.correct_answer,
+			  COALESCE(q.points, 1) AS points, q.rubric, q.answer_key, q.explanation
+			  FROM question q
+			  WHERE q.id = ANY($1::uuid[]) AND ($2::uuid IS NULL OR q.school_id = $2)
+			  ORDER BY array_position($1::uuid[], q.id)`
 
-	// Calculate percentage score
-	gradePercentage := (totalScore / maxScore) * 100
+	var questions []Question
+	err := r.db.SelectContext(ctx, &questions, query, pq.Array(questionIDs), tenant.Filter(ctx))
+	return questions, err
+}
 
-	// Upsert grade
+// GradeExam sets the grade of the exam directly and records the change,
+// without an attempt, in the grade history. It returns ErrGradedByAttempts
+// when the student submitted an attempt, whose grading decides the grade.
+func (r *repository) GradeExam(ctx context.Context, examID, studentID uuid.UUID, grade float64, change GradeChange) error {
+	if err := r.checkExamTenant(ctx, examID); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	selectQuery := `SELECT grade FROM exam_grade WHERE exam_id = $1 AND student_id = $2 AND is_deleted = false FOR UPDATE`
 	upsertQuery := `INSERT INTO exam_grade (id, exam_id, student_id, grade, created_at, updated_at) 
 					VALUES ($1, $2, $3, $4, $5, $6)
-					ON CONFLICT (exam_id, student_id) 
-					DO UPDATE SET grade = $4, updated_at = $6`
+					ON CONFLICT (exam_id, student_id, is_deleted) 
+					DO UPDATE SET grade = $4, score = NULL, max_score = NULL, updated_at = $6`
+
+	return r.saveGrade(ctx, examID, studentID, 0, false, now, func(tx *sqlx.Tx) (*float64, error) {
+		// Grades derived from attempts are only changed through them, so
+		// later grading cannot silently overwrite a grade set here
+		attempts, err := lockAttempts(ctx, tx, examID, studentID)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(attempts, func(a ExamAttempt) bool { return a.Answers != nil }) {
+			return nil, commonError.ErrGradedByAttempts
+		}
+
+		var previous *float64
+		err = tx.GetContext(ctx, &previous, selectQuery, examID, studentID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, upsertQuery, uuid.New(), examID, studentID, grade, now, now)
+		if err != nil {
+			return nil, err
+		}
+
+		change.ExamID = examID
+		change.StudentID = studentID
+		change.PreviousGrade, change.Grade = previous, &grade
+		return &grade, addGradeChange(ctx, tx, change, now)
+	})
+}
 
-	_, err := r.db.ExecContext(ctx, upsertQuery, uuid.New(), examID, studentID, gradePercentage, now, now)
+// SetFeedback stores the teacher's feedback to the student on the exam,
+// which students see once the grades are released.
+func (r *repository) SetFeedback(ctx context.Context, examID, studentID uuid.UUID, feedback string) error {
+	if err := r.checkExamTenant(ctx, examID); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	upsertQuery := `INSERT INTO exam_grade (id, exam_id, student_id, feedback, created_at, updated_at)
+					VALUES ($1, $2, $3, $4, $5, $5)
+					ON CONFLICT (exam_id, student_id, is_deleted)
+					DO UPDATE SET feedback = $4, updated_at = $5`
+	_, err := r.db.ExecContext(ctx, upsertQuery, uuid.New(), examID, studentID, feedback, now)
 	return err
 }
 
+// ReleaseGrades releases the grades of the exam to students at releasedAt,
+// or withdraws a manual release with 0.
+func (r *repository) ReleaseGrades(ctx context.Context, examID uuid.UUID, releasedAt int64) error {
+	query := `UPDATE exam SET released_at = $1, updated_at = $2
+			  WHERE id = $3 AND ($4::uuid IS NULL OR school_id = $4)`
+	res, err := r.db.ExecContext(ctx, query, releasedAt, time.Now().UnixMilli(), examID, tenant.Filter(ctx))
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// saveGrade runs the write in a transaction and, when it stores a grade,
+// writes the ExamGraded event with the grade write returns. attempt is the
+// number of the graded attempt, 0 for grades set directly.
+func (r *repository) saveGrade(ctx context.Context, examID, studentID uuid.UUID, attempt int, auto bool, now int64, write func(tx *sqlx.Tx) (*float64, error)) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	if err := tenant.Apply(ctx, tx); err != nil {
+		return err
+	}
+
+	grade, err := write(tx)
+	if err != nil {
+		return err
+	}
+
+	if grade != nil {
+		schoolID, err := examSchoolID(ctx, tx, examID)
+		if err != nil {
+			return err
+		}
+
+		err = event.Write(ctx, tx, schoolID, event.ExamGraded{
+			ExamID:    examID,
+			StudentID: studentID,
+			SchoolID:  schoolID,
+			Attempt:   attempt,
+			Grade:     *grade,
+			Auto:      auto,
+			GradedAt:  now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
 func (r *repository) GetExamStudents(ctx context.Context, examID uuid.UUID, query request.GetExamStudentsQuery) ([]StudentWithGrade, int, error) {
-	baseQuery := `SELECT u.id, u.name, u.email, eg.grade, u.created_at, u.updated_at
+	if err := r.checkExamTenant(ctx, examID); err != nil {
+		return nil, 0, err
+	}
+
+	baseQuery := `SELECT u.id, u.name, u.email, eg.grade, eg.score, eg.max_score, COALESCE(eg.feedback, '') AS feedback,
+				  (SELECT COUNT(*) FROM exam_attempt ea WHERE ea.exam_id = $1 AND ea.student_id = u.id) AS attempts,
+				  (SELECT COUNT(*) FROM exam_integrity_event ie WHERE ie.exam_id = $1 AND ie.student_id = u.id) AS integrity_events,
+				  ARRAY(SELECT DISTINCT ie.type FROM exam_integrity_event ie WHERE ie.exam_id = $1 AND ie.student_id = u.id ORDER BY ie.type) AS integrity_flags,
+				  u.created_at, u.updated_at
 				  FROM users u
 				  JOIN class_student cs ON u.id = cs.student_id
 				  JOIN exam_class ec ON cs.class_id = ec.class_id
-				  LEFT JOIN exam_grade eg ON u.id = eg.student_id AND eg.exam_id = $1
+				  LEFT JOIN exam_grade eg ON u.id = eg.student_id AND eg.exam_id = $1 AND eg.is_deleted = false
 				  WHERE ec.exam_id = $1`
 
 	countQuery := `SELECT COUNT(*)
@@ -406,33 +949,43 @@ func (r *repository) GetExamStudents(ctx context.Context, examID uuid.UUID, quer
 	return students, total, nil
 }
 
-func (r *repository) SubmitExamAnswers(ctx context.Context, examID, studentID uuid.UUID, answers []request.ExamAnswer) error {
-	now := time.Now().UnixMilli()
+// IsExamAssigned reports whether the exam is assigned to one of the
+// student's classes.
+func (r *repository) IsExamAssigned(ctx context.Context, examID, studentID uuid.UUID) (bool, error) {
+	var assigned bool
+	query := `SELECT EXISTS (
+				  SELECT 1 FROM exam e
+				  JOIN exam_class ec ON ec.exam_id = e.id
+				  JOIN class_student cs ON cs.class_id = ec.class_id
+				  WHERE e.id = $1 AND cs.student_id = $2 AND ($3::uuid IS NULL OR e.school_id = $3)
+			  )`
+	err := r.db.GetContext(ctx, &assigned, query, examID, studentID, tenant.Filter(ctx))
+	return assigned, err
+}
 
-	// Convert answers to JSON
-	answersJSON, err := json.Marshal(answers)
+func (r *repository) GetStudentExams(ctx context.Context, studentID uuid.UUID, query request.GetStudentExamsQuery) ([]StudentExam, int, error) {
+	schoolID, err := tenant.ScopeString(ctx, query.SchoolID)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	// Upsert exam submission
-	upsertQuery := `INSERT INTO exam_grade (id, exam_id, student_id, answers, created_at, updated_at) 
-					VALUES ($1, $2, $3, $4, $5, $6)
-					ON CONFLICT (exam_id, student_id) 
-					DO UPDATE SET answers = $4, updated_at = $6`
-
-	_, err = r.db.ExecContext(ctx, upsertQuery, uuid.New(), examID, studentID, string(answersJSON), now, now)
-	return err
-}
-
-func (r *repository) GetStudentExams(ctx context.Context, studentID uuid.UUID, query request.GetStudentExamsQuery) ([]StudentExamWithAnswers, int, error) {
-	baseQuery := `SELECT e.id, e.name, e.school_id, e.subject_id, s.name as subject_name, 
-				  eg.grade, eg.answers, e.created_at, e.updated_at
+	baseQuery := `SELECT e.id, e.name, e.school_id, e.subject_id, s.name as subject_name,
+				  e.start_at, e.end_at, e.duration_minutes, e.shuffle_questions, e.shuffle_options, e.draw_per_student,
+				  e.max_attempts, e.scoring_policy, e.release_mode, e.released_at, e.show_correct_answers, e.show_explanations,
+				  eg.grade, eg.score, eg.max_score, COALESCE(eg.feedback, '') AS feedback,
+				  COALESCE(ea.number, 0) AS attempts, COALESCE(ea.started_at, 0) AS started_at,
+				  COALESCE(ea.answers IS NOT NULL, false) AS is_submitted, e.created_at, e.updated_at
 				  FROM exam e
 				  JOIN subject s ON e.subject_id = s.id
 				  JOIN exam_class ec ON e.id = ec.exam_id
 				  JOIN class_student cs ON ec.class_id = cs.class_id
-				  LEFT JOIN exam_grade eg ON e.id = eg.exam_id AND eg.student_id = $1
+				  LEFT JOIN exam_grade eg ON e.id = eg.exam_id AND eg.student_id = $1 AND eg.is_deleted = false
+				  LEFT JOIN LATERAL (
+					  SELECT number, started_at, answers FROM exam_attempt
+					  WHERE exam_id = e.id AND student_id = $1
+					  ORDER BY number DESC
+					  LIMIT 1
+				  ) ea ON true
 				  WHERE cs.student_id = $1 AND e.school_id = $2`
 
 	countQuery := `SELECT COUNT(*)
@@ -441,7 +994,7 @@ func (r *repository) GetStudentExams(ctx context.Context, studentID uuid.UUID, q
 				   JOIN class_student cs ON ec.class_id = cs.class_id
 				   WHERE cs.student_id = $1 AND e.school_id = $2`
 
-	params := []interface{}{studentID, query.SchoolID}
+	params := []interface{}{studentID, schoolID}
 	paramCount := 2
 
 	if query.SubjectID != "" {
@@ -451,11 +1004,11 @@ func (r *repository) GetStudentExams(ctx context.Context, studentID uuid.UUID, q
 		params = append(params, query.SubjectID)
 	}
 
-	var exams []StudentExamWithAnswers
+	var exams []StudentExam
 	limitOrderQuery := fmt.Sprintf(" ORDER BY %s %s LIMIT $%d OFFSET $%d", query.OrderBy, query.Order, paramCount+1, paramCount+2)
 	params = append(params, query.PageSize, query.GetOffset())
 
-	err := r.db.SelectContext(ctx, &exams, baseQuery+limitOrderQuery, params...)
+	err = r.db.SelectContext(ctx, &exams, baseQuery+limitOrderQuery, params...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -469,22 +1022,47 @@ func (r *repository) GetStudentExams(ctx context.Context, studentID uuid.UUID, q
 	return exams, total, nil
 }
 
-func (r *repository) GetStudentExamDetail(ctx context.Context, examID, studentID uuid.UUID) (*StudentExamWithAnswers, error) {
-	query := `SELECT e.id, e.name, e.school_id, e.subject_id, s.name as subject_name, 
-			  eg.grade, eg.answers, e.created_at, e.updated_at
+func (r *repository) GetStudentExamDetail(ctx context.Context, examID, studentID uuid.UUID) (*StudentExam, error) {
+	query := `SELECT e.id, e.name, e.school_id, e.subject_id, s.name as subject_name,
+			  e.start_at, e.end_at, e.duration_minutes, e.shuffle_questions, e.shuffle_options, e.draw_per_student,
+			  e.max_attempts, e.scoring_policy, e.release_mode, e.released_at, e.show_correct_answers, e.show_explanations,
+			  eg.grade, eg.score, eg.max_score, COALESCE(eg.feedback, '') AS feedback,
+			  COALESCE(ea.number, 0) AS attempts, COALESCE(ea.started_at, 0) AS started_at,
+			  COALESCE(ea.answers IS NOT NULL, false) AS is_submitted, e.created_at, e.updated_at
 			  FROM exam e
 			  JOIN subject s ON e.subject_id = s.id
-			  LEFT JOIN exam_grade eg ON e.id = eg.exam_id AND eg.student_id = $2
-			  WHERE e.id = $1`
-
-	var exam StudentExamWithAnswers
-	err := r.db.GetContext(ctx, &exam, query, examID, studentID)
+			  LEFT JOIN exam_grade eg ON e.id = eg.exam_id AND eg.student_id = $2 AND eg.is_deleted = false
+			  LEFT JOIN LATERAL (
+				  SELECT number, started_at, answers FROM exam_attempt
+				  WHERE exam_id = e.id AND student_id = $2
+				  ORDER BY number DESC
+				  LIMIT 1
+			  ) ea ON true
+			  WHERE e.id = $1 AND ($3::uuid IS NULL OR e.school_id = $3)`
+
+	var exam StudentExam
+	err := r.db.GetContext(ctx, &exam, query, examID, studentID, tenant.Filter(ctx))
 	if err != nil {
 		return nil, err
 	}
 	return &exam, nil
 }
 
+// checkExamTenant returns sql.ErrNoRows when the exam does not exist within
+// the caller's school.
+func (r *repository) checkExamTenant(ctx context.Context, examID uuid.UUID) error {
+	var id uuid.UUID
+	query := `SELECT id FROM exam WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2)`
+	return r.db.GetContext(ctx, &id, query, examID, tenant.Filter(ctx))
+}
+
+// examSchoolID returns the school of the exam, for events.
+func examSchoolID(ctx context.Context, tx *sqlx.Tx, examID uuid.UUID) (uuid.UUID, error) {
+	var schoolID uuid.UUID
+	err := tx.GetContext(ctx, &schoolID, "SELECT school_id FROM exam WHERE id = $1", examID)
+	return schoolID, err
+}
+
 func (r *repository) Redis() *redis.Client {
 	return r.rdb
 }
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"encoding/json"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// answerKey is the answer key the question module stores for the
+// auto-graded types other than multiple choice.
+type answerKey struct {
+	Boolean       *bool             `json:"boolean"`
+	Options       []string          `json:"options"`
+	Accepted      []string          `json:"accepted"`
+	Patterns      []string          `json:"patterns"`
+	CaseSensitive bool              `json:"case_sensitive"`
+	Number        *float64          `json:"number"`
+	Tolerance     float64           `json:"tolerance"`
+	Pairs         map[string]string `json:"pairs"`
+	Order         []string          `json:"order"`
+}
+
+// autoScore returns the share of the question's points the answer earns,
+// from 0 to 1. Multi-select, matching and ordering give partial credit.
+func autoScore(question repository.Question, answer request.ExamAnswer) float64 {
+	if question.QuestionType == "multiple_choice" {
+		if answer.SelectedOption != nil && question.CorrectAnswer != nil && *answer.SelectedOption == *question.CorrectAnswer {
+			return 1
+		}
+		return 0
+	}
+
+	if question.AnswerKey == nil {
+		log.Warn().Str("question_id", question.ID.String()).Msg("question has no answer key")
+		return 0
+	}
+	var key answerKey
+	if err := json.Unmarshal([]byte(*question.AnswerKey), &key); err != nil {
+		log.Err(err).Str("question_id", question.ID.String()).Msg("Failed to parse answer key")
+		return 0
+	}
+
+	switch question.QuestionType {
+	case "true_false":
+		value, err := strconv.ParseBool(strings.TrimSpace(answer.Answer))
+		if err == nil && key.Boolean != nil && value == *key.Boolean {
+			return 1
+		}
+	case "multi_select":
+		// Every wrong pick cancels a right one
+		var right, wrong int
+		for i, option := range answer.SelectedOptions {
+			if slices.Contains(answer.SelectedOptions[:i], option) {
+				continue
+			}
+			if slices.Contains(key.Options, option) {
+				right++
+			} else {
+				wrong++
+			}
+		}
+		if len(key.Options) > 0 {
+			return math.Max(float64(right-wrong), 0) / float64(len(key.Options))
+		}
+	case "short_answer":
+		if matchShortAnswer(key, answer.Answer) {
+			return 1
+		}
+	case "numeric":
+		value, err := strconv.ParseFloat(strings.TrimSpace(answer.Answer), 64)
+		if err == nil && key.Number != nil && math.Abs(value-*key.Number) <= key.Tolerance {
+			return 1
+		}
+	case "matching":
+		var right int
+		for left, r := range key.Pairs {
+			if answer.Pairs[left] == r {
+				right++
+			}
+		}
+		if len(key.Pairs) > 0 {
+			return float64(right) / float64(len(key.Pairs))
+		}
+	case "ordering":
+		var right int
+		for i, option := range key.Order {
+			if i < len(answer.SelectedOptions) && answer.SelectedOptions[i] == option {
+				right++
+			}
+		}
+		if len(key.Order) > 0 {
+			return float64(right) / float64(len(key.Order))
+		}
+	}
+	return 0
+}
+
+// matchShortAnswer compares the trimmed answer with the accepted answers and
+// matches it against the patterns, which have to match the whole answer.
+func matchShortAnswer(key answerKey, answer string) bool {
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return false
+	}
+
+	for _, accepted := range key.Accepted {
+		accepted = strings.TrimSpace(accepted)
+		if answer == accepted || (!key.CaseSensitive && strings.EqualFold(answer, accepted)) {
+			return true
+		}
+	}
+
+	flags := "(?i)"
+	if key.CaseSensitive {
+		flags = ""
+	}
+	for _, pattern := range key.Patterns {
+		re, err := regexp.Compile(flags + `^(?:` + pattern + `)$`)
+		if err != nil {
+			log.Err(err).Str("pattern", pattern).Msg("Failed to compile short answer pattern")
+			continue
+		}
+		if re.MatchString(answer) {
+			return true
+		}
+	}
+	return false
+}
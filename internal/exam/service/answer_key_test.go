@@ -0,0 +1,133 @@
+package service
+
+import (
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func keyQuestion(questionType, keyJSON string) repository.Question {
+	question := repository.Question{ID: uuid.New(), QuestionType: questionType, Points: 4}
+	if keyJSON != "" {
+		question.AnswerKey = &keyJSON
+	}
+	return question
+}
+
+func TestAutoScore(t *testing.T) {
+	choice := choiceQuestion(4, "b")
+	trueFalse := keyQuestion("true_false", `{"boolean": false}`)
+	multiSelect := keyQuestion("multi_select", `{"options": ["a", "c", "d"]}`)
+	shortAnswer := keyQuestion("short_answer", `{"accepted": ["Paris", " Lutetia "], "patterns": ["paris,? france"]}`)
+	caseSensitive := keyQuestion("short_answer", `{"accepted": ["NaCl"], "patterns": ["H2O|CO2"], "case_sensitive": true}`)
+	numeric := keyQuestion("numeric", `{"number": 3.14, "tolerance": 0.01}`)
+	exact := keyQuestion("numeric", `{"number": 42}`)
+	matching := keyQuestion("matching", `{"pairs": {"l1": "r1", "l2": "r2", "l3": "r3", "l4": "r4"}}`)
+	ordering := keyQuestion("ordering", `{"order": ["a", "b", "c", "d"]}`)
+
+	tests := []struct {
+		name     string
+		question repository.Question
+		answer   request.ExamAnswer
+		want     float64
+	}{
+		{"multiple choice, right", choice, request.ExamAnswer{SelectedOption: ptr("b")}, 1},
+		{"multiple choice, wrong", choice, request.ExamAnswer{SelectedOption: ptr("a")}, 0},
+		{"multiple choice, unanswered", choice, request.ExamAnswer{}, 0},
+		{"multiple choice without key", repository.Question{QuestionType: "multiple_choice"}, request.ExamAnswer{SelectedOption: ptr("a")}, 0},
+
+		{"true/false, right", trueFalse, request.ExamAnswer{Answer: "false"}, 1},
+		{"true/false, padded", trueFalse, request.ExamAnswer{Answer: " False "}, 1},
+		{"true/false, wrong", trueFalse, request.ExamAnswer{Answer: "true"}, 0},
+		{"true/false, not a boolean", trueFalse, request.ExamAnswer{Answer: "no"}, 0},
+		{"true/false, empty", trueFalse, request.ExamAnswer{}, 0},
+
+		{"multi-select, all", multiSelect, request.ExamAnswer{SelectedOptions: []string{"d", "a", "c"}}, 1},
+		{"multi-select, two of three", multiSelect, request.ExamAnswer{SelectedOptions: []string{"a", "c"}}, 2.0 / 3},
+		{"multi-select, wrong cancels right", multiSelect, request.ExamAnswer{SelectedOptions: []string{"a", "c", "b"}}, 1.0 / 3},
+		{"multi-select, every option", multiSelect, request.ExamAnswer{SelectedOptions: []string{"a", "b", "c", "d", "e"}}, 1.0 / 3},
+		{"multi-select, more wrong than right", multiSelect, request.ExamAnswer{SelectedOptions: []string{"a", "b", "e"}}, 0},
+		{"multi-select, duplicates count once", multiSelect, request.ExamAnswer{SelectedOptions: []string{"a", "a", "a"}}, 1.0 / 3},
+		{"multi-select, none", multiSelect, request.ExamAnswer{}, 0},
+
+		{"short answer, accepted", shortAnswer, request.ExamAnswer{Answer: "Paris"}, 1},
+		{"short answer, ignores case", shortAnswer, request.ExamAnswer{Answer: "  pARIS "}, 1},
+		{"short answer, trims the key", shortAnswer, request.ExamAnswer{Answer: "lutetia"}, 1},
+		{"short answer, pattern", shortAnswer, request.ExamAnswer{Answer: "Paris France"}, 1},
+		{"short answer, pattern matches whole answer", shortAnswer, request.ExamAnswer{Answer: "Paris, France!"}, 0},
+		{"short answer, wrong", shortAnswer, request.ExamAnswer{Answer: "London"}, 0},
+		{"short answer, empty", shortAnswer, request.ExamAnswer{Answer: "   "}, 0},
+		{"short answer, case sensitive", caseSensitive, request.ExamAnswer{Answer: "NaCl"}, 1},
+		{"short answer, case sensitive, wrong case", caseSensitive, request.ExamAnswer{Answer: "nacl"}, 0},
+		{"short answer, case sensitive pattern", caseSensitive, request.ExamAnswer{Answer: "CO2"}, 1},
+		{"short answer, case sensitive pattern, wrong case", caseSensitive, request.ExamAnswer{Answer: "co2"}, 0},
+
+		{"numeric, exact", numeric, request.ExamAnswer{Answer: "3.14"}, 1},
+		{"numeric, within tolerance", numeric, request.ExamAnswer{Answer: " 3.149 "}, 1},
+		{"numeric, below tolerance", numeric, request.ExamAnswer{Answer: "3.139"}, 1},
+		{"numeric, outside tolerance", numeric, request.ExamAnswer{Answer: "3.16"}, 0},
+		{"numeric, not a number", numeric, request.ExamAnswer{Answer: "pi"}, 0},
+		{"numeric without tolerance", exact, request.ExamAnswer{Answer: "42"}, 1},
+		{"numeric without tolerance, off", exact, request.ExamAnswer{Answer: "42.0001"}, 0},
+		{"numeric, scientific notation", exact, request.ExamAnswer{Answer: "4.2e1"}, 1},
+
+		{"matching, all", matching, request.ExamAnswer{Pairs: map[string]string{"l1": "r1", "l2": "r2", "l3": "r3", "l4": "r4"}}, 1},
+		{"matching, half", matching, request.ExamAnswer{Pairs: map[string]string{"l1": "r1", "l2": "r2", "l3": "r4", "l4": "r3"}}, 0.5},
+		{"matching, partial answer", matching, request.ExamAnswer{Pairs: map[string]string{"l3": "r3"}}, 0.25},
+		{"matching, unknown left", matching, request.ExamAnswer{Pairs: map[string]string{"l9": "r1"}}, 0},
+		{"matching, none", matching, request.ExamAnswer{}, 0},
+
+		{"ordering, right", ordering, request.ExamAnswer{SelectedOptions: []string{"a", "b", "c", "d"}}, 1},
+		{"ordering, two swapped", ordering, request.ExamAnswer{SelectedOptions: []string{"a", "c", "b", "d"}}, 0.5},
+		{"ordering, reversed", ordering, request.ExamAnswer{SelectedOptions: []string{"d", "c", "b", "a"}}, 0},
+		{"ordering, too short", ordering, request.ExamAnswer{SelectedOptions: []string{"a", "b"}}, 0.5},
+		{"ordering, none", ordering, request.ExamAnswer{}, 0},
+
+		{"no answer key", keyQuestion("numeric", ""), request.ExamAnswer{Answer: "1"}, 0},
+		{"broken answer key", keyQuestion("numeric", "{"), request.ExamAnswer{Answer: "1"}, 0},
+		{"empty answer key", keyQuestion("multi_select", "{}"), request.ExamAnswer{SelectedOptions: []string{"a"}}, 0},
+		{"essay", keyQuestion("essay", "{}"), request.ExamAnswer{Answer: "An essay"}, 0},
+	}
+
+	for _, tt := range tests {
+		tt.answer.QuestionID = tt.question.ID
+		if got := autoScore(tt.question, tt.answer); got != tt.want {
+			t.Errorf("%s: autoScore = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchShortAnswerInvalidPattern(t *testing.T) {
+	key := answerKey{Patterns: []string{"(", "colou?r"}}
+	if !matchShortAnswer(key, "Color") {
+		t.Fatalf("an invalid pattern stops the valid ones from matching")
+	}
+}
+
+// Partial credit is applied to the question's points and rounded to cents.
+func TestGradeSubmissionPartialCredit(t *testing.T) {
+	multiSelect := keyQuestion("multi_select", `{"options": ["a", "c", "d"]}`)
+	ordering := keyQuestion("ordering", `{"order": ["a", "b", "c", "d"]}`)
+	numeric := keyQuestion("numeric", `{"number": 10, "tolerance": 0.5}`)
+	questions := []repository.Question{multiSelect, ordering, numeric}
+
+	answers := []request.ExamAnswer{
+		{QuestionID: multiSelect.ID, SelectedOptions: []string{"a", "c"}},
+		{QuestionID: ordering.ID, SelectedOptions: []string{"a", "b", "d", "c"}},
+		{QuestionID: numeric.ID, Answer: "10.5"},
+	}
+
+	result := gradeSubmission(questions, answers, nil)
+	wantScores := []float64{2.67, 2, 4}
+	wantCorrect := []bool{false, false, true}
+	for i, question := range result.Questions {
+		if *question.Score != wantScores[i] || *question.Correct != wantCorrect[i] {
+			t.Errorf("%s: score %v correct %v, want %v %v", question.QuestionType, *question.Score, *question.Correct, wantScores[i], wantCorrect[i])
+		}
+	}
+	if result.Score != 8.67 || result.MaxScore != 12 || result.Grade == nil || *result.Grade != 72.25 {
+		t.Errorf("got %v/%v grade %v, want 8.67/12 grade 72.25", result.Score, result.MaxScore, deref(result.Grade))
+	}
+}
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"enuma-elish/internal/exam/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// StartExamAttempt starts the clock of the student's open attempt, opening
+// the next one when the latest was submitted. The deadline is the start plus
+// the time limit, cut off at the end of the exam window.
+func (s *service) StartExamAttempt(ctx context.Context, examID, studentID uuid.UUID) (response.ExamAttemptResponse, error) {
+	exam, err := s.assignedExam(ctx, examID, studentID)
+	if err != nil {
+		return response.ExamAttemptResponse{}, err
+	}
+
+	now := time.Now().UnixMilli()
+	if err := checkWindow(exam, now); err != nil {
+		return response.ExamAttemptResponse{}, err
+	}
+
+	attempt, err := s.openAttempt(ctx, exam, studentID)
+	if err != nil {
+		return response.ExamAttemptResponse{}, err
+	}
+
+	var deadline int64
+	if exam.DurationMinutes > 0 {
+		deadline = now + (time.Duration(exam.DurationMinutes) * time.Minute).Milliseconds()
+	}
+	if exam.EndAt > 0 && (deadline == 0 || exam.EndAt < deadline) {
+		deadline = exam.EndAt
+	}
+
+	attempt, err = s.repository.StartAttempt(ctx, attempt.ID, now, deadline)
+	if err != nil {
+		log.Err(err).Msg("Failed to start exam attempt")
+		return response.ExamAttemptResponse{}, err
+	}
+
+	return response.ExamAttemptResponse{
+		ExamID:           examID,
+		Number:           attempt.Number,
+		StartedAt:        attempt.StartedAt,
+		DeadlineAt:       attempt.DeadlineAt,
+		RemainingSeconds: remainingSeconds(attempt.DeadlineAt, attempt.Answers != nil, now),
+		IsSubmitted:      attempt.Answers != nil,
+	}, nil
+}
+
+// openAttempt returns the student's open attempt, opening the next one when
+// there is none and the student has attempts left.
+func (s *service) openAttempt(ctx context.Context, exam *repository.ExamWithSubject, studentID uuid.UUID) (*repository.ExamAttempt, error) {
+	attempt, err := s.repository.OpenAttempt(ctx, exam.ID, studentID, exam.MaxAttempts, newSeed())
+	if err != nil {
+		if !errors.Is(err, commonError.ErrNoAttemptsLeft) {
+			log.Err(err).Msg("Failed to open exam attempt")
+		}
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// currentAttempt returns the student's open attempt. Only StartExamAttempt
+// opens attempts, so saves and submissions from a stale tab never use one
+// up: without an attempt they fail with ErrExamNotStarted, and once the
+// latest is submitted with ErrAttemptSubmitted.
+func (s *service) currentAttempt(ctx context.Context, examID, studentID uuid.UUID) (*repository.ExamAttempt, error) {
+	attempt, err := s.repository.GetLatestAttempt(ctx, examID, studentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, commonError.ErrExamNotStarted
+		}
+		log.Err(err).Msg("Failed to get exam attempt")
+		return nil, err
+	}
+	if attempt.Answers != nil {
+		return nil, commonError.ErrAttemptSubmitted
+	}
+	return attempt, nil
+}
+
+// checkSubmission rejects submissions outside the exam window and, for
+// started attempts, after the deadline plus the configured grace period.
+// Timed exams have to be started before they can be submitted.
+func (s *service) checkSubmission(exam *repository.ExamWithSubject, attempt *repository.ExamAttempt, now int64) error {
+	started := attempt.StartedAt > 0
+
+	if exam.DurationMinutes > 0 && !started {
+		return commonError.ErrExamNotStarted
+	}
+	if exam.StartAt > 0 && now < exam.StartAt {
+		return commonError.ErrExamNotOpen
+	}
+
+	grace := int64(s.config.Exam.SubmitGrace) * 1000
+	if started {
+		if attempt.DeadlineAt > 0 && now > attempt.DeadlineAt+grace {
+			return commonError.ErrExamTimeUp
+		}
+		return nil
+	}
+	if exam.EndAt > 0 && now > exam.EndAt+grace {
+		return commonError.ErrExamClosed
+	}
+	return nil
+}
+
+// CloseExpiredAttempts submits the started attempts whose deadline and grace
+// period passed without a submission, so they count as answered with what
+// their draft holds and get graded. Drafts are flushed first so the latest
+// saves count.
+func (s *service) CloseExpiredAttempts(ctx context.Context) error {
+	if err := s.FlushDrafts(ctx); err != nil {
+		return err
+	}
+
+	grace := time.Duration(s.config.Exam.SubmitGrace) * time.Second
+	closed, err := s.repository.CloseExpiredAttempts(ctx, time.Now().Add(-grace).UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	for _, attempt := range closed {
+		if err := s.repository.ClearDraft(ctx, attempt.ID); err != nil {
+			log.Err(err).Msg("Failed to clear exam draft")
+		}
+		s.autoGrade(ctx, attempt, parseAnswers(attempt.Answers))
+	}
+	if len(closed) > 0 {
+		log.Info().Int("attempts", len(closed)).Msg("closed expired exam attempts")
+	}
+	return nil
+}
+
+func (s *service) GetStudentAttempts(ctx context.Context, examID, studentID uuid.UUID) (response.AttemptHistoryResponse, error) {
+	if _, err := s.assignedExam(ctx, examID, studentID); err != nil {
+		return response.AttemptHistoryResponse{}, err
+	}
+	return s.attemptHistory(ctx, examID, studentID, false)
+}
+
+func (s *service) GetExamStudentAttempts(ctx context.Context, examID, studentID uuid.UUID) (response.AttemptHistoryResponse, error) {
+	return s.attemptHistory(ctx, examID, studentID, true)
+}
+
+// attemptHistory lists the attempts with their answers for teachers, and
+// for students with the results of graded attempts once the grades are
+// released.
+func (s *service) attemptHistory(ctx context.Context, examID, studentID uuid.UUID, teacher bool) (response.AttemptHistoryResponse, error) {
+	exam, err := s.repository.GetStudentExamDetail(ctx, examID, studentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return response.AttemptHistoryResponse{}, commonError.ErrExamNotFound
+		}
+		log.Err(err).Msg("Failed to get exam")
+		return response.AttemptHistoryResponse{}, err
+	}
+
+	attempts, err := s.repository.GetAttempts(ctx, examID, studentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam attempts")
+		return response.AttemptHistoryResponse{}, err
+	}
+
+	// Teachers see the grades whether or not they are released
+	now := time.Now().UnixMilli()
+	released := teacher || s.gradesReleased(exam, now)
+
+	// Students see how they answered each question once the latest attempt
+	// reveals the answers
+	var revealed bool
+	if len(attempts) > 0 {
+		revealed = s.answersRevealed(exam, &attempts[len(attempts)-1], now)
+	}
+
+	res := response.AttemptHistoryResponse{
+		ExamID:        examID,
+		StudentID:     studentID,
+		MaxAttempts:   exam.MaxAttempts,
+		ScoringPolicy: exam.ScoringPolicy,
+		IsReleased:    released,
+	}
+	if released {
+		res.Grade = exam.Grade
+		res.Feedback = exam.Feedback
+	}
+	for _, attempt := range attempts {
+		summary := response.AttemptSummaryResponse{
+			Number:      attempt.Number,
+			StartedAt:   attempt.StartedAt,
+			DeadlineAt:  attempt.DeadlineAt,
+			SubmittedAt: attempt.SubmittedAt,
+			IsSubmitted: attempt.Answers != nil,
+		}
+		if released {
+			summary.Score = attempt.Score
+			summary.MaxScore = attempt.MaxScore
+			summary.Grade = attempt.Grade
+			summary.IsGraded = attempt.Grade != nil
+		}
+		if teacher {
+			summary.Answers = answerResponses(attempt.Answers)
+			summary.Results = questionResultResponses(parseResults(attempt.Result))
+		} else if released {
+			summary.Results = studentResults(&attempt, revealed)
+		}
+		res.Attempts = append(res.Attempts, summary)
+	}
+	return res, nil
+}
+
+// finalGrade derives the grade of the exam from the submitted attempts:
+// "highest" takes the best graded attempt, "latest" the latest submitted
+// one, and "average" averages them once every one of them is graded.
+func finalGrade(policy string, attempts []repository.ExamAttempt) repository.FinalGrade {
+	var submitted []repository.ExamAttempt
+	for _, attempt := range attempts {
+		if attempt.Answers != nil {
+			submitted = append(submitted, attempt)
+		}
+	}
+	if len(submitted) == 0 {
+		return repository.FinalGrade{}
+	}
+
+	switch policy {
+	case "latest":
+		latest := submitted[len(submitted)-1]
+		return repository.FinalGrade{Grade: latest.Grade, Score: latest.Score, MaxScore: latest.MaxScore}
+	case "average":
+		var grade, score, maxScore float64
+		scored := true
+		for _, attempt := range submitted {
+			if attempt.Grade == nil {
+				return repository.FinalGrade{}
+			}
+			grade += *attempt.Grade
+			if attempt.Score == nil || attempt.MaxScore == nil {
+				scored = false
+				continue
+			}
+			score += *attempt.Score
+			maxScore += *attempt.MaxScore
+		}
+
+		n := float64(len(submitted))
+		grade = math.Round(grade/n*100) / 100
+		final := repository.FinalGrade{Grade: &grade}
+		if scored {
+			score = math.Round(score/n*100) / 100
+			maxScore = math.Round(maxScore/n*100) / 100
+			final.Score, final.MaxScore = &score, &maxScore
+		}
+		return final
+	default:
+		var best *repository.ExamAttempt
+		for i, attempt := range submitted {
+			if attempt.Grade != nil && (best == nil || *attempt.Grade > *best.Grade) {
+				best = &submitted[i]
+			}
+		}
+		if best == nil {
+			return repository.FinalGrade{}
+		}
+		return repository.FinalGrade{Grade: best.Grade, Score: best.Score, MaxScore: best.MaxScore}
+	}
+}
+
+func answerResponses(answersJSON *string) []response.StudentExamAnswerResponse {
+	return examAnswerResponses(parseAnswers(answersJSON))
+}
+
+// parseAnswers reads the stored answers of an attempt, nil until it is
+// submitted.
+func parseAnswers(answersJSON *string) []request.ExamAnswer {
+	if answersJSON == nil {
+		return nil
+	}
+
+	var answers []request.ExamAnswer
+	if err := json.Unmarshal([]byte(*answersJSON), &answers); err != nil {
+		log.Err(err).Msg("Failed to parse exam answers")
+		return nil
+	}
+	return answers
+}
+
+func examAnswerResponses(answers []request.ExamAnswer) []response.StudentExamAnswerResponse {
+	var res []response.StudentExamAnswerResponse
+	for _, answer := range answers {
+		res = append(res, response.StudentExamAnswerResponse{
+			QuestionID:      answer.QuestionID,
+			Answer:          answer.Answer,
+			SelectedOption:  answer.SelectedOption,
+			SelectedOptions: answer.SelectedOptions,
+			Pairs:           answer.Pairs,
+		})
+	}
+	return res
+}
+
+func (s *service) assignedExam(ctx context.Context, examID, studentID uuid.UUID) (*repository.ExamWithSubject, error) {
+	exam, err := s.repository.GetExamByID(ctx, examID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, commonError.ErrExamNotFound
+		}
+		log.Err(err).Msg("Failed to get exam")
+		return nil, err
+	}
+
+	assigned, err := s.repository.IsExamAssigned(ctx, examID, studentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to check exam assignment")
+		return nil, err
+	}
+	if !assigned {
+		return nil, commonError.ErrExamNotFound
+	}
+	return exam, nil
+}
+
+func checkWindow(exam *repository.ExamWithSubject, now int64) error {
+	if exam.StartAt > 0 && now < exam.StartAt {
+		return commonError.ErrExamNotOpen
+	}
+	if exam.EndAt > 0 && now > exam.EndAt {
+		return commonError.ErrExamClosed
+	}
+	return nil
+}
+
+// remainingSeconds is nil for attempts without a deadline and for submitted
+// ones, and never negative.
+func remainingSeconds(deadline int64, submitted bool, now int64) *int64 {
+	if deadline == 0 || submitted {
+		return nil
+	}
+	remaining := max((deadline-now)/1000, 0)
+	return &remaining
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"enuma-elish/internal/exam/repository"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"testing"
+)
+
+func TestCheckWindow(t *testing.T) {
+	startAt, endAt := int64(1_000_000), int64(2_000_000)
+
+	tests := []struct {
+		name string
+		exam repository.ExamWithSubject
+		now  int64
+		want error
+	}{
+		{"no window", repository.ExamWithSubject{}, 0, nil},
+		{"before start", repository.ExamWithSubject{StartAt: startAt, EndAt: endAt}, startAt - 1, commonError.ErrExamNotOpen},
+		{"at start", repository.ExamWithSubject{StartAt: startAt, EndAt: endAt}, startAt, nil},
+		{"at end", repository.ExamWithSubject{StartAt: startAt, EndAt: endAt}, endAt, nil},
+		{"after end", repository.ExamWithSubject{StartAt: startAt, EndAt: endAt}, endAt + 1, commonError.ErrExamClosed},
+		{"start only", repository.ExamWithSubject{StartAt: startAt}, endAt * 10, nil},
+		{"end only", repository.ExamWithSubject{EndAt: endAt}, 0, nil},
+		{"end only, closed", repository.ExamWithSubject{EndAt: endAt}, endAt + 1, commonError.ErrExamClosed},
+	}
+
+	for _, tt := range tests {
+		if err := checkWindow(&tt.exam, tt.now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkWindow = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRemainingSeconds(t *testing.T) {
+	deadline := int64(1_000_000)
+
+	tests := []struct {
+		name      string
+		deadline  int64
+		submitted bool
+		now       int64
+		want      *int64
+	}{
+		{"no deadline", 0, false, deadline, nil},
+		{"submitted", deadline, true, 0, nil},
+		{"whole seconds", deadline, false, deadline - 90_000, ptr(int64(90))},
+		{"rounds down", deadline, false, deadline - 1_999, ptr(int64(1))},
+		{"under a second", deadline, false, deadline - 999, ptr(int64(0))},
+		{"at deadline", deadline, false, deadline, ptr(int64(0))},
+		{"past deadline", deadline, false, deadline + 5_000, ptr(int64(0))},
+	}
+
+	for _, tt := range tests {
+		got := remainingSeconds(tt.deadline, tt.submitted, tt.now)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("%s: remainingSeconds = %v, want %v", tt.name, deref(got), deref(tt.want))
+		}
+	}
+}
+
+func TestQuestionsVisible(t *testing.T) {
+	startAt, endAt := int64(1_000_000), int64(2_000_000)
+	exam := &repository.ExamWithSubject{StartAt: startAt, EndAt: endAt}
+	answers := "[]"
+
+	tests := []struct {
+		name    string
+		attempt *repository.ExamAttempt
+		now     int64
+		want    bool
+	}{
+		{"no attempt", nil, startAt, false},
+		{"not started", &repository.ExamAttempt{Number: 1}, startAt, false},
+		{"started", &repository.ExamAttempt{Number: 1, StartedAt: startAt}, startAt + 1, true},
+		{"started, closed", &repository.ExamAttempt{Number: 1, StartedAt: startAt}, endAt + 1, false},
+		{"submitted, closed", &repository.ExamAttempt{Number: 1, StartedAt: startAt, Answers: &answers}, endAt + 1, true},
+	}
+
+	for _, tt := range tests {
+		if got := questionsVisible(exam, tt.attempt, tt.now); got != tt.want {
+			t.Errorf("%s: questionsVisible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// deref prints a pointer in test failures.
+func deref[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func TestFinalGrade(t *testing.T) {
+	answers := "[]"
+	attempt := func(number int, grade, score, maxScore *float64) repository.ExamAttempt {
+		return repository.ExamAttempt{Number: number, Answers: &answers, Grade: grade, Score: score, MaxScore: maxScore}
+	}
+	open := repository.ExamAttempt{Number: 3}
+
+	graded := []repository.ExamAttempt{
+		attempt(1, ptr(60.0), ptr(6.0), ptr(10.0)),
+		attempt(2, ptr(90.0), ptr(9.0), ptr(10.0)),
+		attempt(3, ptr(70.0), ptr(7.0), ptr(10.0)),
+	}
+	pending := []repository.ExamAttempt{
+		attempt(1, ptr(60.0), ptr(6.0), ptr(10.0)),
+		attempt(2, nil, nil, nil),
+	}
+	withOpen := []repository.ExamAttempt{
+		attempt(1, ptr(60.0), ptr(6.0), ptr(10.0)),
+		attempt(2, ptr(80.0), ptr(8.0), ptr(10.0)),
+		open,
+	}
+	gradedDirectly := []repository.ExamAttempt{
+		attempt(1, ptr(50.0), nil, nil),
+		attempt(2, ptr(75.0), ptr(3.0), ptr(4.0)),
+	}
+	uneven := []repository.ExamAttempt{
+		attempt(1, ptr(100.0), ptr(1.0), ptr(1.0)),
+		attempt(2, ptr(0.0), ptr(0.0), ptr(2.0)),
+		attempt(3, ptr(0.0), ptr(0.0), ptr(2.0)),
+	}
+
+	tests := []struct {
+		name     string
+		policy   string
+		attempts []repository.ExamAttempt
+		want     repository.FinalGrade
+	}{
+		{"no attempts", "highest", nil, repository.FinalGrade{}},
+		{"only an open attempt", "latest", []repository.ExamAttempt{open}, repository.FinalGrade{}},
+
+		{"highest", "highest", graded, repository.FinalGrade{Grade: ptr(90.0), Score: ptr(9.0), MaxScore: ptr(10.0)}},
+		{"highest is the default", "", graded, repository.FinalGrade{Grade: ptr(90.0), Score: ptr(9.0), MaxScore: ptr(10.0)}},
+		{"highest skips ungraded", "highest", pending, repository.FinalGrade{Grade: ptr(60.0), Score: ptr(6.0), MaxScore: ptr(10.0)}},
+		{"highest, nothing graded", "highest", []repository.ExamAttempt{attempt(1, nil, nil, nil)}, repository.FinalGrade{}},
+		{"highest skips the open attempt", "highest", withOpen, repository.FinalGrade{Grade: ptr(80.0), Score: ptr(8.0), MaxScore: ptr(10.0)}},
+
+		{"latest", "latest", graded, repository.FinalGrade{Grade: ptr(70.0), Score: ptr(7.0), MaxScore: ptr(10.0)}},
+		{"latest waits for the latest", "latest", pending, repository.FinalGrade{}},
+		{"latest skips the open attempt", "latest", withOpen, repository.FinalGrade{Grade: ptr(80.0), Score: ptr(8.0), MaxScore: ptr(10.0)}},
+
+		{"average", "average", graded, repository.FinalGrade{Grade: ptr(73.33), Score: ptr(7.33), MaxScore: ptr(10.0)}},
+		{"average waits for every attempt", "average", pending, repository.FinalGrade{}},
+		{"average skips the open attempt", "average", withOpen, repository.FinalGrade{Grade: ptr(70.0), Score: ptr(7.0), MaxScore: ptr(10.0)}},
+		{"average without scores", "average", gradedDirectly, repository.FinalGrade{Grade: ptr(62.5)}},
+		{"average of grades", "average", uneven, repository.FinalGrade{Grade: ptr(33.33), Score: ptr(0.33), MaxScore: ptr(1.67)}},
+	}
+
+	for _, tt := range tests {
+		got := finalGrade(tt.policy, tt.attempts)
+		if !sameFloat(got.Grade, tt.want.Grade) || !sameFloat(got.Score, tt.want.Score) || !sameFloat(got.MaxScore, tt.want.MaxScore) {
+			t.Errorf("%s: finalGrade = %v %v/%v, want %v %v/%v", tt.name,
+				deref(got.Grade), deref(got.Score), deref(got.MaxScore),
+				deref(tt.want.Grade), deref(tt.want.Score), deref(tt.want.MaxScore))
+		}
+	}
+}
+
+func sameFloat(a, b *float64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"enuma-elish/internal/exam/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// blueprintRules turns the blueprint of the request into rules, the subject
+// of the exam standing in for rules without one.
+func blueprintRules(data request.CreateExamRequest) []repository.BlueprintRule {
+	var rules []repository.BlueprintRule
+	for _, rule := range data.Blueprint {
+		subjectID := data.SubjectID
+		if rule.SubjectID != nil {
+			subjectID = *rule.SubjectID
+		}
+		rules = append(rules, repository.BlueprintRule{
+			SubjectID:       subjectID,
+			QuestionType:    rule.QuestionType,
+			DifficultyLevel: rule.DifficultyLevel,
+			Count:           rule.Count,
+		})
+	}
+	return rules
+}
+
+// drawQuestions draws the blueprint from the question bank, leaving out the
+// questions the exam already has.
+func (s *service) drawQuestions(ctx context.Context, schoolID uuid.UUID, blueprint []repository.BlueprintRule, exclude []uuid.UUID) ([]uuid.UUID, error) {
+	drawn, err := s.repository.DrawQuestions(ctx, schoolID, blueprint, exclude)
+	if err != nil {
+		if !errors.Is(err, commonError.ErrNotEnoughQuestions) {
+			log.Err(err).Msg("Failed to draw exam questions")
+		}
+		return nil, err
+	}
+	return drawn, nil
+}
+
+// attemptQuestions returns the questions of the attempt. Exams drawn per
+// student add the questions drawn for the attempt to their own, which are
+// drawn and stored the first time they are needed so grading and review see
+// the same set.
+func (s *service) attemptQuestions(ctx context.Context, examID uuid.UUID, attempt *repository.ExamAttempt) ([]repository.Question, error) {
+	questions, err := s.repository.GetExamQuestions(ctx, examID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam questions")
+		return nil, err
+	}
+
+	exam, err := s.repository.GetExamByID(ctx, examID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, commonError.ErrExamNotFound
+		}
+		log.Err(err).Msg("Failed to get exam")
+		return nil, err
+	}
+	if !exam.DrawPerStudent || attempt == nil {
+		return questions, nil
+	}
+
+	questionIDs, err := s.repository.GetAttemptQuestionIDs(ctx, attempt.ID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get attempt questions")
+		return nil, err
+	}
+	if questionIDs == nil {
+		blueprint, err := s.repository.GetExamBlueprint(ctx, examID)
+		if err != nil {
+			log.Err(err).Msg("Failed to get exam blueprint")
+			return nil, err
+		}
+
+		exclude := make([]uuid.UUID, 0, len(questions))
+		for _, question := range questions {
+			exclude = append(exclude, question.ID)
+		}
+		drawn, err := s.drawQuestions(ctx, exam.SchoolID, blueprint, exclude)
+		if err != nil {
+			return nil, err
+		}
+
+		questionIDs, err = s.repository.EnsureAttemptQuestions(ctx, attempt.ID, drawn)
+		if err != nil {
+			log.Err(err).Msg("Failed to store attempt questions")
+			return nil, err
+		}
+	}
+
+	drawn, err := s.repository.GetQuestionsByIDs(ctx, questionIDs)
+	if err != nil {
+		log.Err(err).Msg("Failed to get attempt questions")
+		return nil, err
+	}
+	return append(questions, drawn...), nil
+}
+
+func blueprintResponses(blueprint []repository.BlueprintRule) []response.BlueprintRuleResponse {
+	var res []response.BlueprintRuleResponse
+	for _, rule := range blueprint {
+		res = append(res, response.BlueprintRuleResponse{
+			SubjectID:       rule.SubjectID,
+			QuestionType:    rule.QuestionType,
+			DifficultyLevel: rule.DifficultyLevel,
+			Count:           rule.Count,
+		})
+	}
+	return res
+}
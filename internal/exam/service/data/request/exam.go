@@ -3,6 +3,7 @@ package request
 import (
 	commonHttp "enuma-elish/pkg/http"
 	"fmt"
+	"slices"
 
 	"github.com/google/uuid"
 )
@@ -14,36 +15,205 @@ type CreateExamRequest struct {
 	ClassID           uuid.UUID   `json:"class_id" validate:"required"`
 	MultipleChoiceIDs []uuid.UUID `json:"multiple_choice_ids"`
 	EssayQuestionIDs  []uuid.UUID `json:"essay_question_ids"`
+	QuestionIDs       []uuid.UUID `json:"question_ids"` // Questions of any type
+	// QuestionPoints overrides the points of questions in this exam
+	QuestionPoints map[uuid.UUID]int `json:"question_points" validate:"omitempty,dive,min=1"`
+	// The window students can start and submit the exam in, unix ms; 0
+	// leaves that side open
+	StartAt int64 `json:"start_at" validate:"min=0"`
+	EndAt   int64 `json:"end_at" validate:"min=0"`
+	// DurationMinutes is the time limit of an attempt, 0 for none
+	DurationMinutes int `json:"duration_minutes" validate:"min=0,max=1440"`
+	// Students see the questions and/or their options in an order of their
+	// own, which stays the same across reloads
+	ShuffleQuestions bool `json:"shuffle_questions"`
+	ShuffleOptions   bool `json:"shuffle_options"`
+	// Blueprint draws questions from the question bank on top of the listed
+	// ones, once for the exam or, with DrawPerStudent, for every student.
+	// Both are fixed once the exam is created.
+	Blueprint      []BlueprintRule `json:"blueprint" validate:"omitempty,dive"`
+	DrawPerStudent bool            `json:"draw_per_student"`
+	// MaxAttempts is how many attempts a student gets, 1 when left out;
+	// ScoringPolicy picks which of them make the grade of the exam
+	MaxAttempts   int    `json:"max_attempts" validate:"min=0,max=100"`
+	ScoringPolicy string `json:"scoring_policy" validate:"omitempty,oneof=highest latest average"`
+	// ReleaseMode is when students see their grades and feedback:
+	// "immediate" (default), "after_close" once end_at passed, or "manual"
+	// when a teacher releases them. Once released the exam may show the
+	// correct answers and the explanations of its questions.
+	ReleaseMode        string `json:"release_mode" validate:"omitempty,oneof=immediate after_close manual"`
+	ShowCorrectAnswers bool   `json:"show_correct_answers"`
+	ShowExplanations   bool   `json:"show_explanations"`
+}
+
+// BlueprintRule draws Count random questions of a type and difficulty from
+// the question bank of a subject.
+type BlueprintRule struct {
+	SubjectID       *uuid.UUID `json:"subject_id,omitempty"` // Defaults to the subject of the exam
+	QuestionType    string     `json:"question_type" validate:"required,oneof=multiple_choice essay true_false multi_select short_answer numeric matching ordering"`
+	DifficultyLevel string     `json:"difficulty_level" validate:"required,oneof=easy medium hard"`
+	Count           int        `json:"count" validate:"required,min=1,max=100"`
 }
 
 // Custom validation to ensure at least one question type is provided
 func (r CreateExamRequest) Validate() error {
-	if len(r.MultipleChoiceIDs) == 0 && len(r.EssayQuestionIDs) == 0 {
-		return fmt.Errorf("at least one question (multiple choice or essay) must be provided")
+	if len(r.AllQuestionIDs()) == 0 && len(r.Blueprint) == 0 {
+		return fmt.Errorf("at least one question or blueprint rule must be provided")
+	}
+	if r.DrawPerStudent && len(r.Blueprint) == 0 {
+		return fmt.Errorf("draw_per_student needs a blueprint")
+	}
+	for questionID := range r.QuestionPoints {
+		if !slices.Contains(r.AllQuestionIDs(), questionID) {
+			return fmt.Errorf("question_points has question %s that is not in the exam", questionID)
+		}
 	}
 	return nil
 }
 
+// AllQuestionIDs combines the question IDs of every list.
+func (r CreateExamRequest) AllQuestionIDs() []uuid.UUID {
+	return slices.Concat(r.MultipleChoiceIDs, r.EssayQuestionIDs, r.QuestionIDs)
+}
+
+// Attempts returns the number of attempts and the scoring policy, with their
+// defaults filled in.
+func (r CreateExamRequest) Attempts() (int, string) {
+	maxAttempts, policy := r.MaxAttempts, r.ScoringPolicy
+	if maxAttempts == 0 {
+		maxAttempts = 1
+	}
+	if policy == "" {
+		policy = "highest"
+	}
+	return maxAttempts, policy
+}
+
+// Release returns the release mode with its default filled in.
+func (r CreateExamRequest) Release() string {
+	if r.ReleaseMode == "" {
+		return "immediate"
+	}
+	return r.ReleaseMode
+}
+
+// ValidRelease reports whether the exam has the end its release mode needs.
+func (r CreateExamRequest) ValidRelease() bool {
+	return r.ReleaseMode != "after_close" || r.EndAt > 0
+}
+
+// ValidWindow reports whether the exam ends after it starts.
+func (r CreateExamRequest) ValidWindow() bool {
+	return r.EndAt == 0 || r.EndAt > r.StartAt
+}
+
 type AssignExamToClassRequest struct {
 	ExamID  uuid.UUID `json:"exam_id" validate:"required"`
 	ClassID uuid.UUID `json:"class_id" validate:"required"`
 }
 
+// FeedbackRequest sets the teacher's feedback to a student on the exam; an
+// empty feedback clears it.
+type FeedbackRequest struct {
+	ExamID    uuid.UUID `json:"exam_id" validate:"required"`
+	StudentID uuid.UUID `json:"student_id" validate:"required"`
+	Feedback  string    `json:"feedback" validate:"max=5000"`
+}
+
+// CreateRegradeRequest disputes the score of a question of one of the
+// student's graded attempts.
+type CreateRegradeRequest struct {
+	ExamID     uuid.UUID `json:"-"`
+	Attempt    int       `json:"attempt" validate:"required,min=1"`
+	QuestionID uuid.UUID `json:"question_id" validate:"required"`
+	Reason     string    `json:"reason" validate:"required,max=2000"`
+}
+
+// ResolveRegradeRequest accepts a regrade request with the adjusted score
+// of the question, at most its points, or rejects it.
+type ResolveRegradeRequest struct {
+	RegradeID uuid.UUID `json:"-"`
+	Status    string    `json:"status" validate:"required,oneof=accepted rejected"`
+	Score     *float64  `json:"score,omitempty" validate:"omitempty,min=0"`
+	Comment   string    `json:"comment" validate:"max=2000"`
+}
+
 type GradeExamRequest struct {
 	ExamID    uuid.UUID `json:"exam_id" validate:"required"`
 	StudentID uuid.UUID `json:"student_id" validate:"required"`
 	Grade     float64   `json:"grade" validate:"required,min=0,max=100"`
 }
 
+// ScoreEssaysRequest scores essays of a submitted attempt, by default the
+// student's latest. Once every essay has a score the grade is computed from
+// the points of the whole exam.
+type ScoreEssaysRequest struct {
+	ExamID    uuid.UUID    `json:"exam_id" validate:"required"`
+	StudentID uuid.UUID    `json:"student_id" validate:"required"`
+	Attempt   int          `json:"attempt" validate:"min=0"`
+	Scores    []EssayScore `json:"scores" validate:"required,min=1,dive"`
+}
+
+// EssayScore scores one essay either directly with Score, at most the points
+// of the question, or by picking a level of every criterion of its rubric.
+type EssayScore struct {
+	QuestionID uuid.UUID        `json:"question_id" validate:"required"`
+	Score      *float64         `json:"score,omitempty" validate:"omitempty,min=0"`
+	Criteria   []CriterionScore `json:"criteria,omitempty" validate:"omitempty,dive"`
+	Feedback   string           `json:"feedback" validate:"max=2000"`
+}
+
+type CriterionScore struct {
+	CriterionID string `json:"criterion_id" validate:"required"`
+	LevelID     string `json:"level_id" validate:"required"`
+}
+
+// SubmitExamAnswersRequest submits the open attempt with its saved draft;
+// the answers sent along replace the draft's answers to their questions.
 type SubmitExamAnswersRequest struct {
 	ExamID  uuid.UUID    `json:"exam_id" validate:"required"`
-	Answers []ExamAnswer `json:"answers" validate:"required,min=1"`
+	Answers []ExamAnswer `json:"answers" validate:"omitempty,dive"`
+}
+
+// SaveDraftRequest saves answers into the draft of the open attempt.
+// BaseVersion is the draft version the answers were made on; saving a
+// question another session saved after it is a conflict.
+type SaveDraftRequest struct {
+	ExamID      uuid.UUID    `json:"-"`
+	IP          string       `json:"-"`
+	UserAgent   string       `json:"-"`
+	BaseVersion int64        `json:"base_version" validate:"min=0"`
+	Answers     []ExamAnswer `json:"answers" validate:"required,min=1,max=100,dive"`
 }
 
+// ReportIntegrityEventsRequest reports what the client noticed during the
+// open attempt. Events without a time, or with one in the future, take the
+// time they are received.
+type ReportIntegrityEventsRequest struct {
+	ExamID    uuid.UUID        `json:"-"`
+	IP        string           `json:"-"`
+	UserAgent string           `json:"-"`
+	Events    []IntegrityEvent `json:"events" validate:"required,min=1,max=50,dive"`
+}
+
+type IntegrityEvent struct {
+	Type       string `json:"type" validate:"required,oneof=focus_lost tab_hidden copy paste fullscreen_exit"`
+	Detail     string `json:"detail" validate:"max=500"`
+	OccurredAt int64  `json:"occurred_at" validate:"min=0"`
+}
+
+// ExamAnswer is the answer to one question; which field is used depends on
+// the question type.
 type ExamAnswer struct {
-	QuestionID     uuid.UUID `json:"question_id" validate:"required"`
-	Answer         string    `json:"answer" validate:"required"`
-	SelectedOption *string   `json:"selected_option,omitempty"` // For multiple choice
+	QuestionID uuid.UUID `json:"question_id" validate:"required"`
+	// Answer is the text of essay, short answer and numeric questions, and
+	// "true" or "false" for true/false questions
+	Answer          string   `json:"answer"`
+	SelectedOption  *string  `json:"selected_option,omitempty"`  // For multiple choice
+	SelectedOptions []string `json:"selected_options,omitempty"` // For multi-select, and the order of ordering questions
+	// Pairs has the right option ID picked for every left option ID of
+	// matching questions
+	Pairs map[string]string `json:"pairs,omitempty"`
 }
 
 type GetStudentExamsQuery struct {
@@ -89,3 +259,16 @@ func (q GetListExamQuery) Get() (commonHttp.Query, map[string]interface{}) {
 type GetExamStudentsQuery struct {
 	commonHttp.Query
 }
+
+type GetRegradeRequestsQuery struct {
+	Status string `form:"status" validate:"omitempty,oneof=pending accepted rejected"`
+	commonHttp.Query
+}
+
+func (q GetRegradeRequestsQuery) Get() (commonHttp.Query, map[string]interface{}) {
+	f := map[string]interface{}{}
+	if q.Status != "" {
+		f["status"] = q.Status
+	}
+	return q.Query, f
+}
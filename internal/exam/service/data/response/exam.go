@@ -3,96 +3,314 @@ package response
 import "github.com/google/uuid"
 
 type ExamResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	SchoolID    uuid.UUID `json:"school_id"`
-	SubjectID   uuid.UUID `json:"subject_id"`
-	SubjectName string    `json:"subject_name"`
-	CreatedAt   int64     `json:"created_at"`
-	UpdatedAt   int64     `json:"updated_at"`
+	ID               uuid.UUID `json:"id"`
+	Name             string    `json:"name"`
+	SchoolID         uuid.UUID `json:"school_id"`
+	SubjectID        uuid.UUID `json:"subject_id"`
+	SubjectName      string    `json:"subject_name"`
+	StartAt          int64     `json:"start_at"`
+	EndAt            int64     `json:"end_at"`
+	DurationMinutes  int       `json:"duration_minutes"`
+	ShuffleQuestions bool      `json:"shuffle_questions"`
+	ShuffleOptions   bool      `json:"shuffle_options"`
+	DrawPerStudent   bool      `json:"draw_per_student"`
+	MaxAttempts      int       `json:"max_attempts"`
+	ScoringPolicy    string    `json:"scoring_policy"`
+	ReleaseMode      string    `json:"release_mode"`
+	ReleasedAt       int64     `json:"released_at"`
+	ShowCorrect      bool      `json:"show_correct_answers"`
+	ShowExplanations bool      `json:"show_explanations"`
+	CreatedAt        int64     `json:"created_at"`
+	UpdatedAt        int64     `json:"updated_at"`
 }
 
 type GetListExamResponse []ExamResponse
 
 type DetailExamResponse struct {
-	ID          uuid.UUID              `json:"id"`
-	Name        string                 `json:"name"`
-	SchoolID    uuid.UUID              `json:"school_id"`
-	SubjectID   uuid.UUID              `json:"subject_id"`
-	SubjectName string                 `json:"subject_name"`
-	Questions   []ExamQuestionResponse `json:"questions"`
-	CreatedAt   int64                  `json:"created_at"`
-	UpdatedAt   int64                  `json:"updated_at"`
+	ID               uuid.UUID               `json:"id"`
+	Name             string                  `json:"name"`
+	SchoolID         uuid.UUID               `json:"school_id"`
+	SubjectID        uuid.UUID               `json:"subject_id"`
+	SubjectName      string                  `json:"subject_name"`
+	StartAt          int64                   `json:"start_at"`
+	EndAt            int64                   `json:"end_at"`
+	DurationMinutes  int                     `json:"duration_minutes"`
+	ShuffleQuestions bool                    `json:"shuffle_questions"`
+	ShuffleOptions   bool                    `json:"shuffle_options"`
+	DrawPerStudent   bool                    `json:"draw_per_student"`
+	Blueprint        []BlueprintRuleResponse `json:"blueprint"`
+	MaxAttempts      int                     `json:"max_attempts"`
+	ScoringPolicy    string                  `json:"scoring_policy"`
+	ReleaseMode      string                  `json:"release_mode"`
+	ReleasedAt       int64                   `json:"released_at"`
+	ShowCorrect      bool                    `json:"show_correct_answers"`
+	ShowExplanations bool                    `json:"show_explanations"`
+	Questions        []ExamQuestionResponse  `json:"questions"` // Without the questions drawn per student
+	CreatedAt        int64                   `json:"created_at"`
+	UpdatedAt        int64                   `json:"updated_at"`
+}
+
+type BlueprintRuleResponse struct {
+	SubjectID       uuid.UUID `json:"subject_id"`
+	QuestionType    string    `json:"question_type"`
+	DifficultyLevel string    `json:"difficulty_level"`
+	Count           int       `json:"count"`
 }
 
 type ExamQuestionResponse struct {
-	ID            uuid.UUID                `json:"id"`
-	Question      string                   `json:"question"`
-	QuestionType  string                   `json:"question_type"`
-	Options       []QuestionOptionResponse `json:"options,omitempty"`
-	CorrectAnswer *string                  `json:"correct_answer,omitempty"` // Only for teachers
+	ID           uuid.UUID                `json:"id"`
+	Question     string                   `json:"question"`
+	QuestionType string                   `json:"question_type"`
+	Options      []QuestionOptionResponse `json:"options,omitempty"`
+	Points       int                      `json:"points"`
+	// The answer and its explanation are for teachers, and for students
+	// once the exam releases them
+	CorrectAnswer *string            `json:"correct_answer,omitempty"`
+	AnswerKey     *AnswerKeyResponse `json:"answer_key,omitempty"`
+	Explanation   string             `json:"explanation,omitempty"`
+}
+
+type AnswerKeyResponse struct {
+	Boolean       *bool             `json:"boolean,omitempty"`
+	Options       []string          `json:"options,omitempty"`
+	Accepted      []string          `json:"accepted,omitempty"`
+	Patterns      []string          `json:"patterns,omitempty"`
+	CaseSensitive bool              `json:"case_sensitive,omitempty"`
+	Number        *float64          `json:"number,omitempty"`
+	Tolerance     float64           `json:"tolerance,omitempty"`
+	Pairs         map[string]string `json:"pairs,omitempty"`
+	Order         []string          `json:"order,omitempty"`
 }
 
 type QuestionOptionResponse struct {
 	ID   string `json:"id"`
 	Text string `json:"text"`
+	Side string `json:"side,omitempty"` // Only for matching
 }
 
 type ExamStudentResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Grade     *float64  `json:"grade"`
-	IsGraded  bool      `json:"is_graded"`
-	CreatedAt int64     `json:"created_at"`
-	UpdatedAt int64     `json:"updated_at"`
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Grade    *float64  `json:"grade"`
+	Score    *float64  `json:"score"`
+	MaxScore *float64  `json:"max_score"`
+	IsGraded bool      `json:"is_graded"`
+	Attempts int       `json:"attempts"`
+	Feedback string    `json:"feedback"`
+	// IntegrityFlags has the types of the integrity events of the student
+	IntegrityEvents int      `json:"integrity_events"`
+	IntegrityFlags  []string `json:"integrity_flags"`
+	CreatedAt       int64    `json:"created_at"`
+	UpdatedAt       int64    `json:"updated_at"`
 }
 
 type GetExamStudentsResponse []ExamStudentResponse
 
 type StudentExamResponse struct {
-	ID          uuid.UUID              `json:"id"`
-	Name        string                 `json:"name"`
-	SchoolID    uuid.UUID              `json:"school_id"`
-	SubjectID   uuid.UUID              `json:"subject_id"`
-	SubjectName string                 `json:"subject_name"`
-	Questions   []ExamQuestionResponse `json:"questions"`
-	Grade       *float64               `json:"grade"`
-	IsSubmitted bool                   `json:"is_submitted"`
-	IsGraded    bool                   `json:"is_graded"`
-	CreatedAt   int64                  `json:"created_at"`
-	UpdatedAt   int64                  `json:"updated_at"`
+	ID              uuid.UUID              `json:"id"`
+	Name            string                 `json:"name"`
+	SchoolID        uuid.UUID              `json:"school_id"`
+	SubjectID       uuid.UUID              `json:"subject_id"`
+	SubjectName     string                 `json:"subject_name"`
+	StartAt         int64                  `json:"start_at"`
+	EndAt           int64                  `json:"end_at"`
+	DurationMinutes int                    `json:"duration_minutes"`
+	Questions       []ExamQuestionResponse `json:"questions"`
+	Grade           *float64               `json:"grade"` // Null until released
+	MaxAttempts     int                    `json:"max_attempts"`
+	Attempts        int                    `json:"attempts"`
+	// The state of the latest attempt
+	IsStarted   bool  `json:"is_started"`
+	IsSubmitted bool  `json:"is_submitted"`
+	IsGraded    bool  `json:"is_graded"`
+	IsReleased  bool  `json:"is_released"`
+	CreatedAt   int64 `json:"created_at"`
+	UpdatedAt   int64 `json:"updated_at"`
 }
 
 type GetStudentExamsResponse []StudentExamResponse
 
 type StudentExamDetailResponse struct {
-	ID          uuid.UUID                   `json:"id"`
-	Name        string                      `json:"name"`
-	SchoolID    uuid.UUID                   `json:"school_id"`
-	SubjectID   uuid.UUID                   `json:"subject_id"`
-	SubjectName string                      `json:"subject_name"`
-	Questions   []ExamQuestionResponse      `json:"questions"`
-	Answers     []StudentExamAnswerResponse `json:"answers"`
-	Grade       *float64                    `json:"grade"`
-	IsSubmitted bool                        `json:"is_submitted"`
-	IsGraded    bool                        `json:"is_graded"`
-	CreatedAt   int64                       `json:"created_at"`
-	UpdatedAt   int64                       `json:"updated_at"`
+	ID              uuid.UUID `json:"id"`
+	Name            string    `json:"name"`
+	SchoolID        uuid.UUID `json:"school_id"`
+	SubjectID       uuid.UUID `json:"subject_id"`
+	SubjectName     string    `json:"subject_name"`
+	StartAt         int64     `json:"start_at"`
+	EndAt           int64     `json:"end_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	MaxAttempts     int       `json:"max_attempts"`
+	ScoringPolicy   string    `json:"scoring_policy"`
+	Attempts        int       `json:"attempts"`
+	// Grade, Score and MaxScore are the final ones of the exam. They, the
+	// results and the feedback stay empty until the grades are released.
+	Grade      *float64 `json:"grade"`
+	Score      *float64 `json:"score"`
+	MaxScore   *float64 `json:"max_score"`
+	Feedback   string   `json:"feedback"`
+	IsReleased bool     `json:"is_released"`
+	// The questions, answers and results are of the latest attempt
+	Attempt      int                         `json:"attempt"`
+	AttemptGrade *float64                    `json:"attempt_grade"`
+	Questions    []ExamQuestionResponse      `json:"questions"`
+	Answers      []StudentExamAnswerResponse `json:"answers"`
+	Results      []QuestionResultResponse    `json:"results"`
+	StartedAt    int64                       `json:"started_at"`
+	DeadlineAt   int64                       `json:"deadline_at"`
+	// Answers of an open attempt are its draft, saved at DraftVersion
+	DraftVersion int64 `json:"draft_version"`
+	// RemainingSeconds is null unless a started attempt has a deadline and
+	// was not submitted yet
+	RemainingSeconds *int64 `json:"remaining_seconds"`
+	IsStarted        bool   `json:"is_started"`
+	IsSubmitted      bool   `json:"is_submitted"`
+	IsGraded         bool   `json:"is_graded"`
+	CreatedAt        int64  `json:"created_at"`
+	UpdatedAt        int64  `json:"updated_at"`
+}
+
+type ExamAttemptResponse struct {
+	ExamID           uuid.UUID `json:"exam_id"`
+	Number           int       `json:"number"`
+	StartedAt        int64     `json:"started_at"`
+	DeadlineAt       int64     `json:"deadline_at"`
+	RemainingSeconds *int64    `json:"remaining_seconds"`
+	IsSubmitted      bool      `json:"is_submitted"`
+}
+
+type DraftResponse struct {
+	ExamID  uuid.UUID                   `json:"exam_id"`
+	Attempt int                         `json:"attempt"`
+	Version int64                       `json:"version"`
+	SavedAt int64                       `json:"saved_at"`
+	Answers []StudentExamAnswerResponse `json:"answers"`
 }
 
 type StudentExamAnswerResponse struct {
-	QuestionID     uuid.UUID `json:"question_id"`
-	Answer         string    `json:"answer"`
-	SelectedOption *string   `json:"selected_option,omitempty"`
-}
-
-type AutoGradeResultResponse struct {
-	TotalQuestions      int     `json:"total_questions"`
-	CorrectAnswers      int     `json:"correct_answers"`
-	Score               float64 `json:"score"`
-	MaxScore            float64 `json:"max_score"`
-	GradePercentage     float64 `json:"grade_percentage"`
-	MultipleChoiceScore float64 `json:"multiple_choice_score"`
-	EssayPending        bool    `json:"essay_pending"`
+	QuestionID      uuid.UUID         `json:"question_id"`
+	Answer          string            `json:"answer"`
+	SelectedOption  *string           `json:"selected_option,omitempty"`
+	SelectedOptions []string          `json:"selected_options,omitempty"`
+	Pairs           map[string]string `json:"pairs,omitempty"`
+}
+
+type QuestionResultResponse struct {
+	QuestionID   uuid.UUID `json:"question_id"`
+	QuestionType string    `json:"question_type"`
+	Points       int       `json:"points"`
+	Score        *float64  `json:"score"`
+	Correct      *bool     `json:"correct,omitempty"`
+	// Rubric levels picked for an essay and the teacher's feedback
+	Criteria []CriterionResultResponse `json:"criteria,omitempty"`
+	Feedback string                    `json:"feedback,omitempty"`
+	Regraded bool                      `json:"regraded,omitempty"`
+}
+
+type CriterionResultResponse struct {
+	CriterionID string  `json:"criterion_id"`
+	LevelID     string  `json:"level_id"`
+	Points      float64 `json:"points"`
+}
+
+type ExamResultResponse struct {
+	ExamID    uuid.UUID                `json:"exam_id"`
+	StudentID uuid.UUID                `json:"student_id"`
+	Attempt   int                      `json:"attempt"`
+	Score     float64                  `json:"score"`
+	MaxScore  float64                  `json:"max_score"`
+	Grade     *float64                 `json:"grade"`
+	IsGraded  bool                     `json:"is_graded"`
+	Results   []QuestionResultResponse `json:"results"`
+}
+
+// AttemptHistoryResponse lists a student's attempts at an exam next to the
+// final grade the scoring policy derives from them.
+type AttemptHistoryResponse struct {
+	ExamID        uuid.UUID                `json:"exam_id"`
+	StudentID     uuid.UUID                `json:"student_id"`
+	MaxAttempts   int                      `json:"max_attempts"`
+	ScoringPolicy string                   `json:"scoring_policy"`
+	Grade         *float64                 `json:"grade"`
+	Feedback      string                   `json:"feedback"`
+	IsReleased    bool                     `json:"is_released"`
+	Attempts      []AttemptSummaryResponse `json:"attempts"`
+}
+
+type AttemptSummaryResponse struct {
+	Number      int      `json:"number"`
+	StartedAt   int64    `json:"started_at"`
+	DeadlineAt  int64    `json:"deadline_at"`
+	SubmittedAt int64    `json:"submitted_at"`
+	Score       *float64 `json:"score"`
+	MaxScore    *float64 `json:"max_score"`
+	Grade       *float64 `json:"grade"`
+	IsSubmitted bool     `json:"is_submitted"`
+	IsGraded    bool     `json:"is_graded"`
+	// Teachers get the answers of every attempt, students the results of
+	// graded ones
+	Answers []StudentExamAnswerResponse `json:"answers,omitempty"`
+	Results []QuestionResultResponse    `json:"results,omitempty"`
+}
+
+// IntegrityTimelineResponse is a student's integrity events of an exam over
+// all attempts, oldest first, with the number of events of every type.
+type IntegrityTimelineResponse struct {
+	ExamID    uuid.UUID                `json:"exam_id"`
+	StudentID uuid.UUID                `json:"student_id"`
+	Counts    map[string]int           `json:"counts"`
+	Events    []IntegrityEventResponse `json:"events"`
+}
+
+type IntegrityEventResponse struct {
+	Attempt    int    `json:"attempt"`
+	Type       string `json:"type"`
+	Source     string `json:"source"` // "client" or "server"
+	Detail     string `json:"detail"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	OccurredAt int64  `json:"occurred_at"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+type RegradeResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	ExamID        uuid.UUID  `json:"exam_id"`
+	StudentID     uuid.UUID  `json:"student_id"`
+	StudentName   string     `json:"student_name"`
+	Attempt       int        `json:"attempt"`
+	QuestionID    uuid.UUID  `json:"question_id"`
+	Reason        string     `json:"reason"`
+	Status        string     `json:"status"`
+	Comment       string     `json:"comment"`
+	PreviousScore *float64   `json:"previous_score"`
+	AdjustedScore *float64   `json:"adjusted_score"`
+	ResolvedBy    *uuid.UUID `json:"resolved_by"`
+	ResolvedAt    int64      `json:"resolved_at"`
+	CreatedAt     int64      `json:"created_at"`
+	UpdatedAt     int64      `json:"updated_at"`
+}
+
+type GetRegradeRequestsResponse []RegradeResponse
+
+// GradeHistoryResponse is every change of a student's grades of an exam,
+// oldest first. Attempt is 0 for grades of the exam set directly.
+type GradeHistoryResponse struct {
+	ExamID    uuid.UUID             `json:"exam_id"`
+	StudentID uuid.UUID             `json:"student_id"`
+	Changes   []GradeChangeResponse `json:"changes"`
+}
+
+type GradeChangeResponse struct {
+	Attempt       int        `json:"attempt"`
+	Source        string     `json:"source"` // "auto", "essay", "regrade", "direct" or "policy"
+	QuestionID    *uuid.UUID `json:"question_id,omitempty"`
+	RegradeID     *uuid.UUID `json:"regrade_id,omitempty"`
+	PreviousScore *float64   `json:"previous_score"`
+	Score         *float64   `json:"score"`
+	PreviousGrade *float64   `json:"previous_grade"`
+	Grade         *float64   `json:"grade"`
+	ChangedBy     *uuid.UUID `json:"changed_by"`
+	Note          string     `json:"note"`
+	CreatedAt     int64      `json:"created_at"`
 }
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"enuma-elish/internal/exam/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveDraft saves answers into the draft of the student's open attempt. On a
+// conflict with another session the current draft is returned along with
+// ErrDraftConflict, so the client can merge and save again on its version.
+func (s *service) SaveDraft(ctx context.Context, studentID uuid.UUID, data request.SaveDraftRequest) (response.DraftResponse, error) {
+	exam, err := s.assignedExam(ctx, data.ExamID, studentID)
+	if err != nil {
+		return response.DraftResponse{}, err
+	}
+
+	attempt, err := s.currentAttempt(ctx, exam.ID, studentID)
+	if err != nil {
+		return response.DraftResponse{}, err
+	}
+	if err := s.checkSubmission(exam, attempt, time.Now().UnixMilli()); err != nil {
+		return response.DraftResponse{}, err
+	}
+	s.trackSession(ctx, attempt, data.IP, data.UserAgent)
+
+	questions, err := s.attemptQuestions(ctx, exam.ID, attempt)
+	if err != nil {
+		return response.DraftResponse{}, err
+	}
+	for _, answer := range data.Answers {
+		if !slices.ContainsFunc(questions, func(q repository.Question) bool { return q.ID == answer.QuestionID }) {
+			return response.DraftResponse{}, commonError.ErrInvalidDraftAnswer
+		}
+	}
+
+	_, err = s.repository.SaveDraft(ctx, attempt.ID, data.BaseVersion, data.Answers)
+	if err != nil {
+		if errors.Is(err, commonError.ErrDraftConflict) {
+			draft, draftErr := s.draftResponse(ctx, attempt)
+			if draftErr != nil {
+				return response.DraftResponse{}, draftErr
+			}
+			return draft, err
+		}
+		if !errors.Is(err, commonError.ErrAttemptSubmitted) {
+			log.Err(err).Msg("Failed to save exam draft")
+		}
+		return response.DraftResponse{}, err
+	}
+
+	return s.draftResponse(ctx, attempt)
+}
+
+// FlushDrafts writes the drafts saved since the last run to Postgres, so
+// they outlive Redis and expired attempts are closed with them.
+func (s *service) FlushDrafts(ctx context.Context) error {
+	flushed, err := s.repository.FlushDrafts(ctx)
+	if flushed > 0 {
+		log.Info().Int("drafts", flushed).Msg("flushed exam drafts")
+	}
+	return err
+}
+
+func (s *service) draftResponse(ctx context.Context, attempt *repository.ExamAttempt) (response.DraftResponse, error) {
+	draft, err := s.repository.GetDraft(ctx, attempt.ID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam draft")
+		return response.DraftResponse{}, err
+	}
+
+	return response.DraftResponse{
+		ExamID:  attempt.ExamID,
+		Attempt: attempt.Number,
+		Version: draft.Version,
+		SavedAt: draft.SavedAt,
+		Answers: examAnswerResponses(draft.Answers),
+	}, nil
+}
+
+// mergeAnswers puts the answers submitted in place of the draft's answers to
+// the same questions.
+func mergeAnswers(draft, submitted []request.ExamAnswer) []request.ExamAnswer {
+	answers := make([]request.ExamAnswer, 0, len(draft)+len(submitted))
+	for _, answer := range draft {
+		if !slices.ContainsFunc(submitted, func(a request.ExamAnswer) bool { return a.QuestionID == answer.QuestionID }) {
+			answers = append(answers, answer)
+		}
+	}
+	return append(answers, submitted...)
+}
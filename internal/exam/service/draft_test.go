@@ -0,0 +1,59 @@
+package service
+
+import (
+	"enuma-elish/internal/exam/service/data/request"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMergeAnswers(t *testing.T) {
+	q1, q2, q3 := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name      string
+		draft     []request.ExamAnswer
+		submitted []request.ExamAnswer
+		want      []request.ExamAnswer
+	}{
+		{"nothing", nil, nil, []request.ExamAnswer{}},
+		{"draft only", []request.ExamAnswer{{QuestionID: q1, Answer: "draft"}}, nil, []request.ExamAnswer{{QuestionID: q1, Answer: "draft"}}},
+		{"submitted only", nil, []request.ExamAnswer{{QuestionID: q1, Answer: "submitted"}}, []request.ExamAnswer{{QuestionID: q1, Answer: "submitted"}}},
+		{
+			"submitted replaces the draft",
+			[]request.ExamAnswer{{QuestionID: q1, Answer: "draft"}, {QuestionID: q2, SelectedOption: ptr("a")}},
+			[]request.ExamAnswer{{QuestionID: q2, SelectedOption: ptr("b")}, {QuestionID: q3, Answer: "new"}},
+			[]request.ExamAnswer{{QuestionID: q1, Answer: "draft"}, {QuestionID: q2, SelectedOption: ptr("b")}, {QuestionID: q3, Answer: "new"}},
+		},
+		{
+			// An empty answer sent along clears the draft's answer
+			"submitted empty answer",
+			[]request.ExamAnswer{{QuestionID: q1, Answer: "draft"}},
+			[]request.ExamAnswer{{QuestionID: q1}},
+			[]request.ExamAnswer{{QuestionID: q1}},
+		},
+	}
+
+	for _, tt := range tests {
+		got := mergeAnswers(tt.draft, tt.submitted)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d answers, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i].QuestionID != tt.want[i].QuestionID || got[i].Answer != tt.want[i].Answer || deref(got[i].SelectedOption) != deref(tt.want[i].SelectedOption) {
+				t.Errorf("%s: answer %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestMergeAnswersKeepsDraft(t *testing.T) {
+	q1 := uuid.New()
+	draft := []request.ExamAnswer{{QuestionID: q1, Answer: "draft"}}
+
+	mergeAnswers(draft, []request.ExamAnswer{{QuestionID: q1, Answer: "submitted"}})
+	if draft[0].Answer != "draft" {
+		t.Fatalf("mergeAnswers changed the draft")
+	}
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"enuma-elish/internal/exam/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// autoGrade stores the breakdown of a newly submitted attempt. Attempts
+// without answered essays get their grade right away, the others once their
+// essays are scored.
+func (s *service) autoGrade(ctx context.Context, attempt repository.ExamAttempt, answers []request.ExamAnswer) {
+	questions, err := s.attemptQuestions(ctx, attempt.ExamID, &attempt)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam questions for auto-grading")
+		return
+	}
+
+	result := gradeSubmission(questions, answers, nil)
+	if err := s.saveResult(ctx, attempt, result, repository.GradeChange{Source: "auto"}); err != nil {
+		log.Err(err).Msg("Failed to store auto-grade result")
+	}
+}
+
+// saveResult stores the result of the attempt with the grade of the exam
+// the scoring policy derives from it and the student's other attempts.
+func (s *service) saveResult(ctx context.Context, attempt repository.ExamAttempt, result repository.ExamResult, change repository.GradeChange) error {
+	scoring, err := s.scoring(ctx, attempt.ExamID)
+	if err != nil {
+		return err
+	}
+	return s.repository.SaveAttemptResult(ctx, attempt, result, scoring, change)
+}
+
+// scoring derives the grade of the exam by its scoring policy. The
+// repository applies it to the attempts it locked, so concurrent gradings
+// never build the grade from stale attempts.
+func (s *service) scoring(ctx context.Context, examID uuid.UUID) (repository.Scoring, error) {
+	exam, err := s.repository.GetExamByID(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(attempts []repository.ExamAttempt) repository.FinalGrade {
+		return finalGrade(exam.ScoringPolicy, attempts)
+	}, nil
+}
+
+func (s *service) ScoreEssays(ctx context.Context, data request.ScoreEssaysRequest) (response.ExamResultResponse, error) {
+	attempts, err := s.repository.GetAttempts(ctx, data.ExamID, data.StudentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam attempts")
+		return response.ExamResultResponse{}, err
+	}
+
+	// The requested attempt, or else the latest submitted one
+	var attempt *repository.ExamAttempt
+	for i := range attempts {
+		if attempts[i].Answers != nil && (data.Attempt == 0 || attempts[i].Number == data.Attempt) {
+			attempt = &attempts[i]
+		}
+	}
+	if attempt == nil {
+		return response.ExamResultResponse{}, commonError.ErrExamNotSubmitted
+	}
+
+	var answers []request.ExamAnswer
+	if err := json.Unmarshal([]byte(*attempt.Answers), &answers); err != nil {
+		return response.ExamResultResponse{}, err
+	}
+
+	questions, err := s.attemptQuestions(ctx, data.ExamID, attempt)
+	if err != nil {
+		return response.ExamResultResponse{}, err
+	}
+
+	// Keep the essays scored and the questions regraded before
+	essays := scoredResults(attempt)
+
+	for _, score := range data.Scores {
+		i := slices.IndexFunc(questions, func(q repository.Question) bool { return q.ID == score.QuestionID })
+		if i < 0 || questions[i].QuestionType != "essay" {
+			return response.ExamResultResponse{}, commonError.ErrInvalidEssayScore
+		}
+
+		essay, err := scoreEssay(questions[i], score)
+		if err != nil {
+			return response.ExamResultResponse{}, err
+		}
+		essays[score.QuestionID] = essay
+	}
+
+	result := gradeSubmission(questions, answers, essays)
+	err = s.saveResult(ctx, *attempt, result, repository.GradeChange{Source: "essay", ChangedBy: changedBy(ctx)})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return response.ExamResultResponse{}, commonError.ErrExamNotSubmitted
+		}
+		log.Err(err).Msg("Failed to store essay scores")
+		return response.ExamResultResponse{}, err
+	}
+
+	return response.ExamResultResponse{
+		ExamID:    data.ExamID,
+		StudentID: data.StudentID,
+		Attempt:   attempt.Number,
+		Score:     result.Score,
+		MaxScore:  result.MaxScore,
+		Grade:     result.Grade,
+		IsGraded:  result.Grade != nil,
+		Results:   questionResultResponses(result.Questions),
+	}, nil
+}
+
+// rubric is the part of a question's rubric needed to score an essay.
+type rubric struct {
+	Criteria []struct {
+		ID     string `json:"id"`
+		Levels []struct {
+			ID     string  `json:"id"`
+			Points float64 `json:"points"`
+		} `json:"levels"`
+	} `json:"criteria"`
+}
+
+// scoreEssay turns a teacher's score into the result of the essay. A rubric
+// score is the share of the rubric's points the picked levels are worth,
+// applied to the points of the question in the exam.
+func scoreEssay(question repository.Question, score request.EssayScore) (repository.QuestionResult, error) {
+	essay := repository.QuestionResult{
+		QuestionID:   question.ID,
+		QuestionType: question.QuestionType,
+		Points:       question.Points,
+		Feedback:     score.Feedback,
+	}
+
+	if len(score.Criteria) == 0 {
+		if score.Score == nil || *score.Score > float64(question.Points) {
+			return essay, commonError.ErrInvalidEssayScore
+		}
+		essay.Score = score.Score
+		return essay, nil
+	}
+
+	if score.Score != nil || question.Rubric == nil {
+		return essay, commonError.ErrInvalidRubricScore
+	}
+	var r rubric
+	if err := json.Unmarshal([]byte(*question.Rubric), &r); err != nil {
+		return essay, err
+	}
+	if len(score.Criteria) != len(r.Criteria) {
+		return essay, commonError.ErrInvalidRubricScore
+	}
+
+	picked := make(map[string]string)
+	for _, criterion := range score.Criteria {
+		picked[criterion.CriterionID] = criterion.LevelID
+	}
+
+	var total, maxTotal float64
+	for _, criterion := range r.Criteria {
+		levelID, ok := picked[criterion.ID]
+		if !ok {
+			return essay, commonError.ErrInvalidRubricScore
+		}
+
+		found := false
+		var best float64
+		for _, level := range criterion.Levels {
+			best = max(best, level.Points)
+			if level.ID == levelID {
+				found = true
+				total += level.Points
+				essay.Criteria = append(essay.Criteria, repository.CriterionResult{
+					CriterionID: criterion.ID,
+					LevelID:     level.ID,
+					Points:      level.Points,
+				})
+			}
+		}
+		if !found {
+			return essay, commonError.ErrInvalidRubricScore
+		}
+		maxTotal += best
+	}
+	if maxTotal == 0 {
+		return essay, commonError.ErrInvalidRubricScore
+	}
+
+	points := math.Round(total/maxTotal*float64(question.Points)*100) / 100
+	essay.Score = &points
+	return essay, nil
+}
+
+// gradeSubmission scores every question by its points: scored essays and
+// regraded questions as a teacher scored them, the other types
+// automatically. Unanswered questions score 0, so only answered essays
+// without a score hold back the grade.
+func gradeSubmission(questions []repository.Question, answers []request.ExamAnswer, scored map[uuid.UUID]repository.QuestionResult) repository.ExamResult {
+	answerMap := make(map[uuid.UUID]request.ExamAnswer)
+	for _, answer := range answers {
+		answerMap[answer.QuestionID] = answer
+	}
+
+	result := repository.ExamResult{Auto: true}
+	pending := false
+	for _, question := range questions {
+		questionResult := repository.QuestionResult{
+			QuestionID:   question.ID,
+			QuestionType: question.QuestionType,
+			Points:       question.Points,
+		}
+		answer, answered := answerMap[question.ID]
+
+		if teacher, ok := scored[question.ID]; ok {
+			questionResult.Score = teacher.Score
+			questionResult.Correct = teacher.Correct
+			questionResult.Criteria = teacher.Criteria
+			questionResult.Feedback = teacher.Feedback
+			questionResult.Regraded = teacher.Regraded
+			result.Auto = false
+		} else if question.QuestionType == "essay" {
+			if !answered || strings.TrimSpace(answer.Answer) == "" {
+				score := 0.0
+				questionResult.Score = &score
+			} else {
+				pending = true
+			}
+		} else {
+			share := 0.0
+			if answered {
+				share = autoScore(question, answer)
+			}
+			score := math.Round(share*float64(question.Points)*100) / 100
+			correct := share == 1
+			questionResult.Score = &score
+			questionResult.Correct = &correct
+		}
+
+		result.MaxScore += float64(question.Points)
+		if questionResult.Score != nil {
+			result.Score += *questionResult.Score
+		}
+		result.Questions = append(result.Questions, questionResult)
+	}
+
+	if !pending && result.MaxScore > 0 {
+		grade := math.Round(result.Score/result.MaxScore*10000) / 100
+		result.Grade = &grade
+	}
+	return result
+}
+
+// scoredResults returns the results of the attempt a teacher scored: the
+// scored essays and the regraded questions.
+func scoredResults(attempt *repository.ExamAttempt) map[uuid.UUID]repository.QuestionResult {
+	scored := make(map[uuid.UUID]repository.QuestionResult)
+	for _, previous := range parseResults(attempt.Result) {
+		if (previous.QuestionType == "essay" && previous.Score != nil) || previous.Regraded {
+			scored[previous.QuestionID] = previous
+		}
+	}
+	return scored
+}
+
+// parseResults reads the stored breakdown, nil for submissions graded before
+// it was kept.
+func parseResults(result *string) []repository.QuestionResult {
+	if result == nil {
+		return nil
+	}
+	var questions []repository.QuestionResult
+	if err := json.Unmarshal([]byte(*result), &questions); err != nil {
+		log.Err(err).Msg("Failed to parse exam result")
+		return nil
+	}
+	return questions
+}
+
+func questionResultResponses(questions []repository.QuestionResult) []response.QuestionResultResponse {
+	var res []response.QuestionResultResponse
+	for _, question := range questions {
+		questionResponse := response.QuestionResultResponse{
+			QuestionID:   question.QuestionID,
+			QuestionType: question.QuestionType,
+			Points:       question.Points,
+			Score:        question.Score,
+			Correct:      question.Correct,
+			Feedback:     question.Feedback,
+			Regraded:     question.Regraded,
+		}
+		for _, criterion := range question.Criteria {
+			questionResponse.Criteria = append(questionResponse.Criteria, response.CriterionResultResponse{
+				CriterionID: criterion.CriterionID,
+				LevelID:     criterion.LevelID,
+				Points:      criterion.Points,
+			})
+		}
+		res = append(res, questionResponse)
+	}
+	return res
+}
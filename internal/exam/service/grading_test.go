@@ -0,0 +1,251 @@
+package service
+
+import (
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func choiceQuestion(points int, correct string) repository.Question {
+	return repository.Question{ID: uuid.New(), QuestionType: "multiple_choice", Points: points, CorrectAnswer: &correct}
+}
+
+func essayQuestion(points int, rubricJSON string) repository.Question {
+	question := repository.Question{ID: uuid.New(), QuestionType: "essay", Points: points}
+	if rubricJSON != "" {
+		question.Rubric = &rubricJSON
+	}
+	return question
+}
+
+func TestGradeSubmission(t *testing.T) {
+	choice := choiceQuestion(2, "a")
+	other := choiceQuestion(3, "b")
+	essay := essayQuestion(5, "")
+
+	teacherScore := 4.0
+	scoredEssay := map[uuid.UUID]repository.QuestionResult{
+		essay.ID: {QuestionID: essay.ID, QuestionType: "essay", Points: 5, Score: &teacherScore, Feedback: "Good"},
+	}
+
+	tests := []struct {
+		name     string
+		answers  []request.ExamAnswer
+		scored   map[uuid.UUID]repository.QuestionResult
+		score    float64
+		grade    *float64
+		auto     bool
+		pending  bool
+		feedback string
+	}{
+		{
+			name:    "unanswered",
+			score:   0,
+			grade:   ptr(0.0),
+			auto:    true,
+			answers: nil,
+		},
+		{
+			name: "essay waits for a score",
+			answers: []request.ExamAnswer{
+				{QuestionID: choice.ID, SelectedOption: ptr("a")},
+				{QuestionID: essay.ID, Answer: "An essay"},
+			},
+			score:   2,
+			auto:    true,
+			pending: true,
+		},
+		{
+			name: "blank essay scores 0",
+			answers: []request.ExamAnswer{
+				{QuestionID: choice.ID, SelectedOption: ptr("a")},
+				{QuestionID: other.ID, SelectedOption: ptr("b")},
+				{QuestionID: essay.ID, Answer: "  "},
+			},
+			score: 5,
+			grade: ptr(50.0),
+			auto:  true,
+		},
+		{
+			name: "scored essay",
+			answers: []request.ExamAnswer{
+				{QuestionID: choice.ID, SelectedOption: ptr("b")},
+				{QuestionID: other.ID, SelectedOption: ptr("b")},
+				{QuestionID: essay.ID, Answer: "An essay"},
+			},
+			scored:   scoredEssay,
+			score:    7,
+			grade:    ptr(70.0),
+			feedback: "Good",
+		},
+		{
+			name: "answer to another question",
+			answers: []request.ExamAnswer{
+				{QuestionID: uuid.New(), SelectedOption: ptr("a")},
+				{QuestionID: other.ID, SelectedOption: ptr("b")},
+			},
+			score: 3,
+			grade: ptr(30.0),
+			auto:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		result := gradeSubmission([]repository.Question{choice, other, essay}, tt.answers, tt.scored)
+
+		if result.MaxScore != 10 || result.Score != tt.score || result.Auto != tt.auto {
+			t.Errorf("%s: got score %v/%v auto %v, want %v/10 auto %v", tt.name, result.Score, result.MaxScore, result.Auto, tt.score, tt.auto)
+		}
+		if (result.Grade == nil) != (tt.grade == nil) || (result.Grade != nil && *result.Grade != *tt.grade) {
+			t.Errorf("%s: grade = %v, want %v", tt.name, deref(result.Grade), deref(tt.grade))
+		}
+		if len(result.Questions) != 3 {
+			t.Fatalf("%s: got %d question results, want 3", tt.name, len(result.Questions))
+		}
+		if essayResult := result.Questions[2]; (essayResult.Score == nil) != tt.pending || essayResult.Feedback != tt.feedback {
+			t.Errorf("%s: essay result = %+v", tt.name, essayResult)
+		}
+		if result.Questions[2].Correct != nil {
+			t.Errorf("%s: essay is marked correct or wrong", tt.name)
+		}
+	}
+}
+
+func TestGradeSubmissionRounds(t *testing.T) {
+	questions := []repository.Question{choiceQuestion(1, "a"), choiceQuestion(1, "a"), choiceQuestion(1, "a")}
+	answers := []request.ExamAnswer{{QuestionID: questions[0].ID, SelectedOption: ptr("a")}}
+
+	result := gradeSubmission(questions, answers, nil)
+	if result.Grade == nil || *result.Grade != 33.33 {
+		t.Fatalf("grade = %v, want 33.33", deref(result.Grade))
+	}
+	if !*result.Questions[0].Correct || *result.Questions[1].Correct {
+		t.Fatalf("correct = %v, %v, want true, false", *result.Questions[0].Correct, *result.Questions[1].Correct)
+	}
+}
+
+func TestGradeSubmissionWithoutQuestions(t *testing.T) {
+	result := gradeSubmission(nil, nil, nil)
+	if result.Grade != nil || result.MaxScore != 0 {
+		t.Fatalf("grade of an exam without questions = %v, want none", deref(result.Grade))
+	}
+}
+
+// A regraded question keeps the teacher's score when the attempt is graded
+// again, even though the answer is wrong.
+func TestGradeSubmissionKeepsRegrade(t *testing.T) {
+	question := choiceQuestion(4, "a")
+	adjusted, correct := 4.0, true
+	scored := map[uuid.UUID]repository.QuestionResult{
+		question.ID: {QuestionID: question.ID, Score: &adjusted, Correct: &correct, Regraded: true},
+	}
+
+	result := gradeSubmission([]repository.Question{question}, []request.ExamAnswer{{QuestionID: question.ID, SelectedOption: ptr("b")}}, scored)
+	if result.Score != 4 || !result.Questions[0].Regraded || result.Auto {
+		t.Fatalf("result = %+v, want the regraded score", result)
+	}
+}
+
+func TestScoredResults(t *testing.T) {
+	essay, choice, regraded := uuid.New(), uuid.New(), uuid.New()
+	score := 1.0
+	attempt := gradedAttempt(t, 1, []repository.QuestionResult{
+		{QuestionID: essay, QuestionType: "essay", Score: &score},
+		{QuestionID: uuid.New(), QuestionType: "essay"},
+		{QuestionID: choice, QuestionType: "multiple_choice", Score: &score},
+		{QuestionID: regraded, QuestionType: "numeric", Score: &score, Regraded: true},
+	})
+
+	scored := scoredResults(attempt)
+	if len(scored) != 2 {
+		t.Fatalf("got %d scored results, want the essay and the regrade", len(scored))
+	}
+	if _, ok := scored[essay]; !ok {
+		t.Errorf("scored essay missing")
+	}
+	if _, ok := scored[regraded]; !ok {
+		t.Errorf("regraded question missing")
+	}
+}
+
+func TestScoreEssay(t *testing.T) {
+	rubricJSON := `{"criteria": [
+		{"id": "content", "levels": [{"id": "weak", "points": 0}, {"id": "fair", "points": 2}, {"id": "strong", "points": 4}]},
+		{"id": "style", "levels": [{"id": "poor", "points": 1}, {"id": "good", "points": 2}]}
+	]}`
+	withRubric := essayQuestion(10, rubricJSON)
+	plain := essayQuestion(10, "")
+	empty := essayQuestion(10, `{"criteria": [{"id": "content", "levels": [{"id": "none", "points": 0}]}]}`)
+
+	criteria := func(levels ...string) []request.CriterionScore {
+		var scores []request.CriterionScore
+		for i := 0; i < len(levels); i += 2 {
+			scores = append(scores, request.CriterionScore{CriterionID: levels[i], LevelID: levels[i+1]})
+		}
+		return scores
+	}
+
+	tests := []struct {
+		name     string
+		question repository.Question
+		score    request.EssayScore
+		want     *float64
+		err      error
+	}{
+		{"score", plain, request.EssayScore{Score: ptr(7.5)}, ptr(7.5), nil},
+		{"full score", plain, request.EssayScore{Score: ptr(10.0)}, ptr(10.0), nil},
+		{"above points", plain, request.EssayScore{Score: ptr(10.5)}, nil, commonError.ErrInvalidEssayScore},
+		{"no score", plain, request.EssayScore{}, nil, commonError.ErrInvalidEssayScore},
+		{"rubric, best levels", withRubric, request.EssayScore{Criteria: criteria("content", "strong", "style", "good")}, ptr(10.0), nil},
+		{"rubric, lowest levels", withRubric, request.EssayScore{Criteria: criteria("content", "weak", "style", "poor")}, ptr(1.67), nil},
+		{"rubric, mixed", withRubric, request.EssayScore{Criteria: criteria("style", "good", "content", "fair")}, ptr(6.67), nil},
+		{"rubric and score", withRubric, request.EssayScore{Score: ptr(5.0), Criteria: criteria("content", "fair", "style", "good")}, nil, commonError.ErrInvalidRubricScore},
+		{"criteria without rubric", plain, request.EssayScore{Criteria: criteria("content", "fair")}, nil, commonError.ErrInvalidRubricScore},
+		{"missing criterion", withRubric, request.EssayScore{Criteria: criteria("content", "fair")}, nil, commonError.ErrInvalidRubricScore},
+		{"criterion twice", withRubric, request.EssayScore{Criteria: criteria("content", "fair", "content", "weak")}, nil, commonError.ErrInvalidRubricScore},
+		{"unknown criterion", withRubric, request.EssayScore{Criteria: criteria("content", "fair", "grammar", "good")}, nil, commonError.ErrInvalidRubricScore},
+		{"unknown level", withRubric, request.EssayScore{Criteria: criteria("content", "fair", "style", "great")}, nil, commonError.ErrInvalidRubricScore},
+		{"rubric without points", empty, request.EssayScore{Criteria: criteria("content", "none")}, nil, commonError.ErrInvalidRubricScore},
+	}
+
+	for _, tt := range tests {
+		tt.score.QuestionID = tt.question.ID
+		tt.score.Feedback = "Feedback"
+		result, err := scoreEssay(tt.question, tt.score)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: scoreEssay error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if result.Score == nil || *result.Score != *tt.want {
+			t.Errorf("%s: score = %v, want %v", tt.name, deref(result.Score), *tt.want)
+		}
+		if result.Points != 10 || result.Feedback != "Feedback" || result.QuestionID != tt.question.ID {
+			t.Errorf("%s: result = %+v", tt.name, result)
+		}
+		if len(result.Criteria) != len(tt.score.Criteria) {
+			t.Errorf("%s: got %d criteria, want %d", tt.name, len(result.Criteria), len(tt.score.Criteria))
+		}
+	}
+}
+
+func TestScoreEssayCriteria(t *testing.T) {
+	question := essayQuestion(4, `{"criteria": [{"id": "content", "levels": [{"id": "fair", "points": 2}, {"id": "strong", "points": 4}]}]}`)
+
+	result, err := scoreEssay(question, request.EssayScore{Criteria: []request.CriterionScore{{CriterionID: "content", LevelID: "fair"}}})
+	if err != nil {
+		t.Fatalf("scoreEssay: %v", err)
+	}
+	if len(result.Criteria) != 1 || result.Criteria[0] != (repository.CriterionResult{CriterionID: "content", LevelID: "fair", Points: 2}) {
+		t.Fatalf("criteria = %+v, want the picked level", result.Criteria)
+	}
+	if *result.Score != 2 {
+		t.Fatalf("score = %v, want 2", *result.Score)
+	}
+}
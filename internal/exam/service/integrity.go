@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"enuma-elish/internal/exam/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// ReportIntegrityEvents stores the events the client reported for the
+// student's open attempt, along with those the server detects from the
+// request itself.
+func (s *service) ReportIntegrityEvents(ctx context.Context, studentID uuid.UUID, data request.ReportIntegrityEventsRequest) error {
+	if _, err := s.assignedExam(ctx, data.ExamID, studentID); err != nil {
+		return err
+	}
+
+	attempt, err := s.currentAttempt(ctx, data.ExamID, studentID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	events := s.sessionEvents(ctx, attempt, data.IP, data.UserAgent, now)
+	for _, reported := range data.Events {
+		occurredAt := reported.OccurredAt
+		if occurredAt == 0 || occurredAt > now {
+			occurredAt = now
+		}
+		events = append(events, integrityEvent(attempt, reported.Type, "client", reported.Detail, data.IP, data.UserAgent, occurredAt))
+	}
+
+	if err := s.repository.AddIntegrityEvents(ctx, events); err != nil {
+		log.Err(err).Msg("Failed to store integrity events")
+		return err
+	}
+	return nil
+}
+
+// trackSession stores what the server detects from a request on the
+// attempt. It never fails the request, proctoring must not get in the way of
+// taking the exam.
+func (s *service) trackSession(ctx context.Context, attempt *repository.ExamAttempt, ip, userAgent string) {
+	events := s.sessionEvents(ctx, attempt, ip, userAgent, time.Now().UnixMilli())
+	if err := s.repository.AddIntegrityEvents(ctx, events); err != nil {
+		log.Err(err).Msg("Failed to store integrity events")
+	}
+}
+
+// sessionEvents records the session and IP of the request on the attempt,
+// and returns a concurrent_session event when another session is active on
+// it and an ip_change event when the IP differs from the previous request.
+func (s *service) sessionEvents(ctx context.Context, attempt *repository.ExamAttempt, ip, userAgent string, now int64) []repository.IntegrityEvent {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil || claim.Sid == "" {
+		return nil
+	}
+
+	session, err := s.repository.TrackAttemptSession(ctx, attempt.ID, claim.Sid, ip)
+	if err != nil {
+		log.Err(err).Msg("Failed to track exam attempt session")
+		return nil
+	}
+
+	var events []repository.IntegrityEvent
+	if session.ConcurrentWith != "" {
+		detail := fmt.Sprintf("session %s while session %s is active", claim.Sid, session.ConcurrentWith)
+		events = append(events, integrityEvent(attempt, "concurrent_session", "server", detail, ip, userAgent, now))
+	}
+	if session.PreviousIP != "" && ip != "" && session.PreviousIP != ip {
+		detail := fmt.Sprintf("%s to %s", session.PreviousIP, ip)
+		events = append(events, integrityEvent(attempt, "ip_change", "server", detail, ip, userAgent, now))
+	}
+	return events
+}
+
+func integrityEvent(attempt *repository.ExamAttempt, eventType, source, detail, ip, userAgent string, occurredAt int64) repository.IntegrityEvent {
+	return repository.IntegrityEvent{
+		ID:         uuid.New(),
+		AttemptID:  attempt.ID,
+		ExamID:     attempt.ExamID,
+		StudentID:  attempt.StudentID,
+		Type:       eventType,
+		Source:     source,
+		Detail:     detail,
+		IP:         ip,
+		UserAgent:  userAgent,
+		OccurredAt: occurredAt,
+		CreatedAt:  time.Now().UnixMilli(),
+	}
+}
+
+func (s *service) GetExamStudentIntegrity(ctx context.Context, examID, studentID uuid.UUID) (response.IntegrityTimelineResponse, error) {
+	events, err := s.repository.GetIntegrityEvents(ctx, examID, studentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return response.IntegrityTimelineResponse{}, commonError.ErrExamNotFound
+		}
+		log.Err(err).Msg("Failed to get integrity events")
+		return response.IntegrityTimelineResponse{}, err
+	}
+
+	res := response.IntegrityTimelineResponse{
+		ExamID:    examID,
+		StudentID: studentID,
+		Counts:    make(map[string]int),
+	}
+	for _, event := range events {
+		res.Counts[event.Type]++
+		res.Events = append(res.Events, response.IntegrityEventResponse{
+			Attempt:    event.Attempt,
+			Type:       event.Type,
+			Source:     event.Source,
+			Detail:     event.Detail,
+			IP:         event.IP,
+			UserAgent:  event.UserAgent,
+			OccurredAt: event.OccurredAt,
+			CreatedAt:  event.CreatedAt,
+		})
+	}
+	return res, nil
+}
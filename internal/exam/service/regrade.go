@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"enuma-elish/internal/exam/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	commonHttp "enuma-elish/pkg/http"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/mailer"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// CreateRegradeRequest opens a regrade request on a question of one of the
+// student's attempts. Only graded attempts whose grades the student sees
+// can be disputed, so grades held back by the release mode cannot.
+func (s *service) CreateRegradeRequest(ctx context.Context, studentID uuid.UUID, data request.CreateRegradeRequest) (response.RegradeResponse, error) {
+	if _, err := s.assignedExam(ctx, data.ExamID, studentID); err != nil {
+		return response.RegradeResponse{}, err
+	}
+
+	exam, err := s.repository.GetStudentExamDetail(ctx, data.ExamID, studentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam")
+		return response.RegradeResponse{}, err
+	}
+
+	attempts, err := s.repository.GetAttempts(ctx, data.ExamID, studentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam attempts")
+		return response.RegradeResponse{}, err
+	}
+
+	now := time.Now().UnixMilli()
+	i := slices.IndexFunc(attempts, func(a repository.ExamAttempt) bool { return a.Number == data.Attempt })
+	if i < 0 || attempts[i].Grade == nil || !s.gradesReleased(exam, now) {
+		return response.RegradeResponse{}, commonError.ErrRegradeNotGraded
+	}
+	attempt := attempts[i]
+
+	results := parseResults(attempt.Result)
+	if !slices.ContainsFunc(results, func(q repository.QuestionResult) bool { return q.QuestionID == data.QuestionID }) {
+		return response.RegradeResponse{}, commonError.ErrRegradeNotInAttempt
+	}
+
+	regrade := repository.RegradeRequest{
+		ID:         uuid.New(),
+		ExamID:     data.ExamID,
+		AttemptID:  attempt.ID,
+		StudentID:  studentID,
+		QuestionID: data.QuestionID,
+		Reason:     data.Reason,
+		Status:     "pending",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Attempt:    attempt.Number,
+	}
+	err = s.repository.CreateRegradeRequest(ctx, regrade)
+	if err != nil {
+		if !errors.Is(err, commonError.ErrRegradePending) {
+			log.Err(err).Msg("Failed to create regrade request")
+		}
+		return response.RegradeResponse{}, err
+	}
+
+	return regradeResponse(regrade), nil
+}
+
+func (s *service) GetStudentRegradeRequests(ctx context.Context, examID, studentID uuid.UUID) (response.GetRegradeRequestsResponse, error) {
+	if _, err := s.assignedExam(ctx, examID, studentID); err != nil {
+		return nil, err
+	}
+
+	regrades, err := s.repository.GetStudentRegradeRequests(ctx, examID, studentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get regrade requests")
+		return nil, err
+	}
+
+	exam, err := s.repository.GetStudentExamDetail(ctx, examID, studentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam")
+		return nil, err
+	}
+	attempt, err := s.repository.GetLatestAttempt(ctx, examID, studentID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Err(err).Msg("Failed to get exam attempt")
+		return nil, err
+	}
+
+	// The scores of a question give the answer key away like the results do
+	revealed := s.answersRevealed(exam, attempt, time.Now().UnixMilli())
+
+	var res response.GetRegradeRequestsResponse
+	for _, regrade := range regrades {
+		item := regradeResponse(regrade)
+		if !revealed {
+			item.PreviousScore = nil
+			item.AdjustedScore = nil
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+func (s *service) GetRegradeRequests(ctx context.Context, examID uuid.UUID, query request.GetRegradeRequestsQuery) (response.GetRegradeRequestsResponse, *commonHttp.Meta, error) {
+	regrades, total, err := s.repository.GetRegradeRequests(ctx, examID, query)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, commonError.ErrExamNotFound
+		}
+		log.Err(err).Msg("Failed to get regrade requests")
+		return nil, nil, err
+	}
+
+	var res response.GetRegradeRequestsResponse
+	for _, regrade := range regrades {
+		res = append(res, regradeResponse(regrade))
+	}
+
+	meta := commonHttp.NewMetaFromQuery(query, total)
+	return res, meta, nil
+}
+
+// ResolveRegradeRequest accepts or rejects a pending regrade request and
+// lets the student know. Accepting regrades the attempt with the adjusted
+// score of the question, which later essay scoring keeps, and the grade of
+// the exam follows from it.
+func (s *service) ResolveRegradeRequest(ctx context.Context, data request.ResolveRegradeRequest) (response.RegradeResponse, error) {
+	regrade, err := s.repository.GetRegradeRequest(ctx, data.RegradeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return response.RegradeResponse{}, commonError.ErrRegradeNotFound
+		}
+		log.Err(err).Msg("Failed to get regrade request")
+		return response.RegradeResponse{}, err
+	}
+	if regrade.Status != "pending" {
+		return response.RegradeResponse{}, commonError.ErrRegradeResolved
+	}
+
+	attempts, err := s.repository.GetAttempts(ctx, regrade.ExamID, regrade.StudentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam attempts")
+		return response.RegradeResponse{}, err
+	}
+	i := slices.IndexFunc(attempts, func(a repository.ExamAttempt) bool { return a.ID == regrade.AttemptID })
+	if i < 0 {
+		return response.RegradeResponse{}, commonError.ErrRegradeNotFound
+	}
+	attempt := attempts[i]
+
+	now := time.Now().UnixMilli()
+	regrade.Status = data.Status
+	regrade.Comment = data.Comment
+	regrade.ResolvedBy = changedBy(ctx)
+	regrade.ResolvedAt = now
+	regrade.UpdatedAt = now
+
+	results := parseResults(attempt.Result)
+	if j := slices.IndexFunc(results, func(q repository.QuestionResult) bool { return q.QuestionID == regrade.QuestionID }); j >= 0 {
+		regrade.PreviousScore = results[j].Score
+	}
+
+	var result *repository.ExamResult
+	var scoring repository.Scoring
+	if data.Status == "accepted" {
+		questions, err := s.attemptQuestions(ctx, regrade.ExamID, &attempt)
+		if err != nil {
+			return response.RegradeResponse{}, err
+		}
+		j := slices.IndexFunc(questions, func(q repository.Question) bool { return q.ID == regrade.QuestionID })
+		if j < 0 || data.Score == nil || *data.Score > float64(questions[j].Points) {
+			return response.RegradeResponse{}, commonError.ErrInvalidRegradeScore
+		}
+
+		scored := scoredResults(&attempt)
+		regraded := repository.QuestionResult{
+			QuestionID:   questions[j].ID,
+			QuestionType: questions[j].QuestionType,
+			Points:       questions[j].Points,
+			Score:        data.Score,
+			Feedback:     scored[regrade.QuestionID].Feedback,
+			Regraded:     true,
+		}
+		if questions[j].QuestionType != "essay" {
+			correct := *data.Score == float64(questions[j].Points)
+			regraded.Correct = &correct
+		}
+		scored[regrade.QuestionID] = regraded
+
+		graded := gradeSubmission(questions, parseAnswers(attempt.Answers), scored)
+		scoring, err = s.scoring(ctx, regrade.ExamID)
+		if err != nil {
+			log.Err(err).Msg("Failed to get exam")
+			return response.RegradeResponse{}, err
+		}
+		result = &graded
+		regrade.AdjustedScore = data.Score
+	}
+
+	err = s.repository.ResolveRegradeRequest(ctx, *regrade, attempt, result, scoring)
+	if err != nil {
+		if !errors.Is(err, commonError.ErrRegradeResolved) {
+			log.Err(err).Msg("Failed to resolve regrade request")
+		}
+		return response.RegradeResponse{}, err
+	}
+
+	err = s.mail.Enqueue(ctx, mailer.Email{
+		To:       regrade.StudentEmail,
+		Template: mailer.TemplateRegradeResolved,
+		SchoolID: regrade.SchoolID,
+		Data:     mailer.Data{Name: regrade.StudentName, Exam: regrade.ExamName, Status: regrade.Status},
+	})
+	if err != nil {
+		log.Err(err).Str("email", regrade.StudentEmail).Msg("Failed to enqueue regrade email")
+	}
+
+	return regradeResponse(*regrade), nil
+}
+
+func (s *service) GetExamStudentGradeHistory(ctx context.Context, examID, studentID uuid.UUID) (response.GradeHistoryResponse, error) {
+	changes, err := s.repository.GetGradeChanges(ctx, examID, studentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return response.GradeHistoryResponse{}, commonError.ErrExamNotFound
+		}
+		log.Err(err).Msg("Failed to get grade history")
+		return response.GradeHistoryResponse{}, err
+	}
+
+	res := response.GradeHistoryResponse{ExamID: examID, StudentID: studentID}
+	for _, change := range changes {
+		res.Changes = append(res.Changes, response.GradeChangeResponse{
+			Attempt:       change.Attempt,
+			Source:        change.Source,
+			QuestionID:    change.QuestionID,
+			RegradeID:     change.RegradeID,
+			PreviousScore: change.PreviousScore,
+			Score:         change.Score,
+			PreviousGrade: change.PreviousGrade,
+			Grade:         change.Grade,
+			ChangedBy:     change.ChangedBy,
+			Note:          change.Note,
+			CreatedAt:     change.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+// changedBy is the user changing a grade, for the grade history.
+func changedBy(ctx context.Context) *uuid.UUID {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil || claim.User.ID == uuid.Nil {
+		return nil
+	}
+	return &claim.User.ID
+}
+
+func regradeResponse(regrade repository.RegradeRequest) response.RegradeResponse {
+	return response.RegradeResponse{
+		ID:            regrade.ID,
+		ExamID:        regrade.ExamID,
+		StudentID:     regrade.StudentID,
+		StudentName:   regrade.StudentName,
+		Attempt:       regrade.Attempt,
+		QuestionID:    regrade.QuestionID,
+		Reason:        regrade.Reason,
+		Status:        regrade.Status,
+		Comment:       regrade.Comment,
+		PreviousScore: regrade.PreviousScore,
+		AdjustedScore: regrade.AdjustedScore,
+		ResolvedBy:    regrade.ResolvedBy,
+		ResolvedAt:    regrade.ResolvedAt,
+		CreatedAt:     regrade.CreatedAt,
+		UpdatedAt:     regrade.UpdatedAt,
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"enuma-elish/internal/exam/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// ReleaseGrades releases the grades of the exam to students now, whatever
+// its release mode; WithdrawGrades takes a release back.
+func (s *service) ReleaseGrades(ctx context.Context, examID uuid.UUID) error {
+	return s.releaseGrades(ctx, examID, time.Now().UnixMilli())
+}
+
+func (s *service) WithdrawGrades(ctx context.Context, examID uuid.UUID) error {
+	return s.releaseGrades(ctx, examID, 0)
+}
+
+func (s *service) releaseGrades(ctx context.Context, examID uuid.UUID, releasedAt int64) error {
+	err := s.repository.ReleaseGrades(ctx, examID, releasedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return commonError.ErrExamNotFound
+		}
+		log.Err(err).Msg("Failed to release exam grades")
+		return err
+	}
+	return nil
+}
+
+func (s *service) SetFeedback(ctx context.Context, data request.FeedbackRequest) error {
+	assigned, err := s.repository.IsExamAssigned(ctx, data.ExamID, data.StudentID)
+	if err != nil {
+		log.Err(err).Msg("Failed to check exam assignment")
+		return err
+	}
+	if !assigned {
+		return commonError.ErrExamNotFound
+	}
+
+	err = s.repository.SetFeedback(ctx, data.ExamID, data.StudentID, data.Feedback)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return commonError.ErrExamNotFound
+		}
+		log.Err(err).Msg("Failed to store exam feedback")
+		return err
+	}
+	return nil
+}
+
+// gradesReleased reports whether students see their grades, results and
+// feedback of the exam. "after_close" waits out the submission grace too,
+// so nobody still submitting sees them.
+func (s *service) gradesReleased(exam *repository.StudentExam, now int64) bool {
+	if exam.ReleasedAt > 0 {
+		return true
+	}
+	switch exam.ReleaseMode {
+	case "after_close":
+		return exam.EndAt > 0 && now > exam.EndAt+int64(s.config.Exam.SubmitGrace)*1000
+	case "manual":
+		return false
+	default:
+		return true
+	}
+}
+
+// answersRevealed reports whether the student sees the correct answers and
+// explanations the exam shows: once the grades are released and the student
+// cannot take another attempt, as the exam closed or they used them all.
+func (s *service) answersRevealed(exam *repository.StudentExam, attempt *repository.ExamAttempt, now int64) bool {
+	if attempt == nil || attempt.Answers == nil || !s.gradesReleased(exam, now) {
+		return false
+	}
+	closed := exam.EndAt > 0 && now > exam.EndAt+int64(s.config.Exam.SubmitGrace)*1000
+	return closed || attempt.Number >= exam.MaxAttempts
+}
+
+// answerKeyResponse shows the stored answer key of a question.
+func answerKeyResponse(keyJSON *string) *response.AnswerKeyResponse {
+	if keyJSON == nil {
+		return nil
+	}
+	var key answerKey
+	if err := json.Unmarshal([]byte(*keyJSON), &key); err != nil {
+		log.Err(err).Msg("Failed to parse answer key")
+		return nil
+	}
+	return &response.AnswerKeyResponse{
+		Boolean:       key.Boolean,
+		Options:       key.Options,
+		Accepted:      key.Accepted,
+		Patterns:      key.Patterns,
+		CaseSensitive: key.CaseSensitive,
+		Number:        key.Number,
+		Tolerance:     key.Tolerance,
+		Pairs:         key.Pairs,
+		Order:         key.Order,
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"encoding/json"
+	"enuma-elish/config"
+	"enuma-elish/internal/exam/repository"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func testService() *service {
+	return &service{config: &config.Config{Exam: config.Exam{SubmitGrace: 60}}}
+}
+
+func gradedAttempt(t *testing.T, number int, results []repository.QuestionResult) *repository.ExamAttempt {
+	t.Helper()
+	answers := "[]"
+	result, err := json.Marshal(results)
+	if err != nil {
+		t.Fatalf("marshal results: %v", err)
+	}
+	resultJSON := string(result)
+	grade := 50.0
+	return &repository.ExamAttempt{Number: number, Answers: &answers, Result: &resultJSON, Grade: &grade}
+}
+
+// An exam with attempts left releases grades without revealing the answers,
+// so the results must not tell which questions were answered correctly.
+func TestStudentResultsWithAttemptsLeft(t *testing.T) {
+	s := testService()
+	now := int64(1_000_000)
+	exam := &repository.StudentExam{MaxAttempts: 3, ReleaseMode: "immediate", ShowCorrect: true}
+
+	full, none := 1.0, 0.0
+	correct, wrong := true, false
+	results := []repository.QuestionResult{
+		{QuestionID: uuid.New(), QuestionType: "multiple_choice", Points: 1, Score: &full, Correct: &correct},
+		{QuestionID: uuid.New(), QuestionType: "true_false", Points: 1, Score: &none, Correct: &wrong, Feedback: "Read again"},
+	}
+
+	first := gradedAttempt(t, 1, results)
+	if !s.gradesReleased(exam, now) {
+		t.Fatalf("grades of an immediate exam are not released")
+	}
+	if s.answersRevealed(exam, first, now) {
+		t.Fatalf("answers revealed with attempts left")
+	}
+
+	hidden := studentResults(first, s.answersRevealed(exam, first, now))
+	if len(hidden) != 2 {
+		t.Fatalf("got %d results, want 2", len(hidden))
+	}
+	for _, result := range hidden {
+		if result.Score != nil || result.Correct != nil {
+			t.Errorf("result of %s shows score %v and correct %v with attempts left", result.QuestionType, result.Score, result.Correct)
+		}
+	}
+	if hidden[1].Feedback != "Read again" {
+		t.Errorf("feedback = %q, want it kept", hidden[1].Feedback)
+	}
+
+	last := gradedAttempt(t, 3, results)
+	if !s.answersRevealed(exam, last, now) {
+		t.Fatalf("answers not revealed after the last attempt")
+	}
+	shown := studentResults(last, true)
+	if shown[0].Score == nil || *shown[0].Score != 1 || shown[0].Correct == nil || !*shown[0].Correct {
+		t.Errorf("revealed result = %+v, want the score and correct", shown[0])
+	}
+}
+
+func TestGradesReleased(t *testing.T) {
+	s := testService()
+	endAt := int64(1_000_000)
+	grace := int64(60_000)
+
+	tests := []struct {
+		name string
+		exam repository.StudentExam
+		now  int64
+		want bool
+	}{
+		{"immediate", repository.StudentExam{ReleaseMode: "immediate"}, 0, true},
+		{"default mode", repository.StudentExam{}, 0, true},
+		{"manual", repository.StudentExam{ReleaseMode: "manual"}, endAt * 2, false},
+		{"manual released", repository.StudentExam{ReleaseMode: "manual", ReleasedAt: 1}, 0, true},
+		{"after close, open", repository.StudentExam{ReleaseMode: "after_close", EndAt: endAt}, endAt - 1, false},
+		{"after close, in grace", repository.StudentExam{ReleaseMode: "after_close", EndAt: endAt}, endAt + grace, false},
+		{"after close, closed", repository.StudentExam{ReleaseMode: "after_close", EndAt: endAt}, endAt + grace + 1, true},
+		{"after close without end", repository.StudentExam{ReleaseMode: "after_close"}, endAt * 2, false},
+		{"after close released", repository.StudentExam{ReleaseMode: "after_close", EndAt: endAt, ReleasedAt: 1}, 0, true},
+	}
+
+	for _, tt := range tests {
+		if got := s.gradesReleased(&tt.exam, tt.now); got != tt.want {
+			t.Errorf("%s: gradesReleased = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnswersRevealed(t *testing.T) {
+	s := testService()
+	endAt := int64(1_000_000)
+	grace := int64(60_000)
+	answers := "[]"
+	submitted := func(number int) *repository.ExamAttempt {
+		return &repository.ExamAttempt{Number: number, Answers: &answers}
+	}
+
+	tests := []struct {
+		name    string
+		exam    repository.StudentExam
+		attempt *repository.ExamAttempt
+		now     int64
+		want    bool
+	}{
+		{"no attempt", repository.StudentExam{MaxAttempts: 1}, nil, 0, false},
+		{"open attempt", repository.StudentExam{MaxAttempts: 1}, &repository.ExamAttempt{Number: 1}, 0, false},
+		{"last attempt", repository.StudentExam{MaxAttempts: 1}, submitted(1), 0, true},
+		{"attempts left", repository.StudentExam{MaxAttempts: 2}, submitted(1), 0, false},
+		{"attempts left, closed", repository.StudentExam{MaxAttempts: 2, EndAt: endAt}, submitted(1), endAt + grace + 1, true},
+		{"attempts left, in grace", repository.StudentExam{MaxAttempts: 2, EndAt: endAt}, submitted(1), endAt + grace, false},
+		{"not released", repository.StudentExam{MaxAttempts: 1, ReleaseMode: "manual"}, submitted(1), 0, false},
+	}
+
+	for _, tt := range tests {
+		if got := s.answersRevealed(&tt.exam, tt.attempt, tt.now); got != tt.want {
+			t.Errorf("%s: answersRevealed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
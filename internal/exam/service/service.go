@@ -2,12 +2,16 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"enuma-elish/config"
 	"enuma-elish/internal/exam/repository"
 	"enuma-elish/internal/exam/service/data/request"
 	"enuma-elish/internal/exam/service/data/response"
+	commonError "enuma-elish/pkg/error"
 	commonHttp "enuma-elish/pkg/http"
+	"enuma-elish/pkg/mailer"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,23 +27,44 @@ type Service interface {
 
 	AssignExamToClass(ctx context.Context, data request.AssignExamToClassRequest) error
 	GradeExam(ctx context.Context, data request.GradeExamRequest) error
+	SetFeedback(ctx context.Context, data request.FeedbackRequest) error
+	ReleaseGrades(ctx context.Context, examID uuid.UUID) error
+	WithdrawGrades(ctx context.Context, examID uuid.UUID) error
+	ScoreEssays(ctx context.Context, data request.ScoreEssaysRequest) (response.ExamResultResponse, error)
 	GetExamStudents(ctx context.Context, examID uuid.UUID, query request.GetExamStudentsQuery) (response.GetExamStudentsResponse, *commonHttp.Meta, error)
+	GetExamStudentAttempts(ctx context.Context, examID, studentID uuid.UUID) (response.AttemptHistoryResponse, error)
+	GetExamStudentIntegrity(ctx context.Context, examID, studentID uuid.UUID) (response.IntegrityTimelineResponse, error)
+	GetExamStudentGradeHistory(ctx context.Context, examID, studentID uuid.UUID) (response.GradeHistoryResponse, error)
+	GetRegradeRequests(ctx context.Context, examID uuid.UUID, query request.GetRegradeRequestsQuery) (response.GetRegradeRequestsResponse, *commonHttp.Meta, error)
+	ResolveRegradeRequest(ctx context.Context, data request.ResolveRegradeRequest) (response.RegradeResponse, error)
 
 	// Student exam operations
 	SubmitExamAnswers(ctx context.Context, studentID uuid.UUID, data request.SubmitExamAnswersRequest) error
+	SaveDraft(ctx context.Context, studentID uuid.UUID, data request.SaveDraftRequest) (response.DraftResponse, error)
+	ReportIntegrityEvents(ctx context.Context, studentID uuid.UUID, data request.ReportIntegrityEventsRequest) error
 	GetStudentExams(ctx context.Context, studentID uuid.UUID, query request.GetStudentExamsQuery) (response.GetStudentExamsResponse, *commonHttp.Meta, error)
 	GetStudentExamDetail(ctx context.Context, examID, studentID uuid.UUID) (response.StudentExamDetailResponse, error)
+	StartExamAttempt(ctx context.Context, examID, studentID uuid.UUID) (response.ExamAttemptResponse, error)
+	GetStudentAttempts(ctx context.Context, examID, studentID uuid.UUID) (response.AttemptHistoryResponse, error)
+	CreateRegradeRequest(ctx context.Context, studentID uuid.UUID, data request.CreateRegradeRequest) (response.RegradeResponse, error)
+	GetStudentRegradeRequests(ctx context.Context, examID, studentID uuid.UUID) (response.GetRegradeRequestsResponse, error)
+
+	// CloseExpiredAttempts and FlushDrafts are run by the scheduler
+	CloseExpiredAttempts(ctx context.Context) error
+	FlushDrafts(ctx context.Context) error
 }
 
 type service struct {
 	config     *config.Config
 	repository repository.Repository
+	mail       *mailer.Sender
 }
 
-func New(config *config.Config, repository repository.Repository) Service {
+func New(config *config.Config, repository repository.Repository, mail *mailer.Sender) Service {
 	return &service{
 		config:     config,
 		repository: repository,
+		mail:       mail,
 	}
 }
 
@@ -48,23 +73,50 @@ func (s *service) CreateExam(ctx context.Context, data request.CreateExamRequest
 	if err := data.Validate(); err != nil {
 		return err
 	}
+	if !data.ValidWindow() {
+		return commonError.ErrInvalidExamWindow
+	}
+	if !data.ValidRelease() {
+		return commonError.ErrInvalidReleaseMode
+	}
 
+	maxAttempts, scoringPolicy := data.Attempts()
 	now := time.Now().UnixMilli()
 	exam := repository.Exam{
-		ID:        uuid.New(),
-		Name:      data.Name,
-		SchoolID:  data.SchoolID,
-		SubjectID: data.SubjectID,
-		CreatedAt: now,
-		UpdatedAt: 0,
+		ID:               uuid.New(),
+		Name:             data.Name,
+		SchoolID:         data.SchoolID,
+		SubjectID:        data.SubjectID,
+		StartAt:          data.StartAt,
+		EndAt:            data.EndAt,
+		DurationMinutes:  data.DurationMinutes,
+		ShuffleQuestions: data.ShuffleQuestions,
+		ShuffleOptions:   data.ShuffleOptions,
+		DrawPerStudent:   data.DrawPerStudent,
+		MaxAttempts:      maxAttempts,
+		ScoringPolicy:    scoringPolicy,
+		ReleaseMode:      data.Release(),
+		ShowCorrect:      data.ShowCorrectAnswers,
+		ShowExplanations: data.ShowExplanations,
+		CreatedAt:        now,
+		UpdatedAt:        0,
+	}
+
+	blueprint := blueprintRules(data)
+	questionIDs := data.AllQuestionIDs()
+	if len(blueprint) > 0 {
+		// Exams drawn per student draw here only to check the bank can fill
+		// the blueprint
+		drawn, err := s.drawQuestions(ctx, data.SchoolID, blueprint, questionIDs)
+		if err != nil {
+			return err
+		}
+		if !data.DrawPerStudent {
+			questionIDs = append(questionIDs, drawn...)
+		}
 	}
 
-	// Combine all question IDs
-	var allQuestionIDs []uuid.UUID
-	allQuestionIDs = append(allQuestionIDs, data.MultipleChoiceIDs...)
-	allQuestionIDs = append(allQuestionIDs, data.EssayQuestionIDs...)
-
-	err := s.repository.CreateExam(ctx, exam, allQuestionIDs)
+	err := s.repository.CreateExam(ctx, exam, questionIDs, data.QuestionPoints, blueprint)
 	if err != nil {
 		log.Err(err).Msg("Failed to create exam")
 		return err
@@ -93,23 +145,33 @@ func (s *service) GetDetailExam(ctx context.Context, examID uuid.UUID) (response
 		return response.DetailExamResponse{}, err
 	}
 
+	blueprint, err := s.repository.GetExamBlueprint(ctx, examID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get exam blueprint")
+		return response.DetailExamResponse{}, err
+	}
+
 	var questionResponses []response.ExamQuestionResponse
 	for _, question := range questions {
 		questionResponse := response.ExamQuestionResponse{
 			ID:            question.ID,
 			Question:      question.Question,
 			QuestionType:  question.QuestionType,
+			Points:        question.Points,
 			CorrectAnswer: question.CorrectAnswer, // Include for teachers
+			AnswerKey:     answerKeyResponse(question.AnswerKey),
+			Explanation:   question.Explanation,
 		}
 
-		// Parse options for multiple choice questions
-		if question.QuestionType == "multiple_choice" && question.Options != nil {
+		// Parse options of the question types that have them
+		if question.Options != nil {
 			var options []map[string]string
 			if err := json.Unmarshal([]byte(*question.Options), &options); err == nil {
 				for _, option := range options {
 					questionResponse.Options = append(questionResponse.Options, response.QuestionOptionResponse{
 						ID:   option["id"],
 						Text: option["text"],
+						Side: option["side"],
 					})
 				}
 			}
@@ -119,14 +181,27 @@ func (s *service) GetDetailExam(ctx context.Context, examID uuid.UUID) (response
 	}
 
 	res := response.DetailExamResponse{
-		ID:          exam.ID,
-		Name:        exam.Name,
-		SchoolID:    exam.SchoolID,
-		SubjectID:   exam.SubjectID,
-		SubjectName: exam.SubjectName,
-		Questions:   questionResponses,
-		CreatedAt:   exam.CreatedAt,
-		UpdatedAt:   exam.UpdatedAt,
+		ID:               exam.ID,
+		Name:             exam.Name,
+		SchoolID:         exam.SchoolID,
+		SubjectID:        exam.SubjectID,
+		SubjectName:      exam.SubjectName,
+		StartAt:          exam.StartAt,
+		EndAt:            exam.EndAt,
+		DurationMinutes:  exam.DurationMinutes,
+		ShuffleQuestions: exam.ShuffleQuestions,
+		ShuffleOptions:   exam.ShuffleOptions,
+		DrawPerStudent:   exam.DrawPerStudent,
+		Blueprint:        blueprintResponses(blueprint),
+		MaxAttempts:      exam.MaxAttempts,
+		ScoringPolicy:    exam.ScoringPolicy,
+		ReleaseMode:      exam.ReleaseMode,
+		ReleasedAt:       exam.ReleasedAt,
+		ShowCorrect:      exam.ShowCorrect,
+		ShowExplanations: exam.ShowExplanations,
+		Questions:        questionResponses,
+		CreatedAt:        exam.CreatedAt,
+		UpdatedAt:        exam.UpdatedAt,
 	}
 
 	return res, nil
@@ -142,13 +217,25 @@ func (s *service) GetListExams(ctx context.Context, query request.GetListExamQue
 	res := response.GetListExamResponse{}
 	for _, exam := range exams {
 		res = append(res, response.ExamResponse{
-			ID:          exam.ID,
-			Name:        exam.Name,
-			SchoolID:    exam.SchoolID,
-			SubjectID:   exam.SubjectID,
-			SubjectName: exam.SubjectName,
-			CreatedAt:   exam.CreatedAt,
-			UpdatedAt:   exam.UpdatedAt,
+			ID:               exam.ID,
+			Name:             exam.Name,
+			SchoolID:         exam.SchoolID,
+			SubjectID:        exam.SubjectID,
+			SubjectName:      exam.SubjectName,
+			StartAt:          exam.StartAt,
+			EndAt:            exam.EndAt,
+			DurationMinutes:  exam.DurationMinutes,
+			ShuffleQuestions: exam.ShuffleQuestions,
+			ShuffleOptions:   exam.ShuffleOptions,
+			DrawPerStudent:   exam.DrawPerStudent,
+			MaxAttempts:      exam.MaxAttempts,
+			ScoringPolicy:    exam.ScoringPolicy,
+			ReleaseMode:      exam.ReleaseMode,
+			ReleasedAt:       exam.ReleasedAt,
+			ShowCorrect:      exam.ShowCorrect,
+			ShowExplanations: exam.ShowExplanations,
+			CreatedAt:        exam.CreatedAt,
+			UpdatedAt:        exam.UpdatedAt,
 		})
 	}
 
@@ -157,16 +244,41 @@ func (s *service) GetListExams(ctx context.Context, query request.GetListExamQue
 }
 
 func (s *service) UpdateExam(ctx context.Context, examID uuid.UUID, data request.CreateExamRequest) error {
-	now := time.Now().UnixMilli()
-	exam := repository.Exam{
-		Name:      data.Name,
-		SubjectID: data.SubjectID,
-		UpdatedAt: now,
+	if !data.ValidWindow() {
+		return commonError.ErrInvalidExamWindow
+	}
+	if !data.ValidRelease() {
+		return commonError.ErrInvalidReleaseMode
 	}
 
-	err := s.repository.UpdateExam(ctx, examID, exam)
+	maxAttempts, scoringPolicy := data.Attempts()
+	now := time.Now().UnixMilli()
+	exam := repository.Exam{
+		Name:             data.Name,
+		SubjectID:        data.SubjectID,
+		StartAt:          data.StartAt,
+		EndAt:            data.EndAt,
+		DurationMinutes:  data.DurationMinutes,
+		ShuffleQuestions: data.ShuffleQuestions,
+		ShuffleOptions:   data.ShuffleOptions,
+		MaxAttempts:      maxAttempts,
+		ScoringPolicy:    scoringPolicy,
+		ReleaseMode:      data.Release(),
+		ShowCorrect:      data.ShowCorrectAnswers,
+		ShowExplanations: data.ShowExplanations,
+		UpdatedAt:        now,
+	}
+
+	scoring := func(attempts []repository.ExamAttempt) repository.FinalGrade {
+		return finalGrade(scoringPolicy, attempts)
+	}
+
+	change := repository.GradeChange{Source: "policy", ChangedBy: changedBy(ctx), Note: "scoring policy changed to " + scoringPolicy}
+	err := s.repository.UpdateExam(ctx, examID, exam, scoring, change)
 	if err != nil {
-		log.Err(err).Msg("Failed to update exam")
+		if !errors.Is(err, commonError.ErrMaxAttemptsUsed) {
+			log.Err(err).Msg("Failed to update exam")
+		}
 		return err
 	}
 
@@ -192,9 +304,12 @@ func (s *service) AssignExamToClass(ctx context.Context, data request.AssignExam
 }
 
 func (s *service) GradeExam(ctx context.Context, data request.GradeExamRequest) error {
-	err := s.repository.GradeExam(ctx, data.ExamID, data.StudentID, data.Grade)
+	change := repository.GradeChange{Source: "direct", ChangedBy: changedBy(ctx)}
+	err := s.repository.GradeExam(ctx, data.ExamID, data.StudentID, data.Grade, change)
 	if err != nil {
-		log.Err(err).Msg("Failed to grade exam")
+		if !errors.Is(err, commonError.ErrGradedByAttempts) {
+			log.Err(err).Msg("Failed to grade exam")
+		}
 		return err
 	}
 	return nil
@@ -210,13 +325,19 @@ func (s *service) GetExamStudents(ctx context.Context, examID uuid.UUID, query r
 	var res response.GetExamStudentsResponse
 	for _, student := range students {
 		res = append(res, response.ExamStudentResponse{
-			ID:        student.ID,
-			Name:      student.Name,
-			Email:     student.Email,
-			Grade:     student.Grade,
-			IsGraded:  student.Grade != nil,
-			CreatedAt: student.CreatedAt,
-			UpdatedAt: student.UpdatedAt,
+			ID:              student.ID,
+			Name:            student.Name,
+			Email:           student.Email,
+			Grade:           student.Grade,
+			Score:           student.Score,
+			MaxScore:        student.MaxScore,
+			IsGraded:        student.Grade != nil,
+			Attempts:        student.Attempts,
+			Feedback:        student.Feedback,
+			IntegrityEvents: student.IntegrityEvents,
+			IntegrityFlags:  student.IntegrityFlags,
+			CreatedAt:       student.CreatedAt,
+			UpdatedAt:       student.UpdatedAt,
 		})
 	}
 
@@ -224,106 +345,52 @@ func (s *service) GetExamStudents(ctx context.Context, examID uuid.UUID, query r
 	return res, meta, nil
 }
 
+// SubmitExamAnswers submits the student's open attempt, which has to be
+// started first.
 func (s *service) SubmitExamAnswers(ctx context.Context, studentID uuid.UUID, data request.SubmitExamAnswersRequest) error {
-	// Submit answers first
-	err := s.repository.SubmitExamAnswers(ctx, data.ExamID, studentID, data.Answers)
+	exam, err := s.assignedExam(ctx, data.ExamID, studentID)
 	if err != nil {
-		log.Err(err).Msg("Failed to submit exam answers")
 		return err
 	}
 
-	// Auto-grade multiple choice questions
-	gradeResult, err := s.autoGradeMultipleChoice(ctx, data.ExamID, studentID, data.Answers)
-	if err != nil {
-		log.Err(err).Msg("Failed to auto-grade multiple choice questions")
-		// Don't return error here, as submission was successful
-	} else {
-		// Store the auto-grade result if no essays need manual grading
-		if !gradeResult.EssayPending {
-			err = s.repository.AutoGradeExam(ctx, data.ExamID, studentID, gradeResult.Score, gradeResult.MaxScore)
-			if err != nil {
-				log.Err(err).Msg("Failed to store auto-grade result")
-			}
-		}
-	}
-
-	return nil
-}
-
-// Helper method to auto-grade multiple choice questions
-func (s *service) autoGradeMultipleChoice(ctx context.Context, examID, studentID uuid.UUID, answers []request.ExamAnswer) (*response.AutoGradeResultResponse, error) {
-	// Get all questions for the exam
-	questions, err := s.repository.GetExamQuestions(ctx, examID)
+	attempt, err := s.currentAttempt(ctx, exam.ID, studentID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	// Create maps for quick lookup
-	questionMap := make(map[uuid.UUID]repository.Question)
-	answerMap := make(map[uuid.UUID]request.ExamAnswer)
-
-	for _, question := range questions {
-		questionMap[question.ID] = question
+	if err := s.checkSubmission(exam, attempt, time.Now().UnixMilli()); err != nil {
+		return err
 	}
 
-	for _, answer := range answers {
-		answerMap[answer.QuestionID] = answer
+	// Seal the draft so saves from other sessions cannot slip in while the
+	// attempt is submitted
+	draft, err := s.repository.SealDraft(ctx, attempt.ID)
+	if err != nil {
+		log.Err(err).Msg("Failed to seal exam draft")
+		return err
 	}
+	answers := mergeAnswers(draft.Answers, data.Answers)
 
-	var totalQuestions int
-	var multipleChoiceQuestions int
-	var correctAnswers int
-	var hasEssayQuestions bool
-
-	// Grade each question
-	for _, question := range questions {
-		totalQuestions++
-
-		if question.QuestionType == "multiple_choice" {
-			multipleChoiceQuestions++
-
-			// Check if student answered this question
-			if studentAnswer, exists := answerMap[question.ID]; exists {
-				// For multiple choice, check selected option
-				if studentAnswer.SelectedOption != nil && question.CorrectAnswer != nil {
-					if *studentAnswer.SelectedOption == *question.CorrectAnswer {
-						correctAnswers++
-					}
-				}
-			}
-		} else if question.QuestionType == "essay" {
-			hasEssayQuestions = true
+	// Submit answers first
+	err = s.repository.SubmitAttempt(ctx, *attempt, answers)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return commonError.ErrAttemptSubmitted
+		}
+		if err := s.repository.UnsealDraft(ctx, attempt.ID); err != nil {
+			log.Err(err).Msg("Failed to unseal exam draft")
 		}
+		log.Err(err).Msg("Failed to submit exam answers")
+		return err
 	}
 
-	// Calculate score (assuming each question has equal weight)
-	var score float64
-	var maxScore float64 = float64(totalQuestions)
-
-	if multipleChoiceQuestions > 0 {
-		// Only count multiple choice for now
-		multipleChoiceScore := float64(correctAnswers)
-		if hasEssayQuestions {
-			// If there are essay questions, only grade multiple choice part
-			score = multipleChoiceScore
-			maxScore = float64(multipleChoiceQuestions)
-		} else {
-			// If only multiple choice, this is the final score
-			score = multipleChoiceScore
-		}
+	if err := s.repository.ClearDraft(ctx, attempt.ID); err != nil {
+		log.Err(err).Msg("Failed to clear exam draft")
 	}
 
-	gradePercentage := (score / maxScore) * 100
+	// Don't return grading errors, as submission was successful
+	s.autoGrade(ctx, *attempt, answers)
 
-	return &response.AutoGradeResultResponse{
-		TotalQuestions:      totalQuestions,
-		CorrectAnswers:      correctAnswers,
-		Score:               score,
-		MaxScore:            maxScore,
-		GradePercentage:     gradePercentage,
-		MultipleChoiceScore: float64(correctAnswers),
-		EssayPending:        hasEssayQuestions,
-	}, nil
+	return nil
 }
 
 func (s *service) GetStudentExams(ctx context.Context, studentID uuid.UUID, query request.GetStudentExamsQuery) (response.GetStudentExamsResponse, *commonHttp.Meta, error) {
@@ -333,56 +400,97 @@ func (s *service) GetStudentExams(ctx context.Context, studentID uuid.UUID, quer
 		return response.GetStudentExamsResponse{}, nil, err
 	}
 
+	now := time.Now().UnixMilli()
 	var res response.GetStudentExamsResponse
 	for _, exam := range exams {
-		res = append(res, response.StudentExamResponse{
-			ID:          exam.ID,
-			Name:        exam.Name,
-			SchoolID:    exam.SchoolID,
-			SubjectID:   exam.SubjectID,
-			SubjectName: exam.SubjectName,
-			Grade:       exam.Grade,
-			IsSubmitted: exam.Answers != nil,
-			IsGraded:    exam.Grade != nil,
-			CreatedAt:   exam.CreatedAt,
-			UpdatedAt:   exam.UpdatedAt,
-		})
+		examResponse := response.StudentExamResponse{
+			ID:              exam.ID,
+			Name:            exam.Name,
+			SchoolID:        exam.SchoolID,
+			SubjectID:       exam.SubjectID,
+			SubjectName:     exam.SubjectName,
+			StartAt:         exam.StartAt,
+			EndAt:           exam.EndAt,
+			DurationMinutes: exam.DurationMinutes,
+			MaxAttempts:     exam.MaxAttempts,
+			Attempts:        exam.Attempts,
+			IsStarted:       exam.StartedAt > 0,
+			IsSubmitted:     exam.IsSubmitted,
+			IsReleased:      s.gradesReleased(&exam, now),
+			CreatedAt:       exam.CreatedAt,
+			UpdatedAt:       exam.UpdatedAt,
+		}
+		if examResponse.IsReleased {
+			examResponse.Grade = exam.Grade
+			examResponse.IsGraded = exam.Grade != nil
+		}
+		res = append(res, examResponse)
 	}
 
 	meta := commonHttp.NewMetaFromQuery(query, total)
 	return res, meta, nil
 }
 
+// GetStudentExamDetail shows the student's latest attempt. The questions
+// are only shown once the attempt is started inside the exam window, and
+// again once it is submitted, so the paper cannot be read before the clock
+// runs.
 func (s *service) GetStudentExamDetail(ctx context.Context, examID, studentID uuid.UUID) (response.StudentExamDetailResponse, error) {
+	assigned, err := s.assignedExam(ctx, examID, studentID)
+	if err != nil {
+		return response.StudentExamDetailResponse{}, err
+	}
+
 	exam, err := s.repository.GetStudentExamDetail(ctx, examID, studentID)
 	if err != nil {
 		log.Err(err).Msg("Failed to get student exam detail")
 		return response.StudentExamDetailResponse{}, err
 	}
 
-	questions, err := s.repository.GetExamQuestions(ctx, examID)
-	if err != nil {
-		log.Err(err).Msg("Failed to get exam questions")
+	attempt, err := s.repository.GetLatestAttempt(ctx, examID, studentID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Err(err).Msg("Failed to get exam attempt")
 		return response.StudentExamDetailResponse{}, err
 	}
 
+	now := time.Now().UnixMilli()
+	var questions []repository.Question
+	if questionsVisible(assigned, attempt, now) {
+		questions, err = s.attemptQuestions(ctx, examID, attempt)
+		if err != nil {
+			return response.StudentExamDetailResponse{}, err
+		}
+	}
+
+	released := s.gradesReleased(exam, now)
+	revealed := s.answersRevealed(exam, attempt, now)
+
 	var questionResponses []response.ExamQuestionResponse
 	for _, question := range questions {
 		questionResponse := response.ExamQuestionResponse{
 			ID:           question.ID,
 			Question:     question.Question,
 			QuestionType: question.QuestionType,
-			// Don't include correct answer for students
+			Points:       question.Points,
+		}
+		// Students see the answers only once the exam reveals them
+		if revealed && exam.ShowCorrect {
+			questionResponse.CorrectAnswer = question.CorrectAnswer
+			questionResponse.AnswerKey = answerKeyResponse(question.AnswerKey)
+		}
+		if revealed && exam.ShowExplanations {
+			questionResponse.Explanation = question.Explanation
 		}
 
-		// Parse options for multiple choice questions
-		if question.QuestionType == "multiple_choice" && question.Options != nil {
+		// Parse options of the question types that have them
+		if question.Options != nil {
 			var options []map[string]string
 			if err := json.Unmarshal([]byte(*question.Options), &options); err == nil {
 				for _, option := range options {
 					questionResponse.Options = append(questionResponse.Options, response.QuestionOptionResponse{
 						ID:   option["id"],
 						Text: option["text"],
+						Side: option["side"],
 					})
 				}
 			}
@@ -391,35 +499,92 @@ func (s *service) GetStudentExamDetail(ctx context.Context, examID, studentID uu
 		questionResponses = append(questionResponses, questionResponse)
 	}
 
-	var answerResponses []response.StudentExamAnswerResponse
-	if exam.Answers != nil {
-		var answers []request.ExamAnswer
-		err := json.Unmarshal([]byte(*exam.Answers), &answers)
-		if err == nil {
-			for _, answer := range answers {
-				answerResponses = append(answerResponses, response.StudentExamAnswerResponse{
-					QuestionID:     answer.QuestionID,
-					Answer:         answer.Answer,
-					SelectedOption: answer.SelectedOption,
-				})
+	res := response.StudentExamDetailResponse{
+		ID:              exam.ID,
+		Name:            exam.Name,
+		SchoolID:        exam.SchoolID,
+		SubjectID:       exam.SubjectID,
+		SubjectName:     exam.SubjectName,
+		StartAt:         exam.StartAt,
+		EndAt:           exam.EndAt,
+		DurationMinutes: exam.DurationMinutes,
+		MaxAttempts:     exam.MaxAttempts,
+		ScoringPolicy:   exam.ScoringPolicy,
+		Questions:       questionResponses,
+		IsReleased:      released,
+		CreatedAt:       exam.CreatedAt,
+		UpdatedAt:       exam.UpdatedAt,
+	}
+	if released {
+		res.Grade = exam.Grade
+		res.Score = exam.Score
+		res.MaxScore = exam.MaxScore
+		res.Feedback = exam.Feedback
+		res.IsGraded = exam.Grade != nil
+	}
+
+	if attempt != nil {
+		if exam.ShuffleQuestions || exam.ShuffleOptions {
+			shuffleQuestions(questionResponses, attempt.Seed, exam.ShuffleQuestions, exam.ShuffleOptions)
+		}
+
+		res.Attempts = attempt.Number
+		res.Attempt = attempt.Number
+		res.Answers = answerResponses(attempt.Answers)
+		res.StartedAt = attempt.StartedAt
+		res.DeadlineAt = attempt.DeadlineAt
+		res.RemainingSeconds = remainingSeconds(attempt.DeadlineAt, attempt.Answers != nil, now)
+		res.IsStarted = attempt.StartedAt > 0
+		res.IsSubmitted = attempt.Answers != nil
+		if released {
+			res.AttemptGrade = attempt.Grade
+			res.Results = studentResults(attempt, revealed)
+		}
+
+		// Resume an open attempt from its draft
+		if attempt.Answers == nil {
+			draft, err := s.repository.GetDraft(ctx, attempt.ID)
+			if err != nil {
+				log.Err(err).Msg("Failed to get exam draft")
+				return response.StudentExamDetailResponse{}, err
 			}
+			res.Answers = examAnswerResponses(draft.Answers)
+			res.DraftVersion = draft.Version
 		}
 	}
 
-	res := response.StudentExamDetailResponse{
-		ID:          exam.ID,
-		Name:        exam.Name,
-		SchoolID:    exam.SchoolID,
-		SubjectID:   exam.SubjectID,
-		SubjectName: exam.SubjectName,
-		Questions:   questionResponses,
-		Answers:     answerResponses,
-		Grade:       exam.Grade,
-		IsSubmitted: exam.Answers != nil,
-		IsGraded:    exam.Grade != nil,
-		CreatedAt:   exam.CreatedAt,
-		UpdatedAt:   exam.UpdatedAt,
+	return res, nil
+}
+
+// questionsVisible reports whether the student may read the questions of
+// the attempt: after it is submitted, or while it is started and the exam
+// window is open.
+func questionsVisible(exam *repository.ExamWithSubject, attempt *repository.ExamAttempt, now int64) bool {
+	if attempt == nil {
+		return false
+	}
+	if attempt.Answers != nil {
+		return true
 	}
+	return attempt.StartedAt > 0 && checkWindow(exam, now) == nil
+}
 
-	return res, nil
+// studentResults is the breakdown of an attempt shown to the student, which
+// is released together with the attempt's grade. Whether and how well a
+// question was answered gives the answer key away, so until the answers are
+// revealed only the feedback is shown.
+func studentResults(attempt *repository.ExamAttempt, revealed bool) []response.QuestionResultResponse {
+	if attempt.Grade == nil {
+		return nil
+	}
+
+	results := questionResultResponses(parseResults(attempt.Result))
+	if !revealed {
+		for i := range results {
+			results[i].Score = nil
+			results[i].Correct = nil
+			results[i].Criteria = nil
+		}
+	}
+	return results
 }
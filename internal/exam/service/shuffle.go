@@ -0,0 +1,47 @@
+package service
+
+import (
+	"enuma-elish/internal/exam/service/data/response"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// newSeed returns a random seed; 0 is reserved for "no seed yet".
+func newSeed() int64 {
+	for {
+		if seed := rand.Int64(); seed != 0 {
+			return seed
+		}
+	}
+}
+
+// shuffleQuestions reorders the questions and/or their options in place for
+// a seed. Options keep their IDs, so answers are graded as usual. Questions
+// are sorted by ID first, which makes the order depend on the seed alone;
+// each question's options get a generator of their own, so they keep their
+// order when the exam gains or loses questions.
+func shuffleQuestions(questions []response.ExamQuestionResponse, seed int64, byQuestion, byOption bool) {
+	if byQuestion {
+		slices.SortFunc(questions, func(a, b response.ExamQuestionResponse) int {
+			return strings.Compare(a.ID.String(), b.ID.String())
+		})
+		rng := rand.New(rand.NewPCG(uint64(seed), 0))
+		rng.Shuffle(len(questions), func(i, j int) {
+			questions[i], questions[j] = questions[j], questions[i]
+		})
+	}
+
+	if byOption {
+		for _, question := range questions {
+			h := fnv.New64a()
+			h.Write(question.ID[:])
+			rng := rand.New(rand.NewPCG(uint64(seed), h.Sum64()))
+			options := question.Options
+			rng.Shuffle(len(options), func(i, j int) {
+				options[i], options[j] = options[j], options[i]
+			})
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"enuma-elish/internal/exam/service/data/response"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func shuffleTestQuestions(n int) []response.ExamQuestionResponse {
+	questions := make([]response.ExamQuestionResponse, n)
+	for i := range questions {
+		questions[i] = response.ExamQuestionResponse{ID: uuid.New(), Question: fmt.Sprintf("Question %d", i)}
+		for j := range 5 {
+			questions[i].Options = append(questions[i].Options, response.QuestionOptionResponse{ID: fmt.Sprintf("%d-%d", i, j)})
+		}
+	}
+	return questions
+}
+
+// cloneQuestions copies the questions with their options, which
+// shuffleQuestions reorders in place.
+func cloneQuestions(questions []response.ExamQuestionResponse) []response.ExamQuestionResponse {
+	clone := slices.Clone(questions)
+	for i := range clone {
+		clone[i].Options = slices.Clone(clone[i].Options)
+	}
+	return clone
+}
+
+func questionOrder(questions []response.ExamQuestionResponse) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, question := range questions {
+		ids = append(ids, question.ID)
+	}
+	return ids
+}
+
+func optionOrder(questions []response.ExamQuestionResponse, id uuid.UUID) []string {
+	i := slices.IndexFunc(questions, func(q response.ExamQuestionResponse) bool { return q.ID == id })
+	var ids []string
+	for _, option := range questions[i].Options {
+		ids = append(ids, option.ID)
+	}
+	return ids
+}
+
+func TestShuffleQuestionsIsDeterministic(t *testing.T) {
+	questions := shuffleTestQuestions(20)
+
+	first := cloneQuestions(questions)
+	shuffleQuestions(first, 42, true, true)
+
+	// The order the questions are loaded in does not matter
+	reversed := cloneQuestions(questions)
+	slices.Reverse(reversed)
+	shuffleQuestions(reversed, 42, true, true)
+
+	if !slices.Equal(questionOrder(first), questionOrder(reversed)) {
+		t.Fatalf("the same seed gave different question orders")
+	}
+	for _, question := range questions {
+		if !slices.Equal(optionOrder(first, question.ID), optionOrder(reversed, question.ID)) {
+			t.Fatalf("the same seed gave different option orders for %s", question.ID)
+		}
+	}
+
+	other := cloneQuestions(questions)
+	shuffleQuestions(other, 43, true, true)
+	if slices.Equal(questionOrder(first), questionOrder(other)) {
+		t.Fatalf("different seeds gave the same question order")
+	}
+
+	slices.SortFunc(first, func(a, b response.ExamQuestionResponse) int { return slices.Compare(a.ID[:], b.ID[:]) })
+	slices.SortFunc(other, func(a, b response.ExamQuestionResponse) int { return slices.Compare(a.ID[:], b.ID[:]) })
+	if !slices.Equal(questionOrder(first), questionOrder(other)) {
+		t.Fatalf("shuffling lost or duplicated questions")
+	}
+}
+
+func TestShuffleOptionsKeepOrderAcrossQuestionChanges(t *testing.T) {
+	questions := shuffleTestQuestions(10)
+
+	all := cloneQuestions(questions)
+	shuffleQuestions(all, 7, false, true)
+
+	// A question removed from the exam leaves the other options alone
+	fewer := cloneQuestions(questions[1:])
+	shuffleQuestions(fewer, 7, false, true)
+
+	for _, question := range questions[1:] {
+		if !slices.Equal(optionOrder(all, question.ID), optionOrder(fewer, question.ID)) {
+			t.Fatalf("options of %s moved when another question was removed", question.ID)
+		}
+	}
+}
+
+func TestShuffleQuestionsFlags(t *testing.T) {
+	questions := shuffleTestQuestions(10)
+
+	unchanged := cloneQuestions(questions)
+	shuffleQuestions(unchanged, 42, false, false)
+	if !slices.Equal(questionOrder(unchanged), questionOrder(questions)) {
+		t.Fatalf("questions moved without shuffling")
+	}
+
+	optionsOnly := cloneQuestions(questions)
+	shuffleQuestions(optionsOnly, 42, false, true)
+	if !slices.Equal(questionOrder(optionsOnly), questionOrder(questions)) {
+		t.Fatalf("questions moved when only options are shuffled")
+	}
+	moved := false
+	for _, question := range questions {
+		moved = moved || !slices.Equal(optionOrder(optionsOnly, question.ID), optionOrder(questions, question.ID))
+	}
+	if !moved {
+		t.Fatalf("no options moved")
+	}
+
+	questionsOnly := cloneQuestions(questions)
+	shuffleQuestions(questionsOnly, 42, true, false)
+	for _, question := range questions {
+		if !slices.Equal(optionOrder(questionsOnly, question.ID), optionOrder(questions, question.ID)) {
+			t.Fatalf("options moved when only questions are shuffled")
+		}
+	}
+}
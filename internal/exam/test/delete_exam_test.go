@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDeleteGradedExam(t *testing.T) {
+	ctx := context.Background()
+	db := testInfra.Postgres
+	repo := repository.New(db, testInfra.Redis)
+
+	var schoolID, userID uuid.UUID
+	if err := db.GetContext(ctx, &schoolID, `SELECT id FROM school LIMIT 1`); err != nil {
+		t.Skipf("no school to create the exam in: %v", err)
+	}
+	if err := db.GetContext(ctx, &userID, `SELECT id FROM users WHERE email = 'admin@gmail.com'`); err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+
+	subjectID := uuid.New()
+	_, err := db.ExecContext(ctx, `INSERT INTO subject (id, name, school_id, created_by) VALUES ($1, $2, $3, $4)`,
+		subjectID, "Delete exam test", schoolID, userID)
+	if err != nil {
+		t.Fatalf("failed to create subject: %v", err)
+	}
+	var questionID uuid.UUID
+	err = db.GetContext(ctx, &questionID, `INSERT INTO question (question, created_by) VALUES ($1, $2) RETURNING id`,
+		"Delete exam test", userID)
+	if err != nil {
+		t.Fatalf("failed to create question: %v", err)
+	}
+	t.Cleanup(func() {
+		db.ExecContext(ctx, `DELETE FROM question WHERE id = $1`, questionID)
+		db.ExecContext(ctx, `DELETE FROM subject WHERE id = $1`, subjectID)
+	})
+
+	now := time.Now().UnixMilli()
+	exam := repository.Exam{
+		ID:            uuid.New(),
+		Name:          "Delete exam test " + uuid.NewString(),
+		SchoolID:      schoolID,
+		SubjectID:     subjectID,
+		MaxAttempts:   1,
+		ScoringPolicy: "highest",
+		ReleaseMode:   "immediate",
+		CreatedAt:     now,
+	}
+	if err := repo.CreateExam(ctx, exam, []uuid.UUID{questionID}, nil, nil); err != nil {
+		t.Fatalf("failed to create exam: %v", err)
+	}
+
+	attempt, err := repo.OpenAttempt(ctx, exam.ID, userID, exam.MaxAttempts, 0)
+	if err != nil {
+		t.Fatalf("failed to open attempt: %v", err)
+	}
+	if err := repo.SubmitAttempt(ctx, *attempt, []request.ExamAnswer{}); err != nil {
+		t.Fatalf("failed to submit attempt: %v", err)
+	}
+
+	score, maxScore, grade := 0.0, 1.0, 0.0
+	result := repository.ExamResult{
+		Score:     score,
+		MaxScore:  maxScore,
+		Questions: []repository.QuestionResult{{QuestionID: questionID, QuestionType: "multiple_choice", Points: 1, Score: &score}},
+		Grade:     &grade,
+		Auto:      true,
+	}
+	scoring := func([]repository.ExamAttempt) repository.FinalGrade {
+		return repository.FinalGrade{Grade: &grade, Score: &score, MaxScore: &maxScore}
+	}
+	err = repo.SaveAttemptResult(ctx, *attempt, result, scoring, repository.GradeChange{Source: "auto"})
+	if err != nil {
+		t.Fatalf("failed to grade attempt: %v", err)
+	}
+
+	err = repo.CreateRegradeRequest(ctx, repository.RegradeRequest{
+		ID:         uuid.New(),
+		ExamID:     exam.ID,
+		AttemptID:  attempt.ID,
+		StudentID:  userID,
+		QuestionID: questionID,
+		Reason:     "Delete exam test",
+		Status:     "pending",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		t.Fatalf("failed to create regrade request: %v", err)
+	}
+
+	if err := repo.DeleteExam(ctx, exam.ID); err != nil {
+		t.Fatalf("failed to delete graded exam: %v", err)
+	}
+
+	_, err = repo.GetExamByID(ctx, exam.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected the exam to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package test
+
+import (
+	"context"
+	"enuma-elish/internal/exam/repository"
+	"enuma-elish/internal/exam/service/data/request"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUpdateAttemptedExam(t *testing.T) {
+	ctx := context.Background()
+	db := testInfra.Postgres
+	repo := repository.New(db, testInfra.Redis)
+
+	var schoolID, userID uuid.UUID
+	if err := db.GetContext(ctx, &schoolID, `SELECT id FROM school LIMIT 1`); err != nil {
+		t.Skipf("no school to create the exam in: %v", err)
+	}
+	if err := db.GetContext(ctx, &userID, `SELECT id FROM users WHERE email = 'admin@gmail.com'`); err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+
+	subjectID := uuid.New()
+	_, err := db.ExecContext(ctx, `INSERT INTO subject (id, name, school_id, created_by) VALUES ($1, $2, $3, $4)`,
+		subjectID, "Update exam test", schoolID, userID)
+	if err != nil {
+		t.Fatalf("failed to create subject: %v", err)
+	}
+	var questionID uuid.UUID
+	err = db.GetContext(ctx, &questionID, `INSERT INTO question (question, created_by) VALUES ($1, $2) RETURNING id`,
+		"Update exam test", userID)
+	if err != nil {
+		t.Fatalf("failed to create question: %v", err)
+	}
+
+	now := time.Now().UnixMilli()
+	exam := repository.Exam{
+		ID:            uuid.New(),
+		Name:          "Update exam test " + uuid.NewString(),
+		SchoolID:      schoolID,
+		SubjectID:     subjectID,
+		MaxAttempts:   3,
+		ScoringPolicy: "highest",
+		ReleaseMode:   "immediate",
+		CreatedAt:     now,
+	}
+	if err := repo.CreateExam(ctx, exam, []uuid.UUID{questionID}, nil, nil); err != nil {
+		t.Fatalf("failed to create exam: %v", err)
+	}
+	t.Cleanup(func() {
+		repo.DeleteExam(ctx, exam.ID)
+		db.ExecContext(ctx, `DELETE FROM question WHERE id = $1`, questionID)
+		db.ExecContext(ctx, `DELETE FROM subject WHERE id = $1`, subjectID)
+	})
+
+	// The test derives grades by the policy stored on the exam, the way the
+	// service does
+	scoring := func(policy string) repository.Scoring {
+		return func(attempts []repository.ExamAttempt) repository.FinalGrade {
+			var final repository.FinalGrade
+			var sum float64
+			for _, a := range attempts {
+				if a.Grade == nil {
+					continue
+				}
+				sum += *a.Grade
+				if policy == "highest" && (final.Grade == nil || *a.Grade > *final.Grade) {
+					final.Grade = a.Grade
+				}
+			}
+			if policy == "average" {
+				average := sum / float64(len(attempts))
+				final.Grade = &average
+			}
+			return final
+		}
+	}
+
+	// Two attempts graded 40 and 80
+	for _, grade := range []float64{40, 80} {
+		attempt, err := repo.OpenAttempt(ctx, exam.ID, userID, exam.MaxAttempts, 0)
+		if err != nil {
+			t.Fatalf("failed to open attempt: %v", err)
+		}
+		if err := repo.SubmitAttempt(ctx, *attempt, []request.ExamAnswer{}); err != nil {
+			t.Fatalf("failed to submit attempt: %v", err)
+		}
+
+		score := grade / 100
+		result := repository.ExamResult{Score: score, MaxScore: 1, Grade: &grade, Auto: true}
+		err = repo.SaveAttemptResult(ctx, *attempt, result, scoring("highest"), repository.GradeChange{Source: "auto"})
+		if err != nil {
+			t.Fatalf("failed to grade attempt: %v", err)
+		}
+	}
+
+	examGrade := func() float64 {
+		var grade float64
+		err := db.GetContext(ctx, &grade, `SELECT grade FROM exam_grade WHERE exam_id = $1 AND student_id = $2`, exam.ID, userID)
+		if err != nil {
+			t.Fatalf("failed to get exam grade: %v", err)
+		}
+		return grade
+	}
+	if grade := examGrade(); grade != 80 {
+		t.Fatalf("expected the highest grade 80, got %v", grade)
+	}
+
+	exam.MaxAttempts = 1
+	err = repo.UpdateExam(ctx, exam.ID, exam, scoring("highest"), repository.GradeChange{Source: "policy"})
+	if !errors.Is(err, commonError.ErrMaxAttemptsUsed) {
+		t.Fatalf("expected ErrMaxAttemptsUsed lowering max attempts below the attempts used, got %v", err)
+	}
+
+	exam.MaxAttempts, exam.ScoringPolicy = 2, "average"
+	err = repo.UpdateExam(ctx, exam.ID, exam, scoring("average"), repository.GradeChange{Source: "policy"})
+	if err != nil {
+		t.Fatalf("failed to update exam: %v", err)
+	}
+	if grade := examGrade(); grade != 60 {
+		t.Fatalf("expected the average grade 60 after the policy change, got %v", grade)
+	}
+
+	changes, err := repo.GetGradeChanges(ctx, exam.ID, userID)
+	if err != nil {
+		t.Fatalf("failed to get grade changes: %v", err)
+	}
+	last := changes[len(changes)-1]
+	if last.Source != "policy" || last.PreviousGrade == nil || *last.PreviousGrade != 80 || last.Grade == nil || *last.Grade != 60 {
+		t.Fatalf("expected the policy change from 80 to 60 in the grade history, got %+v", last)
+	}
+}
@@ -30,22 +30,24 @@ func New(c *config.Config, i *infra.Infra, r *gin.Engine, v *validator.Validate)
 
 func (p *PPDB) Init() {
 	r := repository.New(p.i.Postgres)
-	svc := service.New(r, p.c)
+	svc := service.New(r, p.c, p.i.Mail)
 	h := handler.New(svc, p.v)
 
-	authMiddleware := middleware.Auth(p.c.JWT.Secret)
+	p.i.Scheduler.Register("ppdb.close", "*/5 * * * *", svc.CloseEndedPPDB)
+
+	authMiddleware := middleware.Auth(p.i.Keys, p.i.Session, p.i.APIKeys)
 
 	v1 := p.Group("/api/v1/ppdb").Use(authMiddleware)
 
 	// PPDB management (for school admin)
-	v1.POST("", h.CreatePPDB)
+	v1.POST("", middleware.RequirePermission(middleware.PermPPDBManage), h.CreatePPDB)
 	v1.GET("", h.GetListPPDB)
 	v1.GET("/:ppdb_id", h.GetPPDBByID)
-	v1.PUT("/:ppdb_id", h.UpdatePPDB)
-	v1.DELETE("/:ppdb_id", h.DeletePPDB)
+	v1.PUT("/:ppdb_id", middleware.RequirePermission(middleware.PermPPDBManage), h.UpdatePPDB)
+	v1.DELETE("/:ppdb_id", middleware.RequirePermission(middleware.PermPPDBManage), h.DeletePPDB)
 
 	// PPDB registration and management - Now requires authentication
 	v1.POST("/register", h.RegisterPPDB)
-	v1.GET("/registrants", h.GetPPDBRegistrants)
-	v1.POST("/select", h.SelectPPDBStudents)
+	v1.GET("/registrants", middleware.RequirePermission(middleware.PermPPDBManage), h.GetPPDBRegistrants)
+	v1.POST("/select", middleware.RequirePermission(middleware.PermPPDBManage), h.SelectPPDBStudents)
 }
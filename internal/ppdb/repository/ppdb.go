@@ -4,12 +4,15 @@ import (
 	"context"
 	"enuma-elish/internal/ppdb/service/data/request"
 	"enuma-elish/internal/ppdb/service/data/response"
+	"enuma-elish/pkg/event"
 	commonHttp "enuma-elish/pkg/http"
+	"enuma-elish/pkg/tenant"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
 )
 
 type Repository interface {
@@ -18,12 +21,14 @@ type Repository interface {
 	DeletePPDB(ctx context.Context, id uuid.UUID) error
 	GetPPDBByID(ctx context.Context, id uuid.UUID) (*PPDB, error)
 	GetListPPDB(ctx context.Context, query request.GetListPPDBQuery) ([]response.PPDBResponse, *commonHttp.Meta, error)
+	CloseEndedPPDB(ctx context.Context, now int64) (int, error)
 
 	RegisterPPDB(ctx context.Context, ppdbStudent *PPDBStudent) error
 	GetPPDBRegistrants(ctx context.Context, query request.GetPPDBRegistrantsQuery) ([]response.PPDBStudentResponse, *commonHttp.Meta, error)
 	UpdatePPDBStudentStatus(ctx context.Context, ppdbID uuid.UUID, studentIDs []uuid.UUID, status string) error
 	GetPPDBStudentByPPDBIDAndEmail(ctx context.Context, ppdbID uuid.UUID, email string) (*PPDBStudent, error)
 	GetPPDBStudentByPPDBIDAndUserID(ctx context.Context, ppdbID uuid.UUID, userID uuid.UUID) (*PPDBStudent, error) // New method
+	GetPPDBStudentsByStudentIDs(ctx context.Context, ppdbID uuid.UUID, studentIDs []uuid.UUID) ([]PPDBStudent, error)
 }
 
 type PPDB struct {
@@ -31,6 +36,7 @@ type PPDB struct {
 	SchoolID  uuid.UUID `db:"school_id"`
 	StartAt   int64     `db:"start_at"`
 	EndAt     int64     `db:"end_at"`
+	ClosedAt  int64     `db:"closed_at"`
 	CreatedAt int64     `db:"created_at"`
 	UpdatedAt int64     `db:"updated_at"`
 }
@@ -55,29 +61,41 @@ func New(db *sqlx.DB) Repository {
 }
 
 func (r *repository) CreatePPDB(ctx context.Context, ppdb *PPDB) error {
+	schoolID, err := tenant.Scope(ctx, ppdb.SchoolID)
+	if err != nil {
+		return err
+	}
+	ppdb.SchoolID = schoolID
+
 	query := `INSERT INTO ppdb (id, school_id, start_at, end_at, created_at, updated_at) 
 			  VALUES (:id, :school_id, :start_at, :end_at, :created_at, :updated_at)`
 
-	_, err := r.db.NamedExecContext(ctx, query, ppdb)
+	_, err = r.db.NamedExecContext(ctx, query, ppdb)
 	return err
 }
 
 func (r *repository) UpdatePPDB(ctx context.Context, ppdb *PPDB) error {
-	query := `UPDATE ppdb SET start_at = :start_at, end_at = :end_at, updated_at = :updated_at WHERE id = :id`
+	if err := r.checkPPDBTenant(ctx, ppdb.ID); err != nil {
+		return err
+	}
+
+	query := `UPDATE ppdb SET start_at = :start_at, end_at = :end_at, closed_at = :closed_at, updated_at = :updated_at WHERE id = :id`
 
 	_, err := r.db.NamedExecContext(ctx, query, ppdb)
 	return err
 }
 
 func (r *repository) DeletePPDB(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM ppdb WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+	query := `DELETE FROM ppdb WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2)`
+	_, err := r.db.ExecContext(ctx, query, id, tenant.Filter(ctx))
 	return err
 }
 
+// GetPPDBByID and GetListPPDB are intentionally not tenant scoped: prospective
+// students browse the admission periods of schools they do not belong to yet.
 func (r *repository) GetPPDBByID(ctx context.Context, id uuid.UUID) (*PPDB, error) {
 	var ppdb PPDB
-	query := `SELECT id, school_id, start_at, end_at, created_at, updated_at FROM ppdb WHERE id = $1`
+	query := `SELECT id, school_id, start_at, end_at, closed_at, created_at, updated_at FROM ppdb WHERE id = $1`
 
 	err := r.db.GetContext(ctx, &ppdb, query, id)
 	if err != nil {
@@ -156,6 +174,54 @@ func (r *repository) GetListPPDB(ctx context.Context, query request.GetListPPDBQ
 	return result, meta, nil
 }
 
+// CloseEndedPPDB stamps closed_at on every period that ended before now and
+// writes a PPDBClosed event for each of them. It returns the number of
+// periods closed.
+func (r *repository) CloseEndedPPDB(ctx context.Context, now int64) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	var closed []PPDB
+	query := `UPDATE ppdb SET closed_at = $1
+			  WHERE closed_at = 0 AND end_at < $1 AND COALESCE(deleted_at, 0) = 0
+			  RETURNING id, school_id, start_at, end_at, closed_at, created_at, updated_at`
+	err = tx.SelectContext(ctx, &closed, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ppdb := range closed {
+		err = event.Write(ctx, tx, ppdb.SchoolID, event.PPDBClosed{
+			PPDBID:   ppdb.ID,
+			SchoolID: ppdb.SchoolID,
+			EndAt:    ppdb.EndAt,
+			ClosedAt: now,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	committed = true
+
+	return len(closed), nil
+}
+
 func (r *repository) RegisterPPDB(ctx context.Context, ppdbStudent *PPDBStudent) error {
 	query := `INSERT INTO ppdb_student (id, ppdb_id, student_id, name, email, status, created_at, updated_at) 
 			  VALUES (:id, :ppdb_id, :student_id, :name, :email, :status, :created_at, :updated_at)`
@@ -167,6 +233,11 @@ func (r *repository) RegisterPPDB(ctx context.Context, ppdbStudent *PPDBStudent)
 func (r *repository) GetPPDBRegistrants(ctx context.Context, query request.GetPPDBRegistrantsQuery) ([]response.PPDBStudentResponse, *commonHttp.Meta, error) {
 	httpQuery, filters := query.Get()
 
+	ppdbID, _ := filters["ppdb_id"].(uuid.UUID)
+	if err := r.checkPPDBTenant(ctx, ppdbID); err != nil {
+		return nil, nil, err
+	}
+
 	baseWhere := "WHERE ppdb_id = :ppdb_id"
 	params := map[string]interface{}{
 		"ppdb_id": filters["ppdb_id"],
@@ -239,12 +310,20 @@ func (r *repository) UpdatePPDBStudentStatus(ctx context.Context, ppdbID uuid.UU
 		return nil // No student IDs to update
 	}
 
+	if err := r.checkPPDBTenant(ctx, ppdbID); err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if err := tenant.Apply(ctx, tx); err != nil {
+		return err
+	}
+
 	query := `UPDATE ppdb_student SET status = :status, updated_at = :updated_at 
 			  WHERE ppdb_id = :ppdb_id AND student_id = ANY(:student_ids)`
 
@@ -260,6 +339,22 @@ func (r *repository) UpdatePPDBStudentStatus(ctx context.Context, ppdbID uuid.UU
 		return err
 	}
 
+	var schoolID uuid.UUID
+	err = tx.GetContext(ctx, &schoolID, "SELECT school_id FROM ppdb WHERE id = $1", ppdbID)
+	if err != nil {
+		return err
+	}
+
+	err = event.Write(ctx, tx, schoolID, event.PPDBStudentsUpdated{
+		PPDBID:     ppdbID,
+		SchoolID:   schoolID,
+		StudentIDs: studentIDs,
+		Status:     status,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -286,3 +381,23 @@ func (r *repository) GetPPDBStudentByPPDBIDAndUserID(ctx context.Context, ppdbID
 	}
 	return &ppdbStudent, nil
 }
+
+func (r *repository) GetPPDBStudentsByStudentIDs(ctx context.Context, ppdbID uuid.UUID, studentIDs []uuid.UUID) ([]PPDBStudent, error) {
+	ppdbStudents := []PPDBStudent{}
+	query := `SELECT id, ppdb_id, student_id, name, email, status, created_at, updated_at
+			  FROM ppdb_student WHERE ppdb_id = $1 AND student_id = ANY($2)`
+
+	err := r.db.SelectContext(ctx, &ppdbStudents, query, ppdbID, pq.Array(studentIDs))
+	if err != nil {
+		return nil, err
+	}
+	return ppdbStudents, nil
+}
+
+// checkPPDBTenant returns sql.ErrNoRows when the PPDB period does not exist
+// within the caller's school.
+func (r *repository) checkPPDBTenant(ctx context.Context, ppdbID uuid.UUID) error {
+	var id uuid.UUID
+	query := `SELECT id FROM ppdb WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2)`
+	return r.db.GetContext(ctx, &id, query, ppdbID, tenant.Filter(ctx))
+}
@@ -10,6 +10,7 @@ import (
 	commonError "enuma-elish/pkg/error"
 	commonHttp "enuma-elish/pkg/http"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/mailer"
 	"errors"
 	"time"
 
@@ -27,17 +28,21 @@ type Service interface {
 	RegisterPPDB(ctx context.Context, data request.RegisterPPDBRequest) (response.PPDBRegistrationResponse, error)
 	GetPPDBRegistrants(ctx context.Context, query request.GetPPDBRegistrantsQuery) (response.GetPPDBRegistrantsResponse, *commonHttp.Meta, error)
 	SelectPPDBStudents(ctx context.Context, data request.PPDBSelectionRequest) error
+
+	CloseEndedPPDB(ctx context.Context) error
 }
 
 type service struct {
 	repository repository.Repository
 	config     *config.Config
+	mail       *mailer.Sender
 }
 
-func New(repository repository.Repository, config *config.Config) Service {
+func New(repository repository.Repository, config *config.Config, mail *mailer.Sender) Service {
 	return &service{
 		repository: repository,
 		config:     config,
+		mail:       mail,
 	}
 }
 
@@ -102,6 +107,10 @@ func (s *service) UpdatePPDB(ctx context.Context, data request.UpdatePPDBRequest
 	ppdb.StartAt = data.StartAt
 	ppdb.EndAt = data.EndAt
 	ppdb.UpdatedAt = time.Now().UnixMilli()
+	// Moving the end into the future reopens a closed period
+	if ppdb.EndAt > ppdb.UpdatedAt {
+		ppdb.ClosedAt = 0
+	}
 
 	err = s.repository.UpdatePPDB(ctx, ppdb)
 	if err != nil {
@@ -234,6 +243,16 @@ func (s *service) RegisterPPDB(ctx context.Context, data request.RegisterPPDBReq
 		return response.PPDBRegistrationResponse{}, err
 	}
 
+	err = s.mail.Enqueue(ctx, mailer.Email{
+		To:       ppdbStudent.Email,
+		Template: mailer.TemplatePPDBRegistered,
+		SchoolID: ppdb.SchoolID,
+		Data:     mailer.Data{Name: ppdbStudent.Name},
+	})
+	if err != nil {
+		log.Err(err).Str("email", ppdbStudent.Email).Msg("Failed to enqueue PPDB registration email")
+	}
+
 	return response.PPDBRegistrationResponse{
 		ID:      ppdbStudent.ID,
 		PPDBID:  data.PPDBID,
@@ -288,5 +307,36 @@ func (s *service) SelectPPDBStudents(ctx context.Context, data request.PPDBSelec
 		return err
 	}
 
+	accepted, err := s.repository.GetPPDBStudentsByStudentIDs(ctx, data.PPDBID, data.AcceptedStudents)
+	if err != nil {
+		log.Err(err).Msg("Failed to get accepted PPDB students")
+		return nil
+	}
+
+	for _, v := range accepted {
+		err = s.mail.Enqueue(ctx, mailer.Email{
+			To:       v.Email,
+			Template: mailer.TemplatePPDBAccepted,
+			SchoolID: ppdb.SchoolID,
+			Data:     mailer.Data{Name: v.Name},
+		})
+		if err != nil {
+			log.Err(err).Str("email", v.Email).Msg("Failed to enqueue PPDB acceptance email")
+		}
+	}
+
+	return nil
+}
+
+// CloseEndedPPDB is the ppdb.close scheduled job.
+func (s *service) CloseEndedPPDB(ctx context.Context) error {
+	closed, err := s.repository.CloseEndedPPDB(ctx, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	if closed > 0 {
+		log.Info().Int("closed", closed).Msg("closed ended PPDB periods")
+	}
 	return nil
 }
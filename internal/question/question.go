@@ -33,14 +33,14 @@ func (q *Question) Init() {
 	s := service.New(q.c, r)
 	h := handler.New(s, q.v)
 
-	authMiddleware := middleware.Auth(q.c.JWT.Secret)
+	authMiddleware := middleware.Auth(q.i.Keys, q.i.Session, q.i.APIKeys)
 
-	v1 := q.Group("/api/v1/question").Use(authMiddleware)
+	v1 := q.Group("/api/v1/question").Use(middleware.AllowAPIKey(), authMiddleware)
 
-	v1.POST("", h.CreateQuestion)
-	v1.GET("", h.GetListQuestions)
-	v1.GET("/:question_id", h.GetDetailQuestion)
-	v1.PUT("/:question_id", h.UpdateQuestion)
-	v1.DELETE("/:question_id", h.DeleteQuestion)
-	v1.GET("/by-type", h.GetQuestionsByType)
+	v1.POST("", middleware.RequirePermission(middleware.PermQuestionManage), h.CreateQuestion)
+	v1.GET("", middleware.RequirePermission(middleware.PermQuestionRead), h.GetListQuestions)
+	v1.GET("/:question_id", middleware.RequirePermission(middleware.PermQuestionRead), h.GetDetailQuestion)
+	v1.PUT("/:question_id", middleware.RequirePermission(middleware.PermQuestionManage), h.UpdateQuestion)
+	v1.DELETE("/:question_id", middleware.RequirePermission(middleware.PermQuestionManage), h.DeleteQuestion)
+	v1.GET("/by-type", middleware.RequirePermission(middleware.PermQuestionRead), h.GetQuestionsByType)
 }
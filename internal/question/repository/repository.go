@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"enuma-elish/internal/question/service/data/request"
+	"enuma-elish/pkg/tenant"
 	"fmt"
 
 	"github.com/go-redis/redis/v8"
@@ -21,6 +22,9 @@ type Question struct {
 	SubjectID       uuid.UUID      `db:"subject_id"`
 	DifficultyLevel string         `db:"difficulty_level"`
 	Points          int            `db:"points"`
+	Rubric          *string        `db:"rubric"`
+	AnswerKey       *string        `db:"answer_key"`
+	Explanation     string         `db:"explanation"`
 	CreatedAt       int64          `db:"created_at"`
 	CreatedBy       uuid.UUID      `db:"created_by"`
 	UpdatedAt       int64          `db:"updated_at"`
@@ -40,6 +44,9 @@ type QuestionWithSubject struct {
 	SubjectName     string         `db:"subject_name"`
 	DifficultyLevel string         `db:"difficulty_level"`
 	Points          int            `db:"points"`
+	Rubric          *string        `db:"rubric"`
+	AnswerKey       *string        `db:"answer_key"`
+	Explanation     string         `db:"explanation"`
 	CreatedAt       int64          `db:"created_at"`
 	CreatedBy       uuid.UUID      `db:"created_by"`
 	UpdatedAt       int64          `db:"updated_at"`
@@ -73,22 +80,28 @@ func New(db *sqlx.DB, rdb *redis.Client) Repository {
 }
 
 func (r *repository) CreateQuestion(ctx context.Context, question Question) error {
-	insertQuery := `INSERT INTO question (id, question, question_type, options, correct_answer, school_id, subject_id, difficulty_level, points, created_at, created_by, updated_at) 
-					VALUES (:id, :question, :question_type, :options, :correct_answer, :school_id, :subject_id, :difficulty_level, :points, :created_at, :created_by, :updated_at)`
+	schoolID, err := tenant.Scope(ctx, question.SchoolID)
+	if err != nil {
+		return err
+	}
+	question.SchoolID = schoolID
 
-	_, err := r.db.NamedExecContext(ctx, insertQuery, question)
+	insertQuery := `INSERT INTO question (id, question, question_type, options, correct_answer, school_id, subject_id, difficulty_level, points, rubric, answer_key, explanation, created_at, created_by, updated_at) 
+					VALUES (:id, :question, :question_type, :options, :correct_answer, :school_id, :subject_id, :difficulty_level, :points, :rubric, :answer_key, :explanation, :created_at, :created_by, :updated_at)`
+
+	_, err = r.db.NamedExecContext(ctx, insertQuery, question)
 	return err
 }
 
 func (r *repository) GetQuestionByID(ctx context.Context, questionID uuid.UUID) (*QuestionWithSubject, error) {
 	query := `SELECT q.id, q.question, q.question_type, q.options, q.correct_answer, q.school_id, q.subject_id, s.name as subject_name, 
-			  q.difficulty_level, q.points, q.created_at, q.updated_at
+			  q.difficulty_level, q.points, q.rubric, q.answer_key, q.explanation, q.created_at, q.updated_at
 			  FROM question q
 			  JOIN subject s ON q.subject_id = s.id
-			  WHERE q.id = $1`
+			  WHERE q.id = $1 AND ($2::uuid IS NULL OR q.school_id = $2)`
 
 	var question QuestionWithSubject
-	err := r.db.GetContext(ctx, &question, query, questionID)
+	err := r.db.GetContext(ctx, &question, query, questionID, tenant.Filter(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -96,8 +109,13 @@ func (r *repository) GetQuestionByID(ctx context.Context, questionID uuid.UUID)
 }
 
 func (r *repository) GetListQuestions(ctx context.Context, query request.GetListQuestionQuery) ([]QuestionWithSubject, int, error) {
+	schoolID, err := tenant.ScopeString(ctx, query.SchoolID)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	baseQuery := `SELECT q.id, q.question, q.question_type, q.options, q.correct_answer, q.school_id, q.subject_id, s.name as subject_name, 
-				  q.difficulty_level, q.points, q.created_at, q.updated_at
+				  q.difficulty_level, q.points, q.rubric, q.answer_key, q.explanation, q.created_at, q.updated_at
 				  FROM question q
 				  JOIN subject s ON q.subject_id = s.id
 				  WHERE q.school_id = $1`
@@ -106,7 +124,7 @@ func (r *repository) GetListQuestions(ctx context.Context, query request.GetList
 				   FROM question q
 				   WHERE q.school_id = $1`
 
-	params := []interface{}{query.SchoolID}
+	params := []interface{}{schoolID}
 	paramCount := 1
 
 	if query.SubjectID != "" {
@@ -141,7 +159,7 @@ func (r *repository) GetListQuestions(ctx context.Context, query request.GetList
 	limitOrderQuery := fmt.Sprintf(" ORDER BY %s %s LIMIT $%d OFFSET $%d", query.OrderBy, query.Order, paramCount+1, paramCount+2)
 	params = append(params, query.PageSize, query.GetOffset())
 
-	err := r.db.SelectContext(ctx, &questions, baseQuery+limitOrderQuery, params...)
+	err = r.db.SelectContext(ctx, &questions, baseQuery+limitOrderQuery, params...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -157,16 +175,22 @@ func (r *repository) GetListQuestions(ctx context.Context, query request.GetList
 
 func (r *repository) UpdateQuestion(ctx context.Context, questionID uuid.UUID, question Question) error {
 	updateQuery := `UPDATE question SET question = $1, question_type = $2, options = $3, correct_answer = $4, 
-					subject_id = $5, difficulty_level = $6, points = $7, updated_at = $8 WHERE id = $9`
+					subject_id = $5, difficulty_level = $6, points = $7, rubric = $8, answer_key = $9, explanation = $10, updated_at = $11
+					WHERE id = $12 AND ($13::uuid IS NULL OR school_id = $13)`
 
 	_, err := r.db.ExecContext(ctx, updateQuery, question.Question, question.QuestionType, question.Options,
-		question.CorrectAnswer, question.SubjectID, question.DifficultyLevel, question.Points, question.UpdatedAt, questionID)
+		question.CorrectAnswer, question.SubjectID, question.DifficultyLevel, question.Points, question.Rubric, question.AnswerKey,
+		question.Explanation, question.UpdatedAt, questionID, tenant.Filter(ctx))
 	return err
 }
 
 func (r *repository) DeleteQuestion(ctx context.Context, questionID uuid.UUID) error {
-	// Check if question is used in any exam
-	checkQuery := `SELECT COUNT(*) FROM exam_question WHERE question_id = $1`
+	// Check if question is used in any exam, drawn per student included
+	checkQuery := `SELECT COUNT(DISTINCT exam_id) FROM (
+					   SELECT exam_id FROM exam_question WHERE question_id = $1
+					   UNION ALL
+					   SELECT exam_id FROM exam_attempt WHERE $1 = ANY(question_ids)
+				   ) used`
 	var count int
 	err := r.db.GetContext(ctx, &count, checkQuery, questionID)
 	if err != nil {
@@ -177,21 +201,26 @@ func (r *repository) DeleteQuestion(ctx context.Context, questionID uuid.UUID) e
 		return fmt.Errorf("cannot delete question: it is being used in %d exam(s)", count)
 	}
 
-	deleteQuery := `DELETE FROM question WHERE id = $1`
-	_, err = r.db.ExecContext(ctx, deleteQuery, questionID)
+	deleteQuery := `DELETE FROM question WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2)`
+	_, err = r.db.ExecContext(ctx, deleteQuery, questionID, tenant.Filter(ctx))
 	return err
 }
 
 func (r *repository) GetQuestionsByType(ctx context.Context, schoolID, subjectID uuid.UUID, questionType string) ([]QuestionWithSubject, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `SELECT q.id, q.question, q.question_type, q.options, q.correct_answer, q.school_id, q.subject_id, s.name as subject_name, 
-			  q.difficulty_level, q.points, q.created_at, q.updated_at
+			  q.difficulty_level, q.points, q.rubric, q.answer_key, q.explanation, q.created_at, q.updated_at
 			  FROM question q
 			  JOIN subject s ON q.subject_id = s.id
 			  WHERE q.school_id = $1 AND q.subject_id = $2 AND q.question_type = $3
 			  ORDER BY q.created_at DESC`
 
 	var questions []QuestionWithSubject
-	err := r.db.SelectContext(ctx, &questions, query, schoolID, subjectID, questionType)
+	err = r.db.SelectContext(ctx, &questions, query, schoolID, subjectID, questionType)
 	return questions, err
 }
 
@@ -0,0 +1,155 @@
+package request
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// AnswerKeyRequest is the answer key of the auto-graded types other than
+// multiple_choice, which keeps its correct_answer. Each type uses its own
+// fields:
+//   - true_false: boolean
+//   - multi_select: options, the IDs of every correct option
+//   - short_answer: accepted answers and/or regex patterns
+//   - numeric: number, give or take tolerance
+//   - matching: pairs, the right option ID of every left option ID
+//   - ordering: order, the option IDs in the correct order
+type AnswerKeyRequest struct {
+	Boolean       *bool             `json:"boolean,omitempty"`
+	Options       []string          `json:"options,omitempty"`
+	Accepted      []string          `json:"accepted,omitempty"`
+	Patterns      []string          `json:"patterns,omitempty"`
+	CaseSensitive bool              `json:"case_sensitive,omitempty"`
+	Number        *float64          `json:"number,omitempty"`
+	Tolerance     float64           `json:"tolerance,omitempty" validate:"min=0"`
+	Pairs         map[string]string `json:"pairs,omitempty"`
+	Order         []string          `json:"order,omitempty"`
+}
+
+// validateQuestion checks that a question carries exactly what its type is
+// graded with.
+func validateQuestion(questionType string, options []QuestionOptionRequest, correctAnswer *string, rubric *RubricRequest, key *AnswerKeyRequest) error {
+	optionIDs := make([]string, 0, len(options))
+	for _, option := range options {
+		if slices.Contains(optionIDs, option.ID) {
+			return fmt.Errorf("option %s is defined twice", option.ID)
+		}
+		optionIDs = append(optionIDs, option.ID)
+	}
+
+	if correctAnswer != nil && questionType != "multiple_choice" {
+		return fmt.Errorf("%s questions should not have a correct answer", questionType)
+	}
+	if rubric != nil && questionType != "essay" {
+		return fmt.Errorf("%s questions should not have a rubric", questionType)
+	}
+	if key != nil && (questionType == "multiple_choice" || questionType == "essay") {
+		return fmt.Errorf("%s questions should not have an answer key", questionType)
+	}
+	if key == nil && questionType != "multiple_choice" && questionType != "essay" {
+		return fmt.Errorf("%s questions must have an answer key", questionType)
+	}
+
+	switch questionType {
+	case "multiple_choice":
+		if len(options) < 2 {
+			return fmt.Errorf("multiple choice questions must have at least 2 options")
+		}
+		if correctAnswer == nil {
+			return fmt.Errorf("multiple choice questions must have a correct answer")
+		}
+		if !slices.Contains(optionIDs, *correctAnswer) {
+			return fmt.Errorf("correct answer must match one of the option IDs")
+		}
+	case "essay":
+		if len(options) > 0 {
+			return fmt.Errorf("essay questions should not have options")
+		}
+		if rubric != nil {
+			return rubric.Validate()
+		}
+	case "true_false":
+		if len(options) > 0 {
+			return fmt.Errorf("true/false questions should not have options")
+		}
+		if key.Boolean == nil {
+			return fmt.Errorf("true/false questions must have a boolean answer key")
+		}
+	case "multi_select":
+		if len(options) < 2 {
+			return fmt.Errorf("multi-select questions must have at least 2 options")
+		}
+		if len(key.Options) == 0 {
+			return fmt.Errorf("multi-select questions must have at least 1 correct option")
+		}
+		for i, id := range key.Options {
+			if !slices.Contains(optionIDs, id) || slices.Contains(key.Options[:i], id) {
+				return fmt.Errorf("correct options must be distinct option IDs")
+			}
+		}
+	case "short_answer":
+		if len(options) > 0 {
+			return fmt.Errorf("short answer questions should not have options")
+		}
+		if len(key.Accepted) == 0 && len(key.Patterns) == 0 {
+			return fmt.Errorf("short answer questions must have an accepted answer or pattern")
+		}
+		for _, pattern := range key.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	case "numeric":
+		if len(options) > 0 {
+			return fmt.Errorf("numeric questions should not have options")
+		}
+		if key.Number == nil {
+			return fmt.Errorf("numeric questions must have a number answer key")
+		}
+	case "matching":
+		var left, right []string
+		for _, option := range options {
+			switch option.Side {
+			case "left":
+				left = append(left, option.ID)
+			case "right":
+				right = append(right, option.ID)
+			default:
+				return fmt.Errorf("matching options must be on the left or right side")
+			}
+		}
+		if len(left) == 0 || len(right) == 0 {
+			return fmt.Errorf("matching questions must have options on both sides")
+		}
+		if len(key.Pairs) != len(left) {
+			return fmt.Errorf("matching questions must pair every left option")
+		}
+		for l, r := range key.Pairs {
+			if !slices.Contains(left, l) || !slices.Contains(right, r) {
+				return fmt.Errorf("pairs must match a left option ID to a right option ID")
+			}
+		}
+	case "ordering":
+		if len(options) < 2 {
+			return fmt.Errorf("ordering questions must have at least 2 options")
+		}
+		if len(key.Order) != len(options) {
+			return fmt.Errorf("order must list every option once")
+		}
+		for i, id := range key.Order {
+			if !slices.Contains(optionIDs, id) || slices.Contains(key.Order[:i], id) {
+				return fmt.Errorf("order must list every option once")
+			}
+		}
+	}
+
+	if questionType != "matching" {
+		for _, option := range options {
+			if option.Side != "" {
+				return fmt.Errorf("only matching options have a side")
+			}
+		}
+	}
+	return nil
+}
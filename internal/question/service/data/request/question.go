@@ -9,92 +9,92 @@ import (
 
 type CreateQuestionRequest struct {
 	Question        string                  `json:"question" validate:"required"`
-	QuestionType    string                  `json:"question_type" validate:"required,oneof=multiple_choice essay"`
-	Options         []QuestionOptionRequest `json:"options,omitempty"`
+	QuestionType    string                  `json:"question_type" validate:"required,oneof=multiple_choice essay true_false multi_select short_answer numeric matching ordering"`
+	Options         []QuestionOptionRequest `json:"options,omitempty" validate:"omitempty,dive"`
 	CorrectAnswer   *string                 `json:"correct_answer,omitempty"`
 	SchoolID        uuid.UUID               `json:"school_id" validate:"required"`
 	SubjectID       uuid.UUID               `json:"subject_id" validate:"required"`
 	DifficultyLevel string                  `json:"difficulty_level" validate:"required,oneof=easy medium hard"`
 	Points          int                     `json:"points" validate:"required,min=1"`
+	Rubric          *RubricRequest          `json:"rubric,omitempty" validate:"omitempty"`     // Only for essays
+	AnswerKey       *AnswerKeyRequest       `json:"answer_key,omitempty" validate:"omitempty"` // For the other auto-graded types
+	// Explanation of the answer, shown to students by exams that show them
+	Explanation string `json:"explanation" validate:"max=5000"`
 }
 
 type QuestionOptionRequest struct {
 	ID   string `json:"id" validate:"required"`
 	Text string `json:"text" validate:"required"`
+	Side string `json:"side,omitempty" validate:"omitempty,oneof=left right"` // Only for matching
 }
 
-func (r CreateQuestionRequest) Validate() error {
-	if r.QuestionType == "multiple_choice" {
-		if len(r.Options) < 2 {
-			return fmt.Errorf("multiple choice questions must have at least 2 options")
-		}
-		if r.CorrectAnswer == nil {
-			return fmt.Errorf("multiple choice questions must have a correct answer")
+// RubricRequest describes how an essay is scored: the answer gets one level
+// of every criterion, and the points of the picked levels are scaled to the
+// points of the question.
+type RubricRequest struct {
+	Criteria []RubricCriterionRequest `json:"criteria" validate:"required,min=1,dive"`
+}
+
+type RubricCriterionRequest struct {
+	ID          string               `json:"id" validate:"required"`
+	Name        string               `json:"name" validate:"required"`
+	Description string               `json:"description,omitempty"`
+	Levels      []RubricLevelRequest `json:"levels" validate:"required,min=1,dive"`
+}
+
+type RubricLevelRequest struct {
+	ID          string  `json:"id" validate:"required"`
+	Label       string  `json:"label" validate:"required"`
+	Description string  `json:"description,omitempty"`
+	Points      float64 `json:"points" validate:"min=0"`
+}
+
+func (r RubricRequest) Validate() error {
+	criteria := make(map[string]bool)
+	var maxPoints float64
+	for _, criterion := range r.Criteria {
+		if criteria[criterion.ID] {
+			return fmt.Errorf("rubric criterion %s is defined twice", criterion.ID)
 		}
+		criteria[criterion.ID] = true
 
-		// Validate that correct answer exists in options
-		correctAnswerExists := false
-		for _, option := range r.Options {
-			if option.ID == *r.CorrectAnswer {
-				correctAnswerExists = true
-				break
+		levels := make(map[string]bool)
+		var best float64
+		for _, level := range criterion.Levels {
+			if levels[level.ID] {
+				return fmt.Errorf("rubric level %s of criterion %s is defined twice", level.ID, criterion.ID)
 			}
+			levels[level.ID] = true
+			best = max(best, level.Points)
 		}
-		if !correctAnswerExists {
-			return fmt.Errorf("correct answer must match one of the option IDs")
-		}
-	} else if r.QuestionType == "essay" {
-		if len(r.Options) > 0 {
-			return fmt.Errorf("essay questions should not have options")
-		}
-		if r.CorrectAnswer != nil {
-			return fmt.Errorf("essay questions should not have a correct answer")
-		}
+		maxPoints += best
+	}
+	if maxPoints == 0 {
+		return fmt.Errorf("rubric must be worth more than 0 points")
 	}
-
 	return nil
 }
 
+func (r CreateQuestionRequest) Validate() error {
+	return validateQuestion(r.QuestionType, r.Options, r.CorrectAnswer, r.Rubric, r.AnswerKey)
+}
+
 type UpdateQuestionRequest struct {
 	Question        string                  `json:"question" validate:"required"`
-	QuestionType    string                  `json:"question_type" validate:"required,oneof=multiple_choice essay"`
-	Options         []QuestionOptionRequest `json:"options,omitempty"`
+	QuestionType    string                  `json:"question_type" validate:"required,oneof=multiple_choice essay true_false multi_select short_answer numeric matching ordering"`
+	Options         []QuestionOptionRequest `json:"options,omitempty" validate:"omitempty,dive"`
 	CorrectAnswer   *string                 `json:"correct_answer,omitempty"`
 	SubjectID       uuid.UUID               `json:"subject_id" validate:"required"`
 	DifficultyLevel string                  `json:"difficulty_level" validate:"required,oneof=easy medium hard"`
 	Points          int                     `json:"points" validate:"required,min=1"`
+	Rubric          *RubricRequest          `json:"rubric,omitempty" validate:"omitempty"`     // Only for essays
+	AnswerKey       *AnswerKeyRequest       `json:"answer_key,omitempty" validate:"omitempty"` // For the other auto-graded types
+	// Explanation of the answer, shown to students by exams that show them
+	Explanation string `json:"explanation" validate:"max=5000"`
 }
 
 func (r UpdateQuestionRequest) Validate() error {
-	if r.QuestionType == "multiple_choice" {
-		if len(r.Options) < 2 {
-			return fmt.Errorf("multiple choice questions must have at least 2 options")
-		}
-		if r.CorrectAnswer == nil {
-			return fmt.Errorf("multiple choice questions must have a correct answer")
-		}
-
-		// Validate that correct answer exists in options
-		correctAnswerExists := false
-		for _, option := range r.Options {
-			if option.ID == *r.CorrectAnswer {
-				correctAnswerExists = true
-				break
-			}
-		}
-		if !correctAnswerExists {
-			return fmt.Errorf("correct answer must match one of the option IDs")
-		}
-	} else if r.QuestionType == "essay" {
-		if len(r.Options) > 0 {
-			return fmt.Errorf("essay questions should not have options")
-		}
-		if r.CorrectAnswer != nil {
-			return fmt.Errorf("essay questions should not have a correct answer")
-		}
-	}
-
-	return nil
+	return validateQuestion(r.QuestionType, r.Options, r.CorrectAnswer, r.Rubric, r.AnswerKey)
 }
 
 type GetListQuestionQuery struct {
@@ -124,5 +124,5 @@ func (q GetListQuestionQuery) Get() (commonHttp.Query, map[string]interface{}) {
 type GetQuestionsByTypeQuery struct {
 	SchoolID     uuid.UUID `form:"school_id" binding:"required,uuid"`
 	SubjectID    uuid.UUID `form:"subject_id" binding:"required,uuid"`
-	QuestionType string    `form:"question_type" binding:"required,oneof=multiple_choice essay"`
+	QuestionType string    `form:"question_type" binding:"required,oneof=multiple_choice essay true_false multi_select short_answer numeric matching ordering"`
 }
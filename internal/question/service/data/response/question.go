@@ -13,6 +13,9 @@ type QuestionResponse struct {
 	SubjectName     string                   `json:"subject_name"`
 	DifficultyLevel string                   `json:"difficulty_level"`
 	Points          int                      `json:"points"`
+	Rubric          *RubricResponse          `json:"rubric,omitempty"`
+	AnswerKey       *AnswerKeyResponse       `json:"answer_key,omitempty"`
+	Explanation     string                   `json:"explanation"`
 	CreatedAt       int64                    `json:"created_at"`
 	UpdatedAt       int64                    `json:"updated_at"`
 }
@@ -20,6 +23,37 @@ type QuestionResponse struct {
 type QuestionOptionResponse struct {
 	ID   string `json:"id"`
 	Text string `json:"text"`
+	Side string `json:"side,omitempty"`
+}
+
+type AnswerKeyResponse struct {
+	Boolean       *bool             `json:"boolean,omitempty"`
+	Options       []string          `json:"options,omitempty"`
+	Accepted      []string          `json:"accepted,omitempty"`
+	Patterns      []string          `json:"patterns,omitempty"`
+	CaseSensitive bool              `json:"case_sensitive,omitempty"`
+	Number        *float64          `json:"number,omitempty"`
+	Tolerance     float64           `json:"tolerance,omitempty"`
+	Pairs         map[string]string `json:"pairs,omitempty"`
+	Order         []string          `json:"order,omitempty"`
+}
+
+type RubricResponse struct {
+	Criteria []RubricCriterionResponse `json:"criteria"`
+}
+
+type RubricCriterionResponse struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Description string                `json:"description,omitempty"`
+	Levels      []RubricLevelResponse `json:"levels"`
+}
+
+type RubricLevelResponse struct {
+	ID          string  `json:"id"`
+	Label       string  `json:"label"`
+	Description string  `json:"description,omitempty"`
+	Points      float64 `json:"points"`
 }
 
 type GetListQuestionResponse []QuestionResponse
@@ -35,6 +69,9 @@ type DetailQuestionResponse struct {
 	SubjectName     string                   `json:"subject_name"`
 	DifficultyLevel string                   `json:"difficulty_level"`
 	Points          int                      `json:"points"`
+	Rubric          *RubricResponse          `json:"rubric,omitempty"`
+	AnswerKey       *AnswerKeyResponse       `json:"answer_key,omitempty"`
+	Explanation     string                   `json:"explanation"`
 	CreatedAt       int64                    `json:"created_at"`
 	UpdatedAt       int64                    `json:"updated_at"`
 }
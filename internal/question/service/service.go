@@ -49,7 +49,7 @@ func (s *service) CreateQuestion(ctx context.Context, data request.CreateQuestio
 	}
 
 	var optionsJSON *string
-	if data.QuestionType == "multiple_choice" && len(data.Options) > 0 {
+	if len(data.Options) > 0 {
 		optionsBytes, err := json.Marshal(data.Options)
 		if err != nil {
 			return err
@@ -58,6 +58,15 @@ func (s *service) CreateQuestion(ctx context.Context, data request.CreateQuestio
 		optionsJSON = &optionsStr
 	}
 
+	rubricJSON, err := marshalRubric(data.Rubric)
+	if err != nil {
+		return err
+	}
+	answerKeyJSON, err := marshalAnswerKey(data.AnswerKey)
+	if err != nil {
+		return err
+	}
+
 	question := repository.Question{
 		ID:              uuid.New(),
 		Question:        data.Question,
@@ -68,6 +77,9 @@ func (s *service) CreateQuestion(ctx context.Context, data request.CreateQuestio
 		SubjectID:       data.SubjectID,
 		DifficultyLevel: data.DifficultyLevel,
 		Points:          data.Points,
+		Rubric:          rubricJSON,
+		AnswerKey:       answerKeyJSON,
+		Explanation:     data.Explanation,
 		CreatedAt:       now,
 		CreatedBy:       claim.User.ID,
 		UpdatedAt:       0,
@@ -90,13 +102,14 @@ func (s *service) GetDetailQuestion(ctx context.Context, questionID uuid.UUID) (
 	}
 
 	var options []response.QuestionOptionResponse
-	if question.QuestionType == "multiple_choice" && question.Options != nil {
+	if question.Options != nil {
 		var optionRequests []request.QuestionOptionRequest
 		if err := json.Unmarshal([]byte(*question.Options), &optionRequests); err == nil {
 			for _, option := range optionRequests {
 				options = append(options, response.QuestionOptionResponse{
 					ID:   option.ID,
 					Text: option.Text,
+					Side: option.Side,
 				})
 			}
 		}
@@ -113,6 +126,9 @@ func (s *service) GetDetailQuestion(ctx context.Context, questionID uuid.UUID) (
 		SubjectName:     question.SubjectName,
 		DifficultyLevel: question.DifficultyLevel,
 		Points:          question.Points,
+		Rubric:          parseRubric(question.Rubric),
+		AnswerKey:       parseAnswerKey(question.AnswerKey),
+		Explanation:     question.Explanation,
 		CreatedAt:       question.CreatedAt,
 		UpdatedAt:       question.UpdatedAt,
 	}
@@ -138,13 +154,14 @@ func (s *service) GetListQuestions(ctx context.Context, query request.GetListQue
 	res := response.GetListQuestionResponse{}
 	for _, question := range questions {
 		var options []response.QuestionOptionResponse
-		if question.QuestionType == "multiple_choice" && question.Options != nil {
+		if question.Options != nil {
 			var optionRequests []request.QuestionOptionRequest
 			if err := json.Unmarshal([]byte(*question.Options), &optionRequests); err == nil {
 				for _, option := range optionRequests {
 					options = append(options, response.QuestionOptionResponse{
 						ID:   option.ID,
 						Text: option.Text,
+						Side: option.Side,
 					})
 				}
 			}
@@ -161,6 +178,9 @@ func (s *service) GetListQuestions(ctx context.Context, query request.GetListQue
 			SubjectName:     question.SubjectName,
 			DifficultyLevel: question.DifficultyLevel,
 			Points:          question.Points,
+			Rubric:          parseRubric(question.Rubric),
+			AnswerKey:       parseAnswerKey(question.AnswerKey),
+			Explanation:     question.Explanation,
 			CreatedAt:       question.CreatedAt,
 			UpdatedAt:       question.UpdatedAt,
 		})
@@ -178,7 +198,7 @@ func (s *service) UpdateQuestion(ctx context.Context, questionID uuid.UUID, data
 	now := time.Now().UnixMilli()
 
 	var optionsJSON *string
-	if data.QuestionType == "multiple_choice" && len(data.Options) > 0 {
+	if len(data.Options) > 0 {
 		optionsBytes, err := json.Marshal(data.Options)
 		if err != nil {
 			return err
@@ -187,6 +207,15 @@ func (s *service) UpdateQuestion(ctx context.Context, questionID uuid.UUID, data
 		optionsJSON = &optionsStr
 	}
 
+	rubricJSON, err := marshalRubric(data.Rubric)
+	if err != nil {
+		return err
+	}
+	answerKeyJSON, err := marshalAnswerKey(data.AnswerKey)
+	if err != nil {
+		return err
+	}
+
 	question := repository.Question{
 		Question:        data.Question,
 		QuestionType:    data.QuestionType,
@@ -195,10 +224,13 @@ func (s *service) UpdateQuestion(ctx context.Context, questionID uuid.UUID, data
 		SubjectID:       data.SubjectID,
 		DifficultyLevel: data.DifficultyLevel,
 		Points:          data.Points,
+		Rubric:          rubricJSON,
+		AnswerKey:       answerKeyJSON,
+		Explanation:     data.Explanation,
 		UpdatedAt:       now,
 	}
 
-	err := s.repository.UpdateQuestion(ctx, questionID, question)
+	err = s.repository.UpdateQuestion(ctx, questionID, question)
 	if err != nil {
 		log.Err(err).Msg("Failed to update question")
 		return err
@@ -226,13 +258,14 @@ func (s *service) GetQuestionsByType(ctx context.Context, query request.GetQuest
 	var res response.QuestionsByTypeResponse
 	for _, question := range questions {
 		var options []response.QuestionOptionResponse
-		if question.QuestionType == "multiple_choice" && question.Options != nil {
+		if question.Options != nil {
 			var optionRequests []request.QuestionOptionRequest
 			if err := json.Unmarshal([]byte(*question.Options), &optionRequests); err == nil {
 				for _, option := range optionRequests {
 					options = append(options, response.QuestionOptionResponse{
 						ID:   option.ID,
 						Text: option.Text,
+						Side: option.Side,
 					})
 				}
 			}
@@ -249,6 +282,9 @@ func (s *service) GetQuestionsByType(ctx context.Context, query request.GetQuest
 			SubjectName:     question.SubjectName,
 			DifficultyLevel: question.DifficultyLevel,
 			Points:          question.Points,
+			Rubric:          parseRubric(question.Rubric),
+			AnswerKey:       parseAnswerKey(question.AnswerKey),
+			Explanation:     question.Explanation,
 			CreatedAt:       question.CreatedAt,
 			UpdatedAt:       question.UpdatedAt,
 		})
@@ -256,3 +292,78 @@ func (s *service) GetQuestionsByType(ctx context.Context, query request.GetQuest
 
 	return res, nil
 }
+
+func marshalRubric(rubric *request.RubricRequest) (*string, error) {
+	if rubric == nil {
+		return nil, nil
+	}
+	rubricBytes, err := json.Marshal(rubric)
+	if err != nil {
+		return nil, err
+	}
+	rubricStr := string(rubricBytes)
+	return &rubricStr, nil
+}
+
+func parseRubric(rubricJSON *string) *response.RubricResponse {
+	if rubricJSON == nil {
+		return nil
+	}
+	var rubric request.RubricRequest
+	if err := json.Unmarshal([]byte(*rubricJSON), &rubric); err != nil {
+		return nil
+	}
+
+	res := &response.RubricResponse{}
+	for _, criterion := range rubric.Criteria {
+		criterionResponse := response.RubricCriterionResponse{
+			ID:          criterion.ID,
+			Name:        criterion.Name,
+			Description: criterion.Description,
+		}
+		for _, level := range criterion.Levels {
+			criterionResponse.Levels = append(criterionResponse.Levels, response.RubricLevelResponse{
+				ID:          level.ID,
+				Label:       level.Label,
+				Description: level.Description,
+				Points:      level.Points,
+			})
+		}
+		res.Criteria = append(res.Criteria, criterionResponse)
+	}
+	return res
+}
+
+func marshalAnswerKey(key *request.AnswerKeyRequest) (*string, error) {
+	if key == nil {
+		return nil, nil
+	}
+	keyBytes, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	keyStr := string(keyBytes)
+	return &keyStr, nil
+}
+
+func parseAnswerKey(keyJSON *string) *response.AnswerKeyResponse {
+	if keyJSON == nil {
+		return nil
+	}
+	var key request.AnswerKeyRequest
+	if err := json.Unmarshal([]byte(*keyJSON), &key); err != nil {
+		return nil
+	}
+
+	return &response.AnswerKeyResponse{
+		Boolean:       key.Boolean,
+		Options:       key.Options,
+		Accepted:      key.Accepted,
+		Patterns:      key.Patterns,
+		CaseSensitive: key.CaseSensitive,
+		Number:        key.Number,
+		Tolerance:     key.Tolerance,
+		Pairs:         key.Pairs,
+		Order:         key.Order,
+	}
+}
@@ -131,11 +131,7 @@ func (h *Handler) SwitchSchool(c *gin.Context) {
 
 	token, refreshToken, err := h.service.SwitchSchool(c.Request.Context(), schoolID)
 	if err != nil {
-		response := commonHttp.NewResponse().
-			SetCode(http.StatusInternalServerError).
-			SetMessage(err.Error()).
-			SetErrors(err)
-		c.JSON(response.Code, response)
+		c.Error(err)
 		return
 	}
 
@@ -204,3 +200,289 @@ func (h *Handler) UpdateSchoolProfile(c *gin.Context) {
 		"data":    school,
 	})
 }
+
+func (h *Handler) UpdateSchoolSecurity(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	req := request.UpdateSchoolSecurityRequest{}
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	school, err := h.service.UpdateSchoolSecurity(c.Request.Context(), schoolID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("update school security success").
+		SetData(school)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	req := request.CreateAPIKeyRequest{}
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(c.Request.Context(), schoolID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("create api key success").
+		SetData(key)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(c.Request.Context(), schoolID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("list api keys success").
+		SetData(keys)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("api_key_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	err = h.service.RevokeAPIKey(c.Request.Context(), schoolID, keyID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("revoke api key success")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	req := request.CreateWebhookRequest{}
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	hook, err := h.service.CreateWebhook(c.Request.Context(), schoolID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("create webhook success").
+		SetData(hook)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	hooks, err := h.service.ListWebhooks(c.Request.Context(), schoolID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("list webhooks success").
+		SetData(hooks)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	req := request.UpdateWebhookRequest{}
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	hook, err := h.service.UpdateWebhook(c.Request.Context(), schoolID, webhookID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("update webhook success").
+		SetData(hook)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	err = h.service.DeleteWebhook(c.Request.Context(), schoolID, webhookID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("delete webhook success")
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	deliveries, err := h.service.ListWebhookDeliveries(c.Request.Context(), schoolID, webhookID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("list webhook deliveries success").
+		SetData(deliveries)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) TestWebhook(c *gin.Context) {
+	schoolID, err := uuid.Parse(c.Param("school_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	test, err := h.service.TestWebhook(c.Request.Context(), schoolID, webhookID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusOK).
+		SetMessage("test webhook success").
+		SetData(test)
+
+	c.JSON(http.StatusOK, response)
+}
@@ -8,6 +8,11 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+const (
+	UserSchoolRoleAdmin       = "admin"
+	UserSchoolRoleHeadTeacher = "head_teacher"
+)
+
 type Repository interface {
 	CreateSchool(ctx context.Context, userID uuid.UUID, school School) error
 	GetSchoolByID(ctx context.Context, id uuid.UUID) (*School, error)
@@ -15,6 +20,7 @@ type Repository interface {
 	GetSchoolRoleByUserIDAndSchoolID(ctx context.Context, userID uuid.UUID, schoolID uuid.UUID) (*UserSchoolRole, error)
 	DeleteSchool(ctx context.Context, schoolID uuid.UUID) error
 	UpdateSchoolProfile(ctx context.Context, schoolID uuid.UUID, school School) (*School, error)
+	UpdateSchoolSecurity(ctx context.Context, schoolID uuid.UUID, mfaRequired bool) error
 	GetSchoolStatistics(ctx context.Context, schoolID uuid.UUID) (*SchoolStatistics, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*User, error)
 	GetSchoolCounts(ctx context.Context, schoolIDs []uuid.UUID) (map[uuid.UUID]SchoolCounts, error)
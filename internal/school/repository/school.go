@@ -27,6 +27,7 @@ type School struct {
 	Website     string         `db:"website"`
 	Logo        string         `db:"logo"`
 	Banner      string         `db:"banner"`
+	MFARequired bool           `db:"mfa_required"`
 	CreatedAt   int64          `db:"created_at"`
 	CreatedBy   uuid.UUID      `db:"created_by"`
 	UpdatedAt   int64          `db:"updated_at"`
@@ -94,7 +95,7 @@ func (r *repository) CreateSchool(ctx context.Context, userID uuid.UUID, school
 	adminSchoolRole := UserSchoolRole{
 		UserID:    userID,
 		SchoolID:  school.ID,
-		RoleID:    "admin",
+		RoleID:    UserSchoolRoleAdmin,
 		CreatedBy: userID,
 	}
 
@@ -115,7 +116,7 @@ func (r *repository) GetSchoolByID(ctx context.Context, id uuid.UUID) (*School,
 	school := School{}
 	err := r.db.GetContext(ctx, &school, `SELECT 
 		id, name, level, description, address, city, province, 
-		postal_code, phone, email, website, logo, banner, created_at, created_by, updated_at, updated_by
+		postal_code, phone, email, website, logo, banner, mfa_required, created_at, created_by, updated_at, updated_by
 		FROM school WHERE id = $1`, id)
 	if err != nil {
 		return nil, err
@@ -317,6 +318,11 @@ func (r *repository) UpdateSchoolProfile(ctx context.Context, schoolID uuid.UUID
 	return r.GetSchoolByID(ctx, schoolID)
 }
 
+func (r *repository) UpdateSchoolSecurity(ctx context.Context, schoolID uuid.UUID, mfaRequired bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE school SET mfa_required = $2, updated_at = $3 WHERE id = $1", schoolID, mfaRequired, time.Now().UnixMilli())
+	return err
+}
+
 func (r *repository) GetSchoolStatistics(ctx context.Context, schoolID uuid.UUID) (*SchoolStatistics, error) {
 	stats := &SchoolStatistics{}
 
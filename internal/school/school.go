@@ -30,17 +30,27 @@ func New(c *config.Config, i *infra.Infra, r *gin.Engine, v *validator.Validate)
 
 func (s *School) Init() {
 	r := repository.New(s.i.Postgres)
-	svc := service.New(r, s.c)
+	svc := service.New(r, s.c, s.i.Session, s.i.Keys, s.i.APIKeys, s.i.Webhooks)
 	h := handler.New(svc, s.v)
 
-	authMiddleware := middleware.Auth(s.c.JWT.Secret)
+	authMiddleware := middleware.Auth(s.i.Keys, s.i.Session, s.i.APIKeys)
 
 	v1 := s.Group("/api/v1/school").Use(authMiddleware)
 	v1.POST("", h.CreateSchool)
 	v1.GET("/:school_id", h.GetDetailSchool)
 	v1.GET("", h.ListSchool)
 	v1.GET("/statistic", h.ListSchoolStatistics)
-	v1.DELETE("/:school_id", h.DeleteSchool)
+	v1.DELETE("/:school_id", middleware.RequirePermission(middleware.PermSchoolManage), h.DeleteSchool)
 	v1.GET("/:school_id/switch", h.SwitchSchool)
-	v1.PUT("/:school_id", h.UpdateSchoolProfile)
+	v1.PUT("/:school_id", middleware.RequirePermission(middleware.PermSchoolManage), h.UpdateSchoolProfile)
+	v1.PUT("/:school_id/security", middleware.RequirePermission(middleware.PermSchoolManage), h.UpdateSchoolSecurity)
+	v1.POST("/:school_id/api-keys", middleware.RequirePermission(middleware.PermSchoolManage), h.CreateAPIKey)
+	v1.GET("/:school_id/api-keys", middleware.RequirePermission(middleware.PermSchoolManage), h.ListAPIKeys)
+	v1.DELETE("/:school_id/api-keys/:api_key_id", middleware.RequirePermission(middleware.PermSchoolManage), h.RevokeAPIKey)
+	v1.POST("/:school_id/webhooks", middleware.RequirePermission(middleware.PermSchoolManage), h.CreateWebhook)
+	v1.GET("/:school_id/webhooks", middleware.RequirePermission(middleware.PermSchoolManage), h.ListWebhooks)
+	v1.PUT("/:school_id/webhooks/:webhook_id", middleware.RequirePermission(middleware.PermSchoolManage), h.UpdateWebhook)
+	v1.DELETE("/:school_id/webhooks/:webhook_id", middleware.RequirePermission(middleware.PermSchoolManage), h.DeleteWebhook)
+	v1.GET("/:school_id/webhooks/:webhook_id/deliveries", middleware.RequirePermission(middleware.PermSchoolManage), h.ListWebhookDeliveries)
+	v1.POST("/:school_id/webhooks/:webhook_id/test", middleware.RequirePermission(middleware.PermSchoolManage), h.TestWebhook)
 }
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"enuma-elish/internal/school/service/data/request"
+	"enuma-elish/internal/school/service/data/response"
+	"enuma-elish/pkg/apikey"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/middleware"
+	"enuma-elish/pkg/tenant"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+func (s *service) CreateAPIKey(ctx context.Context, schoolID uuid.UUID, data request.CreateAPIKeyRequest) (*response.APIKey, error) {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are managed by people; a leaked key must not be able to mint more.
+	if claim.Typ == jwt.TypeAPIKey {
+		return nil, commonError.ErrForbidden
+	}
+
+	schoolID, err = tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, scope := range data.Scopes {
+		if !middleware.IsAPIKeyScope(scope) {
+			return nil, commonError.ErrInvalidAPIKeyScope
+		}
+	}
+
+	var expiresAt int64
+	if data.ExpiresInDays > 0 {
+		expiresAt = time.Now().AddDate(0, 0, data.ExpiresInDays).UnixMilli()
+	}
+
+	key, plain, err := s.apiKeys.Create(ctx, schoolID, claim.User.ID, data.Name, data.Scopes, expiresAt)
+	if err != nil {
+		log.Err(err).Str("school_id", schoolID.String()).Msg("error creating api key")
+		return nil, err
+	}
+
+	res := apiKeyResponse(*key)
+	res.Key = plain
+	return &res, nil
+}
+
+func (s *service) ListAPIKeys(ctx context.Context, schoolID uuid.UUID) ([]response.APIKey, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.apiKeys.List(ctx, schoolID)
+	if err != nil {
+		log.Err(err).Str("school_id", schoolID.String()).Msg("error listing api keys")
+		return nil, err
+	}
+
+	res := make([]response.APIKey, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, apiKeyResponse(key))
+	}
+	return res, nil
+}
+
+func (s *service) RevokeAPIKey(ctx context.Context, schoolID, keyID uuid.UUID) error {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.apiKeys.Revoke(ctx, schoolID, keyID)
+	if err != nil {
+		log.Err(err).Str("api_key_id", keyID.String()).Msg("error revoking api key")
+		return err
+	}
+	if !revoked {
+		return commonError.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func apiKeyResponse(key apikey.Key) response.APIKey {
+	return response.APIKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     apikey.Prefix + "_" + key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -34,6 +34,12 @@ type UpdateSchoolProfileRequest struct {
 	Banner      string `json:"banner"`
 }
 
+type UpdateSchoolSecurityRequest struct {
+	// MFARequired forces admins and head teachers of the school to log in
+	// with a second factor.
+	MFARequired *bool `json:"mfa_required" validate:"required"`
+}
+
 type GetListSchoolQuery struct {
 	commonHttp.Query
 	Level string `form:"level"`
@@ -45,3 +51,22 @@ func (q GetListSchoolQuery) Get() (commonHttp.Query, map[string]interface{}) {
 	}
 	return q.Query, f
 }
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required"`
+	// ExpiresInDays of 0 creates a key that does not expire.
+	ExpiresInDays int `json:"expires_in_days" validate:"min=0,max=3650"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,required"`
+}
+
+type UpdateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,required"`
+	// Enabled re-enables a webhook that was disabled after failed deliveries.
+	Enabled *bool `json:"enabled" validate:"required"`
+}
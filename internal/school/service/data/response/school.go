@@ -25,6 +25,7 @@ type School struct {
 	Website     string    `json:"website"`
 	Logo        string    `json:"logo"`
 	Banner      string    `json:"banner"`
+	MFARequired bool      `json:"mfa_required"`
 	Status      string    `json:"status"`
 	CreatedAt   int64     `json:"created_at"`
 	CreatedBy   string    `json:"created_by,omitempty"`
@@ -54,3 +55,46 @@ type ListSchoolStatistics struct {
 	TotalTeachers int `json:"total_teachers"`
 	ActiveSchools int `json:"active_schools"`
 }
+
+type APIKey struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  int64     `json:"expires_at"`
+	LastUsedAt int64     `json:"last_used_at"`
+	LastUsedIP string    `json:"last_used_ip"`
+	RevokedAt  int64     `json:"revoked_at"`
+	CreatedAt  int64     `json:"created_at"`
+	// Key is only returned once, when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+type Webhook struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	Events       []string  `json:"events"`
+	Enabled      bool      `json:"enabled"`
+	FailureCount int       `json:"failure_count"`
+	DisabledAt   int64     `json:"disabled_at"`
+	CreatedAt    int64     `json:"created_at"`
+	UpdatedAt    int64     `json:"updated_at"`
+	// Secret is only returned once, when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID         uuid.UUID `json:"id"`
+	EventID    string    `json:"event_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	DurationMs int64     `json:"duration_ms"`
+	Success    bool      `json:"success"`
+	CreatedAt  int64     `json:"created_at"`
+}
+
+type WebhookTest struct {
+	EventID string `json:"event_id"`
+}
@@ -5,9 +5,12 @@ import (
 	"enuma-elish/internal/school/repository"
 	"enuma-elish/internal/school/service/data/request"
 	"enuma-elish/internal/school/service/data/response"
+	commonError "enuma-elish/pkg/error"
 	commonHttp "enuma-elish/pkg/http"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/tenant"
 	"math"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -105,6 +108,7 @@ func (s *service) GetDetailSchool(ctx context.Context, schoolID uuid.UUID) (resp
 			Website:     school.Website,
 			Logo:        school.Logo,
 			Banner:      school.Banner,
+			MFARequired: school.MFARequired,
 			CreatedAt:   school.CreatedAt,
 			CreatedBy:   user.Name,
 			UpdatedAt:   school.UpdatedAt,
@@ -211,6 +215,9 @@ func (s *service) GetListSchoolStatistics(ctx context.Context) (*response.ListSc
 
 }
 
+// mfaRequiredRoles are the school roles a school can force to use MFA.
+var mfaRequiredRoles = []string{repository.UserSchoolRoleAdmin, repository.UserSchoolRoleHeadTeacher}
+
 func (s *service) SwitchSchool(ctx context.Context, schoolID uuid.UUID) (string, string, error) {
 	claim, err := jwt.ExtractContext(ctx)
 	if err != nil {
@@ -224,45 +231,54 @@ func (s *service) SwitchSchool(ctx context.Context, schoolID uuid.UUID) (string,
 		return "", "", err
 	}
 
-	now := time.Now()
-	exp := now.Add(time.Hour * 2).Unix()
-	nbf := now.Unix()
-	iat := now.Unix()
-	payload := jwt.Payload{
-		Exp: exp,
-		Iat: iat,
-		Nbf: nbf,
-		Iss: "genesis",
-		Sub: claim.User.ID.String(),
-		Aud: "genesis",
-		User: jwt.User{
-			ID:         claim.User.ID,
-			Email:      claim.User.Email,
-			SchoolID:   schoolID,
-			SchoolRole: userSchoolRole.RoleID,
-			UserRole:   claim.User.UserRole,
-		},
+	sess, err := s.sessions.Get(ctx, claim.Sid)
+	if err != nil {
+		return "", "", err
 	}
 
-	token, err := jwt.GenerateToken(payload, s.config.JWT.Secret)
+	school, err := s.repository.GetSchoolByID(ctx, schoolID)
 	if err != nil {
-		log.Err(err).Msg("error generating token")
+		log.Err(err).Msg("error getting school")
+		return "", "", err
+	}
+
+	// Login only asks for a second factor when a school requires it, so a
+	// session started without one has to step up before entering a role
+	// that school protects.
+	if school.MFARequired && slices.Contains(mfaRequiredRoles, userSchoolRole.RoleID) && sess.MFAVerifiedAt == 0 {
+		return "", "", commonError.ErrMFARequired
+	}
+
+	// The refresh token issued before the switch still carries the old
+	// school, so it is rotated out together with the new pair.
+	refreshID, err := s.sessions.Reissue(ctx, sess, s.config.JWT.RefreshTTL())
+	if err != nil {
+		log.Err(err).Msg("error rotating session refresh token")
 		return "", "", err
 	}
 
-	refreshPayload := payload
-	refreshPayload.Exp = now.Add(time.Hour * 240).Unix()
-	refreshToken, err := jwt.GenerateToken(refreshPayload, s.config.JWT.Secret)
+	tokens, err := s.keys.GenerateTokenPair(ctx, jwt.User{
+		ID:         claim.User.ID,
+		Email:      claim.User.Email,
+		SchoolID:   schoolID,
+		SchoolRole: userSchoolRole.RoleID,
+		UserRole:   claim.User.UserRole,
+	}, sess.ID, refreshID, s.config.JWT.AccessTTL(), s.config.JWT.RefreshTTL())
 	if err != nil {
-		log.Err(err).Msg("Error generating refresh token")
+		log.Err(err).Msg("error generating token")
 		return "", "", err
 	}
 
-	return token, refreshToken, nil
+	return tokens.AccessToken, tokens.RefreshToken, nil
 }
 
 func (s *service) DeleteSchool(ctx context.Context, schoolID uuid.UUID) error {
-	err := s.repository.DeleteSchool(ctx, schoolID)
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.DeleteSchool(ctx, schoolID)
 	if err != nil {
 		log.Err(err).Msg("Failed to delete school")
 		return err
@@ -271,6 +287,11 @@ func (s *service) DeleteSchool(ctx context.Context, schoolID uuid.UUID) error {
 }
 
 func (s *service) UpdateSchoolProfile(ctx context.Context, schoolID uuid.UUID, data request.UpdateSchoolProfileRequest) (response.DetailSchool, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return response.DetailSchool{}, err
+	}
+
 	school := repository.School{
 		Name:        data.Name,
 		Level:       data.Level,
@@ -306,6 +327,7 @@ func (s *service) UpdateSchoolProfile(ctx context.Context, schoolID uuid.UUID, d
 			Website:     updatedSchool.Website,
 			Logo:        updatedSchool.Logo,
 			Banner:      updatedSchool.Banner,
+			MFARequired: updatedSchool.MFARequired,
 			CreatedAt:   updatedSchool.CreatedAt,
 			CreatedBy:   updatedSchool.CreatedBy.String(),
 			UpdatedAt:   updatedSchool.UpdatedAt,
@@ -315,6 +337,21 @@ func (s *service) UpdateSchoolProfile(ctx context.Context, schoolID uuid.UUID, d
 	}, nil
 }
 
+func (s *service) UpdateSchoolSecurity(ctx context.Context, schoolID uuid.UUID, data request.UpdateSchoolSecurityRequest) (response.DetailSchool, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return response.DetailSchool{}, err
+	}
+
+	err = s.repository.UpdateSchoolSecurity(ctx, schoolID, *data.MFARequired)
+	if err != nil {
+		log.Err(err).Msg("error updating school security")
+		return response.DetailSchool{}, err
+	}
+
+	return s.GetDetailSchool(ctx, schoolID)
+}
+
 func (s *service) setStatus(deteledAt int64) string {
 	if deteledAt == 0 {
 		return "active"
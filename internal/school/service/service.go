@@ -6,7 +6,11 @@ import (
 	"enuma-elish/internal/school/repository"
 	"enuma-elish/internal/school/service/data/request"
 	"enuma-elish/internal/school/service/data/response"
+	"enuma-elish/pkg/apikey"
 	commonHttp "enuma-elish/pkg/http"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/session"
+	"enuma-elish/pkg/webhook"
 
 	"github.com/google/uuid"
 )
@@ -19,17 +23,35 @@ type Service interface {
 	DeleteSchool(ctx context.Context, schoolID uuid.UUID) error
 	UpdateSchoolProfile(ctx context.Context, schoolID uuid.UUID, data request.UpdateSchoolProfileRequest) (response.DetailSchool, error)
 	GetListSchoolStatistics(ctx context.Context) (*response.ListSchoolStatistics, error)
+	UpdateSchoolSecurity(ctx context.Context, schoolID uuid.UUID, data request.UpdateSchoolSecurityRequest) (response.DetailSchool, error)
+	CreateAPIKey(ctx context.Context, schoolID uuid.UUID, data request.CreateAPIKeyRequest) (*response.APIKey, error)
+	ListAPIKeys(ctx context.Context, schoolID uuid.UUID) ([]response.APIKey, error)
+	RevokeAPIKey(ctx context.Context, schoolID, keyID uuid.UUID) error
+	CreateWebhook(ctx context.Context, schoolID uuid.UUID, data request.CreateWebhookRequest) (*response.Webhook, error)
+	ListWebhooks(ctx context.Context, schoolID uuid.UUID) ([]response.Webhook, error)
+	UpdateWebhook(ctx context.Context, schoolID, webhookID uuid.UUID, data request.UpdateWebhookRequest) (*response.Webhook, error)
+	DeleteWebhook(ctx context.Context, schoolID, webhookID uuid.UUID) error
+	ListWebhookDeliveries(ctx context.Context, schoolID, webhookID uuid.UUID) ([]response.WebhookDelivery, error)
+	TestWebhook(ctx context.Context, schoolID, webhookID uuid.UUID) (*response.WebhookTest, error)
 	// GetSetupSchool(ctx context.Context, userID uuid.UUID) (response.DetailSchool, error)
 }
 
 type service struct {
 	repository repository.Repository
 	config     *config.Config
+	sessions   *session.Store
+	keys       *jwt.KeySet
+	apiKeys    *apikey.Store
+	webhooks   *webhook.Store
 }
 
-func New(r repository.Repository, c *config.Config) Service {
+func New(r repository.Repository, c *config.Config, sessions *session.Store, keys *jwt.KeySet, apiKeys *apikey.Store, webhooks *webhook.Store) Service {
 	return &service{
 		repository: r,
 		config:     c,
+		sessions:   sessions,
+		keys:       keys,
+		apiKeys:    apiKeys,
+		webhooks:   webhooks,
 	}
 }
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"enuma-elish/internal/school/service/data/request"
+	"enuma-elish/internal/school/service/data/response"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/tenant"
+	"enuma-elish/pkg/webhook"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// deliveriesLimit is how many of the latest delivery attempts are listed.
+const deliveriesLimit = 50
+
+func (s *service) CreateWebhook(ctx context.Context, schoolID uuid.UUID, data request.CreateWebhookRequest) (*response.Webhook, error) {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	schoolID, err = tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateWebhook(data.URL, data.Events); err != nil {
+		return nil, err
+	}
+
+	hook, err := s.webhooks.Create(ctx, schoolID, claim.User.ID, data.URL, data.Events)
+	if err != nil {
+		log.Err(err).Str("school_id", schoolID.String()).Msg("error creating webhook")
+		return nil, err
+	}
+
+	res := webhookResponse(*hook)
+	res.Secret = hook.Secret
+	return &res, nil
+}
+
+func (s *service) ListWebhooks(ctx context.Context, schoolID uuid.UUID) ([]response.Webhook, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks, err := s.webhooks.List(ctx, schoolID)
+	if err != nil {
+		log.Err(err).Str("school_id", schoolID.String()).Msg("error listing webhooks")
+		return nil, err
+	}
+
+	res := make([]response.Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		res = append(res, webhookResponse(hook))
+	}
+	return res, nil
+}
+
+func (s *service) UpdateWebhook(ctx context.Context, schoolID, webhookID uuid.UUID, data request.UpdateWebhookRequest) (*response.Webhook, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateWebhook(data.URL, data.Events); err != nil {
+		return nil, err
+	}
+
+	hook, err := s.webhooks.Update(ctx, schoolID, webhookID, data.URL, data.Events, *data.Enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, commonError.ErrWebhookNotFound
+		}
+		log.Err(err).Str("webhook_id", webhookID.String()).Msg("error updating webhook")
+		return nil, err
+	}
+
+	res := webhookResponse(*hook)
+	return &res, nil
+}
+
+func (s *service) DeleteWebhook(ctx context.Context, schoolID, webhookID uuid.UUID) error {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.webhooks.Delete(ctx, schoolID, webhookID)
+	if err != nil {
+		log.Err(err).Str("webhook_id", webhookID.String()).Msg("error deleting webhook")
+		return err
+	}
+	if !deleted {
+		return commonError.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (s *service) ListWebhookDeliveries(ctx context.Context, schoolID, webhookID uuid.UUID) ([]response.WebhookDelivery, error) {
+	hook, err := s.getWebhook(ctx, schoolID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.webhooks.Deliveries(ctx, hook.ID, deliveriesLimit)
+	if err != nil {
+		log.Err(err).Str("webhook_id", webhookID.String()).Msg("error listing webhook deliveries")
+		return nil, err
+	}
+
+	res := make([]response.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		res = append(res, response.WebhookDelivery{
+			ID:         d.ID,
+			EventID:    d.EventID,
+			Event:      d.Event,
+			Attempt:    d.Attempt,
+			StatusCode: d.StatusCode,
+			Error:      d.Error,
+			DurationMs: d.DurationMs,
+			Success:    d.Success,
+			CreatedAt:  d.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (s *service) TestWebhook(ctx context.Context, schoolID, webhookID uuid.UUID) (*response.WebhookTest, error) {
+	hook, err := s.getWebhook(ctx, schoolID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	eventID, err := s.webhooks.Test(ctx, hook)
+	if err != nil {
+		log.Err(err).Str("webhook_id", webhookID.String()).Msg("error queueing webhook test")
+		return nil, err
+	}
+
+	return &response.WebhookTest{EventID: eventID}, nil
+}
+
+func (s *service) getWebhook(ctx context.Context, schoolID, webhookID uuid.UUID) (*webhook.Webhook, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := s.webhooks.Get(ctx, schoolID, webhookID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, commonError.ErrWebhookNotFound
+		}
+		log.Err(err).Str("webhook_id", webhookID.String()).Msg("error getting webhook")
+		return nil, err
+	}
+	return hook, nil
+}
+
+func validateWebhook(url string, events []string) error {
+	if !webhook.ValidURL(url) {
+		return commonError.ErrInvalidWebhookURL
+	}
+	for _, e := range events {
+		if !webhook.ValidEvent(e) {
+			return commonError.ErrInvalidWebhookEvent
+		}
+	}
+	return nil
+}
+
+func webhookResponse(hook webhook.Webhook) response.Webhook {
+	return response.Webhook{
+		ID:           hook.ID,
+		URL:          hook.URL,
+		Events:       hook.Events,
+		Enabled:      hook.Enabled,
+		FailureCount: hook.FailureCount,
+		DisabledAt:   hook.DisabledAt,
+		CreatedAt:    hook.CreatedAt,
+		UpdatedAt:    hook.UpdatedAt,
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	authRequest "enuma-elish/internal/auth/service/data/request"
+	authResponse "enuma-elish/internal/auth/service/data/response"
+	"enuma-elish/internal/school/service/data/request"
+	"enuma-elish/internal/school/service/data/response"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	token := &authResponse.LoginResponse{}
+	httpClient := commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/login").
+		SetMethod(http.MethodPost).
+		SetJsonHeader().
+		SetRequestBody(&authRequest.LoginRequest{Email: "admin@gmail.com", Password: "12345678"}).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(token))
+
+	if httpClient.Error() != nil {
+		t.Fatalf("http response error: %v", httpClient.Error())
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	schools := response.ListSchool{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/school").
+		SetMethod(http.MethodGet).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(&schools))
+
+	if httpClient.Status() != http.StatusOK || len(schools) == 0 {
+		t.Fatalf("expected a school, got status %d", httpClient.Status())
+	}
+	keysUrl := server.URL + "/api/v1/school/" + schools[0].ID.String() + "/api-keys"
+
+	key := &response.APIKey{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(keysUrl).
+		SetMethod(http.MethodPost).
+		SetHeader(header).
+		SetJsonHeader().
+		SetRequestBody(&request.CreateAPIKeyRequest{Name: "sis sync", Scopes: []string{"student.read"}, ExpiresInDays: 1}).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(key))
+
+	if httpClient.Status() != http.StatusOK || key.Key == "" {
+		t.Fatalf("expected status 200 OK with the key, got %d", httpClient.Status())
+	}
+
+	apiKeyHeader := make(http.Header)
+	apiKeyHeader.Set("Authorization", "ApiKey "+key.Key)
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/student").
+		SetMethod(http.MethodGet).
+		SetHeader(apiKeyHeader).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusOK {
+		t.Fatalf("expected status 200 OK with the api key, got %d", httpClient.Status())
+	}
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(keysUrl).
+		SetMethod(http.MethodGet).
+		SetHeader(apiKeyHeader).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusForbidden {
+		t.Fatalf("expected status 403 Forbidden outside the key scopes, got %d", httpClient.Status())
+	}
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/me").
+		SetMethod(http.MethodGet).
+		SetHeader(apiKeyHeader).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusForbidden {
+		t.Fatalf("expected status 403 Forbidden on a route without api keys, got %d", httpClient.Status())
+	}
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(keysUrl + "/" + key.ID.String()).
+		SetMethod(http.MethodDelete).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", httpClient.Status())
+	}
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/student").
+		SetMethod(http.MethodGet).
+		SetHeader(apiKeyHeader).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 Unauthorized after revoke, got %d", httpClient.Status())
+	}
+}
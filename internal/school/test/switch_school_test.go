@@ -0,0 +1,104 @@
+package test
+
+import (
+	authRequest "enuma-elish/internal/auth/service/data/request"
+	authResponse "enuma-elish/internal/auth/service/data/response"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSwitchSchoolRequiresMFA(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	// A teacher of one school who is also the admin of another
+	userID, teacherSchool, adminSchool := uuid.New(), uuid.New(), uuid.New()
+	email := "switch-" + userID.String() + "@gmail.com"
+	password, err := bcrypt.GenerateFromPassword([]byte("12345678"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := testInfra.Postgres
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM user_school_role WHERE user_id = $1", userID)
+		db.Exec("DELETE FROM school WHERE id IN ($1, $2)", teacherSchool, adminSchool)
+		db.Exec("DELETE FROM users WHERE id = $1", userID)
+	})
+
+	now := time.Now().UnixMilli()
+	seed := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO users (id, email, name, password, is_verified, created_by) VALUES ($1, $2, 'Switch', $3, true, $1)", []any{userID, email, string(password)}},
+		{"INSERT INTO school (id, name, level, created_by) VALUES ($1, 'Teacher School', 'senior', $2)", []any{teacherSchool, userID}},
+		{"INSERT INTO school (id, name, level, created_by) VALUES ($1, 'Admin School', 'senior', $2)", []any{adminSchool, userID}},
+		{"INSERT INTO user_school_role (user_id, school_id, role_id, created_at, created_by) VALUES ($1, $2, 'teacher', $3, $1)", []any{userID, teacherSchool, now - 1}},
+		{"INSERT INTO user_school_role (user_id, school_id, role_id, created_at, created_by) VALUES ($1, $2, 'admin', $3, $1)", []any{userID, adminSchool, now}},
+	}
+	for _, s := range seed {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	login := func() *authResponse.LoginResponse {
+		res := &authResponse.LoginResponse{}
+		httpClient := commonHttp.NewHttpClient().
+			SetUrl(server.URL + "/api/v1/auth/login").
+			SetMethod(http.MethodPost).
+			SetJsonHeader().
+			SetRequestBody(&authRequest.LoginRequest{Email: email, Password: "12345678"}).
+			Do().
+			UnmarshalResponse(commonHttp.NewResponse().SetData(res))
+
+		if httpClient.Status() != http.StatusOK {
+			t.Fatalf("expected status 200 OK on login, got %d", httpClient.Status())
+		}
+		return res
+	}
+
+	// Logged in while neither school requires MFA, then the admin school
+	// turns it on
+	token := login()
+	if token.AccessToken == "" {
+		t.Fatalf("expected tokens without mfa, got %+v", token)
+	}
+
+	_, err = db.Exec("UPDATE school SET mfa_required = true WHERE id = $1", adminSchool)
+	if err != nil {
+		t.Fatalf("require mfa: %v", err)
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	switchTo := func(schoolID uuid.UUID) int {
+		return commonHttp.NewHttpClient().
+			SetUrl(server.URL + "/api/v1/school/" + schoolID.String() + "/switch").
+			SetMethod(http.MethodGet).
+			SetHeader(header).
+			Do().
+			UnmarshalResponse(commonHttp.NewResponse()).
+			Status()
+	}
+
+	if status := switchTo(adminSchool); status != http.StatusForbidden {
+		t.Fatalf("expected status 403 Forbidden switching into the admin role without mfa, got %d", status)
+	}
+	if status := switchTo(teacherSchool); status != http.StatusOK {
+		t.Fatalf("expected status 200 OK switching into the teacher role, got %d", status)
+	}
+
+	// Logging in through the teacher school must enroll as well
+	if res := login(); !res.MFAEnrollmentRequired || res.AccessToken != "" {
+		t.Fatalf("expected mfa_enrollment_required on login, got %+v", res)
+	}
+}
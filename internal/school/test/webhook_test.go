@@ -0,0 +1,146 @@
+package test
+
+import (
+	authRequest "enuma-elish/internal/auth/service/data/request"
+	authResponse "enuma-elish/internal/auth/service/data/response"
+	"enuma-elish/internal/school/service/data/request"
+	"enuma-elish/internal/school/service/data/response"
+	commonHttp "enuma-elish/pkg/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	server := httptest.NewServer(testApi)
+	defer server.Close()
+
+	token := &authResponse.LoginResponse{}
+	httpClient := commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/auth/login").
+		SetMethod(http.MethodPost).
+		SetJsonHeader().
+		SetRequestBody(&authRequest.LoginRequest{Email: "admin@gmail.com", Password: "12345678"}).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(token))
+
+	if httpClient.Error() != nil {
+		t.Fatalf("http response error: %v", httpClient.Error())
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	schools := response.ListSchool{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(server.URL + "/api/v1/school").
+		SetMethod(http.MethodGet).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(&schools))
+
+	if httpClient.Status() != http.StatusOK || len(schools) == 0 {
+		t.Fatalf("expected a school, got status %d", httpClient.Status())
+	}
+	webhooksUrl := server.URL + "/api/v1/school/" + schools[0].ID.String() + "/webhooks"
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(webhooksUrl).
+		SetMethod(http.MethodPost).
+		SetHeader(header).
+		SetJsonHeader().
+		SetRequestBody(&request.CreateWebhookRequest{URL: "https://example.com/hook", Events: []string{"exam.deleted"}}).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for an unknown event, got %d", httpClient.Status())
+	}
+
+	hook := &response.Webhook{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(webhooksUrl).
+		SetMethod(http.MethodPost).
+		SetHeader(header).
+		SetJsonHeader().
+		SetRequestBody(&request.CreateWebhookRequest{URL: "https://example.com/hook", Events: []string{"exam.graded", "ppdb.students_updated"}}).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(hook))
+
+	if httpClient.Status() != http.StatusOK || hook.Secret == "" || !hook.Enabled {
+		t.Fatalf("expected status 200 OK with the secret, got %d", httpClient.Status())
+	}
+	hookUrl := webhooksUrl + "/" + hook.ID.String()
+
+	hooks := []response.Webhook{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(webhooksUrl).
+		SetMethod(http.MethodGet).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(&hooks))
+
+	if httpClient.Status() != http.StatusOK || len(hooks) == 0 || hooks[0].Secret != "" {
+		t.Fatalf("expected the webhooks without secrets, got status %d", httpClient.Status())
+	}
+
+	disabled := false
+	updated := &response.Webhook{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(hookUrl).
+		SetMethod(http.MethodPut).
+		SetHeader(header).
+		SetJsonHeader().
+		SetRequestBody(&request.UpdateWebhookRequest{URL: "https://example.com/hook", Events: []string{"exam.graded"}, Enabled: &disabled}).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(updated))
+
+	if httpClient.Status() != http.StatusOK || updated.Enabled || updated.DisabledAt == 0 {
+		t.Fatalf("expected a disabled webhook, got status %d", httpClient.Status())
+	}
+
+	test := &response.WebhookTest{}
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(hookUrl + "/test").
+		SetMethod(http.MethodPost).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse().SetData(test))
+
+	if httpClient.Status() != http.StatusOK || test.EventID == "" {
+		t.Fatalf("expected status 200 OK with the test event id, got %d", httpClient.Status())
+	}
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(hookUrl + "/deliveries").
+		SetMethod(http.MethodGet).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", httpClient.Status())
+	}
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(hookUrl).
+		SetMethod(http.MethodDelete).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", httpClient.Status())
+	}
+
+	httpClient = commonHttp.NewHttpClient().
+		SetUrl(hookUrl).
+		SetMethod(http.MethodDelete).
+		SetHeader(header).
+		Do().
+		UnmarshalResponse(commonHttp.NewResponse())
+
+	if httpClient.Status() != http.StatusNotFound {
+		t.Fatalf("expected status 404 Not Found after delete, got %d", httpClient.Status())
+	}
+}
@@ -3,27 +3,31 @@ package repository
 import (
 	"context"
 	"enuma-elish/internal/storage/service/data/request"
+	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/tenant"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 type StorageLog struct {
-	ID               uuid.UUID `db:"id" json:"id"`
-	UserID           uuid.UUID `db:"user_id" json:"user_id"`
-	PublicID         string    `db:"public_id" json:"public_id"`
-	OriginalFilename string    `db:"original_filename" json:"original_filename"`
-	FileType         string    `db:"file_type" json:"file_type"`
-	FileSize         int64     `db:"file_size" json:"file_size"`
-	MimeType         string    `db:"mime_type" json:"mime_type"`
-	URL              string    `db:"url" json:"url"`
-	SecureURL        string    `db:"secure_url" json:"secure_url"`
-	Folder           *string   `db:"folder" json:"folder"`
-	Width            *int      `db:"width" json:"width"`
-	Height           *int      `db:"height" json:"height"`
-	Format           *string   `db:"format" json:"format"`
-	CreatedAt        int64     `db:"created_at" json:"created_at"`
-	UpdatedAt        int64     `db:"updated_at" json:"updated_at"`
+	ID               uuid.UUID     `db:"id" json:"id"`
+	UserID           uuid.UUID     `db:"user_id" json:"user_id"`
+	SchoolID         uuid.NullUUID `db:"school_id" json:"school_id"`
+	PublicID         string        `db:"public_id" json:"public_id"`
+	OriginalFilename string        `db:"original_filename" json:"original_filename"`
+	FileType         string        `db:"file_type" json:"file_type"`
+	FileSize         int64         `db:"file_size" json:"file_size"`
+	MimeType         string        `db:"mime_type" json:"mime_type"`
+	URL              string        `db:"url" json:"url"`
+	SecureURL        string        `db:"secure_url" json:"secure_url"`
+	Folder           *string       `db:"folder" json:"folder"`
+	Width            *int          `db:"width" json:"width"`
+	Height           *int          `db:"height" json:"height"`
+	Format           *string       `db:"format" json:"format"`
+	CreatedAt        int64         `db:"created_at" json:"created_at"`
+	UpdatedAt        int64         `db:"updated_at" json:"updated_at"`
 }
 
 type Repository interface {
@@ -31,6 +35,8 @@ type Repository interface {
 	GetStorageLogsByUserID(ctx context.Context, userID uuid.UUID, query request.GetStorageHistoryQuery) ([]*StorageLog, int, error)
 	GetStorageLogByPublicID(ctx context.Context, publicID string) (*StorageLog, error)
 	DeleteStorageLog(ctx context.Context, publicID string) error
+	GetDeletedStorageLogs(ctx context.Context, before int64, limit int) ([]*StorageLog, error)
+	PurgeStorageLog(ctx context.Context, id uuid.UUID) error
 	GetStorageLogsByFileType(ctx context.Context, userID uuid.UUID, fileType string, query request.GetStorageHistoryQuery) ([]*StorageLog, int, error)
 }
 
@@ -45,17 +51,19 @@ func New(db *sqlx.DB) Repository {
 }
 
 func (r *repository) CreateStorageLog(ctx context.Context, log *StorageLog) (*StorageLog, error) {
+	log.SchoolID = tenant.Filter(ctx)
+
 	query := `
 		INSERT INTO storage_log (
-			user_id, public_id, original_filename, file_type, file_size, 
+			user_id, school_id, public_id, original_filename, file_type, file_size, 
 			mime_type, url, secure_url, folder, width, height, format
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 		) RETURNING id, created_at, updated_at`
 
 	err := r.db.QueryRowContext(
 		ctx, query,
-		log.UserID, log.PublicID, log.OriginalFilename, log.FileType, log.FileSize,
+		log.UserID, log.SchoolID, log.PublicID, log.OriginalFilename, log.FileType, log.FileSize,
 		log.MimeType, log.URL, log.SecureURL, log.Folder, log.Width, log.Height, log.Format,
 	).Scan(&log.ID, &log.CreatedAt, &log.UpdatedAt)
 
@@ -68,7 +76,7 @@ func (r *repository) CreateStorageLog(ctx context.Context, log *StorageLog) (*St
 
 func (r *repository) GetStorageLogsByUserID(ctx context.Context, userID uuid.UUID, query request.GetStorageHistoryQuery) ([]*StorageLog, int, error) {
 	// Count total records
-	countQuery := `SELECT COUNT(*) FROM storage_log WHERE user_id = $1`
+	countQuery := `SELECT COUNT(*) FROM storage_log WHERE user_id = $1 AND deleted_at = 0`
 	var total int
 	err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total)
 	if err != nil {
@@ -81,7 +89,7 @@ func (r *repository) GetStorageLogsByUserID(ctx context.Context, userID uuid.UUI
 			   mime_type, url, secure_url, folder, width, height, format,
 			   created_at, updated_at
 		FROM storage_log 
-		WHERE user_id = $1 
+		WHERE user_id = $1 AND deleted_at = 0
 		ORDER BY created_at DESC 
 		LIMIT $2 OFFSET $3`
 
@@ -110,7 +118,7 @@ func (r *repository) GetStorageLogsByUserID(ctx context.Context, userID uuid.UUI
 
 func (r *repository) GetStorageLogsByFileType(ctx context.Context, userID uuid.UUID, fileType string, query request.GetStorageHistoryQuery) ([]*StorageLog, int, error) {
 	// Count total records
-	countQuery := `SELECT COUNT(*) FROM storage_log WHERE user_id = $1 AND file_type = $2`
+	countQuery := `SELECT COUNT(*) FROM storage_log WHERE user_id = $1 AND file_type = $2 AND deleted_at = 0`
 	var total int
 	err := r.db.QueryRowContext(ctx, countQuery, userID, fileType).Scan(&total)
 	if err != nil {
@@ -123,7 +131,7 @@ func (r *repository) GetStorageLogsByFileType(ctx context.Context, userID uuid.U
 			   mime_type, url, secure_url, folder, width, height, format,
 			   created_at, updated_at
 		FROM storage_log 
-		WHERE user_id = $1 AND file_type = $2
+		WHERE user_id = $1 AND file_type = $2 AND deleted_at = 0
 		ORDER BY created_at DESC 
 		LIMIT $3 OFFSET $4`
 
@@ -156,10 +164,10 @@ func (r *repository) GetStorageLogByPublicID(ctx context.Context, publicID strin
 			   mime_type, url, secure_url, folder, width, height, format,
 			   created_at, updated_at
 		FROM storage_log 
-		WHERE public_id = $1`
+		WHERE public_id = $1 AND deleted_at = 0 AND ($2::uuid IS NULL OR school_id = $2 OR user_id = $3)`
 
 	var storageLog StorageLog
-	err := r.db.QueryRowContext(ctx, query, publicID).Scan(
+	err := r.db.QueryRowContext(ctx, query, publicID, tenant.Filter(ctx), r.userID(ctx)).Scan(
 		&storageLog.ID, &storageLog.UserID, &storageLog.PublicID, &storageLog.OriginalFilename,
 		&storageLog.FileType, &storageLog.FileSize, &storageLog.MimeType, &storageLog.URL,
 		&storageLog.SecureURL, &storageLog.Folder, &storageLog.Width, &storageLog.Height,
@@ -171,14 +179,42 @@ func (r *repository) GetStorageLogByPublicID(ctx context.Context, publicID strin
 	return &storageLog, nil
 }
 
+// DeleteStorageLog only marks the file as deleted, PurgeStorageLog removes
+// it once the file is gone from Cloudinary.
 func (r *repository) DeleteStorageLog(ctx context.Context, publicID string) error {
-	query := `DELETE FROM storage_log WHERE public_id = $1`
-	_, err := r.db.ExecContext(ctx, query, publicID)
+	query := `UPDATE storage_log SET deleted_at = $4, updated_at = $4
+			  WHERE public_id = $1 AND deleted_at = 0 AND ($2::uuid IS NULL OR school_id = $2 OR user_id = $3)`
+	_, err := r.db.ExecContext(ctx, query, publicID, tenant.Filter(ctx), r.userID(ctx), time.Now().UnixMilli())
+	return err
+}
+
+// GetDeletedStorageLogs returns up to limit files deleted before the given
+// time, oldest first.
+func (r *repository) GetDeletedStorageLogs(ctx context.Context, before int64, limit int) ([]*StorageLog, error) {
+	query := `
+		SELECT id, user_id, public_id, original_filename, file_type, file_size,
+			   mime_type, url, secure_url, folder, width, height, format,
+			   created_at, updated_at
+		FROM storage_log
+		WHERE deleted_at > 0 AND deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2`
+
+	var logs []*StorageLog
+	err := r.db.SelectContext(ctx, &logs, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (r *repository) PurgeStorageLog(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM storage_log WHERE id = $1 AND deleted_at > 0`, id)
 	return err
 }
 
 func (r *repository) CountStorageLogsByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM storage_log WHERE user_id = $1`
+	query := `SELECT COUNT(*) FROM storage_log WHERE user_id = $1 AND deleted_at = 0`
 
 	var count int
 	err := r.db.QueryRowContext(ctx, query, userID).Scan(&count)
@@ -188,3 +224,13 @@ func (r *repository) CountStorageLogsByUserID(ctx context.Context, userID uuid.U
 
 	return count, nil
 }
+
+// userID lets uploaders keep access to their own files that were logged
+// before storage_log carried a school_id.
+func (r *repository) userID(ctx context.Context) uuid.UUID {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return uuid.Nil
+	}
+	return claim.User.ID
+}
@@ -9,8 +9,10 @@ import (
 	"enuma-elish/pkg/cloudinary"
 	commonError "enuma-elish/pkg/error"
 	commonHttp "enuma-elish/pkg/http"
+	"enuma-elish/pkg/jwt"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
@@ -24,8 +26,16 @@ type Service interface {
 	GetFile(ctx context.Context, publicID string) (*response.GetFileResponse, error)
 	GetStorageHistory(ctx context.Context, httpQuery request.GetStorageHistoryQuery) (*response.StorageHistoryResponse, *commonHttp.Meta, error)
 	GetStorageHistoryByType(ctx context.Context, fileType string, httpQuery request.GetStorageHistoryQuery) (*response.StorageHistoryResponse, *commonHttp.Meta, error)
+	PurgeDeletedFiles(ctx context.Context) error
 }
 
+const (
+	// purgeAfter is how long deleted files can still be restored from
+	// Cloudinary before they are purged.
+	purgeAfter = 7 * 24 * time.Hour
+	purgeBatch = 100
+)
+
 type service struct {
 	cloudinaryService *cloudinary.Service
 	repository        repository.Repository
@@ -225,10 +235,15 @@ func (s *service) StoreDocument(ctx context.Context, data request.StoreDocumentR
 }
 
 func (s *service) DeleteFile(ctx context.Context, data request.DeleteFileRequest) (*response.DeleteResponse, error) {
-	// Delete from Cloudinary
-	err := s.cloudinaryService.DeleteFile(ctx, data.PublicID)
+	// Only files visible to the caller's school may be deleted
+	if _, err := s.repository.GetStorageLogByPublicID(ctx, data.PublicID); err != nil {
+		return nil, commonError.ErrNotFound
+	}
+
+	// The file stays on Cloudinary until PurgeDeletedFiles removes it
+	err := s.repository.DeleteStorageLog(ctx, data.PublicID)
 	if err != nil {
-		log.Err(err).Msg("Failed to delete file from cloudinary")
+		log.Err(err).Msg("Failed to delete storage log")
 		return &response.DeleteResponse{
 			Success:  false,
 			PublicID: data.PublicID,
@@ -236,12 +251,6 @@ func (s *service) DeleteFile(ctx context.Context, data request.DeleteFileRequest
 		}, commonError.ErrInternal
 	}
 
-	// Delete from storage log
-	err = s.repository.DeleteStorageLog(ctx, data.PublicID)
-	if err != nil {
-		log.Err(err).Msg("Failed to delete storage log")
-	}
-
 	return &response.DeleteResponse{
 		Success:  true,
 		PublicID: data.PublicID,
@@ -249,6 +258,42 @@ func (s *service) DeleteFile(ctx context.Context, data request.DeleteFileRequest
 	}, nil
 }
 
+// PurgeDeletedFiles is the storage.purge scheduled job. It removes files
+// deleted more than purgeAfter ago from Cloudinary, then their storage log.
+func (s *service) PurgeDeletedFiles(ctx context.Context) error {
+	before := time.Now().Add(-purgeAfter).UnixMilli()
+	purged := 0
+
+	for {
+		logs, err := s.repository.GetDeletedStorageLogs(ctx, before, purgeBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range logs {
+			err = s.cloudinaryService.DeleteFile(ctx, l.PublicID)
+			if err != nil {
+				return err
+			}
+
+			err = s.repository.PurgeStorageLog(ctx, l.ID)
+			if err != nil {
+				return err
+			}
+			purged++
+		}
+
+		if len(logs) < purgeBatch {
+			break
+		}
+	}
+
+	if purged > 0 {
+		log.Info().Int("purged", purged).Msg("purged deleted files")
+	}
+	return nil
+}
+
 func (s *service) GetFile(ctx context.Context, publicID string) (*response.GetFileResponse, error) {
 	// Check if file exists in our database
 	storageLog, err := s.repository.GetStorageLogByPublicID(ctx, publicID)
@@ -362,17 +407,12 @@ func (s *service) GetStorageHistoryByType(ctx context.Context, fileType string,
 }
 
 func (s *service) getUserIDFromContext(ctx context.Context) (uuid.UUID, error) {
-	userIDFromCtx := ctx.Value("user_id")
-	if userIDFromCtx == nil {
-		return uuid.Nil, fmt.Errorf("user ID not found in context")
-	}
-
-	userID, ok := userIDFromCtx.(uuid.UUID)
-	if !ok {
-		return uuid.Nil, fmt.Errorf("invalid user ID format")
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("user ID not found in context: %w", err)
 	}
 
-	return userID, nil
+	return claim.User.ID, nil
 }
 
 // Helper functions for file type validation
@@ -33,7 +33,9 @@ func (s *Storage) Init() {
 	svc := service.New(s.i.Cloudinary, r, s.c)
 	h := handler.New(svc, s.v)
 
-	storage := s.Group("/api/v1/storage").Use(middleware.Auth(s.c.JWT.Secret))
+	s.i.Scheduler.Register("storage.purge", "30 3 * * *", svc.PurgeDeletedFiles)
+
+	storage := s.Group("/api/v1/storage").Use(middleware.Auth(s.i.Keys, s.i.Session, s.i.APIKeys))
 
 	// Storage endpoints
 	storage.POST("/image", h.StoreImage)
@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"enuma-elish/internal/student/service/data/request"
+	"enuma-elish/pkg/tenant"
 	"fmt"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
@@ -17,6 +19,7 @@ type Repository interface {
 	CreateStudent(ctx context.Context, schoolID uuid.UUID, u []User) error
 	CreateStudentVerifyEmailToken(ctx context.Context, email string) (string, error)
 	VerifyEmailToken(ctx context.Context, email string) (string, error)
+	DeleteExpiredInvites(ctx context.Context, before int64) (int, error)
 	GetStudentByEmail(ctx context.Context, email string) (*User, error)
 	GetStudentByID(ctx context.Context, studentID uuid.UUID) (*User, error)
 	Redis() *redis.Client
@@ -64,7 +67,15 @@ type UserSchoolRole struct {
 
 const StudentVerifyEmailTokenKey = "student:verify:email"
 
+// InviteTTL is how long an invite link stays valid.
+const InviteTTL = 24 * time.Hour
+
 func (r *repository) CreateStudent(ctx context.Context, schoolID uuid.UUID, u []User) error {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -135,13 +146,78 @@ func (r *repository) CreateStudent(ctx context.Context, schoolID uuid.UUID, u []
 
 func (r *repository) CreateStudentVerifyEmailToken(ctx context.Context, email string) (string, error) {
 	token := uuid.New().String()
-	err := r.rdb.Set(ctx, StudentVerifyEmailTokenKey+":"+email, token, time.Hour*24).Err()
+	err := r.rdb.Set(ctx, StudentVerifyEmailTokenKey+":"+email, token, InviteTTL).Err()
 	if err != nil {
 		return "", err
 	}
 	return token, nil
 }
 
+// DeleteExpiredInvites removes students invited before the given time who
+// never set up their account and whose invite token expired, together with
+// their school role and class memberships. Users that belong to a school in
+// another role or signed in with an identity provider are kept.
+func (r *repository) DeleteExpiredInvites(ctx context.Context, before int64) (int, error) {
+	var invited []User
+	query := `SELECT u.id, u.email FROM users u
+			  WHERE u.password = '' AND u.is_verified = false AND u.is_deleted = false AND u.created_at < $1
+			  AND EXISTS (SELECT 1 FROM user_school_role usr WHERE usr.user_id = u.id AND usr.role_id = 'student')
+			  AND NOT EXISTS (SELECT 1 FROM user_school_role usr WHERE usr.user_id = u.id AND usr.role_id <> 'student')
+			  AND NOT EXISTS (SELECT 1 FROM user_identity ui WHERE ui.user_id = u.id)`
+	err := r.db.SelectContext(ctx, &invited, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	// Inviting a student again keeps the old user but issues a new token
+	var expired []uuid.UUID
+	for _, u := range invited {
+		n, err := r.rdb.Exists(ctx, StudentVerifyEmailTokenKey+":"+u.Email).Result()
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			expired = append(expired, u.ID)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	for _, query := range []string{
+		"DELETE FROM class_student WHERE student_id = ANY($1)",
+		"DELETE FROM user_school_role WHERE user_id = ANY($1)",
+		"DELETE FROM users WHERE id = ANY($1)",
+	} {
+		_, err = tx.ExecContext(ctx, query, pq.Array(expired))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	committed = true
+
+	return len(expired), nil
+}
+
 func (r *repository) VerifyEmailToken(ctx context.Context, email string) (string, error) {
 	key := StudentVerifyEmailTokenKey + ":" + email
 	token := ""
@@ -164,7 +240,11 @@ func (r *repository) GetStudentByEmail(ctx context.Context, email string) (*User
 
 func (r *repository) GetStudentByID(ctx context.Context, studentID uuid.UUID) (*User, error) {
 	student := &User{}
-	err := r.db.GetContext(ctx, student, "SELECT id, name, password, email, is_verified, created_at, created_by, updated_at FROM users WHERE id = $1", studentID)
+	query := `SELECT id, name, password, email, is_verified, created_at, created_by, updated_at FROM users
+			  WHERE id = $1 AND ($2::uuid IS NULL OR EXISTS (
+				  SELECT 1 FROM user_school_role usr WHERE usr.user_id = users.id AND usr.school_id = $2
+			  ))`
+	err := r.db.GetContext(ctx, student, query, studentID, tenant.Filter(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -185,6 +265,11 @@ func (r *repository) UpdateStudent(ctx context.Context, student User) error {
 }
 
 func (r *repository) DeleteStudent(ctx context.Context, studentID uuid.UUID, schoolID uuid.UUID) error {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -230,11 +315,16 @@ func (r *repository) DeleteStudent(ctx context.Context, studentID uuid.UUID, sch
 }
 
 func (r *repository) GetListStudent(ctx context.Context, httpQuery request.GetListStudentQuery) ([]User, int, error) {
+	schoolID, err := tenant.ScopeString(ctx, httpQuery.SchoolID)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var students []User
 	selectStudent := "SELECT users.id, name, email, is_verified, user_school_role.created_at, user_school_role.updated_at FROM users JOIN user_school_role on users.id = user_school_role.user_id WHERE true"
 	countQuery := "SELECT COUNT(*) FROM users JOIN user_school_role on users.id = user_school_role.user_id WHERE true"
 
-	filterParams := []interface{}{httpQuery.SchoolID, "student"}
+	filterParams := []interface{}{schoolID, "student"}
 	filterQuery := " AND user_school_role.school_id = ? AND user_school_role.role_id = ?"
 
 	if httpQuery.Search != "" && len(httpQuery.SearchBy) > 0 {
@@ -264,7 +354,7 @@ func (r *repository) GetListStudent(ctx context.Context, httpQuery request.GetLi
 	limitOrderParams := []interface{}{httpQuery.PageSize, httpQuery.GetOffset()}
 
 	selectParams := append(filterParams, limitOrderParams...)
-	err := r.db.SelectContext(ctx, &students, r.db.Rebind(selectStudent+filterQuery+limitOrderQuery), selectParams...)
+	err = r.db.SelectContext(ctx, &students, r.db.Rebind(selectStudent+filterQuery+limitOrderQuery), selectParams...)
 	if err != nil {
 		return nil, 0, err
 	}
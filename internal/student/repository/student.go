@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"enuma-elish/pkg/tenant"
 	"fmt"
 	"time"
 
@@ -27,10 +28,14 @@ func (r *repository) UpdateStudentClass(ctx context.Context, studentID, oldClass
 	// Update the class_student record
 	updateQuery := `UPDATE class_student 
 					SET class_id = $1, updated_at = $2 
-					WHERE student_id = $3 AND class_id = $4`
+					WHERE student_id = $3 AND class_id = $4
+					AND ($5::uuid IS NULL OR (
+						EXISTS (SELECT 1 FROM class WHERE id = $1 AND school_id = $5) AND
+						EXISTS (SELECT 1 FROM class WHERE id = $4 AND school_id = $5)
+					))`
 
 	now := time.Now().UnixMilli()
-	result, err := tx.ExecContext(ctx, updateQuery, newClassID, now, studentID, oldClassID)
+	result, err := tx.ExecContext(ctx, updateQuery, newClassID, now, studentID, oldClassID, tenant.Filter(ctx))
 	if err != nil {
 		return err
 	}
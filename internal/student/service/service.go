@@ -9,8 +9,11 @@ import (
 	commonError "enuma-elish/pkg/error"
 	commonHttp "enuma-elish/pkg/http"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/mailer"
+	"enuma-elish/pkg/ratelimit"
 	"fmt"
-	"net/smtp"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,6 +23,7 @@ import (
 
 type Service interface {
 	InviteStudent(ctx context.Context, data request.InviteStudentRequest) error
+	ExpireInvites(ctx context.Context) error
 	VerifyStudentEmail(ctx context.Context, data request.VerifyStudentEmailRequest) error
 	UpdateStudentAfterVerifyEmail(ctx context.Context, data request.UpdateStudentAfterVerifyEmailRequest) error
 	GetListStudent(ctx context.Context, httpQuery request.GetListStudentQuery) (response.GetListStudentResponse, *commonHttp.Meta, error)
@@ -31,10 +35,12 @@ type Service interface {
 type service struct {
 	repository repository.Repository
 	config     *config.Config
+	invite     *ratelimit.Guard
+	mail       *mailer.Sender
 }
 
-func New(repository repository.Repository, config *config.Config) Service {
-	return &service{repository, config}
+func New(repository repository.Repository, config *config.Config, limiter *ratelimit.Limiter, mail *mailer.Sender) Service {
+	return &service{repository, config, limiter.Guard("student_invite"), mail}
 }
 
 func (s *service) InviteStudent(ctx context.Context, data request.InviteStudentRequest) error {
@@ -66,49 +72,33 @@ func (s *service) InviteStudent(ctx context.Context, data request.InviteStudentR
 		return err
 	}
 
-	go func([]repository.User) {
-		for _, v := range students {
-			token, err := s.repository.CreateStudentVerifyEmailToken(context.Background(), v.Email)
-			if err != nil {
-				log.Err(err).Str("email", v.Email).Msg("create student verify token")
-			}
-
-			link := fmt.Sprintf("%s/email-verification?email=%s&token=%s&type=invite_student", s.config.Http.FrontendHost, v.Email, token)
-			err = s.sendEmail(v.Email, link, "Email Verification")
-			if err != nil {
-				log.Err(err).Str("email", v.Email).Msg("send email")
-			}
+	for _, v := range students {
+		token, err := s.repository.CreateStudentVerifyEmailToken(ctx, v.Email)
+		if err != nil {
+			log.Err(err).Str("email", v.Email).Msg("create student verify token")
+			return err
 		}
-	}(students)
-
-	return nil
-}
 
-func (s *service) sendEmail(to string, msg, subject string) error {
-	auth := smtp.PlainAuth("", s.config.SMTP.Username, s.config.SMTP.Password, s.config.SMTP.Host)
-	message := []byte(fmt.Sprintf("Subject: %s\r\n\r\n%s", subject, msg))
-
-	addr := fmt.Sprintf("%s:%d", s.config.SMTP.Host, s.config.SMTP.Port)
-	err := smtp.SendMail(addr, auth, s.config.SMTP.Username, []string{to}, message)
-	if err != nil {
-		log.Err(err).Msg("failed to send email")
-		return err
+		link := fmt.Sprintf("%s/email-verification?email=%s&token=%s&type=invite_student", s.config.Http.FrontendHost, url.QueryEscape(v.Email), token)
+		err = s.mail.Enqueue(ctx, mailer.Email{
+			To:       v.Email,
+			Template: mailer.TemplateStudentInvite,
+			SchoolID: data.SchoolID,
+			Data:     mailer.Data{URL: link},
+		})
+		if err != nil {
+			log.Err(err).Str("email", v.Email).Msg("enqueue student invite")
+			return err
+		}
 	}
 
 	return nil
 }
 
 func (s *service) UpdateStudentAfterVerifyEmail(ctx context.Context, data request.UpdateStudentAfterVerifyEmailRequest) error {
-
-	token, err := s.repository.VerifyEmailToken(ctx, data.Email)
+	err := s.verifyInviteToken(ctx, data.Email, data.Token)
 	if err != nil {
-		log.Err(err).Msg("Failed to verify email token")
-		return commonError.ErrInvalidToken
-	}
-
-	if token != data.Token {
-		log.Err(commonError.ErrInvalidToken).Msg("Failed to verify email token")
-		return commonError.ErrInvalidToken
+		return err
 	}
 
 	student, err := s.repository.GetStudentByEmail(ctx, data.Email)
@@ -148,17 +138,49 @@ func hashPassword(password string) (string, error) {
 }
 
 func (s *service) VerifyStudentEmail(ctx context.Context, data request.VerifyStudentEmailRequest) error {
-	token, err := s.repository.VerifyEmailToken(ctx, data.Email)
+	return s.verifyInviteToken(ctx, data.Email, data.Token)
+}
+
+// verifyInviteToken compares the invite token and locks the email out after
+// too many wrong guesses.
+func (s *service) verifyInviteToken(ctx context.Context, email, token string) error {
+	id := strings.ToLower(email)
+	if err := s.invite.Check(ctx, id); err != nil {
+		return err
+	}
+
+	expected, err := s.repository.VerifyEmailToken(ctx, email)
 	if err != nil {
 		log.Err(err).Msg("Failed to verify student email token")
 		return commonError.ErrInvalidToken
 	}
 
-	if token != data.Token {
+	if expected != token {
 		log.Err(commonError.ErrInvalidToken).Msg("Failed to verify student email token")
+		if _, err := s.invite.Fail(ctx, id); err != nil {
+			log.Err(err).Msg("Failed to record invite token failure")
+		}
 		return commonError.ErrInvalidToken
 	}
 
+	if err := s.invite.Reset(ctx, id); err != nil {
+		log.Err(err).Msg("Failed to reset invite token failures")
+	}
+
+	return nil
+}
+
+// ExpireInvites is the student.expire_invites scheduled job.
+func (s *service) ExpireInvites(ctx context.Context) error {
+	before := time.Now().Add(-repository.InviteTTL).UnixMilli()
+	deleted, err := s.repository.DeleteExpiredInvites(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		log.Info().Int("deleted", deleted).Msg("deleted expired student invites")
+	}
 	return nil
 }
 
@@ -30,19 +30,23 @@ func New(c *config.Config, i *infra.Infra, r *gin.Engine, v *validator.Validate)
 
 func (s *Student) Init() {
 	r := repository.New(s.i.Postgres, s.i.Redis)
-	svc := service.New(r, s.c)
+	svc := service.New(r, s.c, s.i.RateLimit, s.i.Mail)
 	h := handler.New(svc, s.v)
 
-	authMiddleware := middleware.Auth(s.c.JWT.Secret)
+	s.i.Scheduler.Register("student.expire_invites", "20 * * * *", svc.ExpireInvites)
 
-	v1 := s.Group("/api/v1/student").Use(authMiddleware)
+	authMiddleware := middleware.Auth(s.i.Keys, s.i.Session, s.i.APIKeys)
 
-	v1.GET("", h.ListStudent)
-	v1.GET("/:student_id", h.GetDetailStudent)
-	v1.DELETE("/:student_id", h.DeleteStudent)
+	v1 := s.Group("/api/v1/student").Use(middleware.AllowAPIKey(), authMiddleware)
 
-	v1.POST("/invite", h.InviteStudent)
-	v1.POST("/invite/verify", h.VerifyStudentEmail)
-	v1.POST("/invite/complete", h.UpdateStudentAfterInvite)
-	v1.PUT("/class", h.UpdateStudentClass)
+	v1.GET("", middleware.RequirePermission(middleware.PermStudentRead), h.ListStudent)
+	v1.GET("/:student_id", middleware.RequirePermission(middleware.PermStudentRead), h.GetDetailStudent)
+	v1.DELETE("/:student_id", middleware.RequirePermission(middleware.PermStudentManage), h.DeleteStudent)
+
+	v1.POST("/invite", middleware.RequirePermission(middleware.PermStudentManage), h.InviteStudent)
+	v1.PUT("/class", middleware.RequirePermission(middleware.PermStudentManage), h.UpdateStudentClass)
+
+	invite := s.Group("/api/v1/student/invite").Use(authMiddleware)
+	invite.POST("/verify", middleware.RateLimit(s.i.RateLimit, "student_invite"), h.VerifyStudentEmail)
+	invite.POST("/complete", middleware.RateLimit(s.i.RateLimit, "student_invite"), h.UpdateStudentAfterInvite)
 }
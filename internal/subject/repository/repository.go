@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"enuma-elish/internal/subject/service/data/request"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/tenant"
 	"fmt"
 	"time"
 
@@ -71,15 +72,22 @@ func New(db *sqlx.DB) Repository {
 }
 
 func (r *repository) CreateSubject(ctx context.Context, subject Subject) error {
+	schoolID, err := tenant.Scope(ctx, subject.SchoolID)
+	if err != nil {
+		return err
+	}
+	subject.SchoolID = schoolID
+
 	query := `INSERT INTO subject (id, school_id, name, created_at, created_by, updated_at) 
 			  VALUES (:id, :school_id, :name, :created_at, :created_by, :updated_at)`
-	_, err := r.db.NamedExecContext(ctx, query, subject)
+	_, err = r.db.NamedExecContext(ctx, query, subject)
 	return err
 }
 
 func (r *repository) GetSubjectByID(ctx context.Context, subjectID uuid.UUID) (*Subject, error) {
 	subject := &Subject{}
-	err := r.db.GetContext(ctx, subject, "SELECT * FROM subject WHERE id = $1", subjectID)
+	query := "SELECT * FROM subject WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2)"
+	err := r.db.GetContext(ctx, subject, query, subjectID, tenant.Filter(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -87,11 +95,16 @@ func (r *repository) GetSubjectByID(ctx context.Context, subjectID uuid.UUID) (*
 }
 
 func (r *repository) GetListSubjects(ctx context.Context, httpQuery request.GetListSubjectQuery) ([]Subject, int, error) {
+	schoolID, err := tenant.ScopeString(ctx, httpQuery.SchoolID)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var subjects []Subject
 	selectQuery := "SELECT * FROM subject WHERE school_id = $1"
 	countQuery := "SELECT COUNT(*) FROM subject WHERE school_id = $1"
 
-	filterParams := []interface{}{httpQuery.SchoolID}
+	filterParams := []interface{}{schoolID}
 	filterQuery := ""
 
 	if httpQuery.Search != "" {
@@ -116,7 +129,7 @@ func (r *repository) GetListSubjects(ctx context.Context, httpQuery request.GetL
 	limitParams := []interface{}{httpQuery.PageSize, httpQuery.GetOffset()}
 	selectParams := append(filterParams, limitParams...)
 
-	err := r.db.SelectContext(ctx, &subjects, selectQuery+filterQuery+orderQuery, selectParams...)
+	err = r.db.SelectContext(ctx, &subjects, selectQuery+filterQuery+orderQuery, selectParams...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -131,17 +144,28 @@ func (r *repository) GetListSubjects(ctx context.Context, httpQuery request.GetL
 }
 
 func (r *repository) UpdateSubject(ctx context.Context, subject Subject) error {
+	if err := r.checkSubjectTenant(ctx, subject.ID); err != nil {
+		return err
+	}
+
 	query := `UPDATE subject SET name = :name, updated_at = :updated_at WHERE id = :id`
 	_, err := r.db.NamedExecContext(ctx, query, subject)
 	return err
 }
 
 func (r *repository) DeleteSubject(ctx context.Context, subjectID uuid.UUID) error {
+	if err := r.checkSubjectTenant(ctx, subjectID); err != nil {
+		return err
+	}
+
 	_, err := r.db.ExecContext(ctx, "DELETE FROM subject WHERE id = $1", subjectID)
 	return err
 }
 
 func (r *repository) AssignTeachersToSubject(ctx context.Context, subjectID uuid.UUID, teacherIDs []uuid.UUID) error {
+	if err := r.checkSubjectTenant(ctx, subjectID); err != nil {
+		return err
+	}
 
 	jwtClaims, err := jwt.ExtractContext(ctx)
 	if err != nil {
@@ -197,6 +221,10 @@ func (r *repository) AssignTeachersToSubject(ctx context.Context, subjectID uuid
 }
 
 func (r *repository) GetTeachersBySubjectID(ctx context.Context, subjectID uuid.UUID, query request.GetTeachersBySubjectQuery) ([]Teacher, int, error) {
+	if err := r.checkSubjectTenant(ctx, subjectID); err != nil {
+		return nil, 0, err
+	}
+
 	baseQuery := `
 		SELECT u.id, u.name, u.email, u.is_verified, u.created_at, u.updated_at
 		FROM users u
@@ -227,3 +255,11 @@ func (r *repository) GetTeachersBySubjectID(ctx context.Context, subjectID uuid.
 
 	return teachers, total, nil
 }
+
+// checkSubjectTenant returns sql.ErrNoRows when the subject does not exist
+// within the caller's school.
+func (r *repository) checkSubjectTenant(ctx context.Context, subjectID uuid.UUID) error {
+	var id uuid.UUID
+	query := `SELECT id FROM subject WHERE id = $1 AND ($2::uuid IS NULL OR school_id = $2)`
+	return r.db.GetContext(ctx, &id, query, subjectID, tenant.Filter(ctx))
+}
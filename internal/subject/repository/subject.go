@@ -10,6 +10,10 @@ import (
 )
 
 func (r *repository) UpdateSubjectClass(ctx context.Context, subjectID, oldClassID, newClassID uuid.UUID) error {
+	if err := r.checkSubjectTenant(ctx, subjectID); err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
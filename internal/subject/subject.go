@@ -33,15 +33,15 @@ func (s *Subject) Init() {
 	svc := service.New(r, s.c)
 	h := handler.New(svc, s.v)
 
-	authMiddleware := middleware.Auth(s.c.JWT.Secret)
+	authMiddleware := middleware.Auth(s.i.Keys, s.i.Session, s.i.APIKeys)
 
-	v1 := s.Group("/api/v1/subject").Use(authMiddleware)
-	v1.POST("", h.CreateSubject)
-	v1.GET("", h.ListSubject)
-	v1.GET("/:subject_id", h.GetDetailSubject)
-	v1.PUT("/:subject_id", h.UpdateSubject)
-	v1.DELETE("/:subject_id", h.DeleteSubject)
-	v1.POST("/assign-teachers", h.AssignTeachersToSubject)
-	v1.GET("/:subject_id/teachers", h.GetTeachersBySubject)
-	v1.PUT("/class", h.UpdateSubjectClass)
+	v1 := s.Group("/api/v1/subject").Use(middleware.AllowAPIKey(), authMiddleware)
+	v1.POST("", middleware.RequirePermission(middleware.PermSubjectManage), h.CreateSubject)
+	v1.GET("", middleware.RequirePermission(middleware.PermSubjectRead), h.ListSubject)
+	v1.GET("/:subject_id", middleware.RequirePermission(middleware.PermSubjectRead), h.GetDetailSubject)
+	v1.PUT("/:subject_id", middleware.RequirePermission(middleware.PermSubjectManage), h.UpdateSubject)
+	v1.DELETE("/:subject_id", middleware.RequirePermission(middleware.PermSubjectManage), h.DeleteSubject)
+	v1.POST("/assign-teachers", middleware.RequirePermission(middleware.PermSubjectManage), h.AssignTeachersToSubject)
+	v1.GET("/:subject_id/teachers", middleware.RequirePermission(middleware.PermSubjectRead), h.GetTeachersBySubject)
+	v1.PUT("/class", middleware.RequirePermission(middleware.PermSubjectManage), h.UpdateSubjectClass)
 }
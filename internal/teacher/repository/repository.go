@@ -13,9 +13,11 @@ import (
 type Repository interface {
 	CreateTeachers(ctx context.Context, teachers []User, schoolID uuid.UUID) error
 	UpdateTeacher(ctx context.Context, teacher User) error
+	AcceptTeacherInvite(ctx context.Context, teacher User) error
 	GetListTeachers(ctx context.Context, httpQuery request.GetListTeacherQuery) ([]User, int, error)
 	CreateTeacherVerifyToken(ctx context.Context, email string) (string, error)
 	VerifyEmailToken(ctx context.Context, email string) (string, error)
+	DeleteExpiredInvites(ctx context.Context, before int64) (int, error)
 	GetTeacherByEmail(ctx context.Context, email string) (*User, error)
 	Redis() *redis.Client
 	Tx(ctx context.Context, options *sql.TxOptions) (*sqlx.Tx, error)
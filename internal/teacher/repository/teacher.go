@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"enuma-elish/internal/teacher/service/data/request"
+	"enuma-elish/pkg/event"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/tenant"
 	"fmt"
 	"time"
 
@@ -19,6 +21,9 @@ import (
 
 const TeacherVerifyEmailTokenKey = "teacher:verify:email"
 
+// InviteTTL is how long an invite link stays valid.
+const InviteTTL = 24 * time.Hour
+
 type User struct {
 	ID         uuid.UUID      `db:"id"`
 	Name       string         `db:"name"`
@@ -88,6 +93,10 @@ type TeacherClass struct {
 }
 
 func (r *repository) CreateTeachers(ctx context.Context, teachers []User, schoolID uuid.UUID) error {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return err
+	}
 
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
@@ -149,6 +158,11 @@ func (r *repository) CreateTeachers(ctx context.Context, teachers []User, school
 }
 
 func (r *repository) CreateTeachersWithAssignments(ctx context.Context, teachers []User, schoolID uuid.UUID, teacherSubjects []TeacherSubject, teacherClasses []TeacherClass) error {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -259,14 +273,70 @@ func (r *repository) UpdateTeacher(ctx context.Context, teacher User) error {
 	return nil
 }
 
+// AcceptTeacherInvite stores the account the teacher set up and writes a
+// TeacherInviteAccepted event for each school the teacher belongs to.
+func (r *repository) AcceptTeacherInvite(ctx context.Context, teacher User) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	updateTeacher := "UPDATE users SET name = :name, email = :email, password = :password, is_verified = :is_verified, updated_at = :updated_at WHERE id = :id"
+	_, err = tx.NamedExecContext(ctx, updateTeacher, teacher)
+	if err != nil {
+		return err
+	}
+
+	var schoolIDs []uuid.UUID
+	err = tx.SelectContext(ctx, &schoolIDs, "SELECT school_id FROM user_school_role WHERE user_id = $1 AND role_id = 'teacher' AND is_deleted = false", teacher.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, schoolID := range schoolIDs {
+		err = event.Write(ctx, tx, schoolID, event.TeacherInviteAccepted{
+			TeacherID:  teacher.ID,
+			SchoolID:   schoolID,
+			Email:      teacher.Email,
+			Name:       teacher.Name,
+			AcceptedAt: teacher.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
 func (r *repository) GetListTeachers(ctx context.Context, httpQuery request.GetListTeacherQuery) ([]User, int, error) {
+	schoolID, err := tenant.ScopeString(ctx, httpQuery.SchoolID)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var teachers []User
 	selectTeacher := "SELECT users.id, name, email, is_verified, user_school_role.created_at, user_school_role.updated_at FROM users JOIN user_school_role on users.id = user_school_role.user_id WHERE true"
 	countQuery := "SELECT COUNT(*) FROM users JOIN user_school_role on users.id = user_school_role.user_id WHERE true "
 
 	filterParams := make([]interface{}, 0)
 	filterQuery := ""
-	filterParams = append(filterParams, httpQuery.SchoolID, "teacher")
+	filterParams = append(filterParams, schoolID, "teacher")
 	filterQuery += " AND user_school_role.school_id = ? AND user_school_role.role_id = ? "
 
 	if httpQuery.Search != "" {
@@ -319,7 +389,7 @@ func (r *repository) GetListTeachers(ctx context.Context, httpQuery request.GetL
 	limitOrderParams := []interface{}{httpQuery.PageSize, httpQuery.GetOffset()}
 
 	selectParams := append(filterParams, limitOrderParams...)
-	err := r.db.SelectContext(ctx, &teachers, r.db.Rebind(selectTeacher+filterQuery+limitOrderQuery), selectParams...)
+	err = r.db.SelectContext(ctx, &teachers, r.db.Rebind(selectTeacher+filterQuery+limitOrderQuery), selectParams...)
 	if err != nil {
 		return nil, 9, err
 	}
@@ -335,7 +405,7 @@ func (r *repository) GetListTeachers(ctx context.Context, httpQuery request.GetL
 
 func (r *repository) CreateTeacherVerifyToken(ctx context.Context, email string) (string, error) {
 	token := uuid.New().String()
-	err := r.rdb.Set(ctx, TeacherVerifyEmailTokenKey+":"+email, token, time.Hour*24).Err()
+	err := r.rdb.Set(ctx, TeacherVerifyEmailTokenKey+":"+email, token, InviteTTL).Err()
 	if err != nil {
 		return "", err
 	}
@@ -343,6 +413,72 @@ func (r *repository) CreateTeacherVerifyToken(ctx context.Context, email string)
 	return token, nil
 }
 
+// DeleteExpiredInvites removes teachers invited before the given time who
+// never set up their account and whose invite token expired, together with
+// their school role and assignments. Users that belong to a school in
+// another role or signed in with an identity provider are kept.
+func (r *repository) DeleteExpiredInvites(ctx context.Context, before int64) (int, error) {
+	var invited []User
+	query := `SELECT u.id, u.email FROM users u
+			  WHERE u.password = '' AND u.is_verified = false AND u.is_deleted = false AND u.created_at < $1
+			  AND EXISTS (SELECT 1 FROM user_school_role usr WHERE usr.user_id = u.id AND usr.role_id = 'teacher')
+			  AND NOT EXISTS (SELECT 1 FROM user_school_role usr WHERE usr.user_id = u.id AND usr.role_id <> 'teacher')
+			  AND NOT EXISTS (SELECT 1 FROM user_identity ui WHERE ui.user_id = u.id)`
+	err := r.db.SelectContext(ctx, &invited, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	// A teacher invited again keeps the old user but gets a new token
+	var expired []uuid.UUID
+	for _, u := range invited {
+		n, err := r.rdb.Exists(ctx, TeacherVerifyEmailTokenKey+":"+u.Email).Result()
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			expired = append(expired, u.ID)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Error().Err(err).Msg("error rolling back transaction")
+			}
+		}
+	}()
+
+	for _, query := range []string{
+		"DELETE FROM teacher_subject WHERE teacher_id = ANY($1)",
+		"DELETE FROM class_teacher WHERE teacher_id = ANY($1)",
+		"DELETE FROM user_school_role WHERE user_id = ANY($1)",
+		"DELETE FROM users WHERE id = ANY($1)",
+	} {
+		_, err = tx.ExecContext(ctx, query, pq.Array(expired))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	committed = true
+
+	return len(expired), nil
+}
+
 func (r *repository) VerifyEmailToken(ctx context.Context, email string) (string, error) {
 	key := TeacherVerifyEmailTokenKey + ":" + email
 	token := ""
@@ -373,6 +509,11 @@ func (r *repository) Tx(ctx context.Context, options *sql.TxOptions) (*sqlx.Tx,
 }
 
 func (r *repository) DeleteTeacher(ctx context.Context, teacherID uuid.UUID, schoolID uuid.UUID) error {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -413,7 +554,11 @@ func (r *repository) DeleteTeacher(ctx context.Context, teacherID uuid.UUID, sch
 
 func (r *repository) GetTeacherByID(ctx context.Context, teacherID uuid.UUID) (*User, error) {
 	teacher := &User{}
-	err := r.db.GetContext(ctx, teacher, "SELECT  id, name, email, password, created_at, created_by, updated_at, deleted_at, deleted_by FROM users WHERE id = $1", teacherID)
+	query := `SELECT id, name, email, password, created_at, created_by, updated_at, deleted_at, deleted_by FROM users
+			  WHERE id = $1 AND ($2::uuid IS NULL OR EXISTS (
+				  SELECT 1 FROM user_school_role usr WHERE usr.user_id = users.id AND usr.school_id = $2
+			  ))`
+	err := r.db.GetContext(ctx, teacher, query, teacherID, tenant.Filter(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -437,10 +582,14 @@ func (r *repository) UpdateTeacherClass(ctx context.Context, teacherID, oldClass
 
 	updateQuery := `UPDATE class_teacher 
 					SET class_id = $1, updated_at = $2 
-					WHERE teacher_id = $3 AND class_id = $4`
+					WHERE teacher_id = $3 AND class_id = $4
+					AND ($5::uuid IS NULL OR (
+						EXISTS (SELECT 1 FROM class WHERE id = $1 AND school_id = $5) AND
+						EXISTS (SELECT 1 FROM class WHERE id = $4 AND school_id = $5)
+					))`
 
 	now := time.Now().UnixMilli()
-	result, err := tx.ExecContext(ctx, updateQuery, newClassID, now, teacherID, oldClassID)
+	result, err := tx.ExecContext(ctx, updateQuery, newClassID, now, teacherID, oldClassID, tenant.Filter(ctx))
 	if err != nil {
 		return err
 	}
@@ -505,6 +654,11 @@ func (r *repository) GetTeacherClasses(ctx context.Context, teacherID uuid.UUID)
 }
 
 func (r *repository) GetTeacherAssignments(ctx context.Context, teacherIDs []uuid.UUID, schoolID uuid.UUID) (map[uuid.UUID][]Subject, map[uuid.UUID][]Class, error) {
+	schoolID, err := tenant.Scope(ctx, schoolID)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Get teacher subjects
 	subjectQuery := `
 		SELECT ts.teacher_id, s.id, s.name, s.school_id, s.created_at, s.updated_at
@@ -518,7 +672,7 @@ func (r *repository) GetTeacherAssignments(ctx context.Context, teacherIDs []uui
 		Subject
 	}
 
-	err := r.db.SelectContext(ctx, &teacherSubjects, subjectQuery, pq.Array(teacherIDs), schoolID)
+	err = r.db.SelectContext(ctx, &teacherSubjects, subjectQuery, pq.Array(teacherIDs), schoolID)
 	if err != nil {
 		return nil, nil, err
 	}
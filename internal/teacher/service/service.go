@@ -7,6 +7,8 @@ import (
 	"enuma-elish/internal/teacher/service/data/request"
 	"enuma-elish/internal/teacher/service/data/response"
 	commonHttp "enuma-elish/pkg/http"
+	"enuma-elish/pkg/mailer"
+	"enuma-elish/pkg/ratelimit"
 
 	"github.com/google/uuid"
 )
@@ -22,16 +24,21 @@ type Service interface {
 	GetTeacherSubjects(ctx context.Context, teacherID uuid.UUID) ([]response.Subject, error)
 	GetTeacherClasses(ctx context.Context, teacherID uuid.UUID) ([]response.Class, error)
 	GetTeacherStatistics(ctx context.Context) (response.TeacherStatistics, error)
+	ExpireInvites(ctx context.Context) error
 }
 
 type service struct {
 	config     *config.Config
 	repository repository.Repository
+	invite     *ratelimit.Guard
+	mail       *mailer.Sender
 }
 
-func New(c *config.Config, r repository.Repository) Service {
+func New(c *config.Config, r repository.Repository, limiter *ratelimit.Limiter, mail *mailer.Sender) Service {
 	return &service{
 		config:     c,
 		repository: r,
+		invite:     limiter.Guard("teacher_invite"),
+		mail:       mail,
 	}
 }
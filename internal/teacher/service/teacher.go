@@ -8,8 +8,10 @@ import (
 	commonError "enuma-elish/pkg/error"
 	commonHttp "enuma-elish/pkg/http"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/mailer"
 	"fmt"
-	"net/smtp"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -92,65 +94,67 @@ func (s *service) InviteTeacher(ctx context.Context, data request.InviteTeacherR
 		return err
 	}
 
-	// Send email invitations
-	go func([]repository.User) {
-		for _, v := range teachers {
-			token, err := s.repository.CreateTeacherVerifyToken(context.Background(), v.Email)
-			if err != nil {
-				log.Err(err).Str("email", v.Email).Msg("create teacher verify token")
-			}
+	// Queue email invitations
+	for _, v := range teachers {
+		token, err := s.repository.CreateTeacherVerifyToken(ctx, v.Email)
+		if err != nil {
+			log.Err(err).Str("email", v.Email).Msg("create teacher verify token")
+			return err
+		}
 
-			link := fmt.Sprintf("%s/email-verification?email=%s&token=%s&type=invite_teacher", s.config.Http.FrontendHost, v.Email, token)
-			err = s.sendEmail(v.Email, link, "Email Verification")
-			if err != nil {
-				log.Err(err).Str("email", v.Email).Msg("send email")
-			}
+		link := fmt.Sprintf("%s/email-verification?email=%s&token=%s&type=invite_teacher", s.config.Http.FrontendHost, url.QueryEscape(v.Email), token)
+		err = s.mail.Enqueue(ctx, mailer.Email{
+			To:       v.Email,
+			Template: mailer.TemplateTeacherInvite,
+			SchoolID: data.SchoolID,
+			Data:     mailer.Data{Name: v.Name, URL: link},
+		})
+		if err != nil {
+			log.Err(err).Str("email", v.Email).Msg("enqueue teacher invite")
+			return err
 		}
-	}(teachers)
+	}
 
 	return nil
 }
 
-func (s *service) sendEmail(to string, msg, subject string) error {
-	auth := smtp.PlainAuth("", s.config.SMTP.Username, s.config.SMTP.Password, s.config.SMTP.Host)
-	message := []byte(fmt.Sprintf("Subject: %s\r\n\r\n%s", subject, msg))
+func (s *service) VerifyTeacherEmail(ctx context.Context, data request.VerifyTeacherEmailRequest) error {
+	return s.verifyInviteToken(ctx, data.Email, data.Token)
+}
 
-	addr := fmt.Sprintf("%s:%d", s.config.SMTP.Host, s.config.SMTP.Port)
-	err := smtp.SendMail(addr, auth, s.config.SMTP.Username, []string{to}, message)
-	if err != nil {
-		log.Err(err).Msg("failed to send email")
+// verifyInviteToken compares the invite token and locks the email out after
+// too many wrong guesses.
+func (s *service) verifyInviteToken(ctx context.Context, email, token string) error {
+	id := strings.ToLower(email)
+	if err := s.invite.Check(ctx, id); err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func (s *service) VerifyTeacherEmail(ctx context.Context, data request.VerifyTeacherEmailRequest) error {
-	token, err := s.repository.VerifyEmailToken(ctx, data.Email)
+	expected, err := s.repository.VerifyEmailToken(ctx, email)
 	if err != nil {
 		log.Err(err).Msg("Failed to verify email token")
 		return commonError.ErrInvalidToken
 	}
 
-	if token != data.Token {
+	if expected != token {
 		log.Err(commonError.ErrInvalidToken).Msg("Failed to verify email token")
+		if _, err := s.invite.Fail(ctx, id); err != nil {
+			log.Err(err).Msg("Failed to record invite token failure")
+		}
 		return commonError.ErrInvalidToken
 	}
 
+	if err := s.invite.Reset(ctx, id); err != nil {
+		log.Err(err).Msg("Failed to reset invite token failures")
+	}
+
 	return nil
 }
 
 func (s *service) UpdateTeacherAfterVerifyEmail(ctx context.Context, data request.UpdateTeacherAfterVerifyEmailRequest) error {
-
-	token, err := s.repository.VerifyEmailToken(ctx, data.Email)
+	err := s.verifyInviteToken(ctx, data.Email, data.Token)
 	if err != nil {
-		log.Err(err).Msg("Failed to verify email token")
-		return commonError.ErrInvalidToken
-	}
-
-	if token != data.Token {
-		log.Err(commonError.ErrInvalidToken).Msg("Failed to verify email token")
-		return commonError.ErrInvalidToken
+		return err
 	}
 
 	teacher, err := s.repository.GetTeacherByEmail(ctx, data.Email)
@@ -174,7 +178,7 @@ func (s *service) UpdateTeacherAfterVerifyEmail(ctx context.Context, data reques
 		UpdatedAt:  time.Now().UnixMilli(),
 	}
 
-	err = s.repository.UpdateTeacher(ctx, *teacher)
+	err = s.repository.AcceptTeacherInvite(ctx, *teacher)
 	if err != nil {
 		log.Err(err).Msg("Failed to update teacher")
 		return commonError.ErrInternal
@@ -359,3 +363,17 @@ func (s *service) GetTeacherClasses(ctx context.Context, teacherID uuid.UUID) ([
 	}
 	return res, nil
 }
+
+// ExpireInvites is the teacher.expire_invites scheduled job.
+func (s *service) ExpireInvites(ctx context.Context) error {
+	before := time.Now().Add(-repository.InviteTTL).UnixMilli()
+	deleted, err := s.repository.DeleteExpiredInvites(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		log.Info().Int("deleted", deleted).Msg("deleted expired teacher invites")
+	}
+	return nil
+}
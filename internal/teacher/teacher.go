@@ -30,22 +30,26 @@ func New(c *config.Config, i *infra.Infra, r *gin.Engine, v *validator.Validate)
 
 func (t *Teacher) Init() {
 	r := repository.New(t.i.Postgres, t.i.Redis)
-	s := service.New(t.c, r)
+	s := service.New(t.c, r, t.i.RateLimit, t.i.Mail)
 	h := handler.New(s, t.v)
 
-	authMiddleware := middleware.Auth(t.c.JWT.Secret)
+	t.i.Scheduler.Register("teacher.expire_invites", "15 * * * *", s.ExpireInvites)
 
-	v1 := t.Group("/api/v1/teacher").Use(authMiddleware)
-	v1.GET("", h.ListTeachers)
-	v1.GET("/:teacher_id", h.GetDetailTeacher)
-	v1.GET("/statistic", h.GetTeacherStatistics)
-	v1.DELETE("/:teacher_id", h.DeleteTeacher)
-	v1.PUT("/class", h.UpdateTeacherClass)
+	authMiddleware := middleware.Auth(t.i.Keys, t.i.Session, t.i.APIKeys)
 
-	v1.POST("/invite", h.InviteTeacher)
-	v1.POST("/invite/verify", h.VerifyTeacherEmail)
-	v1.POST("/invite/complete", h.UpdateTeacherAfterInvite)
+	v1 := t.Group("/api/v1/teacher").Use(middleware.AllowAPIKey(), authMiddleware)
+	v1.GET("", middleware.RequirePermission(middleware.PermTeacherRead), h.ListTeachers)
+	v1.GET("/:teacher_id", middleware.RequirePermission(middleware.PermTeacherRead), h.GetDetailTeacher)
+	v1.GET("/statistic", middleware.RequirePermission(middleware.PermTeacherRead), h.GetTeacherStatistics)
+	v1.DELETE("/:teacher_id", middleware.RequirePermission(middleware.PermTeacherManage), h.DeleteTeacher)
+	v1.PUT("/class", middleware.RequirePermission(middleware.PermTeacherManage), h.UpdateTeacherClass)
 
-	v1.GET("/:teacher_id/subjects", h.GetTeacherSubjects)
-	v1.GET("/:teacher_id/classes", h.GetTeacherClasses)
+	v1.POST("/invite", middleware.RequirePermission(middleware.PermTeacherManage), h.InviteTeacher)
+
+	v1.GET("/:teacher_id/subjects", middleware.RequirePermission(middleware.PermTeacherRead), h.GetTeacherSubjects)
+	v1.GET("/:teacher_id/classes", middleware.RequirePermission(middleware.PermTeacherRead), h.GetTeacherClasses)
+
+	invite := t.Group("/api/v1/teacher/invite").Use(authMiddleware)
+	invite.POST("/verify", middleware.RateLimit(t.i.RateLimit, "teacher_invite"), h.VerifyTeacherEmail)
+	invite.POST("/complete", middleware.RateLimit(t.i.RateLimit, "teacher_invite"), h.UpdateTeacherAfterInvite)
 }
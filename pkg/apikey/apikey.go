@@ -0,0 +1,189 @@
+// Package apikey manages school scoped API keys for machine to machine
+// integrations. Every key acts as its own service account, a users row that
+// cannot log in, so rows written through a key have a real creator.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Prefix starts every key so leaked keys are easy to spot, e.g. by secret
+// scanners. A full key looks like ee_1a2b3c4d_<secret>.
+const Prefix = "ee"
+
+// touchInterval limits how often last used is written back.
+const touchInterval = time.Minute
+
+var ErrInvalidAPIKey = commonError.New("api key is invalid, expired or revoked", http.StatusUnauthorized)
+
+// Key is a row of api_key. Only the SHA-256 of the secret is stored.
+type Key struct {
+	ID         uuid.UUID      `db:"id"`
+	SchoolID   uuid.UUID      `db:"school_id"`
+	UserID     uuid.UUID      `db:"user_id"`
+	Name       string         `db:"name"`
+	Prefix     string         `db:"prefix"`
+	KeyHash    string         `db:"key_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	ExpiresAt  int64          `db:"expires_at"`
+	LastUsedAt int64          `db:"last_used_at"`
+	LastUsedIP string         `db:"last_used_ip"`
+	RevokedAt  int64          `db:"revoked_at"`
+	CreatedBy  uuid.UUID      `db:"created_by"`
+	CreatedAt  int64          `db:"created_at"`
+}
+
+const keyColumns = "id, school_id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, last_used_ip, revoked_at, created_by, created_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func New(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// generate returns a new key and its lookup prefix.
+func generate() (string, string, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := hex.EncodeToString(id)
+	return Prefix + "_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// Create issues a key for the school together with its service account and
+// returns the plain key, which is not stored and cannot be shown again.
+// expiresAt is in milliseconds, 0 never expires.
+func (s *Store) Create(ctx context.Context, schoolID, createdBy uuid.UUID, name string, scopes []string, expiresAt int64) (*Key, string, error) {
+	plain, prefix, err := generate()
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	// The password is not a bcrypt hash, so the service account can never
+	// log in with a password.
+	var userID uuid.UUID
+	err = tx.GetContext(ctx, &userID, `INSERT INTO users (email, name, password, is_verified, role, created_by)
+		VALUES ($1, $2, '!', true, 'user', $3) RETURNING id`,
+		Prefix+"_"+prefix+"@api-key.invalid", name, createdBy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &Key{}
+	err = tx.GetContext(ctx, key, `INSERT INTO api_key (school_id, user_id, name, prefix, key_hash, scopes, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING `+keyColumns,
+		schoolID, userID, name, prefix, hash(plain), pq.StringArray(scopes), expiresAt, createdBy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	committed = true
+
+	return key, plain, nil
+}
+
+// List returns the keys of the school, newest first.
+func (s *Store) List(ctx context.Context, schoolID uuid.UUID) ([]Key, error) {
+	keys := []Key{}
+	err := s.db.SelectContext(ctx, &keys, "SELECT "+keyColumns+" FROM api_key WHERE school_id = $1 ORDER BY created_at DESC", schoolID)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke disables a key of the school immediately and reports false when
+// there is no such active key.
+func (s *Store) Revoke(ctx context.Context, schoolID, id uuid.UUID) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE api_key SET revoked_at = $3 WHERE id = $1 AND school_id = $2 AND revoked_at = 0", id, schoolID, time.Now().UnixMilli())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Authenticate returns the key for a plain key presented by a client, or
+// ErrInvalidAPIKey. Successful uses are recorded at most once per minute.
+func (s *Store) Authenticate(ctx context.Context, plain, ip string) (*Key, error) {
+	parts := strings.SplitN(plain, "_", 3)
+	if len(parts) != 3 || parts[0] != Prefix {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key := &Key{}
+	err := s.db.GetContext(ctx, key, "SELECT "+keyColumns+" FROM api_key WHERE prefix = $1", parts[1])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash(plain)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.RevokedAt != 0 || (key.ExpiresAt != 0 && now.UnixMilli() >= key.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if now.Sub(time.UnixMilli(key.LastUsedAt)) >= touchInterval {
+		_, err = s.db.ExecContext(ctx, "UPDATE api_key SET last_used_at = $2, last_used_ip = $3 WHERE id = $1", key.ID, now.UnixMilli(), ip)
+		if err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = now.UnixMilli()
+		key.LastUsedIP = ip
+	}
+
+	return key, nil
+}
@@ -0,0 +1,178 @@
+package apikey
+
+import (
+	"context"
+	"enuma-elish/config"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+func TestGenerate(t *testing.T) {
+	plain, prefix, err := generate()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	parts := strings.SplitN(plain, "_", 3)
+	if len(parts) != 3 || parts[0] != Prefix || parts[1] != prefix {
+		t.Fatalf("key %q does not start with %s_%s_", plain, Prefix, prefix)
+	}
+	if len(prefix) != 8 || len(parts[2]) != 43 {
+		t.Fatalf("got prefix %q and secret of %d chars, want 8 and 43", prefix, len(parts[2]))
+	}
+
+	other, otherPrefix, err := generate()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if other == plain || otherPrefix == prefix {
+		t.Fatalf("generate returned the same key twice")
+	}
+}
+
+func TestHash(t *testing.T) {
+	plain, _, err := generate()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if len(hash(plain)) != 64 {
+		t.Fatalf("hash is %q, want 64 hex chars", hash(plain))
+	}
+	if hash(plain) != hash(plain) {
+		t.Fatalf("hash is not deterministic")
+	}
+	if hash(plain) == hash(plain+"x") {
+		t.Fatalf("different keys share a hash")
+	}
+}
+
+func TestAuthenticateMalformed(t *testing.T) {
+	s := New(nil)
+	for _, plain := range []string{"", "ee", "ee_1a2b3c4d", "xx_1a2b3c4d_secret"} {
+		if _, err := s.Authenticate(context.Background(), plain, ""); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Authenticate(%q) = %v, want ErrInvalidAPIKey", plain, err)
+		}
+	}
+}
+
+// testStore connects to the Postgres of config.json and returns a school and
+// a user to issue keys for, skipping the test when there is none.
+func testStore(t *testing.T) (*Store, uuid.UUID, uuid.UUID) {
+	c, err := config.New("../../config.json")
+	if err != nil {
+		t.Skipf("no config: %v", err)
+	}
+
+	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", c.Postgres.Username, c.Postgres.Password, c.Postgres.Host, c.Postgres.Port, c.Postgres.Database, c.Postgres.SSLMode)
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Skipf("no postgres: %v", err)
+	}
+
+	var schoolID, userID uuid.UUID
+	if err := db.Get(&schoolID, "SELECT id FROM school LIMIT 1"); err != nil {
+		t.Skipf("no school: %v", err)
+	}
+	if err := db.Get(&userID, "SELECT id FROM users WHERE email = 'admin@gmail.com'"); err != nil {
+		t.Skipf("no user: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return New(db), schoolID, userID
+}
+
+// cleanup deletes the key together with its service account.
+func cleanup(t *testing.T, s *Store, key *Key) {
+	t.Cleanup(func() {
+		s.db.Exec("DELETE FROM api_key WHERE id = $1", key.ID)
+		s.db.Exec("DELETE FROM users WHERE id = $1", key.UserID)
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, schoolID, userID := testStore(t)
+	ctx := context.Background()
+
+	key, plain, err := s.Create(ctx, schoolID, userID, "apikey test", []string{"student.read"}, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	cleanup(t, s, key)
+
+	if key.KeyHash != hash(plain) || strings.Contains(key.KeyHash, plain) {
+		t.Fatalf("the stored hash does not match the key")
+	}
+
+	got, err := s.Authenticate(ctx, plain, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got.ID != key.ID || got.SchoolID != schoolID || len(got.Scopes) != 1 || got.Scopes[0] != "student.read" {
+		t.Fatalf("Authenticate returned %+v, want the created key", got)
+	}
+	if got.LastUsedIP != "10.0.0.1" || got.LastUsedAt == 0 {
+		t.Fatalf("Authenticate did not record the use")
+	}
+
+	// Same prefix, other secret
+	forged := Prefix + "_" + key.Prefix + "_" + strings.Repeat("A", 43)
+	if _, err := s.Authenticate(ctx, forged, ""); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("Authenticate with a wrong secret = %v, want ErrInvalidAPIKey", err)
+	}
+
+	unknown := Prefix + "_00000000_" + strings.SplitN(plain, "_", 3)[2]
+	if _, err := s.Authenticate(ctx, unknown, ""); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("Authenticate with an unknown prefix = %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s, schoolID, userID := testStore(t)
+	ctx := context.Background()
+
+	key, plain, err := s.Create(ctx, schoolID, userID, "apikey test", []string{"student.read"}, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	cleanup(t, s, key)
+
+	if revoked, err := s.Revoke(ctx, uuid.New(), key.ID); err != nil || revoked {
+		t.Fatalf("Revoke from another school = %v, %v, want false", revoked, err)
+	}
+	if revoked, err := s.Revoke(ctx, schoolID, key.ID); err != nil || !revoked {
+		t.Fatalf("Revoke = %v, %v, want true", revoked, err)
+	}
+	if revoked, err := s.Revoke(ctx, schoolID, key.ID); err != nil || revoked {
+		t.Fatalf("second Revoke = %v, %v, want false", revoked, err)
+	}
+
+	if _, err := s.Authenticate(ctx, plain, ""); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("Authenticate after revoke = %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	s, schoolID, userID := testStore(t)
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(-time.Minute).UnixMilli()
+	key, plain, err := s.Create(ctx, schoolID, userID, "apikey test", []string{"student.read"}, expiresAt)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	cleanup(t, s, key)
+
+	if _, err := s.Authenticate(ctx, plain, ""); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("Authenticate of an expired key = %v, want ErrInvalidAPIKey", err)
+	}
+}
@@ -28,4 +28,41 @@ var (
 	ErrInvalidPassword       = New("invalid password", 422)
 	ErrInvalidToken          = New("invalid token", 422)
 	ErrrTeacherAlreadyExists = New("teacher already exists", 422)
+	ErrMFAAlreadyEnabled     = New("two-factor authentication is already enabled", 409)
+	ErrMFANotEnabled         = New("two-factor authentication is not enabled", 422)
+	ErrMFARequired           = New("two-factor authentication is required by the school", 403)
+	ErrInvalidMFACode        = New("invalid two-factor code", 422)
+	ErrMFAChallengeExpired   = New("two-factor challenge expired, login again", 401)
+	ErrUnknownOIDCProvider   = New("unknown identity provider", 404)
+	ErrInvalidOIDCState      = New("sign in request expired or was already used, start again", 401)
+	ErrOIDCEmailNotAllowed   = New("email is not verified or its domain is not allowed", 403)
+	ErrIdentityNotFound      = New("identity not found", 404)
+	ErrAPIKeyNotFound        = New("api key not found", 404)
+	ErrInvalidAPIKeyScope    = New("invalid api key scope", 422)
+	ErrWebhookNotFound       = New("webhook not found", 404)
+	ErrInvalidWebhookEvent   = New("invalid webhook event", 422)
+	ErrInvalidWebhookURL     = New("webhook url must be an absolute http or https url", 422)
+	ErrExamNotFound          = New("exam not found", 404)
+	ErrInvalidExamWindow     = New("exam must end after it starts", 422)
+	ErrInvalidReleaseMode    = New("exam must have an end to release grades after it closes", 422)
+	ErrExamNotOpen           = New("exam is not open yet", 422)
+	ErrExamClosed            = New("exam is closed", 422)
+	ErrExamNotStarted        = New("exam attempt has not been started", 422)
+	ErrExamTimeUp            = New("time for this exam attempt is up", 422)
+	ErrExamNotSubmitted      = New("exam has not been submitted", 422)
+	ErrInvalidEssayScore     = New("score must be for an essay of the exam and within its points", 422)
+	ErrInvalidRubricScore    = New("rubric score must pick one level of every criterion of the rubric", 422)
+	ErrNotEnoughQuestions    = New("question bank does not have enough questions for the blueprint", 422)
+	ErrNoAttemptsLeft        = New("no attempts left for this exam", 422)
+	ErrMaxAttemptsUsed       = New("max attempts cannot be lower than the attempts a student already opened", 409)
+	ErrAttemptSubmitted      = New("exam attempt has already been submitted", 409)
+	ErrDraftConflict         = New("answers were changed by another session, reload the draft", 409)
+	ErrInvalidDraftAnswer    = New("answer must be for a question of the exam attempt", 422)
+	ErrRegradeNotFound       = New("regrade request not found", 404)
+	ErrRegradeNotGraded      = New("exam attempt must be graded and its grade released to request a regrade", 422)
+	ErrRegradeNotInAttempt   = New("question must be part of the graded exam attempt", 422)
+	ErrInvalidRegradeScore   = New("accepting a regrade needs an adjusted score within the points of the question", 422)
+	ErrRegradePending        = New("a regrade request for this question is already pending", 409)
+	ErrRegradeResolved       = New("regrade request has already been resolved", 409)
+	ErrGradedByAttempts      = New("grade is derived from the student's attempts, score essays or resolve regrades instead", 409)
 )
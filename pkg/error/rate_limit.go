@@ -0,0 +1,20 @@
+package error
+
+import (
+	"errors"
+	"time"
+)
+
+// RateLimitError is answered with 429 and a Retry-After header.
+type RateLimitError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func NewRateLimit(msg string, retryAfter time.Duration) RateLimitError {
+	return RateLimitError{Err: errors.New(msg), RetryAfter: retryAfter}
+}
+
+func (e RateLimitError) Error() string {
+	return e.Err.Error()
+}
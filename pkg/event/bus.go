@@ -0,0 +1,83 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Message is an event as published on the bus.
+type Message struct {
+	ID        string
+	Name      string
+	SchoolID  uuid.UUID // uuid.Nil for events of no school
+	Payload   json.RawMessage
+	CreatedAt int64
+}
+
+type handler func(ctx context.Context, msg Message) error
+
+// Bus delivers published events to the subscribers in this process. Events
+// are delivered at least once: when one subscriber fails the relay publishes
+// the event again later, to every subscriber, so handlers must tolerate
+// duplicates.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]handler
+	all      []handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[string][]handler{}}
+}
+
+// Subscribe calls h with every published event of type T.
+func Subscribe[T Event](b *Bus, h func(ctx context.Context, e T) error) {
+	var zero T
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[zero.EventName()] = append(b.handlers[zero.EventName()], func(ctx context.Context, msg Message) error {
+		var e T
+		if err := json.Unmarshal(msg.Payload, &e); err != nil {
+			return fmt.Errorf("decode %s: %w", msg.Name, err)
+		}
+		return h(ctx, e)
+	})
+}
+
+// SubscribeAll calls h with every published event, for consumers that
+// forward events without knowing their types.
+func (b *Bus) SubscribeAll(h func(ctx context.Context, msg Message) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.all = append(b.all, h)
+}
+
+// Publish calls the subscribers of msg and returns their joined errors.
+func (b *Bus) Publish(ctx context.Context, msg Message) error {
+	b.mu.RLock()
+	handlers := append(append([]handler(nil), b.handlers[msg.Name]...), b.all...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := call(ctx, h, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func call(ctx context.Context, h handler, msg Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, msg)
+}
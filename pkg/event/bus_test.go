@@ -0,0 +1,79 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublish(t *testing.T) {
+	bus := NewBus()
+
+	var submitted []ExamSubmitted
+	Subscribe(bus, func(ctx context.Context, e ExamSubmitted) error {
+		submitted = append(submitted, e)
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, e ClassMembersAdded) error {
+		t.Errorf("ClassMembersAdded subscriber got %+v", e)
+		return nil
+	})
+
+	var all []string
+	bus.SubscribeAll(func(ctx context.Context, msg Message) error {
+		all = append(all, msg.Name)
+		return nil
+	})
+
+	e := ExamSubmitted{ExamID: uuid.New(), StudentID: uuid.New(), SchoolID: uuid.New(), SubmittedAt: 1}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bus.Publish(context.Background(), Message{ID: "1", Name: e.EventName(), SchoolID: e.SchoolID, Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(submitted) != 1 || submitted[0] != e {
+		t.Errorf("ExamSubmitted subscriber got %+v, want %+v", submitted, e)
+	}
+	if len(all) != 1 || all[0] != NameExamSubmitted {
+		t.Errorf("SubscribeAll got %v", all)
+	}
+}
+
+func TestPublishErrors(t *testing.T) {
+	bus := NewBus()
+
+	calls := 0
+	Subscribe(bus, func(ctx context.Context, e PPDBStudentsUpdated) error {
+		calls++
+		return errors.New("failed")
+	})
+	Subscribe(bus, func(ctx context.Context, e PPDBStudentsUpdated) error {
+		calls++
+		panic("boom")
+	})
+	Subscribe(bus, func(ctx context.Context, e PPDBStudentsUpdated) error {
+		calls++
+		return nil
+	})
+
+	err := bus.Publish(context.Background(), Message{Name: NamePPDBStudentsUpdated, Payload: json.RawMessage(`{"status":"accepted"}`)})
+	if err == nil {
+		t.Fatal("Publish returned no error")
+	}
+	if calls != 3 {
+		t.Errorf("%d subscribers called, want all 3", calls)
+	}
+
+	err = bus.Publish(context.Background(), Message{Name: NamePPDBStudentsUpdated, Payload: json.RawMessage(`not json`)})
+	if err == nil {
+		t.Error("Publish of an invalid payload returned no error")
+	}
+}
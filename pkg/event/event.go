@@ -0,0 +1,146 @@
+// Package event defines the domain events modules publish and subscribe to.
+// Events are written to the outbox table in the transaction of the change
+// they describe (see Write) and the relay in the worker publishes them on
+// the Bus afterwards, so an event exists exactly when its change committed.
+// Modules only share the types below and never import each other.
+package event
+
+import (
+	"github.com/google/uuid"
+)
+
+// Event is implemented by the event types. The name identifies the type in
+// the outbox and must not change once events were written.
+type Event interface {
+	EventName() string
+}
+
+const (
+	NameExamSubmitted         = "exam.submitted"
+	NameExamGraded            = "exam.graded"
+	NameExamRegradeResolved   = "exam.regrade_resolved"
+	NamePPDBStudentsUpdated   = "ppdb.students_updated"
+	NamePPDBClosed            = "ppdb.closed"
+	NameClassMembersAdded     = "class.members_added"
+	NameClassMembersRemoved   = "class.members_removed"
+	NameTeacherInviteAccepted = "teacher.invite_accepted"
+)
+
+// Names lists every event name, e.g. for validating subscriptions.
+var Names = []string{
+	NameExamSubmitted,
+	NameExamGraded,
+	NameExamRegradeResolved,
+	NamePPDBStudentsUpdated,
+	NamePPDBClosed,
+	NameClassMembersAdded,
+	NameClassMembersRemoved,
+	NameTeacherInviteAccepted,
+}
+
+// Roles of ClassMembersAdded and ClassMembersRemoved.
+const (
+	RoleStudent = "student"
+	RoleTeacher = "teacher"
+)
+
+// ExamSubmitted is written whenever a student submits an attempt at an
+// exam.
+type ExamSubmitted struct {
+	ExamID      uuid.UUID `json:"exam_id"`
+	StudentID   uuid.UUID `json:"student_id"`
+	SchoolID    uuid.UUID `json:"school_id"`
+	Attempt     int       `json:"attempt"`
+	SubmittedAt int64     `json:"submitted_at"`
+}
+
+func (ExamSubmitted) EventName() string { return NameExamSubmitted }
+
+// ExamGraded is written when a grade of the exam is stored, by the
+// automatic grading of attempts without essays (Auto) or by a teacher
+// grading the exam, scoring the last essay of an attempt or accepting a
+// regrade. Grade is the grade of the exam after the scoring policy, Attempt
+// the graded attempt or 0 when the teacher set the grade directly.
+type ExamGraded struct {
+	ExamID    uuid.UUID `json:"exam_id"`
+	StudentID uuid.UUID `json:"student_id"`
+	SchoolID  uuid.UUID `json:"school_id"`
+	Attempt   int       `json:"attempt"`
+	Grade     float64   `json:"grade"`
+	Auto      bool      `json:"auto"`
+	GradedAt  int64     `json:"graded_at"`
+}
+
+func (ExamGraded) EventName() string { return NameExamGraded }
+
+// ExamRegradeResolved is written when a teacher accepts or rejects a
+// student's regrade request on a question of an attempt. Score is the
+// adjusted score of the question of accepted requests.
+type ExamRegradeResolved struct {
+	RequestID  uuid.UUID `json:"request_id"`
+	ExamID     uuid.UUID `json:"exam_id"`
+	StudentID  uuid.UUID `json:"student_id"`
+	SchoolID   uuid.UUID `json:"school_id"`
+	Attempt    int       `json:"attempt"`
+	QuestionID uuid.UUID `json:"question_id"`
+	Status     string    `json:"status"`
+	Score      *float64  `json:"score,omitempty"`
+	ResolvedAt int64     `json:"resolved_at"`
+}
+
+func (ExamRegradeResolved) EventName() string { return NameExamRegradeResolved }
+
+// PPDBStudentsUpdated is written when registrants of a PPDB period get a new
+// status, e.g. "accepted" in the selection.
+type PPDBStudentsUpdated struct {
+	PPDBID     uuid.UUID   `json:"ppdb_id"`
+	SchoolID   uuid.UUID   `json:"school_id"`
+	StudentIDs []uuid.UUID `json:"student_ids"`
+	Status     string      `json:"status"`
+}
+
+func (PPDBStudentsUpdated) EventName() string { return NamePPDBStudentsUpdated }
+
+// PPDBClosed is written by the scheduled job that closes PPDB periods once
+// their end_at passed.
+type PPDBClosed struct {
+	PPDBID   uuid.UUID `json:"ppdb_id"`
+	SchoolID uuid.UUID `json:"school_id"`
+	EndAt    int64     `json:"end_at"`
+	ClosedAt int64     `json:"closed_at"`
+}
+
+func (PPDBClosed) EventName() string { return NamePPDBClosed }
+
+// ClassMembersAdded is written when students or teachers are added to a
+// class. UserIDs are the requested users, some may have been members before.
+type ClassMembersAdded struct {
+	ClassID  uuid.UUID   `json:"class_id"`
+	SchoolID uuid.UUID   `json:"school_id"`
+	Role     string      `json:"role"`
+	UserIDs  []uuid.UUID `json:"user_ids"`
+}
+
+func (ClassMembersAdded) EventName() string { return NameClassMembersAdded }
+
+// ClassMembersRemoved is written when students or teachers leave a class.
+type ClassMembersRemoved struct {
+	ClassID  uuid.UUID   `json:"class_id"`
+	SchoolID uuid.UUID   `json:"school_id"`
+	Role     string      `json:"role"`
+	UserIDs  []uuid.UUID `json:"user_ids"`
+}
+
+func (ClassMembersRemoved) EventName() string { return NameClassMembersRemoved }
+
+// TeacherInviteAccepted is written for every school that invited the teacher
+// once they verified their email and set up their account.
+type TeacherInviteAccepted struct {
+	TeacherID  uuid.UUID `json:"teacher_id"`
+	SchoolID   uuid.UUID `json:"school_id"`
+	Email      string    `json:"email"`
+	Name       string    `json:"name"`
+	AcceptedAt int64     `json:"accepted_at"`
+}
+
+func (TeacherInviteAccepted) EventName() string { return NameTeacherInviteAccepted }
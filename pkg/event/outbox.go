@@ -0,0 +1,158 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	relayBatch       = 100
+	relayInterval    = time.Second
+	relayMaxAttempts = 10
+	relayBackoffMax  = time.Hour
+	// publishedRetention is how long published events are kept, e.g. to
+	// look into what happened, before the relay deletes them.
+	publishedRetention = 7 * 24 * time.Hour
+	pruneInterval      = time.Hour
+)
+
+// Write adds e to the outbox within tx, so the event is published if and
+// only if tx commits. schoolID may be uuid.Nil.
+func Write(ctx context.Context, tx *sqlx.Tx, schoolID uuid.UUID, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	school := uuid.NullUUID{UUID: schoolID, Valid: schoolID != uuid.Nil}
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox (name, school_id, payload, created_at) VALUES ($1, $2, $3, $4)",
+		e.EventName(), school, payload, time.Now().UnixMilli())
+	return err
+}
+
+type outboxRow struct {
+	ID        uuid.UUID     `db:"id"`
+	Name      string        `db:"name"`
+	SchoolID  uuid.NullUUID `db:"school_id"`
+	Payload   []byte        `db:"payload"`
+	Attempts  int           `db:"attempts"`
+	CreatedAt int64         `db:"created_at"`
+}
+
+// Relay publishes pending outbox events on the bus. Several relays may run
+// at once, rows are locked while they are published.
+type Relay struct {
+	db  *sqlx.DB
+	bus *Bus
+}
+
+func NewRelay(db *sqlx.DB, bus *Bus) *Relay {
+	return &Relay{db: db, bus: bus}
+}
+
+// Run publishes events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	var pruned time.Time
+	for ctx.Err() == nil {
+		n, err := r.publish(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Err(err).Msg("failed to publish outbox events")
+		}
+
+		if time.Since(pruned) >= pruneInterval {
+			if err := r.prune(ctx); err != nil && ctx.Err() == nil {
+				log.Err(err).Msg("failed to prune outbox")
+			}
+			pruned = time.Now()
+		}
+
+		// Keep going while there is a backlog.
+		if n == relayBatch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(relayInterval):
+		}
+	}
+}
+
+// publish publishes one batch and returns its size.
+func (r *Relay) publish(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	rows := []outboxRow{}
+	err = tx.SelectContext(ctx, &rows, `SELECT id, name, school_id, payload, attempts, created_at FROM outbox
+		WHERE published_at = 0 AND attempts < $1 AND available_at <= $2
+		ORDER BY created_at LIMIT $3 FOR UPDATE SKIP LOCKED`, relayMaxAttempts, now.UnixMilli(), relayBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		err := r.bus.Publish(ctx, Message{
+			ID:        row.ID.String(),
+			Name:      row.Name,
+			SchoolID:  row.SchoolID.UUID,
+			Payload:   row.Payload,
+			CreatedAt: row.CreatedAt,
+		})
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "UPDATE outbox SET published_at = $2 WHERE id = $1", row.ID, time.Now().UnixMilli())
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		attempts := row.Attempts + 1
+		log.Warn().Err(err).Str("event", row.ID.String()).Str("name", row.Name).Int("attempt", attempts).Msg("event subscriber failed")
+		if attempts >= relayMaxAttempts {
+			log.Error().Str("event", row.ID.String()).Str("name", row.Name).Msg("event gave up after too many attempts")
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE outbox SET attempts = $2, last_error = $3, available_at = $4 WHERE id = $1",
+			row.ID, attempts, err.Error(), now.Add(backoff(attempts)).UnixMilli())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	committed = true
+
+	return len(rows), nil
+}
+
+// backoff doubles from one second for every failed attempt.
+func backoff(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < relayBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, relayBackoffMax)
+}
+
+func (r *Relay) prune(ctx context.Context) error {
+	before := time.Now().Add(-publishedRetention).UnixMilli()
+	_, err := r.db.ExecContext(ctx, "DELETE FROM outbox WHERE published_at > 0 AND published_at < $1", before)
+	return err
+}
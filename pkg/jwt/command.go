@@ -0,0 +1,72 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func (k *KeySet) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "jwt signing key stuff",
+	}
+
+	var algorithm string
+	generate := &cobra.Command{
+		Use:   "generate",
+		Short: "generate a new signing key and retire the active one",
+		Run: func(cmd *cobra.Command, args []string) {
+			key, err := k.Generate(context.Background(), algorithm)
+			if err != nil {
+				log.Error().Err(err).Msg("generate key failed")
+				return
+			}
+			log.Info().Str("kid", key.ID).Str("algorithm", key.Algorithm).Msg("signing key generated")
+		},
+	}
+	generate.Flags().StringVar(&algorithm, "alg", "", "key algorithm, RS256 or EdDSA (default from config)")
+
+	rotate := &cobra.Command{
+		Use:   "rotate",
+		Short: "rotate the signing key if it is older than jwt.rotation_interval",
+		Run: func(cmd *cobra.Command, args []string) {
+			key, err := k.RotateIfDue(context.Background())
+			if err != nil {
+				log.Error().Err(err).Msg("rotate key failed")
+				return
+			}
+			if key == nil {
+				log.Info().Msg("signing key is not due for rotation")
+				return
+			}
+			log.Info().Str("kid", key.ID).Str("algorithm", key.Algorithm).Msg("signing key rotated")
+		},
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "list signing keys",
+		Run: func(cmd *cobra.Command, args []string) {
+			keys, err := k.List(context.Background())
+			if err != nil {
+				log.Error().Err(err).Msg("list keys failed")
+				return
+			}
+
+			for _, key := range keys {
+				status := "active"
+				if key.RetiredAt.Valid {
+					status = "retired, verifies until " + time.UnixMilli(key.ExpiresAt.Int64).Format(time.RFC3339)
+				}
+				fmt.Printf("%s\t%s\t%s\t%s\n", key.ID, key.Algorithm, time.UnixMilli(key.CreatedAt).Format(time.RFC3339), status)
+			}
+		},
+	}
+
+	cmd.AddCommand(generate, rotate, list)
+	return cmd
+}
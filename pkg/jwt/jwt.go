@@ -12,6 +12,17 @@ import (
 
 const ContextKey = "JWT"
 
+// Token types, carried in the typ claim so a refresh token can never be used
+// as an access token and vice versa. TypeAPIKey is never signed, it marks the
+// claims middleware.Auth builds for a request authenticated with an API key.
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+	TypeAPIKey  = "api_key"
+)
+
+var ErrInvalidTokenType = commonError.New("token type is invalid", http.StatusUnauthorized)
+
 type Payload struct {
 	Exp  int64  `json:"exp"`
 	Iat  int64  `json:"iat"`
@@ -19,7 +30,12 @@ type Payload struct {
 	Iss  string `json:"iss"`
 	Nbf  int64  `json:"nbf"`
 	Aud  string `json:"aud"`
+	Jti  string `json:"jti,omitempty"`
+	Sid  string `json:"sid,omitempty"` // session ID, see pkg/session
+	Typ  string `json:"typ"`
 	User User   `json:"user"`
+	// Scopes are the permissions of an API key; sessions use the school role.
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 type User struct {
@@ -64,6 +80,48 @@ func GenerateToken(payload Payload, secret string) (string, error) {
 	return tokenString, nil
 }
 
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// GenerateTokenPair signs the access and refresh token of a session. The
+// refresh token uses refreshID as its jti so the session can tell the current
+// refresh token apart from ones that were already rotated.
+func (k *KeySet) GenerateTokenPair(ctx context.Context, user User, sid, refreshID string, accessTTL, refreshTTL time.Duration) (*TokenPair, error) {
+	now := time.Now()
+	payload := Payload{
+		Exp:  now.Add(accessTTL).Unix(),
+		Iat:  now.Unix(),
+		Nbf:  now.Unix(),
+		Iss:  "genesis",
+		Sub:  user.ID.String(),
+		Aud:  "genesis",
+		Jti:  uuid.New().String(),
+		Sid:  sid,
+		Typ:  TypeAccess,
+		User: user,
+	}
+
+	accessToken, err := k.Sign(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.Exp = now.Add(refreshTTL).Unix()
+	payload.Jti = refreshID
+	payload.Typ = TypeRefresh
+	refreshToken, err := k.Sign(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
 func Verify(token, secret string) (*jwt.Token, error) {
 	payload := new(Payload)
 	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithIssuedAt(), jwt.WithExpirationRequired())
@@ -71,7 +129,7 @@ func Verify(token, secret string) (*jwt.Token, error) {
 		return []byte(secret), nil
 	})
 	if err != nil || !tokenValidation.Valid {
-		return nil, commonError.New("token is invalid", http.StatusUnauthorized)
+		return nil, parseError(err)
 	}
 
 	return tokenValidation, nil
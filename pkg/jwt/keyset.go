@@ -0,0 +1,490 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/pem"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Supported algorithms for keys stored in jwt_key.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	// keyCacheTTL is how long the keys read from Postgres are reused before
+	// they are loaded again, so rotations done by another instance or the
+	// CLI are picked up without a restart.
+	keyCacheTTL = time.Minute
+	// keyReloadInterval throttles reloads triggered by an unknown kid.
+	keyReloadInterval = 5 * time.Second
+)
+
+var (
+	ErrTokenInvalid = commonError.New("token is invalid", http.StatusUnauthorized)
+	ErrTokenExpired = commonError.New("token has expired", http.StatusUnauthorized)
+)
+
+// Key is a row of jwt_key. PrivateKey holds the encrypted PKCS#8 key and is
+// only decrypted for the active key.
+type Key struct {
+	ID         string        `db:"id"`
+	Algorithm  string        `db:"algorithm"`
+	PrivateKey string        `db:"private_key"`
+	PublicKey  string        `db:"public_key"`
+	CreatedAt  int64         `db:"created_at"`
+	RetiredAt  sql.NullInt64 `db:"retired_at"`
+	ExpiresAt  sql.NullInt64 `db:"expires_at"`
+}
+
+// KeySet signs and verifies tokens. While jwt_key has no active key it falls
+// back to HS256 with the configured secret; once a key is generated every
+// token is signed with the active key and carries its kid, and retired keys
+// keep verifying until they expire. HS256 tokens keep verifying for the
+// retention after the first key was generated, so switching to asymmetric
+// keys does not log everyone out.
+type KeySet struct {
+	db          *sqlx.DB
+	secret      string
+	algorithm   string
+	rotateAfter time.Duration
+	retention   time.Duration
+
+	mu       sync.RWMutex
+	loadedAt time.Time
+	signing  *signingKey
+	public   map[string]publicKey
+	// activatedAt is when the first key was generated, 0 while there is none.
+	activatedAt int64
+}
+
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	key    crypto.PrivateKey
+}
+
+type publicKey struct {
+	algorithm string
+	key       crypto.PublicKey
+}
+
+// NewKeySet creates a key set backed by jwt_key. algorithm is used for newly
+// generated keys, rotateAfter is the age at which RotateIfDue replaces the
+// active key (0 disables it) and retention is how long a retired key stays
+// valid for verification, which should cover the longest token lifetime.
+func NewKeySet(db *sqlx.DB, secret, algorithm string, rotateAfter, retention time.Duration) *KeySet {
+	if algorithm == "" {
+		algorithm = AlgorithmRS256
+	}
+
+	return &KeySet{
+		db:          db,
+		secret:      secret,
+		algorithm:   algorithm,
+		rotateAfter: rotateAfter,
+		retention:   retention,
+		public:      map[string]publicKey{},
+	}
+}
+
+// Sign signs the payload with the active key, or HS256 when there is none.
+func (k *KeySet) Sign(ctx context.Context, payload Payload) (string, error) {
+	if err := k.ensureLoaded(ctx, false); err != nil {
+		return "", err
+	}
+
+	k.mu.RLock()
+	signing := k.signing
+	k.mu.RUnlock()
+
+	if signing == nil {
+		return GenerateToken(payload, k.secret)
+	}
+
+	token := jwt.NewWithClaims(signing.method, &payload)
+	token.Header["kid"] = signing.id
+	return token.SignedString(signing.key)
+}
+
+// Verify parses and validates a token signed by Sign. Tokens that do not
+// verify return ErrTokenInvalid or ErrTokenExpired, any other error comes
+// from loading the keys.
+func (k *KeySet) Verify(ctx context.Context, token string) (*jwt.Token, error) {
+	if err := k.ensureLoaded(ctx, false); err != nil {
+		return nil, err
+	}
+
+	unverified, _, err := jwt.NewParser().ParseUnverified(token, new(Payload))
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	kid, _ := unverified.Header["kid"].(string)
+	if kid == "" {
+		if !k.acceptsLegacy(time.Now()) {
+			return nil, ErrTokenInvalid
+		}
+		return Verify(token, k.secret)
+	}
+
+	key, ok := k.publicKey(kid)
+	if !ok {
+		if err := k.ensureLoaded(ctx, true); err != nil {
+			return nil, err
+		}
+		if key, ok = k.publicKey(kid); !ok {
+			return nil, ErrTokenInvalid
+		}
+	}
+
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{key.algorithm}), jwt.WithIssuedAt(), jwt.WithExpirationRequired())
+	parsed, err := parser.ParseWithClaims(token, new(Payload), func(*jwt.Token) (interface{}, error) {
+		return key.key, nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, parseError(err)
+	}
+
+	return parsed, nil
+}
+
+// parseError tells expired tokens apart from every other invalid one.
+func parseError(err error) error {
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return ErrTokenExpired
+	}
+	return ErrTokenInvalid
+}
+
+// acceptsLegacy reports whether HS256 tokens still verify: while no key was
+// generated, and for the retention after the first one, which covers the
+// refresh tokens issued before it.
+func (k *KeySet) acceptsLegacy(now time.Time) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.activatedAt == 0 {
+		return k.signing == nil
+	}
+	return now.Before(time.UnixMilli(k.activatedAt).Add(k.retention))
+}
+
+func (k *KeySet) publicKey(kid string) (publicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.public[kid]
+	return key, ok
+}
+
+// JWK is a public key in the format of RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that are currently valid for verification.
+func (k *KeySet) JWKS(ctx context.Context) (*JWKS, error) {
+	if err := k.ensureLoaded(ctx, false); err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := &JWKS{Keys: make([]JWK, 0, len(k.public))}
+	for kid, pub := range k.public {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: pub.algorithm}
+		switch key := pub.key.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(key)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// List returns every key in jwt_key, newest first.
+func (k *KeySet) List(ctx context.Context) ([]Key, error) {
+	keys := []Key{}
+	err := k.db.SelectContext(ctx, &keys, `SELECT id, algorithm, private_key, public_key, created_at, retired_at, expires_at FROM jwt_key ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Generate creates a new active key and retires the current one.
+func (k *KeySet) Generate(ctx context.Context, algorithm string) (*Key, error) {
+	return k.rotate(ctx, algorithm, true)
+}
+
+// RotateIfDue generates a new key when there is no active key yet or the
+// active key is older than rotateAfter. It returns nil when nothing changed.
+func (k *KeySet) RotateIfDue(ctx context.Context) (*Key, error) {
+	if k.rotateAfter <= 0 {
+		return nil, nil
+	}
+	return k.rotate(ctx, k.algorithm, false)
+}
+
+func (k *KeySet) rotate(ctx context.Context, algorithm string, force bool) (*Key, error) {
+	if algorithm == "" {
+		algorithm = k.algorithm
+	}
+
+	tx, err := k.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	// Instances racing to rotate serialize here so only one key is created.
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('jwt_key'))`)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := Key{}
+	err = tx.GetContext(ctx, &active, `SELECT id, algorithm, private_key, public_key, created_at, retired_at, expires_at FROM jwt_key WHERE retired_at IS NULL ORDER BY created_at DESC LIMIT 1`)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	hasActive := err == nil
+
+	if !force && hasActive && now.Sub(time.UnixMilli(active.CreatedAt)) < k.rotateAfter {
+		return nil, nil
+	}
+
+	key, err := k.newKey(algorithm, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE jwt_key SET retired_at = $1, expires_at = $2 WHERE retired_at IS NULL`, now.UnixMilli(), now.Add(k.retention).UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.NamedExecContext(ctx, `INSERT INTO jwt_key (id, algorithm, private_key, public_key, created_at) VALUES (:id, :algorithm, :private_key, :public_key, :created_at)`, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	committed = true
+
+	if err := k.load(ctx); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (k *KeySet) newKey(algorithm string, now time.Time) (*Key, error) {
+	var (
+		private crypto.PrivateKey
+		public  crypto.PublicKey
+	)
+
+	switch algorithm {
+	case AlgorithmRS256:
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		private, public = rsaKey, &rsaKey.PublicKey
+	case AlgorithmEdDSA:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private, public = priv, pub
+	default:
+		return nil, fmt.Errorf("unsupported jwt key algorithm %q", algorithm)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := k.encrypt(privateDER)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		ID:         uuid.New().String(),
+		Algorithm:  algorithm,
+		PrivateKey: encrypted,
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		CreatedAt:  now.UnixMilli(),
+	}, nil
+}
+
+// ensureLoaded reads the keys from Postgres when the cache is stale. force
+// reloads anyway, but at most once per keyReloadInterval.
+func (k *KeySet) ensureLoaded(ctx context.Context, force bool) error {
+	k.mu.RLock()
+	age := time.Since(k.loadedAt)
+	k.mu.RUnlock()
+
+	if age < keyReloadInterval || (!force && age < keyCacheTTL) {
+		return nil
+	}
+
+	return k.load(ctx)
+}
+
+func (k *KeySet) load(ctx context.Context) error {
+	keys := []Key{}
+	err := k.db.SelectContext(ctx, &keys, `SELECT id, algorithm, private_key, public_key, created_at, retired_at, expires_at FROM jwt_key WHERE expires_at IS NULL OR expires_at > $1 ORDER BY created_at DESC`, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	var activatedAt int64
+	err = k.db.GetContext(ctx, &activatedAt, `SELECT COALESCE(MIN(created_at), 0) FROM jwt_key`)
+	if err != nil {
+		return err
+	}
+
+	return k.setKeys(keys, activatedAt)
+}
+
+// setKeys replaces the cached keys with keys, newest first, of which the
+// first one that is not retired signs.
+func (k *KeySet) setKeys(keys []Key, activatedAt int64) error {
+	var signing *signingKey
+	public := make(map[string]publicKey, len(keys))
+	for _, key := range keys {
+		block, _ := pem.Decode([]byte(key.PublicKey))
+		if block == nil {
+			return fmt.Errorf("jwt key %s: invalid public key", key.ID)
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("jwt key %s: %w", key.ID, err)
+		}
+		public[key.ID] = publicKey{algorithm: key.Algorithm, key: pub}
+
+		if signing != nil || key.RetiredAt.Valid {
+			continue
+		}
+
+		der, err := k.decrypt(key.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("jwt key %s: %w", key.ID, err)
+		}
+		private, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return fmt.Errorf("jwt key %s: %w", key.ID, err)
+		}
+		signing = &signingKey{id: key.ID, method: jwt.GetSigningMethod(key.Algorithm), key: private}
+	}
+
+	k.mu.Lock()
+	k.signing = signing
+	k.public = public
+	k.activatedAt = activatedAt
+	k.loadedAt = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+func (k *KeySet) gcm() (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(k.secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *KeySet) encrypt(plain []byte) (string, error) {
+	gcm, err := k.gcm()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plain, nil)), nil
+}
+
+func (k *KeySet) decrypt(encoded string) ([]byte, error) {
+	gcm, err := k.gcm()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted key is too short")
+	}
+
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
@@ -0,0 +1,265 @@
+package jwt
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testSecret = "keyset test secret"
+
+// testKeySet returns a key set whose keys are set by the test, so it never
+// reads jwt_key.
+func testKeySet(t *testing.T) *KeySet {
+	k := NewKeySet(nil, testSecret, AlgorithmEdDSA, 0, time.Hour)
+	if err := k.setKeys(nil, 0); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+	return k
+}
+
+func testKey(t *testing.T, k *KeySet, algorithm string, createdAt time.Time) Key {
+	key, err := k.newKey(algorithm, createdAt)
+	if err != nil {
+		t.Fatalf("newKey: %v", err)
+	}
+	return *key
+}
+
+func retire(key *Key, at time.Time) {
+	key.RetiredAt = sql.NullInt64{Int64: at.UnixMilli(), Valid: true}
+}
+
+func testPayload() Payload {
+	now := time.Now()
+	return Payload{
+		Exp:  now.Add(time.Minute).Unix(),
+		Iat:  now.Unix(),
+		Nbf:  now.Unix(),
+		Iss:  "genesis",
+		Sub:  "user",
+		Aud:  "genesis",
+		Typ:  TypeAccess,
+		User: User{ID: uuid.New(), Email: "user@example.com"},
+	}
+}
+
+func sign(t *testing.T, k *KeySet) string {
+	token, err := k.Sign(context.Background(), testPayload())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return token
+}
+
+func kid(t *testing.T, token string) string {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, new(Payload))
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestSignAcrossRotation(t *testing.T) {
+	k := testKeySet(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	first := testKey(t, k, AlgorithmEdDSA, now)
+	if err := k.setKeys([]Key{first}, first.CreatedAt); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+	before := sign(t, k)
+	if kid(t, before) != first.ID {
+		t.Fatalf("token is signed with kid %q, want %q", kid(t, before), first.ID)
+	}
+
+	second := testKey(t, k, AlgorithmRS256, now.Add(time.Second))
+	retire(&first, now.Add(time.Second))
+	if err := k.setKeys([]Key{second, first}, first.CreatedAt); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+	after := sign(t, k)
+	if kid(t, after) != second.ID {
+		t.Fatalf("token is signed with kid %q, want %q", kid(t, after), second.ID)
+	}
+
+	for name, token := range map[string]string{"retired key": before, "active key": after} {
+		parsed, err := k.Verify(ctx, token)
+		if err != nil {
+			t.Fatalf("Verify with the %s: %v", name, err)
+		}
+		if claims, _ := ExtractToken(parsed); claims.Sub != "user" {
+			t.Fatalf("Verify with the %s returned claims %+v", name, claims)
+		}
+	}
+
+	// The retired key expired and is no longer loaded
+	if err := k.setKeys([]Key{second}, first.CreatedAt); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+	if _, err := k.Verify(ctx, before); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("Verify with an expired key = %v, want ErrTokenInvalid", err)
+	}
+	if _, err := k.Verify(ctx, after); err != nil {
+		t.Fatalf("Verify with the active key: %v", err)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	k := testKeySet(t)
+	ctx := context.Background()
+
+	key := testKey(t, k, AlgorithmEdDSA, time.Now())
+	if err := k.setKeys([]Key{key}, key.CreatedAt); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+
+	other := testKeySet(t)
+	otherKey := testKey(t, other, AlgorithmEdDSA, time.Now())
+	otherKey.ID = key.ID
+	if err := other.setKeys([]Key{otherKey}, otherKey.CreatedAt); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+
+	expired := testPayload()
+	expired.Exp = time.Now().Add(-time.Minute).Unix()
+	expiredToken, err := k.Sign(ctx, expired)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	valid := sign(t, k)
+	tests := map[string]string{
+		"malformed":      "not.a.token",
+		"unknown key":    sign(t, other),
+		"truncated sign": valid[:len(valid)-4],
+	}
+	for name, token := range tests {
+		if _, err := k.Verify(ctx, token); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("Verify %s = %v, want ErrTokenInvalid", name, err)
+		}
+	}
+
+	if _, err := k.Verify(ctx, expiredToken); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Verify expired = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	k := testKeySet(t)
+	now := time.Now()
+
+	rsaKey := testKey(t, k, AlgorithmRS256, now)
+	edKey := testKey(t, k, AlgorithmEdDSA, now.Add(time.Second))
+	retire(&rsaKey, now.Add(time.Second))
+	if err := k.setKeys([]Key{edKey, rsaKey}, rsaKey.CreatedAt); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+
+	set, err := k.JWKS(context.Background())
+	if err != nil {
+		t.Fatalf("JWKS: %v", err)
+	}
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", len(set.Keys))
+	}
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "sig" {
+			t.Errorf("key %s has use %q, want sig", jwk.Kid, jwk.Use)
+		}
+		switch jwk.Kid {
+		case rsaKey.ID:
+			if jwk.Kty != "RSA" || jwk.Alg != AlgorithmRS256 || jwk.N == "" || jwk.E != "AQAB" || jwk.X != "" {
+				t.Errorf("RSA key is %+v", jwk)
+			}
+		case edKey.ID:
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != AlgorithmEdDSA || err != nil || len(x) != 32 || jwk.N != "" {
+				t.Errorf("Ed25519 key is %+v", jwk)
+			}
+		default:
+			t.Errorf("unexpected kid %q", jwk.Kid)
+		}
+	}
+}
+
+func TestJWKSWithoutKeys(t *testing.T) {
+	set, err := testKeySet(t).JWKS(context.Background())
+	if err != nil {
+		t.Fatalf("JWKS: %v", err)
+	}
+	if set.Keys == nil || len(set.Keys) != 0 {
+		t.Fatalf("JWKS = %+v, want an empty key list", set.Keys)
+	}
+}
+
+func TestHS256Fallback(t *testing.T) {
+	k := testKeySet(t)
+	ctx := context.Background()
+
+	legacy := sign(t, k)
+	if kid(t, legacy) != "" {
+		t.Fatalf("token without keys carries kid %q", kid(t, legacy))
+	}
+	if _, err := Verify(legacy, testSecret); err != nil {
+		t.Fatalf("token without keys is not signed with the secret: %v", err)
+	}
+	if _, err := k.Verify(ctx, legacy); err != nil {
+		t.Fatalf("Verify without keys: %v", err)
+	}
+
+	forged, err := GenerateToken(testPayload(), "other secret")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := k.Verify(ctx, forged); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("Verify with another secret = %v, want ErrTokenInvalid", err)
+	}
+
+	// Within the retention after the first key, HS256 tokens keep working
+	key := testKey(t, k, AlgorithmEdDSA, time.Now())
+	if err := k.setKeys([]Key{key}, key.CreatedAt); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+	if kid(t, sign(t, k)) != key.ID {
+		t.Fatalf("tokens are not signed with the active key after it was generated")
+	}
+	if _, err := k.Verify(ctx, legacy); err != nil {
+		t.Fatalf("Verify of an HS256 token after the first key: %v", err)
+	}
+
+	// Past it they are rejected
+	activatedAt := time.Now().Add(-2 * time.Hour)
+	if err := k.setKeys([]Key{key}, activatedAt.UnixMilli()); err != nil {
+		t.Fatalf("setKeys: %v", err)
+	}
+	if _, err := k.Verify(ctx, legacy); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("Verify of an HS256 token past the retention = %v, want ErrTokenInvalid", err)
+	}
+}
+
+func TestEncryptPrivateKey(t *testing.T) {
+	k := testKeySet(t)
+	key := testKey(t, k, AlgorithmEdDSA, time.Now())
+
+	if _, err := k.decrypt(key.PrivateKey); err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+
+	other := NewKeySet(nil, "other secret", AlgorithmEdDSA, 0, time.Hour)
+	if _, err := other.decrypt(key.PrivateKey); err == nil {
+		t.Fatalf("decrypt with another secret succeeded")
+	}
+	if err := other.setKeys([]Key{key}, key.CreatedAt); err == nil {
+		t.Fatalf("setKeys with another secret succeeded")
+	}
+}
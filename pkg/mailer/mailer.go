@@ -0,0 +1,211 @@
+// Package mailer renders the transactional emails and delivers them through
+// a pluggable Mailer: SMTP in production, a log/file sink for development
+// and an in-memory one for tests.
+package mailer
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"enuma-elish/pkg/queue"
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"strings"
+	textTemplate "text/template"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// Templates, see templates/<name>.txt and templates/<name>.html.
+const (
+	TemplateVerifyEmail     = "verify_email"
+	TemplateForgotPassword  = "forgot_password"
+	TemplateUnlockAccount   = "unlock_account"
+	TemplateTeacherInvite   = "teacher_invite"
+	TemplateStudentInvite   = "student_invite"
+	TemplatePPDBRegistered  = "ppdb_registered"
+	TemplatePPDBAccepted    = "ppdb_accepted"
+	TemplateRegradeResolved = "regrade_resolved"
+)
+
+var templateNames = []string{
+	TemplateVerifyEmail,
+	TemplateForgotPassword,
+	TemplateUnlockAccount,
+	TemplateTeacherInvite,
+	TemplateStudentInvite,
+	TemplatePPDBRegistered,
+	TemplatePPDBAccepted,
+	TemplateRegradeResolved,
+}
+
+// JobSend is the queue job that renders and sends an Email.
+const JobSend = "mail.send"
+
+//go:embed templates
+var templateFS embed.FS
+
+// Message is a rendered email with a plain text and an HTML body.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Branding is shown in the header of every email. Emails sent on behalf of
+// a school use its name and logo.
+type Branding struct {
+	Name string
+	Logo string
+}
+
+// Email asks the Sender to render Template for To. SchoolID is optional and
+// selects the branding.
+type Email struct {
+	To       string
+	Template string
+	SchoolID uuid.UUID
+	Data     Data
+}
+
+// Data is what the templates may use; fields a template does not need are
+// left empty.
+type Data struct {
+	Name    string
+	URL     string
+	Minutes int
+	Exam    string
+	Status  string
+}
+
+type emailTemplate struct {
+	text *textTemplate.Template
+	html *htmlTemplate.Template
+}
+
+type Sender struct {
+	mailer    Mailer
+	db        *sqlx.DB
+	queue     *queue.Queue
+	brand     Branding
+	templates map[string]emailTemplate
+}
+
+// NewSender parses the embedded templates. Enqueued emails go through q,
+// brand is used when an email has no school or the school has no name or
+// logo.
+func NewSender(m Mailer, db *sqlx.DB, q *queue.Queue, brand Branding) (*Sender, error) {
+	templates := make(map[string]emailTemplate, len(templateNames))
+	for _, name := range templateNames {
+		text, err := textTemplate.ParseFS(templateFS, "templates/"+name+".txt")
+		if err != nil {
+			return nil, err
+		}
+
+		html, err := htmlTemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, err
+		}
+
+		templates[name] = emailTemplate{text: text, html: html}
+	}
+
+	return &Sender{mailer: m, db: db, queue: q, brand: brand, templates: templates}, nil
+}
+
+// Mailer returns the underlying mailer, e.g. the Memory one in tests.
+func (s *Sender) Mailer() Mailer {
+	return s.mailer
+}
+
+// Render builds the message without sending it.
+func (s *Sender) Render(e Email, brand Branding) (Message, error) {
+	t, ok := s.templates[e.Template]
+	if !ok {
+		return Message{}, fmt.Errorf("mailer: unknown template %q", e.Template)
+	}
+
+	data := struct {
+		Brand Branding
+		Data  Data
+	}{brand, e.Data}
+
+	var subject, text, html strings.Builder
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      []string{e.To},
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// Send renders the email with the branding of its school and delivers it.
+func (s *Sender) Send(ctx context.Context, e Email) error {
+	msg, err := s.Render(e, s.branding(ctx, e.SchoolID))
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// Enqueue queues the email for the worker, so requests do not wait on the
+// mail server and failed sends are retried.
+func (s *Sender) Enqueue(ctx context.Context, e Email) error {
+	_, err := s.queue.Enqueue(ctx, JobSend, e)
+	return err
+}
+
+// Handle is the queue handler for JobSend.
+func (s *Sender) Handle(ctx context.Context, payload json.RawMessage) error {
+	e := Email{}
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return err
+	}
+	return s.Send(ctx, e)
+}
+
+func (s *Sender) branding(ctx context.Context, schoolID uuid.UUID) Branding {
+	brand := s.brand
+	if schoolID == uuid.Nil || s.db == nil {
+		return brand
+	}
+
+	school := struct {
+		Name string `db:"name"`
+		Logo string `db:"logo"`
+	}{}
+	err := s.db.GetContext(ctx, &school, "SELECT name, logo FROM school WHERE id = $1", schoolID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Err(err).Str("school_id", schoolID.String()).Msg("failed to get school branding")
+		}
+		return brand
+	}
+
+	if school.Name != "" {
+		brand.Name = school.Name
+	}
+	if school.Logo != "" {
+		brand.Logo = school.Logo
+	}
+	return brand
+}
@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestRenderAllTemplates(t *testing.T) {
+	s, err := NewSender(NewMemory(), nil, nil, Branding{Name: "Genesis"})
+	if err != nil {
+		t.Fatalf("new sender: %v", err)
+	}
+
+	data := Data{Name: "Budi", URL: "https://example.com/verify?token=a&email=b", Minutes: 30}
+	brand := Branding{Name: "SMA <1>", Logo: "https://example.com/logo.png"}
+	for _, name := range templateNames {
+		msg, err := s.Render(Email{To: "budi@example.com", Template: name, Data: data}, brand)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+			t.Errorf("%s: bad subject %q", name, msg.Subject)
+		}
+		if !strings.Contains(msg.HTML, "SMA &lt;1&gt;") || !strings.Contains(msg.HTML, "logo.png") {
+			t.Errorf("%s: html is missing the escaped branding", name)
+		}
+		if !strings.Contains(msg.Text, "Budi") && !strings.Contains(msg.Text, data.URL) {
+			t.Errorf("%s: text has neither the name nor the link", name)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	from := mail.Address{Name: "Genesis", Address: "no-reply@example.com"}
+	body, err := Build(from, Message{
+		To:      []string{"budi@example.com"},
+		Subject: "Selamat datang, Budi — ✓",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Selamat datang, Budi — ✓" {
+		t.Fatalf("unexpected subject %q (%v)", subject, err)
+	}
+	if msg.Header.Get("Message-ID") == "" || msg.Header.Get("Date") == "" {
+		t.Fatal("missing Message-ID or Date header")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("unexpected content type %q", msg.Header.Get("Content-Type"))
+	}
+
+	var types []string
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		b, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type")+": "+string(b))
+	}
+
+	if len(types) != 2 || !strings.Contains(types[0], "text/plain") || !strings.HasSuffix(types[1], "<p>html body</p>") {
+		t.Fatalf("unexpected parts %q", types)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	s, err := NewSender(m, nil, nil, Branding{Name: "Genesis"})
+	if err != nil {
+		t.Fatalf("new sender: %v", err)
+	}
+
+	err = s.Send(context.Background(), Email{
+		To:       "budi@example.com",
+		Template: TemplateForgotPassword,
+		Data:     Data{URL: "https://example.com/reset"},
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	msg, ok := m.Last("budi@example.com")
+	if !ok || !strings.Contains(msg.Text, "https://example.com/reset") {
+		t.Fatalf("expected the reset link, got %+v", msg)
+	}
+}
@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"context"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Log is the development mailer. It logs every message and, when dir is
+// set, also writes it there as an .eml file that mail clients can open.
+type Log struct {
+	dir  string
+	from mail.Address
+}
+
+func NewLog(dir string, from mail.Address) *Log {
+	return &Log{dir: dir, from: from}
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	event := log.Info().Strs("to", msg.To).Str("subject", msg.Subject)
+	if l.dir == "" {
+		event.Str("text", msg.Text).Msg("email")
+		return nil
+	}
+
+	body, err := Build(l.from, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(l.dir, strconv.FormatInt(time.Now().UnixNano(), 10)+".eml")
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return err
+	}
+
+	event.Str("file", path).Msg("email")
+	return nil
+}
+
+// Memory keeps sent messages for tests.
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Last returns the most recent message sent to, if any.
+func (m *Memory) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		for _, addr := range m.messages[i].To {
+			if addr == to {
+				return m.messages[i], true
+			}
+		}
+	}
+	return Message{}, false
+}
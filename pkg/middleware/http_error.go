@@ -8,7 +8,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/rs/zerolog/log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -27,6 +29,19 @@ func ErrorParser() gin.HandlerFunc {
 		}
 
 		if err := c.Errors.Last(); err != nil {
+			var rateLimitErr commonError.RateLimitError
+			if errors.As(err.Err, &rateLimitErr) {
+				seconds := int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))
+				c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+
+				response := commonHttp.NewResponse().
+					SetCode(http.StatusTooManyRequests).
+					SetMessage(rateLimitErr.Error())
+
+				c.JSON(http.StatusTooManyRequests, response)
+				return
+			}
+
 			var apiErr commonError.Error
 			if errors.As(err.Err, &apiErr) {
 				response := commonHttp.NewResponse().
@@ -2,68 +2,135 @@ package middleware
 
 import (
 	"context"
+	"enuma-elish/pkg/apikey"
+	commonError "enuma-elish/pkg/error"
 	commonHttp "enuma-elish/pkg/http"
 	"enuma-elish/pkg/jwt"
+	"enuma-elish/pkg/session"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
-func Auth(secret string) gin.HandlerFunc {
+// allowAPIKey is the gin context key set by AllowAPIKey.
+const allowAPIKey = "allow_api_key"
+
+// AllowAPIKey lets the Auth registered after it accept API keys. Routes
+// without it only accept session tokens, so a key never reaches a route
+// that does not check its scopes.
+func AllowAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(allowAPIKey, true)
+		c.Next()
+	}
+}
+
+// Auth accepts either a session access token, "Bearer {token}", or, behind
+// AllowAPIKey, an API key, "ApiKey {key}". Both end up as a *jwt.Payload in
+// the request context; for API keys the user is the key's service account
+// and RequirePermission checks the key's scopes instead of a school role.
+func Auth(keys *jwt.KeySet, sessions *session.Store, apiKeys *apikey.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			response := commonHttp.NewResponse().
-				SetCode(http.StatusUnauthorized).
-				SetMessage("Unauthorized").
-				SetErrors([]string{"Authorization header is empty"})
-
-			c.JSON(response.Code, response)
-			c.Abort()
+			unauthorized(c, "Authorization header is empty")
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			response := commonHttp.NewResponse().
-				SetCode(http.StatusUnauthorized).
-				SetMessage("Unauthorized").
-				SetErrors([]string{"Authorization format must be Bearer {token}"})
-
-			c.JSON(response.Code, response)
-			c.Abort()
+		if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "ApiKey") {
+			unauthorized(c, "Authorization format must be Bearer {token} or ApiKey {key}")
 			return
 		}
 
-		tokenStr := parts[1]
-		token, err := jwt.Verify(tokenStr, secret)
-		if err != nil {
-			response := commonHttp.NewResponse().
-				SetCode(http.StatusUnauthorized).
-				SetMessage("Unauthorized").
-				SetErrors([]string{err.Error()})
+		var claim *jwt.Payload
+		if parts[0] == "ApiKey" {
+			key, err := apiKeys.Authenticate(c.Request.Context(), parts[1], c.ClientIP())
+			if err != nil {
+				if !errors.Is(err, apikey.ErrInvalidAPIKey) {
+					log.Err(err).Msg("failed to authenticate api key")
+				}
+				unauthorized(c, apikey.ErrInvalidAPIKey.Error())
+				return
+			}
+			if !c.GetBool(allowAPIKey) {
+				c.Error(commonError.ErrForbidden)
+				c.Abort()
+				return
+			}
 
-			c.JSON(response.Code, response)
-			c.Abort()
-			return
+			claim = &jwt.Payload{
+				Jti:    key.ID.String(),
+				Typ:    jwt.TypeAPIKey,
+				Scopes: key.Scopes,
+				User: jwt.User{
+					ID:       key.UserID,
+					SchoolID: key.SchoolID,
+				},
+			}
+		} else {
+			var ok bool
+			claim, ok = sessionClaim(c, keys, sessions, parts[1])
+			if !ok {
+				return
+			}
 		}
 
-		claim, err := jwt.ExtractToken(token)
-		if err != nil {
-			response := commonHttp.NewResponse().
-				SetCode(http.StatusUnauthorized).
-				SetMessage("Unauthorized").
-				SetErrors([]string{err.Error()})
+		ctx := context.WithValue(c.Request.Context(), jwt.ContextKey, claim)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
 
-			c.JSON(response.Code, response)
+func sessionClaim(c *gin.Context, keys *jwt.KeySet, sessions *session.Store, tokenStr string) (*jwt.Payload, bool) {
+	token, err := keys.Verify(c.Request.Context(), tokenStr)
+	if err != nil {
+		if !errors.Is(err, jwt.ErrTokenInvalid) && !errors.Is(err, jwt.ErrTokenExpired) {
+			// Loading the signing keys failed, which says nothing about
+			// the token
+			log.Err(err).Msg("failed to verify token")
+			c.Error(commonError.ErrInternal)
 			c.Abort()
-			return
+			return nil, false
 		}
+		unauthorized(c, err.Error())
+		return nil, false
+	}
 
-		ctx := context.WithValue(c.Request.Context(), jwt.ContextKey, claim)
-		c.Request = c.Request.WithContext(ctx)
+	claim, err := jwt.ExtractToken(token)
+	if err != nil {
+		unauthorized(c, jwt.ErrTokenInvalid.Error())
+		return nil, false
+	}
 
-		c.Next()
+	if claim.Typ != jwt.TypeAccess {
+		unauthorized(c, jwt.ErrInvalidTokenType.Error())
+		return nil, false
 	}
+
+	sess, err := sessions.Get(c.Request.Context(), claim.Sid)
+	if err != nil || sess.UserID != claim.User.ID {
+		unauthorized(c, session.ErrSessionRevoked.Error())
+		return nil, false
+	}
+
+	if err := sessions.Touch(c.Request.Context(), sess, c.Request.UserAgent(), c.ClientIP()); err != nil {
+		log.Err(err).Str("session", sess.ID).Msg("failed to touch session")
+	}
+
+	return claim, true
+}
+
+func unauthorized(c *gin.Context, reason string) {
+	response := commonHttp.NewResponse().
+		SetCode(http.StatusUnauthorized).
+		SetMessage("Unauthorized").
+		SetErrors([]string{reason})
+
+	c.JSON(response.Code, response)
+	c.Abort()
 }
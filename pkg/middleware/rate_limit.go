@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"enuma-elish/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit caps how often a client IP may call the routes registered under
+// name within the configured window.
+func RateLimit(limiter *ratelimit.Limiter, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := limiter.Allow(c.Request.Context(), name+":ip:"+c.ClientIP())
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
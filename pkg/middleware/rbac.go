@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// School roles as stored in the school_role enum.
+const (
+	RoleAdmin       = "admin"
+	RoleHeadTeacher = "head_teacher"
+	RoleTeacher     = "teacher"
+	RoleStudent     = "student"
+)
+
+// UserRoleAdmin is the platform wide users.role that bypasses school role checks.
+const UserRoleAdmin = "admin"
+
+type Permission string
+
+const (
+	PermSchoolManage   Permission = "school.manage"
+	PermTeacherRead    Permission = "teacher.read"
+	PermTeacherManage  Permission = "teacher.manage"
+	PermStudentRead    Permission = "student.read"
+	PermStudentManage  Permission = "student.manage"
+	PermClassRead      Permission = "class.read"
+	PermClassManage    Permission = "class.manage"
+	PermSubjectRead    Permission = "subject.read"
+	PermSubjectManage  Permission = "subject.manage"
+	PermQuestionRead   Permission = "question.read"
+	PermQuestionManage Permission = "question.manage"
+	PermExamRead       Permission = "exam.read"
+	PermExamManage     Permission = "exam.manage"
+	PermExamGrade      Permission = "exam.grade"
+	PermExamTake       Permission = "exam.take"
+	PermPPDBManage     Permission = "ppdb.manage"
+)
+
+var (
+	staff      = []string{RoleAdmin, RoleHeadTeacher, RoleTeacher}
+	management = []string{RoleAdmin, RoleHeadTeacher}
+)
+
+// permissions is the single source of truth for which school roles are
+// allowed to perform an action. Routes reference a Permission instead of
+// listing roles so the matrix can be reviewed in one place.
+var permissions = map[Permission][]string{
+	PermSchoolManage:   {RoleAdmin},
+	PermTeacherRead:    staff,
+	PermTeacherManage:  management,
+	PermStudentRead:    staff,
+	PermStudentManage:  management,
+	PermClassRead:      staff,
+	PermClassManage:    management,
+	PermSubjectRead:    staff,
+	PermSubjectManage:  management,
+	PermQuestionRead:   staff,
+	PermQuestionManage: staff,
+	PermExamRead:       staff,
+	PermExamManage:     staff,
+	PermExamGrade:      staff,
+	PermExamTake:       {RoleStudent},
+	PermPPDBManage:     management,
+}
+
+// apiKeyScopes are the permissions an API key may be granted. Managing the
+// school, its users and admissions and taking exams are left to people.
+var apiKeyScopes = []Permission{
+	PermTeacherRead,
+	PermStudentRead,
+	PermClassRead,
+	PermClassManage,
+	PermSubjectRead,
+	PermSubjectManage,
+	PermQuestionRead,
+	PermQuestionManage,
+	PermExamRead,
+	PermExamManage,
+	PermExamGrade,
+}
+
+// IsAPIKeyScope reports whether an API key may be granted the permission.
+func IsAPIKeyScope(scope string) bool {
+	return slices.Contains(apiKeyScopes, Permission(scope))
+}
+
+// Can reports whether the claim is allowed to perform the permission. API
+// keys are limited to their scopes, and keys issued before a scope left
+// apiKeyScopes lose it.
+func Can(claim *jwt.Payload, p Permission) bool {
+	if claim == nil {
+		return false
+	}
+
+	if claim.Typ == jwt.TypeAPIKey {
+		return IsAPIKeyScope(string(p)) && slices.Contains(claim.Scopes, string(p))
+	}
+
+	if claim.User.UserRole == UserRoleAdmin {
+		return true
+	}
+
+	return slices.Contains(permissions[p], claim.User.SchoolRole)
+}
+
+// RequirePermission must be registered after Auth. It aborts with
+// commonError.ErrForbidden when the caller's school role lacks the permission.
+func RequirePermission(p Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claim, err := jwt.ExtractContext(c.Request.Context())
+		if err != nil {
+			c.Error(commonError.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		if !Can(claim, p) {
+			c.Error(commonError.ErrForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole is the ad hoc variant of RequirePermission for routes that do
+// not fit an entry of the permission table.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claim, err := jwt.ExtractContext(c.Request.Context())
+		if err != nil {
+			c.Error(commonError.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		if claim.User.UserRole != UserRoleAdmin && !slices.Contains(roles, claim.User.SchoolRole) {
+			c.Error(commonError.ErrForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
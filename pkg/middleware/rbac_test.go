@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"enuma-elish/pkg/jwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func claimFor(schoolRole, userRole string) *jwt.Payload {
+	return &jwt.Payload{Typ: jwt.TypeAccess, User: jwt.User{SchoolRole: schoolRole, UserRole: userRole}}
+}
+
+func TestCan(t *testing.T) {
+	// Every permission with the school roles allowed to perform it
+	want := map[Permission][]string{
+		PermSchoolManage:   {RoleAdmin},
+		PermTeacherRead:    {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermTeacherManage:  {RoleAdmin, RoleHeadTeacher},
+		PermStudentRead:    {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermStudentManage:  {RoleAdmin, RoleHeadTeacher},
+		PermClassRead:      {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermClassManage:    {RoleAdmin, RoleHeadTeacher},
+		PermSubjectRead:    {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermSubjectManage:  {RoleAdmin, RoleHeadTeacher},
+		PermQuestionRead:   {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermQuestionManage: {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermExamRead:       {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermExamManage:     {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermExamGrade:      {RoleAdmin, RoleHeadTeacher, RoleTeacher},
+		PermExamTake:       {RoleStudent},
+		PermPPDBManage:     {RoleAdmin, RoleHeadTeacher},
+	}
+	if len(want) != len(permissions) {
+		t.Fatalf("the test covers %d permissions, the table has %d", len(want), len(permissions))
+	}
+
+	roles := []string{RoleAdmin, RoleHeadTeacher, RoleTeacher, RoleStudent, "", "janitor"}
+	for p, allowed := range want {
+		for _, role := range roles {
+			expected := false
+			for _, r := range allowed {
+				expected = expected || r == role
+			}
+			if got := Can(claimFor(role, "user"), p); got != expected {
+				t.Errorf("Can(%q, %s) = %v, want %v", role, p, got, expected)
+			}
+		}
+
+		// Platform admins bypass the school roles
+		if !Can(claimFor(RoleStudent, UserRoleAdmin), p) {
+			t.Errorf("Can(platform admin, %s) = false, want true", p)
+		}
+	}
+
+	if Can(nil, PermExamRead) {
+		t.Errorf("Can without a claim = true, want false")
+	}
+	if Can(claimFor(RoleAdmin, "user"), "unknown.permission") {
+		t.Errorf("Can of an unknown permission = true, want false")
+	}
+}
+
+func TestCanAPIKey(t *testing.T) {
+	key := func(scopes ...string) *jwt.Payload {
+		// The role of the service account must not matter
+		return &jwt.Payload{Typ: jwt.TypeAPIKey, Scopes: scopes, User: jwt.User{SchoolRole: RoleAdmin, UserRole: UserRoleAdmin}}
+	}
+
+	tests := []struct {
+		name  string
+		claim *jwt.Payload
+		perm  Permission
+		want  bool
+	}{
+		{"granted scope", key("exam.read", "student.read"), PermExamRead, true},
+		{"other granted scope", key("exam.read", "student.read"), PermStudentRead, true},
+		{"scope not granted", key("exam.read"), PermExamManage, false},
+		{"no scopes", key(), PermExamRead, false},
+		{"manage does not imply read", key("class.manage"), PermClassRead, false},
+		{"school.manage is never allowed", key("school.manage"), PermSchoolManage, false},
+		{"teacher.manage is never allowed", key("teacher.manage"), PermTeacherManage, false},
+		{"student.manage is never allowed", key("student.manage"), PermStudentManage, false},
+		{"ppdb.manage is never allowed", key("ppdb.manage"), PermPPDBManage, false},
+		{"exam.take is never allowed", key("exam.take"), PermExamTake, false},
+	}
+
+	for _, tt := range tests {
+		if got := Can(tt.claim, tt.perm); got != tt.want {
+			t.Errorf("%s: Can = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsAPIKeyScope(t *testing.T) {
+	for _, scope := range []string{"teacher.read", "student.read", "class.read", "class.manage", "subject.read", "subject.manage", "question.read", "question.manage", "exam.read", "exam.manage", "exam.grade"} {
+		if !IsAPIKeyScope(scope) {
+			t.Errorf("IsAPIKeyScope(%q) = false, want true", scope)
+		}
+	}
+	for _, scope := range []string{"school.manage", "teacher.manage", "student.manage", "ppdb.manage", "exam.take", "", "exam.*"} {
+		if IsAPIKeyScope(scope) {
+			t.Errorf("IsAPIKeyScope(%q) = true, want false", scope)
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		claim *jwt.Payload
+		want  int
+	}{
+		{"allowed", claimFor(RoleTeacher, "user"), http.StatusOK},
+		{"forbidden", claimFor(RoleStudent, "user"), http.StatusForbidden},
+		{"no claim", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		r := gin.New()
+		r.Use(ErrorParser())
+		r.GET("/", func(c *gin.Context) {
+			if tt.claim != nil {
+				c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), jwt.ContextKey, tt.claim))
+			}
+			c.Next()
+		}, RequirePermission(PermExamRead), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
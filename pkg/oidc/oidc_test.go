@@ -0,0 +1,229 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIssuer is a minimal OIDC provider. It hands out one code per
+// authorization request and only redeems it with the matching PKCE verifier.
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]mockGrant
+	claims func(c *Claims)
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	m := &mockIssuer{key: key, codes: map[string]mockGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                m.URL,
+			AuthorizationEndpoint: m.URL + "/authorize",
+			TokenEndpoint:         m.URL + "/token",
+			JWKSURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jwk{{
+			Kid: "test",
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		m.mu.Lock()
+		grant, ok := m.codes[r.PostForm.Get("code")]
+		delete(m.codes, r.PostForm.Get("code"))
+		m.mu.Unlock()
+
+		if !ok || Challenge(r.PostForm.Get("code_verifier")) != grant.challenge || r.PostForm.Get("client_id") != "client" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(Token{
+			AccessToken: "access",
+			TokenType:   "Bearer",
+			IDToken:     m.idToken(t, grant.nonce),
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+// authorize does what the browser and the provider's login page would and
+// returns the code sent to the redirect URL.
+func (m *mockIssuer) authorize(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("response_type") != "code" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	code, _ := RandomString(16)
+	m.mu.Lock()
+	m.codes[code] = mockGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	m.mu.Unlock()
+
+	return code
+}
+
+func (m *mockIssuer) idToken(t *testing.T, nonce string) string {
+	now := time.Now()
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.URL,
+			Subject:   "1234567890",
+			Audience:  jwt.ClaimStrings{"client"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		Nonce:         nonce,
+		Email:         "teacher@school.sch.id",
+		EmailVerified: true,
+		Name:          "Teacher",
+	}
+	if m.claims != nil {
+		m.claims(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatalf("sign id token: %v", err)
+	}
+	return signed
+}
+
+func login(t *testing.T, m *mockIssuer, p *Provider, nonce string, verifier string) (*Claims, error) {
+	ctx := context.Background()
+
+	pkce, _ := NewVerifier()
+	authURL, err := p.AuthCodeURL(ctx, "state", nonce, pkce)
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	code := m.authorize(t, authURL)
+
+	if verifier == "" {
+		verifier = pkce
+	}
+	token, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Verify(ctx, token.IDToken, nonce)
+}
+
+func newProvider(m *mockIssuer) *Provider {
+	return New(Config{
+		Issuer:       m.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:5173/oidc/callback",
+		Scopes:       []string{"email", "profile"},
+	}, m.Client())
+}
+
+func TestLogin(t *testing.T) {
+	m := newMockIssuer(t)
+	p := newProvider(m)
+
+	claims, err := login(t, m, p, "nonce", "")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if claims.Subject != "1234567890" || claims.Email != "teacher@school.sch.id" || !claims.EmailVerified {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	m := newMockIssuer(t)
+	p := newProvider(m)
+
+	_, err := login(t, m, p, "nonce", "not-the-verifier")
+	if !errors.Is(err, ErrExchangeFailed) {
+		t.Fatalf("expected ErrExchangeFailed, got %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims func(c *Claims)
+	}{
+		{"nonce", func(c *Claims) { c.Nonce = "other" }},
+		{"audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-client"} }},
+		{"issuer", func(c *Claims) { c.Issuer = "https://evil.example.com" }},
+		{"expired", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIssuer(t)
+			m.claims = tt.claims
+			p := newProvider(m)
+
+			_, err := login(t, m, p, "nonce", "")
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	m := newMockIssuer(t)
+	p := newProvider(m)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	m.key = other
+
+	_, err = login(t, m, p, "nonce", "")
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+// Package queue is a Redis backed job queue. Jobs survive restarts of both
+// the API and the worker: a job is only removed once its handler succeeded,
+// failed jobs are retried with exponential backoff and end up in a dead
+// letter list once they run out of attempts.
+//
+// Layout, all under the "queue" prefix:
+//   - jobs: hash of job id to the JSON encoded Job
+//   - ready: list of job ids waiting for a worker
+//   - delayed: sorted set of job ids waiting for a retry, scored by due time
+//   - processing: sorted set of reserved job ids, scored by the end of their
+//     visibility timeout; expired ones are handed out again
+//   - dead: list of job ids that ran out of attempts
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const keyPrefix = "queue"
+
+var (
+	jobsKey       = keyPrefix + ":jobs"
+	readyKey      = keyPrefix + ":ready"
+	delayedKey    = keyPrefix + ":delayed"
+	processingKey = keyPrefix + ":processing"
+	deadKey       = keyPrefix + ":dead"
+)
+
+type Config struct {
+	Concurrency int
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// VisibilityTimeout is how long a reserved job may run before it is
+	// considered lost, e.g. because the worker crashed, and handed out again.
+	VisibilityTimeout time.Duration
+	PollInterval      time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Concurrency <= 0 {
+		c.Concurrency = 4
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.BackoffBase <= 0 {
+		c.BackoffBase = 10 * time.Second
+	}
+	if c.BackoffMax <= 0 {
+		c.BackoffMax = time.Hour
+	}
+	if c.VisibilityTimeout <= 0 {
+		c.VisibilityTimeout = 5 * time.Minute
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	return c
+}
+
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error,omitempty"`
+	EnqueuedAt int64           `json:"enqueued_at"`
+	FailedAt   int64           `json:"failed_at,omitempty"`
+}
+
+// Handler runs a job. Returning an error retries it later, so handlers must
+// be safe to run more than once.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type Queue struct {
+	rdb    *redis.Client
+	config Config
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func New(rdb *redis.Client, c Config) *Queue {
+	return &Queue{rdb: rdb, config: c.withDefaults(), handlers: map[string]Handler{}}
+}
+
+func (q *Queue) Config() Config {
+	return q.config
+}
+
+// Handle registers the handler for jobs of jobType. Only the worker needs
+// handlers, the API just enqueues.
+func (q *Queue) Handle(jobType string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = h
+}
+
+func (q *Queue) handler(jobType string) (Handler, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	h, ok := q.handlers[jobType]
+	return h, ok
+}
+
+// Enqueue stores a job with the JSON encoding of payload and returns its id.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	job := Job{
+		ID:         uuid.New().String(),
+		Type:       jobType,
+		Payload:    raw,
+		EnqueuedAt: time.Now().UnixMilli(),
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, jobsKey, job.ID, data)
+		pipe.LPush(ctx, readyKey, job.ID)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return job.ID, nil
+}
+
+// reserveScript moves due retries and jobs whose visibility timeout passed
+// back to ready, then pops the oldest ready job into processing.
+var reserveScript = redis.NewScript(`
+local now = ARGV[1]
+for _, key in ipairs({KEYS[2], KEYS[3]}) do
+	local ids = redis.call('ZRANGEBYSCORE', key, '-inf', now, 'LIMIT', 0, 100)
+	for _, id in ipairs(ids) do
+		redis.call('ZREM', key, id)
+		redis.call('LPUSH', KEYS[1], id)
+	end
+end
+local id = redis.call('RPOP', KEYS[1])
+if not id then
+	return false
+end
+redis.call('ZADD', KEYS[3], ARGV[2], id)
+return id
+`)
+
+// reserve returns the next job, or nil when there is none.
+func (q *Queue) reserve(ctx context.Context) (*Job, error) {
+	now := time.Now()
+	id, err := reserveScript.Run(ctx, q.rdb, []string{readyKey, delayedKey, processingKey},
+		now.UnixMilli(), now.Add(q.config.VisibilityTimeout).UnixMilli()).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	data, err := q.rdb.HGet(ctx, jobsKey, id).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			q.rdb.ZRem(ctx, processingKey, id)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+
+	// Attempts counts deliveries, so a job that keeps killing its worker
+	// still runs out of attempts.
+	job.Attempts++
+	if job.Attempts > q.config.MaxAttempts {
+		return nil, q.bury(ctx, job, "visibility timeout exceeded")
+	}
+	if err := q.save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (q *Queue) save(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.rdb.HSet(ctx, jobsKey, job.ID, data).Err()
+}
+
+func (q *Queue) ack(ctx context.Context, job *Job) error {
+	_, err := q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, processingKey, job.ID)
+		pipe.HDel(ctx, jobsKey, job.ID)
+		return nil
+	})
+	return err
+}
+
+// retry schedules the job again after the backoff, or buries it once it
+// has no attempts left.
+func (q *Queue) retry(ctx context.Context, job *Job, cause error) error {
+	if job.Attempts >= q.config.MaxAttempts {
+		return q.bury(ctx, job, cause.Error())
+	}
+
+	job.LastError = cause.Error()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	due := time.Now().Add(q.Backoff(job.Attempts)).UnixMilli()
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, jobsKey, job.ID, data)
+		pipe.ZRem(ctx, processingKey, job.ID)
+		pipe.ZAdd(ctx, delayedKey, &redis.Z{Score: float64(due), Member: job.ID})
+		return nil
+	})
+	return err
+}
+
+// bury moves the job to the dead letter list, where it stays until it is
+// retried by hand.
+func (q *Queue) bury(ctx context.Context, job *Job, reason string) error {
+	job.LastError = reason
+	job.FailedAt = time.Now().UnixMilli()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, jobsKey, job.ID, data)
+		pipe.ZRem(ctx, processingKey, job.ID)
+		pipe.LPush(ctx, deadKey, job.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Error().Str("job", job.ID).Str("type", job.Type).Int("attempts", job.Attempts).Str("reason", reason).Msg("job moved to dead letter")
+	return nil
+}
+
+// Backoff is the delay before the next try of a job that failed attempt
+// times: BackoffBase doubled for every further attempt, up to BackoffMax.
+func (q *Queue) Backoff(attempt int) time.Duration {
+	delay := q.config.BackoffBase
+	for i := 1; i < attempt && delay < q.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, q.config.BackoffMax)
+}
+
+// Run works on jobs with Concurrency workers until ctx is cancelled. Jobs
+// that are running by then are finished first.
+func (q *Queue) Run(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < q.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.reserve(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Err(err).Msg("failed to reserve job")
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(q.config.PollInterval):
+			}
+			continue
+		}
+
+		q.process(context.WithoutCancel(ctx), job)
+	}
+}
+
+func (q *Queue) process(ctx context.Context, job *Job) {
+	h, ok := q.handler(job.Type)
+	if !ok {
+		if err := q.bury(ctx, job, "no handler for job type "+job.Type); err != nil {
+			log.Err(err).Str("job", job.ID).Msg("failed to bury job")
+		}
+		return
+	}
+
+	err := q.call(ctx, h, job)
+	if err == nil {
+		if err := q.ack(ctx, job); err != nil {
+			log.Err(err).Str("job", job.ID).Msg("failed to ack job")
+		}
+		return
+	}
+
+	log.Warn().Err(err).Str("job", job.ID).Str("type", job.Type).Int("attempt", job.Attempts).Msg("job failed")
+	if err := q.retry(ctx, job, err); err != nil {
+		log.Err(err).Str("job", job.ID).Msg("failed to retry job")
+	}
+}
+
+// call runs the handler within the visibility timeout and turns a panic
+// into an error.
+func (q *Queue) call(ctx context.Context, h Handler, job *Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, q.config.VisibilityTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return h(ctx, job.Payload)
+}
+
+// Dead returns up to limit jobs from the dead letter list, newest first.
+func (q *Queue) Dead(ctx context.Context, limit int64) ([]Job, error) {
+	ids, err := q.rdb.LRange(ctx, deadKey, 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []Job{}, nil
+	}
+
+	values, err := q.rdb.HMGet(ctx, jobsKey, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(values))
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		job := Job{}
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Retry moves a dead job back to ready with fresh attempts and reports
+// false when id is not in the dead letter list.
+func (q *Queue) Retry(ctx context.Context, id string) (bool, error) {
+	n, err := q.rdb.LRem(ctx, deadKey, 0, id).Result()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	data, err := q.rdb.HGet(ctx, jobsKey, id).Bytes()
+	if err != nil {
+		return false, err
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return false, err
+	}
+	job.Attempts = 0
+	job.FailedAt = 0
+
+	if err := q.save(ctx, job); err != nil {
+		return false, err
+	}
+	if err := q.rdb.LPush(ctx, readyKey, id).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
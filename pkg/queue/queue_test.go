@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"enuma-elish/config"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestBackoff(t *testing.T) {
+	q := New(nil, Config{BackoffBase: time.Second, BackoffMax: 10 * time.Second})
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := q.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+// testRedis connects to the Redis of config.json and skips the test when
+// there is none.
+func testRedis(t *testing.T) *redis.Client {
+	c, err := config.New("../../config.json")
+	if err != nil {
+		t.Skipf("no config: %v", err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", c.Redis.Host, c.Redis.Port),
+		Username: c.Redis.Username,
+		Password: c.Redis.Password,
+		DB:       c.Redis.Database,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("no redis: %v", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Del(context.Background(), jobsKey, readyKey, delayedKey, processingKey, deadKey)
+		rdb.Close()
+	})
+	return rdb
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	rdb := testRedis(t)
+	ctx := context.Background()
+
+	q := New(rdb, Config{
+		Concurrency:  1,
+		MaxAttempts:  3,
+		BackoffBase:  time.Millisecond,
+		BackoffMax:   time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	})
+
+	done := make(chan string, 10)
+	calls := map[string]int{}
+	q.Handle("flaky", func(ctx context.Context, payload json.RawMessage) error {
+		var name string
+		if err := json.Unmarshal(payload, &name); err != nil {
+			return err
+		}
+		calls[name]++
+		if calls[name] < 2 {
+			return errors.New("try again")
+		}
+		done <- name
+		return nil
+	})
+	q.Handle("broken", func(ctx context.Context, payload json.RawMessage) error {
+		panic("broken")
+	})
+
+	flaky, err := q.Enqueue(ctx, "flaky", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken, err := q.Enqueue(ctx, "broken", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(runCtx)
+		close(stopped)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("flaky job did not succeed")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var jobs []Job
+	for time.Now().Before(deadline) {
+		jobs, err = q.Dead(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-stopped
+
+	if len(jobs) != 1 || jobs[0].ID != broken {
+		t.Fatalf("dead jobs = %+v, want only %s", jobs, broken)
+	}
+	if jobs[0].Attempts != 3 || jobs[0].LastError != "panic: broken" {
+		t.Errorf("dead job = %+v", jobs[0])
+	}
+
+	if n := rdb.HExists(ctx, jobsKey, flaky).Val(); n {
+		t.Errorf("succeeded job %s was not removed", flaky)
+	}
+
+	ok, err := q.Retry(ctx, broken)
+	if err != nil || !ok {
+		t.Fatalf("Retry = %v, %v", ok, err)
+	}
+	if n := rdb.LLen(ctx, readyKey).Val(); n != 1 {
+		t.Errorf("ready jobs = %d, want 1", n)
+	}
+}
+
+func TestVisibilityTimeout(t *testing.T) {
+	rdb := testRedis(t)
+	ctx := context.Background()
+
+	q := New(rdb, Config{MaxAttempts: 2, VisibilityTimeout: 50 * time.Millisecond})
+
+	id, err := q.Enqueue(ctx, "lost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A worker reserves the job and dies.
+	job, err := q.reserve(ctx)
+	if err != nil || job == nil || job.ID != id {
+		t.Fatalf("reserve = %+v, %v", job, err)
+	}
+	if job, _ := q.reserve(ctx); job != nil {
+		t.Fatalf("job %s handed out twice within the visibility timeout", job.ID)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	job, err = q.reserve(ctx)
+	if err != nil || job == nil || job.ID != id || job.Attempts != 2 {
+		t.Fatalf("reserve after timeout = %+v, %v", job, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if job, _ := q.reserve(ctx); job != nil {
+		t.Fatalf("job %s handed out after running out of attempts", job.ID)
+	}
+	if n := rdb.LLen(ctx, deadKey).Val(); n != 1 {
+		t.Errorf("dead jobs = %d, want 1", n)
+	}
+}
@@ -0,0 +1,217 @@
+// Package ratelimit throttles credential guessing with Redis sliding windows.
+// Limiter.Allow caps plain request rates, e.g. per IP, while a Guard tracks
+// failed attempts per account and adds progressive delays and a temporary
+// lockout on top.
+package ratelimit
+
+import (
+	"context"
+	commonError "enuma-elish/pkg/error"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const keyPrefix = "ratelimit"
+
+const (
+	msgTooManyRequests = "too many requests, try again later"
+	msgAccountLocked   = "too many failed attempts, account is temporarily locked"
+)
+
+type Config struct {
+	Window          time.Duration
+	IPLimit         int
+	DelayAfter      int
+	DelayBase       time.Duration
+	DelayMax        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Window <= 0 {
+		c.Window = 15 * time.Minute
+	}
+	if c.IPLimit <= 0 {
+		c.IPLimit = 30
+	}
+	if c.DelayAfter <= 0 {
+		c.DelayAfter = 3
+	}
+	if c.DelayBase <= 0 {
+		c.DelayBase = time.Second
+	}
+	if c.DelayMax <= 0 {
+		c.DelayMax = time.Minute
+	}
+	if c.LockoutAfter <= 0 {
+		c.LockoutAfter = 10
+	}
+	if c.LockoutDuration <= 0 {
+		c.LockoutDuration = 30 * time.Minute
+	}
+	return c
+}
+
+type Limiter struct {
+	rdb    *redis.Client
+	config Config
+}
+
+func New(rdb *redis.Client, c Config) *Limiter {
+	return &Limiter{rdb: rdb, config: c.withDefaults()}
+}
+
+func (l *Limiter) Config() Config {
+	return l.config
+}
+
+// slidingWindowScript trims entries older than the window and only records
+// the new one while the limit is not reached. It returns the number of
+// entries and, when limited, the milliseconds until the oldest one expires.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if limit > 0 and count >= limit then
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	return {count, tonumber(oldest[2]) + window - now}
+end
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], window)
+return {count + 1, 0}
+`)
+
+func (l *Limiter) hit(ctx context.Context, key string, limit int, window time.Duration) (int, time.Duration, error) {
+	res, err := slidingWindowScript.Run(ctx, l.rdb, []string{key},
+		time.Now().UnixMilli(), window.Milliseconds(), limit, uuid.New().String()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(res[0]), time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// Allow records a request of key and rejects it with a RateLimitError once
+// the configured IP limit is reached within the window.
+func (l *Limiter) Allow(ctx context.Context, key string) error {
+	_, retryAfter, err := l.hit(ctx, keyPrefix+":"+key, l.config.IPLimit, l.config.Window)
+	if err != nil {
+		return err
+	}
+
+	if retryAfter > 0 {
+		return commonError.NewRateLimit(msgTooManyRequests, retryAfter)
+	}
+	return nil
+}
+
+// Guard tracks failed attempts of one kind of credential, e.g. "login".
+type Guard struct {
+	limiter *Limiter
+	name    string
+}
+
+func (l *Limiter) Guard(name string) *Guard {
+	return &Guard{limiter: l, name: name}
+}
+
+// LockoutDuration is how long id stays locked once Fail reported a lockout.
+func (g *Guard) LockoutDuration() time.Duration {
+	return g.limiter.config.LockoutDuration
+}
+
+func (g *Guard) failKey(id string) string {
+	return keyPrefix + ":" + g.name + ":fail:" + id
+}
+
+func (g *Guard) lockKey(id string) string {
+	return keyPrefix + ":" + g.name + ":lock:" + id
+}
+
+// Check rejects the attempt while id is locked or still has to wait out the
+// delay earned by its previous failures.
+func (g *Guard) Check(ctx context.Context, id string) error {
+	c := g.limiter.config
+
+	ttl, err := g.limiter.rdb.PTTL(ctx, g.lockKey(id)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return commonError.NewRateLimit(msgAccountLocked, ttl)
+	}
+
+	key := g.failKey(id)
+	now := time.Now()
+	failures, err := g.limiter.rdb.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min: formatMillis(now.Add(-c.Window)),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return err
+	}
+	if len(failures) < c.DelayAfter {
+		return nil
+	}
+
+	last := time.UnixMilli(int64(failures[0].Score))
+	wait := Backoff(len(failures)-c.DelayAfter, c.DelayBase, c.DelayMax)
+	if retryAfter := last.Add(wait).Sub(now); retryAfter > 0 {
+		return commonError.NewRateLimit(msgTooManyRequests, retryAfter)
+	}
+
+	return nil
+}
+
+// Fail records a failed attempt. It reports true when this failure locked
+// id, so the caller can notify the owner once.
+func (g *Guard) Fail(ctx context.Context, id string) (bool, error) {
+	c := g.limiter.config
+
+	count, _, err := g.limiter.hit(ctx, g.failKey(id), 0, c.Window)
+	if err != nil {
+		return false, err
+	}
+
+	if count < c.LockoutAfter {
+		return false, nil
+	}
+
+	locked, err := g.limiter.rdb.SetNX(ctx, g.lockKey(id), 1, c.LockoutDuration).Result()
+	if err != nil {
+		return false, err
+	}
+	return locked, nil
+}
+
+// Reset forgets the failures of id, e.g. after a successful login.
+func (g *Guard) Reset(ctx context.Context, id string) error {
+	return g.limiter.rdb.Del(ctx, g.failKey(id)).Err()
+}
+
+// Unlock lifts a lockout and forgets the failures that caused it.
+func (g *Guard) Unlock(ctx context.Context, id string) error {
+	return g.limiter.rdb.Del(ctx, g.lockKey(id), g.failKey(id)).Err()
+}
+
+// Backoff doubles base for every failure past the free ones, capped at max.
+func Backoff(n int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 0; i < n && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
+
+func formatMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
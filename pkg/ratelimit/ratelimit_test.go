@@ -0,0 +1,219 @@
+package ratelimit
+
+import (
+	"context"
+	"enuma-elish/config"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for n, w := range want {
+		if got := Backoff(n, time.Second, 10*time.Second); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", n, got, w)
+		}
+	}
+
+	if got := Backoff(0, time.Minute, time.Second); got != time.Second {
+		t.Errorf("Backoff above max = %s, want the max", got)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	c := Config{IPLimit: 5}.withDefaults()
+	if c.IPLimit != 5 || c.Window != 15*time.Minute || c.LockoutAfter != 10 || c.LockoutDuration != 30*time.Minute {
+		t.Fatalf("withDefaults = %+v", c)
+	}
+}
+
+// testRedis connects to the Redis of config.json and skips the test when
+// there is none.
+func testRedis(t *testing.T) *redis.Client {
+	c, err := config.New("../../config.json")
+	if err != nil {
+		t.Skipf("no config: %v", err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", c.Redis.Host, c.Redis.Port),
+		Username: c.Redis.Username,
+		Password: c.Redis.Password,
+		DB:       c.Redis.Database,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("no redis: %v", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Close()
+	})
+	return rdb
+}
+
+// testID returns a key no other test run uses and deletes what the test
+// stored under it.
+func testID(t *testing.T, rdb *redis.Client, names ...string) string {
+	id := uuid.New().String()
+	t.Cleanup(func() {
+		keys := []string{keyPrefix + ":" + id}
+		for _, name := range names {
+			g := &Guard{name: name}
+			keys = append(keys, g.failKey(id), g.lockKey(id))
+		}
+		rdb.Del(context.Background(), keys...)
+	})
+	return id
+}
+
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var rateLimitErr commonError.RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("got %v, want a RateLimitError", err)
+	}
+	return rateLimitErr.RetryAfter
+}
+
+func TestAllowSlidingWindow(t *testing.T) {
+	rdb := testRedis(t)
+	ctx := context.Background()
+	l := New(rdb, Config{IPLimit: 3, Window: 500 * time.Millisecond})
+	key := testID(t, rdb)
+
+	for i := range 3 {
+		if err := l.Allow(ctx, key); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+
+	wait := retryAfter(t, l.Allow(ctx, key))
+	if wait <= 0 || wait > 500*time.Millisecond {
+		t.Fatalf("retry after %s, want within the window", wait)
+	}
+
+	// Rejected requests are not recorded, so the window slides past the
+	// first ones
+	time.Sleep(wait + 50*time.Millisecond)
+	if err := l.Allow(ctx, key); err != nil {
+		t.Fatalf("request after the window: %v", err)
+	}
+
+	if err := l.Allow(ctx, uuid.New().String()); err != nil {
+		t.Fatalf("other key: %v", err)
+	}
+}
+
+func TestGuardLockout(t *testing.T) {
+	rdb := testRedis(t)
+	ctx := context.Background()
+	g := New(rdb, Config{Window: time.Minute, DelayAfter: 100, LockoutAfter: 3, LockoutDuration: 300 * time.Millisecond}).Guard("test")
+	id := testID(t, rdb, "test")
+
+	for i := range 2 {
+		locked, err := g.Fail(ctx, id)
+		if err != nil || locked {
+			t.Fatalf("failure %d = %v, %v, want no lockout", i+1, locked, err)
+		}
+		if err := g.Check(ctx, id); err != nil {
+			t.Fatalf("Check after failure %d: %v", i+1, err)
+		}
+	}
+
+	locked, err := g.Fail(ctx, id)
+	if err != nil || !locked {
+		t.Fatalf("third failure = %v, %v, want a lockout", locked, err)
+	}
+	if wait := retryAfter(t, g.Check(ctx, id)); wait <= 0 || wait > g.LockoutDuration() {
+		t.Fatalf("locked retry after %s, want within the lockout", wait)
+	}
+
+	// Only the failure that locked reports it
+	if locked, err := g.Fail(ctx, id); err != nil || locked {
+		t.Fatalf("failure while locked = %v, %v, want no new lockout", locked, err)
+	}
+
+	if err := g.Check(ctx, uuid.New().String()); err != nil {
+		t.Fatalf("Check of another id: %v", err)
+	}
+
+	time.Sleep(g.LockoutDuration() + 50*time.Millisecond)
+	if err := g.Check(ctx, id); err != nil {
+		t.Fatalf("Check after the lockout expired: %v", err)
+	}
+
+	// The failures are still in the window, so the next one locks again
+	if locked, err := g.Fail(ctx, id); err != nil || !locked {
+		t.Fatalf("failure after the lockout = %v, %v, want a lockout", locked, err)
+	}
+}
+
+func TestGuardDelay(t *testing.T) {
+	rdb := testRedis(t)
+	ctx := context.Background()
+	g := New(rdb, Config{Window: time.Minute, DelayAfter: 2, DelayBase: 200 * time.Millisecond, DelayMax: time.Second, LockoutAfter: 100}).Guard("test")
+	id := testID(t, rdb, "test")
+
+	g.Fail(ctx, id)
+	if err := g.Check(ctx, id); err != nil {
+		t.Fatalf("Check before the delay starts: %v", err)
+	}
+
+	g.Fail(ctx, id)
+	if wait := retryAfter(t, g.Check(ctx, id)); wait <= 0 || wait > 200*time.Millisecond {
+		t.Fatalf("retry after %s, want up to the base delay", wait)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if err := g.Check(ctx, id); err != nil {
+		t.Fatalf("Check after the delay: %v", err)
+	}
+
+	g.Fail(ctx, id)
+	if wait := retryAfter(t, g.Check(ctx, id)); wait <= 200*time.Millisecond || wait > 400*time.Millisecond {
+		t.Fatalf("retry after %s, want the doubled delay", wait)
+	}
+}
+
+func TestGuardReset(t *testing.T) {
+	rdb := testRedis(t)
+	ctx := context.Background()
+	g := New(rdb, Config{Window: time.Minute, DelayAfter: 2, DelayBase: time.Minute, LockoutAfter: 3, LockoutDuration: time.Minute}).Guard("test")
+	id := testID(t, rdb, "test")
+
+	g.Fail(ctx, id)
+	g.Fail(ctx, id)
+	retryAfter(t, g.Check(ctx, id))
+
+	if err := g.Reset(ctx, id); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if err := g.Check(ctx, id); err != nil {
+		t.Fatalf("Check after Reset: %v", err)
+	}
+
+	// Resetting keeps a lockout, unlocking lifts it
+	for range 3 {
+		g.Fail(ctx, id)
+	}
+	if err := g.Reset(ctx, id); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	retryAfter(t, g.Check(ctx, id))
+
+	if err := g.Unlock(ctx, id); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := g.Check(ctx, id); err != nil {
+		t.Fatalf("Check after Unlock: %v", err)
+	}
+	if locked, err := g.Fail(ctx, id); err != nil || locked {
+		t.Fatalf("failure after Unlock = %v, %v, want the failures forgotten", locked, err)
+	}
+}
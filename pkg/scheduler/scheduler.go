@@ -0,0 +1,268 @@
+// Package scheduler runs periodic jobs described by cron expressions. Every
+// API replica runs the scheduler, Redis decides which one runs a job:
+//   - tick:<job>:<unix>: claimed with SET NX by the first replica to reach a
+//     scheduled minute, so clock skew between replicas can not run it twice
+//   - lock:<job>: held while the job runs, a run that is still going when
+//     the next one is due makes the next one skip instead of overlapping
+//   - job:<job>: hash with the outcome of the last run and the number of
+//     consecutive failures
+//
+// Jobs register themselves from the Init() of their module; they run without
+// claims, so repositories see them as a background context.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const keyPrefix = "scheduler"
+
+// tickTTL only has to outlive the clock skew between replicas.
+const tickTTL = time.Hour
+
+type Config struct {
+	// Timeout bounds a single run; the job lock expires with it.
+	Timeout time.Duration
+	// Location the cron expressions are evaluated in.
+	Location *time.Location
+}
+
+func (c Config) withDefaults() Config {
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Minute
+	}
+	if c.Location == nil {
+		c.Location = time.UTC
+	}
+	return c
+}
+
+// Func is a scheduled job. A failed run is not retried, the job simply runs
+// again at its next scheduled time.
+type Func func(ctx context.Context) error
+
+type job struct {
+	name     string
+	schedule *Schedule
+	fn       Func
+}
+
+// Status is a registered job and the outcome of its last run. Times are unix
+// milliseconds, zero when the job has not run yet.
+type Status struct {
+	Name           string
+	Spec           string
+	Running        bool
+	LastRunAt      int64
+	LastSuccessAt  int64
+	LastDurationMs int64
+	LastError      string
+	Failures       int64
+	NextRunAt      int64
+}
+
+type Scheduler struct {
+	rdb    *redis.Client
+	config Config
+
+	mu   sync.RWMutex
+	jobs []*job
+}
+
+func New(rdb *redis.Client, c Config) *Scheduler {
+	return &Scheduler{rdb: rdb, config: c.withDefaults()}
+}
+
+// Register adds a job. It panics on a duplicate name or an invalid spec, both
+// are programming errors that should stop the API from starting.
+func (s *Scheduler) Register(name, spec string, fn Func) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		panic(fmt.Sprintf("scheduler: job %s: %v", name, err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.name == name {
+			panic(fmt.Sprintf("scheduler: job %s registered twice", name))
+		}
+	}
+	s.jobs = append(s.jobs, &job{name: name, schedule: schedule, fn: fn})
+}
+
+func (s *Scheduler) registered() []*job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*job(nil), s.jobs...)
+}
+
+// Run wakes up at the start of every minute and starts the jobs due in it
+// until ctx is canceled.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Info().Int("jobs", len(s.registered())).Msg("scheduler started")
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		tick := time.Now().In(s.config.Location).Truncate(time.Minute).Add(time.Minute)
+		timer := time.NewTimer(time.Until(tick))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, j := range s.registered() {
+			if !j.schedule.Match(tick) {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.run(ctx, j, tick)
+			}()
+		}
+	}
+}
+
+// releaseScript deletes the lock only while it still belongs to this run.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (s *Scheduler) run(ctx context.Context, j *job, tick time.Time) {
+	logger := log.With().Str("job", j.name).Logger()
+
+	tickKey := keyPrefix + ":tick:" + j.name + ":" + strconv.FormatInt(tick.Unix(), 10)
+	claimed, err := s.rdb.SetNX(ctx, tickKey, 1, tickTTL).Result()
+	if err != nil {
+		logger.Err(err).Msg("failed to claim scheduled job")
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	lockKey := keyPrefix + ":lock:" + j.name
+	token := uuid.New().String()
+	locked, err := s.rdb.SetNX(ctx, lockKey, token, s.config.Timeout).Result()
+	if err != nil {
+		logger.Err(err).Msg("failed to lock scheduled job")
+		return
+	}
+	if !locked {
+		logger.Warn().Msg("previous run of scheduled job still in progress, skipped")
+		return
+	}
+	defer func() {
+		if err := releaseScript.Run(context.WithoutCancel(ctx), s.rdb, []string{lockKey}, token).Err(); err != nil {
+			logger.Err(err).Msg("failed to release scheduled job lock")
+		}
+	}()
+
+	start := time.Now()
+	err = s.call(ctx, j)
+	duration := time.Since(start)
+
+	if err != nil {
+		logger.Err(err).Dur("duration", duration).Msg("scheduled job failed")
+	} else {
+		logger.Info().Dur("duration", duration).Msg("scheduled job finished")
+	}
+
+	if err := s.record(context.WithoutCancel(ctx), j, start, duration, err); err != nil {
+		logger.Err(err).Msg("failed to record scheduled job run")
+	}
+}
+
+// call runs the job within the timeout and turns a panic into an error.
+func (s *Scheduler) call(ctx context.Context, j *job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return j.fn(ctx)
+}
+
+func (s *Scheduler) record(ctx context.Context, j *job, start time.Time, duration time.Duration, runErr error) error {
+	key := keyPrefix + ":job:" + j.name
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key,
+			"last_run_at", start.UnixMilli(),
+			"last_duration_ms", duration.Milliseconds(),
+		)
+		if runErr != nil {
+			pipe.HSet(ctx, key, "last_error", runErr.Error())
+			pipe.HIncrBy(ctx, key, "failures", 1)
+		} else {
+			pipe.HSet(ctx, key, "last_error", "", "last_success_at", time.Now().UnixMilli(), "failures", 0)
+		}
+		return nil
+	})
+	return err
+}
+
+// Jobs returns the registered jobs in registration order.
+func (s *Scheduler) Jobs(ctx context.Context) ([]Status, error) {
+	jobs := s.registered()
+
+	states := make([]*redis.StringStringMapCmd, len(jobs))
+	locks := make([]*redis.IntCmd, len(jobs))
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, j := range jobs {
+			states[i] = pipe.HGetAll(ctx, keyPrefix+":job:"+j.name)
+			locks[i] = pipe.Exists(ctx, keyPrefix+":lock:"+j.name)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	now := time.Now().In(s.config.Location)
+	statuses := make([]Status, 0, len(jobs))
+	for i, j := range jobs {
+		state := states[i].Val()
+		status := Status{
+			Name:           j.name,
+			Spec:           j.schedule.String(),
+			Running:        locks[i].Val() > 0,
+			LastRunAt:      parseInt(state["last_run_at"]),
+			LastSuccessAt:  parseInt(state["last_success_at"]),
+			LastDurationMs: parseInt(state["last_duration_ms"]),
+			LastError:      state["last_error"],
+			Failures:       parseInt(state["failures"]),
+		}
+		if next := j.schedule.Next(now); !next.IsZero() {
+			status.NextRunAt = next.UnixMilli()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"enuma-elish/config"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// testRedis connects to the Redis of config.json and skips the test when
+// there is none.
+func testRedis(t *testing.T, jobs ...string) *redis.Client {
+	c, err := config.New("../../config.json")
+	if err != nil {
+		t.Skipf("no config: %v", err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", c.Redis.Host, c.Redis.Port),
+		Username: c.Redis.Username,
+		Password: c.Redis.Password,
+		DB:       c.Redis.Database,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("no redis: %v", err)
+	}
+
+	clean := func() {
+		for _, name := range jobs {
+			keys, _ := rdb.Keys(context.Background(), keyPrefix+":*:"+name+"*").Result()
+			if len(keys) > 0 {
+				rdb.Del(context.Background(), keys...)
+			}
+		}
+	}
+	clean()
+	t.Cleanup(func() {
+		clean()
+		rdb.Close()
+	})
+	return rdb
+}
+
+func TestRunOncePerTick(t *testing.T) {
+	rdb := testRedis(t, "test.once")
+	ctx := context.Background()
+
+	var runs atomic.Int32
+	replicas := []*Scheduler{New(rdb, Config{}), New(rdb, Config{})}
+	for _, s := range replicas {
+		s.Register("test.once", "* * * * *", func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+	}
+
+	tick := time.Now().Truncate(time.Minute)
+	for _, s := range replicas {
+		s.run(ctx, s.registered()[0], tick)
+	}
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("runs = %d, want 1", got)
+	}
+
+	replicas[1].run(ctx, replicas[1].registered()[0], tick.Add(time.Minute))
+	if got := runs.Load(); got != 2 {
+		t.Fatalf("runs after the next tick = %d, want 2", got)
+	}
+}
+
+func TestJobsStatus(t *testing.T) {
+	rdb := testRedis(t, "test.status")
+	ctx := context.Background()
+
+	fail := true
+	s := New(rdb, Config{})
+	s.Register("test.status", "@hourly", func(ctx context.Context) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	j := s.registered()[0]
+	tick := time.Now().Truncate(time.Minute)
+
+	s.run(ctx, j, tick)
+	s.run(ctx, j, tick.Add(time.Minute))
+
+	jobs, err := s.Jobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("jobs = %d, want 1", len(jobs))
+	}
+	if got := jobs[0]; got.Failures != 2 || got.LastError != "boom" || got.LastSuccessAt != 0 || got.Running {
+		t.Fatalf("status after two failures = %+v", got)
+	}
+	if jobs[0].NextRunAt <= time.Now().UnixMilli() {
+		t.Errorf("next run %d is not in the future", jobs[0].NextRunAt)
+	}
+
+	fail = false
+	s.run(ctx, j, tick.Add(2*time.Minute))
+
+	jobs, err = s.Jobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jobs[0]; got.Failures != 0 || got.LastError != "" || got.LastSuccessAt == 0 {
+		t.Fatalf("status after a success = %+v", got)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	s := New(nil, Config{})
+	s.Register("test.dup", "@daily", func(ctx context.Context) error { return nil })
+
+	for name, spec := range map[string]string{"test.dup": "@daily", "test.bad": "61 * * * *"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q, %q) did not panic", name, spec)
+				}
+			}()
+			s.Register(name, spec, func(ctx context.Context) error { return nil })
+		}()
+	}
+}
@@ -0,0 +1,294 @@
+package session
+
+import (
+	"context"
+	commonError "enuma-elish/pkg/error"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	SessionKey     = "session"
+	UserSessionKey = "user:sessions"
+)
+
+// touchInterval limits how often last seen is written back to Redis.
+const touchInterval = time.Minute
+
+var (
+	ErrSessionRevoked     = commonError.New("session has been revoked", http.StatusUnauthorized)
+	ErrRefreshTokenReused = commonError.New("refresh token was already used, session has been revoked", http.StatusUnauthorized)
+)
+
+type Session struct {
+	ID         string    `redis:"id" json:"id"`
+	UserID     uuid.UUID `redis:"-" json:"user_id"`
+	UserAgent  string    `redis:"user_agent" json:"user_agent"`
+	IP         string    `redis:"ip" json:"ip"`
+	CreatedAt  int64     `redis:"created_at" json:"created_at"`
+	LastSeenAt int64     `redis:"last_seen_at" json:"last_seen_at"`
+	ExpiresAt  int64     `redis:"expires_at" json:"expires_at"`
+	// RefreshID is the jti of the only refresh token of the session that may
+	// still be exchanged.
+	RefreshID string `redis:"refresh_id" json:"-"`
+	// MFAVerifiedAt is when the user last passed a second factor in this
+	// session, 0 if never.
+	MFAVerifiedAt int64 `redis:"mfa_verified_at" json:"-"`
+}
+
+type Store struct {
+	rdb *redis.Client
+}
+
+func New(rdb *redis.Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+func sessionKey(id string) string {
+	return SessionKey + ":" + id
+}
+
+func userSessionKey(userID uuid.UUID) string {
+	return UserSessionKey + ":" + userID.String()
+}
+
+// Create starts a new session for the user that lives for ttl.
+func (s *Store) Create(ctx context.Context, userID uuid.UUID, userAgent, ip string, ttl time.Duration) (*Session, error) {
+	now := time.Now()
+	sess := &Session{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now.UnixMilli(),
+		LastSeenAt: now.UnixMilli(),
+		ExpiresAt:  now.Add(ttl).UnixMilli(),
+		RefreshID:  uuid.New().String(),
+	}
+
+	key := sessionKey(sess.ID)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key,
+			"id", sess.ID,
+			"user_id", userID.String(),
+			"user_agent", sess.UserAgent,
+			"ip", sess.IP,
+			"created_at", sess.CreatedAt,
+			"last_seen_at", sess.LastSeenAt,
+			"expires_at", sess.ExpiresAt,
+			"refresh_id", sess.RefreshID,
+		)
+		pipe.Expire(ctx, key, ttl)
+		pipe.SAdd(ctx, userSessionKey(userID), sess.ID)
+		pipe.Expire(ctx, userSessionKey(userID), ttl)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+// Get returns ErrSessionRevoked when the session expired or was revoked.
+func (s *Store) Get(ctx context.Context, id string) (*Session, error) {
+	if id == "" {
+		return nil, ErrSessionRevoked
+	}
+
+	cmd := s.rdb.HGetAll(ctx, sessionKey(id))
+	values, err := cmd.Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrSessionRevoked
+	}
+
+	sess := &Session{}
+	if err := cmd.Scan(sess); err != nil {
+		return nil, err
+	}
+
+	sess.UserID, err = uuid.Parse(values["user_id"])
+	if err != nil {
+		return nil, ErrSessionRevoked
+	}
+
+	return sess, nil
+}
+
+// touchScript only updates sessions that still exist so a concurrent
+// revoke is never undone.
+var touchScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HSET', KEYS[1], 'last_seen_at', ARGV[1], 'ip', ARGV[2], 'user_agent', ARGV[3])
+end
+return 0
+`)
+
+// Touch records activity on the session, at most once per touchInterval.
+func (s *Store) Touch(ctx context.Context, sess *Session, userAgent, ip string) error {
+	now := time.Now()
+	if now.Sub(time.UnixMilli(sess.LastSeenAt)) < touchInterval {
+		return nil
+	}
+
+	return touchScript.Run(ctx, s.rdb, []string{sessionKey(sess.ID)}, now.UnixMilli(), ip, userAgent).Err()
+}
+
+// verifyMFAScript, like touchScript, leaves revoked sessions deleted.
+var verifyMFAScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HSET', KEYS[1], 'mfa_verified_at', ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// VerifyMFA records that the user passed a second factor in the session.
+// Switching into a school role that requires MFA needs it.
+func (s *Store) VerifyMFA(ctx context.Context, sess *Session) error {
+	now := time.Now().UnixMilli()
+	res, err := verifyMFAScript.Run(ctx, s.rdb, []string{sessionKey(sess.ID)}, now).Int()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrSessionRevoked
+	}
+
+	sess.MFAVerifiedAt = now
+	return nil
+}
+
+// rotateScript swaps the refresh ID of a session. When ARGV[1] is set it must
+// match the stored refresh ID; a mismatch means an old refresh token was
+// replayed and the whole session is deleted.
+var rotateScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'refresh_id')
+if not current then
+	return 0
+end
+if ARGV[1] ~= '' and current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	redis.call('SREM', KEYS[2], ARGV[5])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'refresh_id', ARGV[2], 'expires_at', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('PEXPIRE', KEYS[2], ARGV[4])
+return 1
+`)
+
+// Rotate exchanges the refresh ID presented by the client for a new one and
+// extends the session by ttl. Presenting a refresh ID that was already
+// rotated revokes the session and returns ErrRefreshTokenReused.
+func (s *Store) Rotate(ctx context.Context, sess *Session, presented string, ttl time.Duration) (string, error) {
+	if presented == "" {
+		return "", ErrRefreshTokenReused
+	}
+	return s.rotate(ctx, sess, presented, ttl)
+}
+
+// Reissue replaces the refresh ID without checking the current one, which
+// invalidates the refresh token the client holds. It is used when new tokens
+// are minted from an access token, e.g. when switching school.
+func (s *Store) Reissue(ctx context.Context, sess *Session, ttl time.Duration) (string, error) {
+	return s.rotate(ctx, sess, "", ttl)
+}
+
+func (s *Store) rotate(ctx context.Context, sess *Session, presented string, ttl time.Duration) (string, error) {
+	next := uuid.New().String()
+	keys := []string{sessionKey(sess.ID), userSessionKey(sess.UserID)}
+	expiresAt := time.Now().Add(ttl).UnixMilli()
+
+	res, err := rotateScript.Run(ctx, s.rdb, keys, presented, next, expiresAt, ttl.Milliseconds(), sess.ID).Int()
+	if err != nil {
+		return "", err
+	}
+
+	switch res {
+	case 0:
+		return "", ErrSessionRevoked
+	case -1:
+		return "", ErrRefreshTokenReused
+	}
+
+	sess.RefreshID = next
+	sess.ExpiresAt = expiresAt
+	return next, nil
+}
+
+// List returns the active sessions of a user, most recently used first.
+func (s *Store) List(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	ids, err := s.rdb.SMembers(ctx, userSessionKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(ids))
+	var stale []interface{}
+	for _, id := range ids {
+		sess, err := s.Get(ctx, id)
+		if errors.Is(err, ErrSessionRevoked) {
+			stale = append(stale, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *sess)
+	}
+
+	if len(stale) > 0 {
+		s.rdb.SRem(ctx, userSessionKey(userID), stale...)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt > sessions[j].LastSeenAt
+	})
+
+	return sessions, nil
+}
+
+// Revoke ends a single session of the user. Sessions of other users are
+// reported as revoked without being touched.
+func (s *Store) Revoke(ctx context.Context, userID uuid.UUID, id string) error {
+	sess, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if sess.UserID != userID {
+		return ErrSessionRevoked
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(id))
+		pipe.SRem(ctx, userSessionKey(userID), id)
+		return nil
+	})
+	return err
+}
+
+// RevokeAll ends every session of the user.
+func (s *Store) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	ids, err := s.rdb.SMembers(ctx, userSessionKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			pipe.Del(ctx, sessionKey(id))
+		}
+		pipe.Del(ctx, userSessionKey(userID))
+		return nil
+	})
+	return err
+}
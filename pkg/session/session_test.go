@@ -0,0 +1,311 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// testStore returns a Store on an in-memory Redis, which the test can fast
+// forward to expire keys.
+func testStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		rdb.Close()
+	})
+	return New(rdb), mr
+}
+
+func TestCreateAndGet(t *testing.T) {
+	s, mr := testStore(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	sess, err := s.Create(ctx, userID, "firefox", "203.0.113.7", time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := s.Get(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.ID != sess.ID || got.UserID != userID || got.UserAgent != "firefox" || got.IP != "203.0.113.7" ||
+		got.RefreshID != sess.RefreshID || got.ExpiresAt != sess.ExpiresAt || got.MFAVerifiedAt != 0 {
+		t.Fatalf("Get = %+v, want %+v", got, sess)
+	}
+
+	if _, err := s.Get(ctx, ""); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Get without id = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := s.Get(ctx, uuid.NewString()); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Get of an unknown session = %v, want ErrSessionRevoked", err)
+	}
+
+	mr.FastForward(time.Hour)
+	if _, err := s.Get(ctx, sess.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Get after the ttl = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	s, mr := testStore(t)
+	ctx := context.Background()
+
+	sess, err := s.Create(ctx, uuid.New(), "", "", time.Minute)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	first := sess.RefreshID
+
+	next, err := s.Rotate(ctx, sess, first, time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if next == first || sess.RefreshID != next {
+		t.Fatalf("Rotate = %q, want a new refresh id stored on the session", next)
+	}
+
+	got, err := s.Get(ctx, sess.ID)
+	if err != nil || got.RefreshID != next || got.ExpiresAt != sess.ExpiresAt {
+		t.Fatalf("Get after Rotate = %+v, %v, want refresh id %q", got, err, next)
+	}
+
+	// Rotating extends the session past its first ttl
+	mr.FastForward(30 * time.Minute)
+	if _, err := s.Get(ctx, sess.ID); err != nil {
+		t.Fatalf("Get after the first ttl: %v", err)
+	}
+}
+
+func TestRotateReuse(t *testing.T) {
+	s, _ := testStore(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	sess, err := s.Create(ctx, userID, "", "", time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	other, err := s.Create(ctx, userID, "", "", time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	first := sess.RefreshID
+
+	if _, err := s.Rotate(ctx, sess, first, time.Hour); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	// Replaying the rotated refresh token revokes the whole session
+	replayed := &Session{ID: sess.ID, UserID: userID}
+	if _, err := s.Rotate(ctx, replayed, first, time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Rotate with a used refresh id = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := s.Get(ctx, sess.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("Get after reuse = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := s.Rotate(ctx, sess, sess.RefreshID, time.Hour); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("Rotate of the revoked session = %v, want ErrSessionRevoked", err)
+	}
+
+	// Other sessions of the user are kept
+	sessions, err := s.List(ctx, userID)
+	if err != nil || len(sessions) != 1 || sessions[0].ID != other.ID {
+		t.Fatalf("List after reuse = %+v, %v, want only the other session", sessions, err)
+	}
+
+	if _, err := s.Rotate(ctx, other, "", time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Rotate without a refresh id = %v, want ErrRefreshTokenReused", err)
+	}
+}
+
+func TestReissue(t *testing.T) {
+	s, _ := testStore(t)
+	ctx := context.Background()
+
+	sess, err := s.Create(ctx, uuid.New(), "", "", time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	old := sess.RefreshID
+
+	next, err := s.Reissue(ctx, sess, time.Hour)
+	if err != nil {
+		t.Fatalf("Reissue: %v", err)
+	}
+	if next == old {
+		t.Fatalf("Reissue kept the refresh id")
+	}
+
+	// The refresh token held before reissuing counts as reused
+	stale := &Session{ID: sess.ID, UserID: sess.UserID}
+	if _, err := s.Rotate(ctx, stale, old, time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Rotate with the refresh id before Reissue = %v, want ErrRefreshTokenReused", err)
+	}
+
+	if _, err := s.Reissue(ctx, sess, time.Hour); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("Reissue of a revoked session = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	s, _ := testStore(t)
+	ctx := context.Background()
+
+	sess, err := s.Create(ctx, uuid.New(), "firefox", "203.0.113.7", time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Within the touch interval nothing is written
+	if err := s.Touch(ctx, sess, "chrome", "198.51.100.9"); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if got, _ := s.Get(ctx, sess.ID); got.UserAgent != "firefox" {
+		t.Fatalf("Touch within the interval changed the session to %+v", got)
+	}
+
+	sess.LastSeenAt -= touchInterval.Milliseconds()
+	if err := s.Touch(ctx, sess, "chrome", "198.51.100.9"); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	got, err := s.Get(ctx, sess.ID)
+	if err != nil || got.UserAgent != "chrome" || got.IP != "198.51.100.9" || got.LastSeenAt <= sess.LastSeenAt {
+		t.Fatalf("Get after Touch = %+v, %v", got, err)
+	}
+
+	// Touching a revoked session does not bring it back
+	if err := s.Revoke(ctx, sess.UserID, sess.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := s.Touch(ctx, sess, "chrome", "198.51.100.9"); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if _, err := s.Get(ctx, sess.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("Get after touching a revoked session = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	s, _ := testStore(t)
+	ctx := context.Background()
+
+	sess, err := s.Create(ctx, uuid.New(), "", "", time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := s.VerifyMFA(ctx, sess); err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if sess.MFAVerifiedAt == 0 {
+		t.Fatalf("VerifyMFA did not set MFAVerifiedAt")
+	}
+
+	// Rotating the refresh token keeps the second factor
+	if _, err := s.Reissue(ctx, sess, time.Hour); err != nil {
+		t.Fatalf("Reissue: %v", err)
+	}
+	got, err := s.Get(ctx, sess.ID)
+	if err != nil || got.MFAVerifiedAt != sess.MFAVerifiedAt {
+		t.Fatalf("Get after VerifyMFA = %+v, %v, want MFAVerifiedAt %d", got, err, sess.MFAVerifiedAt)
+	}
+
+	if err := s.Revoke(ctx, sess.UserID, sess.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := s.VerifyMFA(ctx, sess); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("VerifyMFA of a revoked session = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := s.Get(ctx, sess.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("VerifyMFA brought back the revoked session")
+	}
+}
+
+func TestListAndRevoke(t *testing.T) {
+	s, mr := testStore(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	older, err := s.Create(ctx, userID, "", "", time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	newer, err := s.Create(ctx, userID, "", "", 2*time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	mr.HSet(sessionKey(older.ID), "last_seen_at", "1")
+
+	sessions, err := s.List(ctx, userID)
+	if err != nil || len(sessions) != 2 || sessions[0].ID != newer.ID || sessions[1].ID != older.ID {
+		t.Fatalf("List = %+v, %v, want the most recently used first", sessions, err)
+	}
+
+	// Expired sessions are pruned from the user's set
+	mr.FastForward(90 * time.Minute)
+	sessions, err = s.List(ctx, userID)
+	if err != nil || len(sessions) != 1 || sessions[0].ID != newer.ID {
+		t.Fatalf("List after expiry = %+v, %v, want the newer session", sessions, err)
+	}
+	if ok, _ := mr.SIsMember(userSessionKey(userID), older.ID); ok {
+		t.Fatalf("List kept the expired session in the user's set")
+	}
+
+	// Sessions of other users cannot be revoked
+	if err := s.Revoke(ctx, uuid.New(), newer.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("Revoke by another user = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := s.Get(ctx, newer.ID); err != nil {
+		t.Fatalf("Get after another user's Revoke: %v", err)
+	}
+
+	if err := s.Revoke(ctx, userID, newer.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := s.Get(ctx, newer.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("Get after Revoke = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestRevokeAll(t *testing.T) {
+	s, mr := testStore(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	var ids []string
+	for range 3 {
+		sess, err := s.Create(ctx, userID, "", "", time.Hour)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		ids = append(ids, sess.ID)
+	}
+	kept, err := s.Create(ctx, uuid.New(), "", "", time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := s.RevokeAll(ctx, userID); err != nil {
+		t.Fatalf("RevokeAll: %v", err)
+	}
+	for _, id := range ids {
+		if _, err := s.Get(ctx, id); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("Get %s after RevokeAll = %v, want ErrSessionRevoked", id, err)
+		}
+	}
+	if mr.Exists(userSessionKey(userID)) {
+		t.Errorf("RevokeAll kept the user's session set")
+	}
+
+	if _, err := s.Get(ctx, kept.ID); err != nil {
+		t.Errorf("Get of another user's session: %v", err)
+	}
+}
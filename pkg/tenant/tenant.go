@@ -0,0 +1,78 @@
+package tenant
+
+import (
+	"context"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// userRoleAdmin mirrors middleware.UserRoleAdmin; platform admins are not
+// bound to a single school.
+const userRoleAdmin = "admin"
+
+// Scope resolves the school a repository call may touch. The school of the
+// authenticated user always wins: an empty requested ID is filled in and a
+// different one is rejected with commonError.ErrForbidden. Contexts without
+// claims (background jobs, invite flows) and platform admins keep the
+// requested ID.
+func Scope(ctx context.Context, requested uuid.UUID) (uuid.UUID, error) {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil || claim.User.UserRole == userRoleAdmin {
+		return requested, nil
+	}
+
+	if requested != uuid.Nil && requested != claim.User.SchoolID {
+		return uuid.Nil, commonError.ErrForbidden
+	}
+
+	return claim.User.SchoolID, nil
+}
+
+// ScopeString is Scope for school IDs that arrive as query strings.
+func ScopeString(ctx context.Context, requested string) (string, error) {
+	id := uuid.Nil
+	if requested != "" {
+		parsed, err := uuid.Parse(requested)
+		if err != nil {
+			return "", commonError.ErrForbidden
+		}
+		id = parsed
+	}
+
+	scoped, err := Scope(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if scoped == uuid.Nil {
+		return "", nil
+	}
+	return scoped.String(), nil
+}
+
+// Filter returns the school ID lookups by primary key must be restricted to.
+// It is invalid when the caller is not bound to a school, so queries are
+// written as `($n::uuid IS NULL OR school_id = $n)`.
+func Filter(ctx context.Context) uuid.NullUUID {
+	claim, err := jwt.ExtractContext(ctx)
+	if err != nil || claim.User.UserRole == userRoleAdmin {
+		return uuid.NullUUID{}
+	}
+
+	return uuid.NullUUID{UUID: claim.User.SchoolID, Valid: true}
+}
+
+// Apply sets app.school_id for the rest of the transaction so the row level
+// security policies from migration 24 see the tenant as well.
+func Apply(ctx context.Context, tx *sqlx.Tx) error {
+	school := Filter(ctx)
+	if !school.Valid {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, "SELECT set_config('app.school_id', $1, true)", school.UUID.String())
+	return err
+}
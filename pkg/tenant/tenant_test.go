@@ -0,0 +1,82 @@
+package tenant
+
+import (
+	"context"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/jwt"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func withUser(schoolID uuid.UUID, userRole string) context.Context {
+	claim := &jwt.Payload{User: jwt.User{ID: uuid.New(), SchoolID: schoolID, SchoolRole: "teacher", UserRole: userRole}}
+	return context.WithValue(context.Background(), jwt.ContextKey, claim)
+}
+
+func TestScope(t *testing.T) {
+	school, foreign := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		requested uuid.UUID
+		want      uuid.UUID
+		err       error
+	}{
+		{"own school", withUser(school, "user"), school, school, nil},
+		{"defaults to own school", withUser(school, "user"), uuid.Nil, school, nil},
+		{"foreign school", withUser(school, "user"), foreign, uuid.Nil, commonError.ErrForbidden},
+		{"admin keeps the requested school", withUser(school, userRoleAdmin), foreign, foreign, nil},
+		{"admin without a requested school", withUser(school, userRoleAdmin), uuid.Nil, uuid.Nil, nil},
+		{"no claim keeps the requested school", context.Background(), foreign, foreign, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Scope(tt.ctx, tt.requested)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("%s: Scope = %s, %v, want %s, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestScopeString(t *testing.T) {
+	school, foreign := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		requested string
+		want      string
+		err       error
+	}{
+		{"own school", withUser(school, "user"), school.String(), school.String(), nil},
+		{"defaults to own school", withUser(school, "user"), "", school.String(), nil},
+		{"foreign school", withUser(school, "user"), foreign.String(), "", commonError.ErrForbidden},
+		{"not a uuid", withUser(school, "user"), "school", "", commonError.ErrForbidden},
+		{"admin without a requested school", withUser(school, userRoleAdmin), "", "", nil},
+		{"admin keeps the requested school", withUser(school, userRoleAdmin), foreign.String(), foreign.String(), nil},
+	}
+
+	for _, tt := range tests {
+		got, err := ScopeString(tt.ctx, tt.requested)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("%s: ScopeString = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	school := uuid.New()
+
+	if got := Filter(withUser(school, "user")); !got.Valid || got.UUID != school {
+		t.Errorf("Filter for a user = %+v, want their school", got)
+	}
+	if got := Filter(withUser(school, userRoleAdmin)); got.Valid {
+		t.Errorf("Filter for an admin = %+v, want invalid", got)
+	}
+	if got := Filter(context.Background()); got.Valid {
+		t.Errorf("Filter without a claim = %+v, want invalid", got)
+	}
+}
@@ -0,0 +1,104 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 appendix B, "12345678901234567890".
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// The RFC lists 8 digit codes; 6 digit codes are their last 6 digits.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+		if got != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestCodeSecretFormat(t *testing.T) {
+	want, _ := Code(rfcSecret, 1)
+	got, err := Code(strings.ToLower(rfcSecret)+"==", 1)
+	if err != nil || got != want {
+		t.Fatalf("Code of a lowercase padded secret = %s, %v, want %s", got, err, want)
+	}
+
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Fatalf("Code of an invalid secret succeeded")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	tests := []struct {
+		step int64
+		ok   bool
+	}{
+		{current - 2, false},
+		{current - 1, true},
+		{current, true},
+		{current + 1, true},
+		{current + 2, false},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, tt.step)
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+
+		step, ok := Validate(rfcSecret, code, now)
+		if ok != tt.ok {
+			t.Errorf("Validate of step %+d = %v, want %v", tt.step-current, ok, tt.ok)
+		}
+		if ok && step != tt.step {
+			t.Errorf("Validate of step %+d matched step %+d", tt.step-current, step-current)
+		}
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	if _, ok := Validate(rfcSecret, "050 471", now); !ok {
+		t.Errorf("Validate rejected a code with a space")
+	}
+	for _, code := range []string{"", "05047", "0504711", "14050471"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Validate(%q) accepted a code of the wrong length", code)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("secret %q has %d chars, want 32", secret, len(secret))
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Fatalf("Code of a generated secret: %v", err)
+	}
+}
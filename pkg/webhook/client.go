@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var errPrivateAddress = errors.New("webhook url resolves to a private address")
+
+// newClient returns the client deliveries are sent with. Unless private
+// networks are allowed it refuses to connect to loopback, private and link
+// local addresses, checked after DNS resolution so a public name can not
+// point a school's webhook at our own infrastructure. Redirects are not
+// followed.
+func newClient(c Config) *http.Client {
+	dialer := &net.Dialer{Timeout: c.Timeout}
+	if !c.AllowPrivateNetworks {
+		dialer.Control = func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !publicIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	transport.ResponseHeaderTimeout = c.Timeout
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   c.Timeout + 5*time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast())
+}
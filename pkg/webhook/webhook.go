@@ -0,0 +1,403 @@
+// Package webhook delivers domain events to the systems of a school. The
+// worker subscribes Dispatch to the event bus, which queues one delivery job
+// per matching webhook; Handle then posts the event, signed with the
+// webhook's secret, and records every attempt.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	commonError "enuma-elish/pkg/error"
+	"enuma-elish/pkg/event"
+	"enuma-elish/pkg/queue"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// JobDeliver is the queue job that posts one event to one webhook.
+const JobDeliver = "webhook.deliver"
+
+// EventTest is sent by Test, it can not be subscribed to.
+const EventTest = "webhook.test"
+
+// Headers of every delivery. The signature is "t=<timestamp>,v1=<hex>", see
+// Sign.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-Id"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+type Config struct {
+	Timeout time.Duration
+	// DisableAfter consecutive failed deliveries disable the webhook.
+	DisableAfter int
+	// AllowPrivateNetworks lets webhooks reach loopback and private
+	// addresses, for development only.
+	AllowPrivateNetworks bool
+}
+
+func (c Config) withDefaults() Config {
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.DisableAfter <= 0 {
+		c.DisableAfter = 15
+	}
+	return c
+}
+
+type Webhook struct {
+	ID           uuid.UUID      `db:"id"`
+	SchoolID     uuid.UUID      `db:"school_id"`
+	URL          string         `db:"url"`
+	Events       pq.StringArray `db:"events"`
+	Secret       string         `db:"secret"`
+	Enabled      bool           `db:"enabled"`
+	FailureCount int            `db:"failure_count"`
+	DisabledAt   int64          `db:"disabled_at"`
+	CreatedBy    uuid.UUID      `db:"created_by"`
+	CreatedAt    int64          `db:"created_at"`
+	UpdatedAt    int64          `db:"updated_at"`
+}
+
+const webhookColumns = "id, school_id, url, events, secret, enabled, failure_count, disabled_at, created_by, created_at, updated_at"
+
+// Delivery is one attempt to deliver an event.
+type Delivery struct {
+	ID         uuid.UUID `db:"id"`
+	WebhookID  uuid.UUID `db:"webhook_id"`
+	EventID    string    `db:"event_id"`
+	Event      string    `db:"event"`
+	Attempt    int       `db:"attempt"`
+	StatusCode int       `db:"status_code"`
+	Error      string    `db:"error"`
+	DurationMs int64     `db:"duration_ms"`
+	Success    bool      `db:"success"`
+	CreatedAt  int64     `db:"created_at"`
+}
+
+// job is the payload of JobDeliver.
+type job struct {
+	WebhookID uuid.UUID       `json:"webhook_id"`
+	EventID   string          `json:"event_id"`
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt int64           `json:"created_at"`
+}
+
+// body is what receivers get.
+type body struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt int64           `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type Store struct {
+	db     *sqlx.DB
+	queue  *queue.Queue
+	client *http.Client
+	config Config
+}
+
+func New(db *sqlx.DB, q *queue.Queue, c Config) *Store {
+	c = c.withDefaults()
+	return &Store{db: db, queue: q, client: newClient(c), config: c}
+}
+
+// ValidURL reports whether raw can be used as a webhook url.
+func ValidURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ValidEvent reports whether webhooks can subscribe to name.
+func ValidEvent(name string) bool {
+	return slices.Contains(event.Names, name)
+}
+
+// Sign returns the signature header value for body sent at timestamp
+// (unix seconds): the hex HMAC-SHA256 of "<timestamp>.<body>".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (s *Store) Create(ctx context.Context, schoolID, createdBy uuid.UUID, rawURL string, events []string) (*Webhook, error) {
+	if !ValidURL(rawURL) {
+		return nil, commonError.ErrInvalidWebhookURL
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	hook := &Webhook{}
+	err = s.db.GetContext(ctx, hook, `INSERT INTO webhook (school_id, url, events, secret, created_by)
+		VALUES ($1, $2, $3, $4, $5) RETURNING `+webhookColumns,
+		schoolID, rawURL, pq.StringArray(events), secret, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// List returns the webhooks of the school, newest first.
+func (s *Store) List(ctx context.Context, schoolID uuid.UUID) ([]Webhook, error) {
+	hooks := []Webhook{}
+	err := s.db.SelectContext(ctx, &hooks, "SELECT "+webhookColumns+" FROM webhook WHERE school_id = $1 ORDER BY created_at DESC", schoolID)
+	if err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// Get returns sql.ErrNoRows when the school has no such webhook.
+func (s *Store) Get(ctx context.Context, schoolID, id uuid.UUID) (*Webhook, error) {
+	hook := &Webhook{}
+	err := s.db.GetContext(ctx, hook, "SELECT "+webhookColumns+" FROM webhook WHERE id = $1 AND school_id = $2", id, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// Update replaces url, events and enabled. Enabling a webhook that was
+// disabled after failures starts counting failures from zero again.
+func (s *Store) Update(ctx context.Context, schoolID, id uuid.UUID, rawURL string, events []string, enabled bool) (*Webhook, error) {
+	if !ValidURL(rawURL) {
+		return nil, commonError.ErrInvalidWebhookURL
+	}
+
+	hook := &Webhook{}
+	err := s.db.GetContext(ctx, hook, `UPDATE webhook SET url = $3, events = $4, enabled = $5,
+		failure_count = CASE WHEN $5 AND NOT enabled THEN 0 ELSE failure_count END,
+		disabled_at = CASE WHEN $5 THEN 0 WHEN enabled THEN $6 ELSE disabled_at END,
+		updated_at = $6
+		WHERE id = $1 AND school_id = $2 RETURNING `+webhookColumns,
+		id, schoolID, rawURL, pq.StringArray(events), enabled, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// Delete reports false when the school has no such webhook.
+func (s *Store) Delete(ctx context.Context, schoolID, id uuid.UUID) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM webhook WHERE id = $1 AND school_id = $2", id, schoolID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Deliveries returns the latest delivery attempts of a webhook.
+func (s *Store) Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	err := s.db.SelectContext(ctx, &deliveries, `SELECT id, webhook_id, event_id, event, attempt, status_code, error, duration_ms, success, created_at
+		FROM webhook_delivery WHERE webhook_id = $1 ORDER BY created_at DESC LIMIT $2`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Test queues a webhook.test event for the webhook, even when it is
+// disabled, and returns the event id.
+func (s *Store) Test(ctx context.Context, hook *Webhook) (string, error) {
+	data, err := json.Marshal(map[string]any{"webhook_id": hook.ID, "school_id": hook.SchoolID})
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	_, err = s.queue.Enqueue(ctx, JobDeliver, job{
+		WebhookID: hook.ID,
+		EventID:   id,
+		Event:     EventTest,
+		Data:      data,
+		CreatedAt: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Dispatch is the bus subscriber: it queues a delivery of msg for every
+// enabled webhook of its school that subscribed to it. When enqueueing
+// fails the relay dispatches msg again, so receivers should ignore
+// deliveries whose X-Webhook-Id they have seen.
+func (s *Store) Dispatch(ctx context.Context, msg event.Message) error {
+	if msg.SchoolID == uuid.Nil {
+		return nil
+	}
+
+	var ids []uuid.UUID
+	err := s.db.SelectContext(ctx, &ids, "SELECT id FROM webhook WHERE school_id = $1 AND enabled AND $2 = ANY(events)", msg.SchoolID, msg.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		_, err := s.queue.Enqueue(ctx, JobDeliver, job{
+			WebhookID: id,
+			EventID:   msg.ID,
+			Event:     msg.Name,
+			Data:      msg.Payload,
+			CreatedAt: msg.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handle is the queue handler for JobDeliver. A failed delivery returns an
+// error so the queue retries it with backoff.
+func (s *Store) Handle(ctx context.Context, payload json.RawMessage) error {
+	j := job{}
+	if err := json.Unmarshal(payload, &j); err != nil {
+		return err
+	}
+
+	hook := &Webhook{}
+	err := s.db.GetContext(ctx, hook, "SELECT "+webhookColumns+" FROM webhook WHERE id = $1", j.WebhookID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // deleted since
+		}
+		return err
+	}
+	if !hook.Enabled && j.Event != EventTest {
+		return nil
+	}
+
+	var attempt int
+	err = s.db.GetContext(ctx, &attempt, "SELECT COUNT(*) + 1 FROM webhook_delivery WHERE webhook_id = $1 AND event_id = $2", hook.ID, j.EventID)
+	if err != nil {
+		return err
+	}
+
+	delivery := s.post(ctx, hook, j)
+	delivery.Attempt = attempt
+
+	_, err = s.db.NamedExecContext(ctx, `INSERT INTO webhook_delivery (id, webhook_id, event_id, event, attempt, status_code, error, duration_ms, success, created_at)
+		VALUES (:id, :webhook_id, :event_id, :event, :attempt, :status_code, :error, :duration_ms, :success, :created_at)`, delivery)
+	if err != nil {
+		return err
+	}
+
+	if delivery.Success {
+		if hook.FailureCount > 0 {
+			_, err = s.db.ExecContext(ctx, "UPDATE webhook SET failure_count = 0 WHERE id = $1", hook.ID)
+		}
+		return err
+	}
+
+	if j.Event != EventTest {
+		if err := s.recordFailure(ctx, hook); err != nil {
+			return err
+		}
+	}
+	return errors.New(delivery.Error)
+}
+
+// post sends the event and describes the outcome as a delivery.
+func (s *Store) post(ctx context.Context, hook *Webhook, j job) Delivery {
+	start := time.Now()
+	delivery := Delivery{
+		ID:        uuid.New(),
+		WebhookID: hook.ID,
+		EventID:   j.EventID,
+		Event:     j.Event,
+		CreatedAt: start.UnixMilli(),
+	}
+
+	raw, err := json.Marshal(body{ID: j.EventID, Event: j.Event, CreatedAt: j.CreatedAt, Data: j.Data})
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(raw))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "enuma-elish-webhook")
+	req.Header.Set(HeaderEvent, j.Event)
+	req.Header.Set(HeaderID, j.EventID)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, start.Unix(), raw))
+
+	res, err := s.client.Do(req)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	delivery.StatusCode = res.StatusCode
+	delivery.Success = res.StatusCode >= 200 && res.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("unexpected status %d", res.StatusCode)
+	}
+	return delivery
+}
+
+// recordFailure counts a failed delivery and disables the webhook once
+// DisableAfter deliveries in a row failed.
+func (s *Store) recordFailure(ctx context.Context, hook *Webhook) error {
+	var disabled bool
+	err := s.db.GetContext(ctx, &disabled, `UPDATE webhook SET failure_count = failure_count + 1,
+		enabled = enabled AND failure_count + 1 < $2,
+		disabled_at = CASE WHEN enabled AND failure_count + 1 >= $2 THEN $3 ELSE disabled_at END
+		WHERE id = $1 RETURNING NOT enabled`, hook.ID, s.config.DisableAfter, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	if disabled && hook.Enabled {
+		log.Warn().Str("webhook", hook.ID.String()).Str("school_id", hook.SchoolID.String()).Msg("webhook disabled after repeated failures")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1","event":"exam.graded"}`)
+	got := Sign("whsec_test", 1700000000, body)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+	if got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+
+	if Sign("whsec_other", 1700000000, body) == got {
+		t.Error("signature does not depend on the secret")
+	}
+	if Sign("whsec_test", 1700000001, body) == got {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"https://example.com/hook":   true,
+		"http://example.com:8080/in": true,
+		"ftp://example.com/hook":     false,
+		"/hook":                      false,
+		"https://":                   false,
+		"not a url":                  false,
+	} {
+		if got := ValidURL(raw); got != want {
+			t.Errorf("ValidURL(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestClientPrivateNetworks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	post := func(c Config) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newClient(c.withDefaults()).Do(req)
+	}
+
+	_, err := post(Config{})
+	if !errors.Is(err, errPrivateAddress) {
+		t.Errorf("request to %s: got %v, want the private address error", server.URL, err)
+	}
+
+	res, err := post(Config{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("request with private networks allowed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusNoContent)
+	}
+}
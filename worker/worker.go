@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"context"
+	"enuma-elish/config"
+	"enuma-elish/infra"
+	"enuma-elish/pkg/event"
+	"enuma-elish/pkg/mailer"
+	"enuma-elish/pkg/webhook"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// Worker runs the background jobs the API enqueues and publishes the
+// events of the outbox.
+type Worker struct {
+	config *config.Config
+	infra  *infra.Infra
+	relay  *event.Relay
+}
+
+func New(c *config.Config, i *infra.Infra) *Worker {
+	i.Queue.Handle(mailer.JobSend, i.Mail.Handle)
+	i.Queue.Handle(webhook.JobDeliver, i.Webhooks.Handle)
+	i.Events.SubscribeAll(i.Webhooks.Dispatch)
+
+	return &Worker{config: c, infra: i, relay: event.NewRelay(i.Postgres, i.Events)}
+}
+
+// Run works on jobs and relays events until SIGINT or SIGTERM, then lets
+// the running jobs finish.
+func (w *Worker) Run() {
+	if w.config.Telemetry.Enable {
+		cleanup := infra.InitTracer(&w.config.Telemetry)
+		defer func() {
+			log.Info().Msg("clean up")
+			err := cleanup(context.Background())
+			if err != nil {
+				log.Error().Err(err).Msg("failed to clean up tracer")
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	relayDone := make(chan struct{})
+	go func() {
+		w.relay.Run(ctx)
+		close(relayDone)
+	}()
+
+	log.Info().Int("concurrency", w.infra.Queue.Config().Concurrency).Msg("worker started")
+	w.infra.Queue.Run(ctx)
+	<-relayDone
+	log.Info().Msg("worker stopped")
+}
+
+func (w *Worker) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "worker",
+		Short: "background job stuff",
+	}
+
+	run := &cobra.Command{
+		Use:   "run",
+		Short: "run worker",
+		Run: func(cmd *cobra.Command, args []string) {
+			w.Run()
+		},
+	}
+
+	var limit int64
+	dead := &cobra.Command{
+		Use:   "dead",
+		Short: "list jobs in the dead letter list",
+		Run: func(cmd *cobra.Command, args []string) {
+			jobs, err := w.infra.Queue.Dead(context.Background(), limit)
+			if err != nil {
+				log.Error().Err(err).Msg("list dead jobs failed")
+				return
+			}
+
+			for _, job := range jobs {
+				fmt.Printf("%s\t%s\t%d\t%s\t%s\n", job.ID, job.Type, job.Attempts, time.UnixMilli(job.FailedAt).Format(time.RFC3339), job.LastError)
+			}
+		},
+	}
+	dead.Flags().Int64Var(&limit, "limit", 50, "number of jobs to list")
+
+	retry := &cobra.Command{
+		Use:   "retry [job id]",
+		Short: "move a dead job back to the queue",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ok, err := w.infra.Queue.Retry(context.Background(), args[0])
+			if err != nil {
+				log.Error().Err(err).Msg("retry job failed")
+				return
+			}
+			if !ok {
+				log.Info().Str("job", args[0]).Msg("job is not in the dead letter list")
+				return
+			}
+			log.Info().Str("job", args[0]).Msg("job queued again")
+		},
+	}
+
+	cmd.AddCommand(run, dead, retry)
+	return cmd
+}